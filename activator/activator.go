@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,7 +20,55 @@ import (
 	"github.com/containernetworking/plugins/pkg/ns"
 )
 
-const ()
+// Activator restores a checkpointed container on incoming connections to any
+// of its ports.
+type Activator interface {
+	// Start starts the activator for the supplied ports. onAccept is called
+	// on every incoming connection while the activator is enabled.
+	Start(ctx context.Context, ports []uint16, onAccept OnAccept) error
+	// Started returns true if the activator has been started.
+	Started() bool
+	// Reset enables the activator again after it has been disabled.
+	Reset() error
+	// DisableRedirects disables the activator so connections go directly to
+	// the container again.
+	DisableRedirects() error
+	// Stop stops the activator and cleans up all of its resources.
+	Stop(ctx context.Context)
+}
+
+// Backend is the mechanism used to activate a container.
+type Backend string
+
+const (
+	// BackendRedirect redirects connections to a userspace proxy using the
+	// eBPF redirector.
+	BackendRedirect Backend = "redirect"
+	// BackendNFQueue queues connections to userspace using iptables NFQUEUE.
+	BackendNFQueue Backend = "nfqueue"
+)
+
+var _ Activator = &Server{}
+
+// Activity is the activity an activator has observed on the connections it
+// proxied.
+type Activity struct {
+	// Connections is the amount of currently open connections.
+	Connections int64
+	// Bytes is the total amount of bytes that have been proxied.
+	Bytes uint64
+	// LastActivity is the time of the last accepted connection or proxied
+	// data.
+	LastActivity time.Time
+}
+
+// Meter is implemented by activators that meter the activity of the
+// connections they proxy.
+type Meter interface {
+	Activity() Activity
+}
+
+var _ Meter = &Server{}
 
 type Server struct {
 	listeners      []net.Listener
@@ -34,10 +83,26 @@ type Server struct {
 	maps           bpfMaps
 	sandboxPid     int
 	started        bool
+	metering       bool
+	holdingTimeout map[uint16]time.Duration
+	originalDst    map[uint16]bool
+	listenerState  ListenerStateFunc
+	onConnection   ConnectionFunc
+	connections    atomic.Int64
+	bytes          atomic.Uint64
+	lastActivity   atomic.Int64
 }
 
 type OnAccept func() error
 
+// ListenerStateFunc is called whenever the listener of a port has been bound
+// or is not accepting connections anymore.
+type ListenerStateFunc func(port uint16, listening bool)
+
+// ConnectionFunc is called for every incoming connection to a port that is
+// handled by the activator.
+type ConnectionFunc func(port uint16)
+
 func NewServer(ctx context.Context, nn ns.NetNS) (*Server, error) {
 	s := &Server{
 		quit:           make(chan interface{}),
@@ -45,6 +110,8 @@ func NewServer(ctx context.Context, nn ns.NetNS) (*Server, error) {
 		proxyTimeout:   time.Second * 5,
 		ns:             nn,
 		sandboxPid:     parsePidFromNetNS(nn),
+		holdingTimeout: map[uint16]time.Duration{},
+		originalDst:    map[uint16]bool{},
 	}
 
 	return s, os.MkdirAll(PinPath(s.sandboxPid), os.ModePerm)
@@ -66,6 +133,11 @@ func parsePidFromNetNS(nn ns.NetNS) int {
 
 var ErrMapNotFound = errors.New("bpf map could not be found")
 
+// Start starts listening for connections to the supplied ports. The server
+// never binds the ports itself but listens on a random free port for each of
+// them and redirects the traffic to it using the eBPF redirector. This means
+// privileged ports (<1024) can be activated without any additional
+// capabilities.
 func (s *Server) Start(ctx context.Context, ports []uint16, onAccept OnAccept) error {
 	s.ports = ports
 
@@ -74,6 +146,10 @@ func (s *Server) Start(ctx context.Context, ports []uint16, onAccept OnAccept) e
 	}
 
 	for _, port := range s.ports {
+		if port == 0 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+
 		proxyPort, err := s.listen(ctx, port, onAccept)
 		if err != nil {
 			return err
@@ -85,6 +161,7 @@ func (s *Server) Start(ctx context.Context, ports []uint16, onAccept OnAccept) e
 		}
 	}
 
+	s.recordActivity()
 	s.started = true
 	return nil
 }
@@ -93,6 +170,63 @@ func (s *Server) Started() bool {
 	return s.started
 }
 
+// SetHTTPHoldingTimeout makes the server respond to connections on port
+// with HTTP 503 and a Retry-After header if the process has not been
+// restored within timeout, instead of holding the connection until it is.
+// The restore continues in the background, so the client can retry. It
+// needs to be called before the server is started.
+func (s *Server) SetHTTPHoldingTimeout(port uint16, timeout time.Duration) {
+	s.holdingTimeout[port] = timeout
+}
+
+// SetOriginalDestination makes the server connect to the address the client
+// connected to when forwarding connections on port, instead of localhost.
+// This way, the local address of the connection accepted by the process
+// (e.g. as returned by getsockname or SO_ORIGINAL_DST) is the original
+// destination of the client. It needs to be called before the server is
+// started.
+func (s *Server) SetOriginalDestination(port uint16) {
+	s.originalDst[port] = true
+}
+
+// SetListenerStateFunc sets a func that is called whenever the state of the
+// listener of a port changes. It needs to be called before the server is
+// started.
+func (s *Server) SetListenerStateFunc(f ListenerStateFunc) {
+	s.listenerState = f
+}
+
+// SetConnectionFunc sets a func that is called for every incoming
+// connection. It needs to be called before the server is started.
+func (s *Server) SetConnectionFunc(f ConnectionFunc) {
+	s.onConnection = f
+}
+
+// EnableMetering configures the server to stay in the data path of running
+// processes. Proxied connections are not subject to the proxy timeout
+// anymore, as they can be long-lived.
+func (s *Server) EnableMetering() {
+	s.metering = true
+}
+
+// Activity returns the activity of the connections proxied by the server.
+// If the server has been started but did not observe any activity yet, the
+// start time is reported as the last activity.
+func (s *Server) Activity() Activity {
+	activity := Activity{
+		Connections: s.connections.Load(),
+		Bytes:       s.bytes.Load(),
+	}
+	if last := s.lastActivity.Load(); last != 0 {
+		activity.LastActivity = time.Unix(0, last)
+	}
+	return activity
+}
+
+func (s *Server) recordActivity() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
 func (s *Server) Reset() error {
 	for _, port := range s.ports {
 		if err := s.enableRedirect(port); err != nil {
@@ -134,6 +268,7 @@ func (s *Server) listen(ctx context.Context, port uint16, onAccept OnAccept) (in
 
 	s.onAccept = onAccept
 
+	s.setListenerState(port, true)
 	s.wg.Add(1)
 	go s.serve(ctx, listener, port)
 
@@ -164,8 +299,16 @@ func (s *Server) Stop(ctx context.Context) {
 	log.G(ctx).Debugf("activator stopped")
 }
 
+func (s *Server) setListenerState(port uint16, listening bool) {
+	if s.listenerState != nil {
+		s.listenerState(port, listening)
+	}
+}
+
 func (s *Server) serve(ctx context.Context, listener net.Listener, port uint16) {
 	defer s.wg.Done()
+	// once we stop accepting, connections to the port end up nowhere.
+	defer s.setListenerState(port, false)
 	wg := sync.WaitGroup{}
 
 	for {
@@ -197,6 +340,12 @@ func (s *Server) serve(ctx context.Context, listener net.Listener, port uint16)
 
 func (s *Server) handleConection(ctx context.Context, conn net.Conn, port uint16) {
 	defer conn.Close()
+	s.connections.Add(1)
+	defer s.connections.Add(-1)
+	s.recordActivity()
+	if s.onConnection != nil {
+		s.onConnection(port)
+	}
 
 	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
 	if !ok {
@@ -210,12 +359,19 @@ func (s *Server) handleConection(ctx context.Context, conn net.Conn, port uint16
 		return
 	}
 
-	if err := s.onAccept(); err != nil {
-		log.G(ctx).Errorf("accept function: %s", err)
+	if err := s.accept(ctx, conn, port); err != nil {
+		if errors.Is(err, errHoldingTimeout) {
+			log.G(ctx).Infof("%s, responded with service unavailable", err)
+		} else {
+			log.G(ctx).Errorf("accept function: %s", err)
+		}
+		if err := s.removeConnection(uint16(tcpAddr.Port)); err != nil {
+			log.G(ctx).Warnf("error removing connection: %s", err)
+		}
 		return
 	}
 
-	backendConn, err := s.connect(ctx, port)
+	backendConn, err := s.connect(ctx, s.backendHost(conn, port), port)
 	if err != nil {
 		log.G(ctx).Errorf("error establishing connection: %s", err)
 		return
@@ -224,10 +380,17 @@ func (s *Server) handleConection(ctx context.Context, conn net.Conn, port uint16
 
 	log.G(ctx).Println("dial succeeded", backendConn.RemoteAddr().String())
 
-	requestContext, cancel := context.WithTimeout(ctx, s.proxyTimeout)
+	var requestContext context.Context
+	var cancel context.CancelFunc
+	if s.metering {
+		// metered connections are proxied for as long as they are open.
+		requestContext, cancel = context.WithCancel(ctx)
+	} else {
+		requestContext, cancel = context.WithTimeout(ctx, s.proxyTimeout)
+	}
 	s.proxyCancel = cancel
 	defer cancel()
-	if err := proxy(requestContext, conn, backendConn); err != nil {
+	if err := proxy(requestContext, &meteredConn{Conn: conn, s: s}, backendConn); err != nil {
 		log.G(ctx).Errorf("error proxying request: %s", err)
 	}
 
@@ -238,7 +401,78 @@ func (s *Server) handleConection(ctx context.Context, conn net.Conn, port uint16
 	log.G(ctx).Println("connection closed", conn.RemoteAddr().String())
 }
 
-func (s *Server) connect(ctx context.Context, port uint16) (net.Conn, error) {
+const (
+	httpUnavailableResponse = "HTTP/1.1 503 Service Unavailable\r\n" +
+		"Retry-After: 1\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"
+	// httpDrainTimeout is how long the request of a client is read after
+	// responding, so closing the connection does not reset it before the
+	// client has read the response.
+	httpDrainTimeout = time.Second
+)
+
+var errHoldingTimeout = errors.New("restore exceeded the HTTP holding timeout")
+
+// accept calls onAccept and waits for it to return. If an HTTP holding
+// timeout is configured for the port and it's exceeded, the client gets an
+// HTTP 503 response while onAccept continues in the background.
+func (s *Server) accept(ctx context.Context, conn net.Conn, port uint16) error {
+	timeout, ok := s.holdingTimeout[port]
+	if !ok {
+		return s.onAccept()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.onAccept()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+	}
+
+	if err := respondUnavailable(conn); err != nil {
+		return fmt.Errorf("responding to client: %w", err)
+	}
+	return fmt.Errorf("%w of %s", errHoldingTimeout, timeout)
+}
+
+func respondUnavailable(conn net.Conn) error {
+	if _, err := io.WriteString(conn, httpUnavailableResponse); err != nil {
+		return err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.CloseWrite(); err != nil {
+			return err
+		}
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(httpDrainTimeout)); err != nil {
+		return err
+	}
+	// the read ends with the deadline or once the client closes the
+	// connection, both of which are expected.
+	_, _ = io.Copy(io.Discard, conn)
+	return nil
+}
+
+// backendHost returns the host the process is connected to for conn.
+func (s *Server) backendHost(conn net.Conn, port uint16) string {
+	if !s.originalDst[port] {
+		return "localhost"
+	}
+
+	// the redirector only changes the port, so the local address of the
+	// client connection is the address the client connected to.
+	localAddr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return "localhost"
+	}
+	return localAddr.IP.String()
+}
+
+func (s *Server) connect(ctx context.Context, host string, port uint16) (net.Conn, error) {
 	var backendConn net.Conn
 
 	ticker := time.NewTicker(time.Millisecond)
@@ -277,7 +511,7 @@ func (s *Server) connect(ctx context.Context, port uint16) (net.Conn, error) {
 					LocalAddr: addr,
 					Timeout:   s.connectTimeout,
 				}
-				backendConn, err = d.Dial("tcp4", fmt.Sprintf("localhost:%d", port))
+				backendConn, err = d.Dial("tcp4", net.JoinHostPort(host, strconv.Itoa(int(port))))
 				return err
 			}); err != nil {
 				var serr syscall.Errno
@@ -406,6 +640,33 @@ func proxy(ctx context.Context, conn1, conn2 net.Conn) error {
 	}
 }
 
+// meteredConn records the data written to and read from the connection as
+// activity of the server.
+type meteredConn struct {
+	net.Conn
+	s *Server
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.record(n)
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.record(n)
+	return n, err
+}
+
+func (c *meteredConn) record(n int) {
+	if n <= 0 {
+		return
+	}
+	c.s.bytes.Add(uint64(n))
+	c.s.recordActivity()
+}
+
 func copy(done chan struct{}, errors chan error, dst io.Writer, src io.Reader) {
 	_, err := io.Copy(dst, src)
 	done <- struct{}{}
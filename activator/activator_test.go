@@ -9,6 +9,8 @@ import (
 	"net/http/httptest"
 	"os"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -20,14 +22,34 @@ import (
 func TestActivator(t *testing.T) {
 	require.NoError(t, MountBPFFS(BPFFSPath))
 
+	tests := map[string]struct {
+		port func(t *testing.T) int
+	}{
+		"unprivileged port": {
+			port: func(t *testing.T) int {
+				port, err := freePort()
+				require.NoError(t, err)
+				return port
+			},
+		},
+		"privileged port": {
+			port: freePrivilegedPort,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			testActivator(t, tc.port(t))
+		})
+	}
+}
+
+func testActivator(t *testing.T, port int) {
 	nn, err := ns.GetCurrentNS()
 	require.NoError(t, err)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	port, err := freePort()
-	require.NoError(t, err)
-
 	s, err := NewServer(ctx, nn)
 	require.NoError(t, err)
 
@@ -35,6 +57,12 @@ func TestActivator(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, bpf.AttachRedirector("lo"))
 
+	connections := atomic.Int64{}
+	s.SetConnectionFunc(func(p uint16) {
+		assert.Equal(t, uint16(port), p)
+		connections.Add(1)
+	})
+
 	response := "ok"
 	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, response)
@@ -92,4 +120,307 @@ func TestActivator(t *testing.T) {
 		}
 	}
 	wg.Wait()
+	assert.GreaterOrEqual(t, connections.Load(), int64(1), "connections to the activator should be counted")
+}
+
+// freePrivilegedPort returns a free port below 1024. The activator itself never
+// binds the port of the container, so this should work without any special
+// capabilities. The test process still needs to be able to bind it to
+// simulate the restored process.
+func freePrivilegedPort(t *testing.T) int {
+	for port := 80; port < 1024; port++ {
+		l, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+		if err != nil {
+			continue
+		}
+		require.NoError(t, l.Close())
+		return port
+	}
+	t.Skip("no free privileged port available")
+	return 0
+}
+
+// TestActivatorHandoff ensures no connection is refused while a running
+// process is handed off to the activator. The redirects are enabled before
+// the listener of the process is closed, the same way as it's done on scale
+// down.
+func TestActivatorHandoff(t *testing.T) {
+	require.NoError(t, MountBPFFS(BPFFSPath))
+
+	nn, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s, err := NewServer(ctx, nn)
+	require.NoError(t, err)
+
+	bpf, err := InitBPF(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, bpf.AttachRedirector("lo"))
+
+	port, err := freePort()
+	require.NoError(t, err)
+
+	response := "ok"
+	serve := func() *http.Server {
+		l, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+		require.NoError(t, err)
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, response)
+		})}
+		go srv.Serve(l)
+		return srv
+	}
+
+	srv := serve()
+	scaledDown := make(chan struct{})
+	once := sync.Once{}
+	require.NoError(t, s.Start(ctx, []uint16{uint16(port)}, func() error {
+		once.Do(func() {
+			// wait until the process has been stopped before restoring it.
+			<-scaledDown
+			srv = serve()
+			if err := s.DisableRedirects(); err != nil {
+				t.Errorf("could not disable redirects: %s", err)
+			}
+		})
+		return nil
+	}))
+	// the process is running, so traffic should go to it directly.
+	require.NoError(t, s.DisableRedirects())
+	t.Cleanup(func() {
+		s.Stop(ctx)
+		cancel()
+		srv.Close()
+	})
+
+	c := &http.Client{
+		Timeout:   time.Millisecond * 500,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+
+	done := make(chan struct{})
+	handedOff := atomic.Bool{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			interrupted := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				afterHandoff := handedOff.Load()
+				resp, err := c.Get(fmt.Sprintf("http://localhost:%d", port))
+				if err != nil {
+					assert.NotErrorIs(t, err, syscall.ECONNREFUSED)
+					// the packets of a request that is in flight while the
+					// redirect is set up are redirected to the activator
+					// as well, so it can be cut off. New connections are
+					// held by the activator until the process is back and
+					// need to get a response, so the failed request gets
+					// one once it's sent again.
+					interrupted++
+					assert.False(t, afterHandoff, "request started after the handoff failed: %s", err)
+					assert.LessOrEqual(t, interrupted, 1, "only the request in flight during the handoff can fail: %s", err)
+					continue
+				}
+				b, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				assert.NoError(t, err)
+				assert.Equal(t, response, string(b))
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	// hand off to the activator before stopping the process.
+	require.NoError(t, s.Reset())
+	handedOff.Store(true)
+	time.Sleep(time.Millisecond * 50)
+	require.NoError(t, srv.Close())
+	close(scaledDown)
+
+	time.Sleep(time.Millisecond * 100)
+	close(done)
+	wg.Wait()
+}
+
+// TestActivatorMetering ensures the activity of a running process is metered
+// if the redirects are kept enabled and that long-lived connections are not
+// cut by the proxy timeout.
+func TestActivatorMetering(t *testing.T) {
+	require.NoError(t, MountBPFFS(BPFFSPath))
+
+	nn, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s, err := NewServer(ctx, nn)
+	require.NoError(t, err)
+	s.EnableMetering()
+	s.proxyTimeout = time.Millisecond * 100
+
+	bpf, err := InitBPF(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, bpf.AttachRedirector("lo"))
+
+	port, err := freePort()
+	require.NoError(t, err)
+
+	response := "ok"
+	l, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, response)
+	})}
+	go srv.Serve(l)
+
+	// the process is already running, so there is nothing to restore.
+	require.NoError(t, s.Start(ctx, []uint16{uint16(port)}, func() error { return nil }))
+	t.Cleanup(func() {
+		s.Stop(ctx)
+		cancel()
+		srv.Close()
+	})
+
+	started := s.Activity()
+	assert.False(t, started.LastActivity.IsZero())
+	assert.Zero(t, started.Bytes)
+
+	c := &http.Client{Timeout: time.Second}
+	get := func() {
+		resp, err := c.Get(fmt.Sprintf("http://localhost:%d", port))
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, response, string(b))
+	}
+
+	get()
+	activity := s.Activity()
+	assert.Equal(t, int64(1), activity.Connections, "keep-alive connection should be open")
+	assert.NotZero(t, activity.Bytes)
+	assert.True(t, activity.LastActivity.After(started.LastActivity))
+
+	// the idle keep-alive connection outlives the proxy timeout.
+	time.Sleep(s.proxyTimeout * 3)
+	assert.Equal(t, int64(1), s.Activity().Connections, "keep-alive connection should still be open")
+	get()
+	assert.Greater(t, s.Activity().Bytes, activity.Bytes)
+
+	c.CloseIdleConnections()
+	assert.Eventually(t, func() bool {
+		return s.Activity().Connections == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestActivatorHTTPHoldingTimeout(t *testing.T) {
+	require.NoError(t, MountBPFFS(BPFFSPath))
+
+	nn, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s, err := NewServer(ctx, nn)
+	require.NoError(t, err)
+
+	bpf, err := InitBPF(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, bpf.AttachRedirector("lo"))
+
+	port, err := freePort()
+	require.NoError(t, err)
+	s.SetHTTPHoldingTimeout(uint16(port), time.Millisecond*100)
+
+	restored := make(chan struct{})
+	require.NoError(t, s.Start(ctx, []uint16{uint16(port)}, func() error {
+		// the restore takes longer than the holding timeout.
+		<-restored
+		return nil
+	}))
+	t.Cleanup(func() {
+		close(restored)
+		s.Stop(ctx)
+		cancel()
+	})
+
+	c := &http.Client{Timeout: time.Second}
+	start := time.Now()
+	resp, err := c.Get(fmt.Sprintf("http://localhost:%d", port))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	assert.Less(t, time.Since(start), time.Millisecond*500)
+}
+
+func TestActivatorListenerState(t *testing.T) {
+	require.NoError(t, MountBPFFS(BPFFSPath))
+
+	nn, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, err := NewServer(ctx, nn)
+	require.NoError(t, err)
+
+	_, err = InitBPF(os.Getpid())
+	require.NoError(t, err)
+
+	port, err := freePort()
+	require.NoError(t, err)
+
+	mu := sync.Mutex{}
+	listening := map[uint16]bool{}
+	s.SetListenerStateFunc(func(port uint16, l bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		listening[port] = l
+	})
+
+	require.NoError(t, s.Start(ctx, []uint16{uint16(port)}, func() error { return nil }))
+	mu.Lock()
+	assert.True(t, listening[uint16(port)])
+	mu.Unlock()
+
+	s.Stop(ctx)
+	mu.Lock()
+	assert.False(t, listening[uint16(port)])
+	mu.Unlock()
+}
+
+func TestBackendHost(t *testing.T) {
+	nn, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	s, err := NewServer(context.Background(), nn)
+	require.NoError(t, err)
+	s.SetOriginalDestination(8080)
+
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp4", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "127.0.0.1", s.backendHost(conn, 8080))
+	assert.Equal(t, "localhost", s.backendHost(conn, 80))
 }
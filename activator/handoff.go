@@ -0,0 +1,304 @@
+package activator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containerd/log"
+	"golang.org/x/sys/unix"
+)
+
+// The handoff protocol is spoken over a unix stream socket that is provided
+// by the process of the container. Before the process is checkpointed, the
+// activator connects to it and writes HandoffTake. The process then sends
+// its established connections as SCM_RIGHTS messages, closes its own copies
+// and closes the handoff connection once it's done. After the restore, the
+// activator connects again, writes HandoffGive and sends the connections
+// back the same way.
+const (
+	HandoffTake byte = 'T'
+	HandoffGive byte = 'G'
+
+	// maxHandoffFDs is the maximum amount of fds per message (SCM_MAX_FD).
+	maxHandoffFDs = 253
+	// maxHandoffConns limits the amount of connections that are held, so a
+	// misbehaving process can't exhaust the fds of the shim.
+	maxHandoffConns     = 4096
+	handoffTimeout      = 10 * time.Second
+	handoffPollInterval = 100 * time.Millisecond
+	handoffDialInterval = 100 * time.Millisecond
+)
+
+// ConnectionHandoff holds the established connections of a process while it
+// is scaled down, so they are not dropped by the checkpoint. As nobody reads
+// from the connections in the meantime, data sent by the clients is buffered
+// by the kernel until the connections have been handed back.
+type ConnectionHandoff struct {
+	mu    sync.Mutex
+	conns []*os.File
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewConnectionHandoff() *ConnectionHandoff {
+	return &ConnectionHandoff{}
+}
+
+// Take requests the established connections from the process listening on
+// the handoff socket at socketPath. onData is called once any of the held
+// connections becomes readable, e.g. to restore the process. Connections
+// that have been closed by the client are dropped. It returns the amount of
+// connections that have been taken over.
+func (h *ConnectionHandoff) Take(ctx context.Context, socketPath string, onData func()) (int, error) {
+	h.stopWatching()
+
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+	if err != nil {
+		return 0, fmt.Errorf("connecting to handoff socket: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(handoffTimeout)); err != nil {
+		return 0, err
+	}
+
+	if _, err := conn.Write([]byte{HandoffTake}); err != nil {
+		return 0, fmt.Errorf("requesting connections: %w", err)
+	}
+
+	files, err := receiveFDs(conn)
+	h.mu.Lock()
+	h.conns = append(h.conns, files...)
+	n := len(h.conns)
+	h.mu.Unlock()
+	if err != nil {
+		return n, err
+	}
+
+	if n > 0 {
+		h.watch(ctx, onData)
+	}
+	return n, nil
+}
+
+// HandBack sends the held connections to the process listening on the
+// handoff socket at socketPath. As a process that has been started from
+// scratch might not be listening yet, connecting is retried until ctx is
+// done. The held connections are closed afterwards, even if they could not
+// be handed back.
+func (h *ConnectionHandoff) HandBack(ctx context.Context, socketPath string) (int, error) {
+	h.stopWatching()
+	h.mu.Lock()
+	files := h.conns
+	h.conns = nil
+	h.mu.Unlock()
+	defer closeFiles(files)
+
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	var conn *net.UnixConn
+	for {
+		var err error
+		conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+		if err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("connecting to handoff socket: %w", err)
+		case <-time.After(handoffDialInterval):
+		}
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(handoffTimeout)); err != nil {
+		return 0, err
+	}
+
+	if _, err := conn.Write([]byte{HandoffGive}); err != nil {
+		return 0, fmt.Errorf("handing back connections: %w", err)
+	}
+	if err := sendFDs(conn, files); err != nil {
+		return 0, fmt.Errorf("handing back connections: %w", err)
+	}
+	return len(files), nil
+}
+
+// Len returns the amount of held connections.
+func (h *ConnectionHandoff) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns)
+}
+
+// Close closes all held connections.
+func (h *ConnectionHandoff) Close() {
+	h.stopWatching()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	closeFiles(h.conns)
+	h.conns = nil
+}
+
+// watch polls the held connections until they are handed back and calls
+// onData once any of them has data to read.
+func (h *ConnectionHandoff) watch(ctx context.Context, onData func()) {
+	h.stopWatching()
+	stop := make(chan struct{})
+	h.mu.Lock()
+	h.stop = stop
+	h.mu.Unlock()
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			readable, err := h.poll()
+			if err != nil {
+				log.G(ctx).Errorf("polling handed off connections: %s", err)
+				return
+			}
+			if readable {
+				onData()
+				return
+			}
+		}
+	}()
+}
+
+func (h *ConnectionHandoff) stopWatching() {
+	h.mu.Lock()
+	stop := h.stop
+	h.stop = nil
+	h.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	h.wg.Wait()
+}
+
+// poll waits up to the poll interval for any of the held connections to
+// become readable. Connections that have been closed by the client are
+// dropped. It returns true if there is data to read.
+func (h *ConnectionHandoff) poll() (bool, error) {
+	h.mu.Lock()
+	fds := make([]unix.PollFd, len(h.conns))
+	for i, f := range h.conns {
+		fds[i] = unix.PollFd{Fd: int32(f.Fd()), Events: unix.POLLIN | unix.POLLRDHUP}
+	}
+	h.mu.Unlock()
+
+	if _, err := unix.Poll(fds, int(handoffPollInterval.Milliseconds())); err != nil {
+		if errors.Is(err, unix.EINTR) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	readable := false
+	open := h.conns[:0]
+	for i, f := range h.conns {
+		revents := fds[i].Revents
+		if revents&unix.POLLIN != 0 {
+			// a connection that has been closed by the client is readable
+			// as well, but there is no data to read.
+			if hasData(fds[i].Fd) {
+				readable = true
+			} else {
+				f.Close()
+				continue
+			}
+		} else if revents&(unix.POLLHUP|unix.POLLERR) != 0 {
+			f.Close()
+			continue
+		}
+		open = append(open, f)
+	}
+	h.conns = open
+	return readable, nil
+}
+
+// hasData returns true if there is data to read on the socket without
+// consuming it.
+func hasData(fd int32) bool {
+	buf := make([]byte, 1)
+	n, _, err := unix.Recvfrom(int(fd), buf, unix.MSG_PEEK|unix.MSG_DONTWAIT)
+	return err == nil && n > 0
+}
+
+// receiveFDs reads SCM_RIGHTS messages from conn until it's closed by the
+// other side.
+func receiveFDs(conn *net.UnixConn) ([]*os.File, error) {
+	files := []*os.File{}
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(maxHandoffFDs*4))
+	for {
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		if oobn > 0 {
+			msgs, perr := unix.ParseSocketControlMessage(oob[:oobn])
+			if perr != nil {
+				return files, fmt.Errorf("parsing control message: %w", perr)
+			}
+			for _, msg := range msgs {
+				fds, perr := unix.ParseUnixRights(&msg)
+				if perr != nil {
+					continue
+				}
+				for _, fd := range fds {
+					if len(files) >= maxHandoffConns {
+						unix.Close(fd)
+						continue
+					}
+					files = append(files, os.NewFile(uintptr(fd), "handoff"))
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return files, fmt.Errorf("receiving connections: %w", err)
+		}
+		if n == 0 && oobn == 0 {
+			// the other side closed the connection.
+			return files, nil
+		}
+	}
+}
+
+// sendFDs sends the files as SCM_RIGHTS messages of at most maxHandoffFDs.
+func sendFDs(conn *net.UnixConn, files []*os.File) error {
+	for len(files) > 0 {
+		n := min(len(files), maxHandoffFDs)
+		fds := make([]int, n)
+		for i, f := range files[:n] {
+			fds[i] = int(f.Fd())
+		}
+		// a control message needs to be sent with at least one byte of data.
+		if _, _, err := conn.WriteMsgUnix([]byte{HandoffGive}, unix.UnixRights(fds...), nil); err != nil {
+			return err
+		}
+		files = files[n:]
+	}
+	return nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,131 @@
+package activator
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// handoffApp implements the process side of the handoff protocol.
+type handoffApp struct {
+	listener *net.UnixListener
+	conns    []*os.File
+	received chan []*os.File
+}
+
+func newHandoffApp(t *testing.T, conns []*os.File) *handoffApp {
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(t.TempDir(), "handoff.sock"), Net: "unix"})
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	app := &handoffApp{listener: l, conns: conns, received: make(chan []*os.File, 1)}
+	go app.serve()
+	return app
+}
+
+func (a *handoffApp) serve() {
+	for {
+		conn, err := a.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+
+		op := make([]byte, 1)
+		if _, err := conn.Read(op); err != nil {
+			conn.Close()
+			continue
+		}
+		switch op[0] {
+		case HandoffTake:
+			_ = sendFDs(conn, a.conns)
+			closeFiles(a.conns)
+			a.conns = nil
+			conn.Close()
+		case HandoffGive:
+			conn.CloseWrite()
+			files, _ := receiveFDs(conn)
+			conn.Close()
+			a.received <- files
+		}
+	}
+}
+
+func tcpConnPair(t *testing.T) (*os.File, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	server, err := l.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	f, err := server.(*net.TCPConn).File()
+	require.NoError(t, err)
+	return f, client
+}
+
+func TestConnectionHandoff(t *testing.T) {
+	ctx := context.Background()
+	held, client := tcpConnPair(t)
+	closed, closingClient := tcpConnPair(t)
+	app := newHandoffApp(t, []*os.File{held, closed})
+
+	h := NewConnectionHandoff()
+	defer h.Close()
+	dataReceived := make(chan struct{})
+	n, err := h.Take(ctx, app.listener.Addr().String(), func() { close(dataReceived) })
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// connections closed by the client are dropped.
+	require.NoError(t, closingClient.Close())
+	assert.Eventually(t, func() bool { return h.Len() == 1 }, time.Second, handoffPollInterval/2)
+
+	_, err = client.Write([]byte("ping"))
+	require.NoError(t, err)
+	select {
+	case <-dataReceived:
+	case <-time.After(time.Second):
+		t.Fatal("data on a held connection should have been reported")
+	}
+
+	n, err = h.HandBack(ctx, app.listener.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Zero(t, h.Len())
+
+	files := <-app.received
+	require.Len(t, files, 1)
+	defer closeFiles(files)
+	buf := make([]byte, 4)
+	n, err = unix.Read(int(files[0].Fd()), buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf[:n]), "data sent while scaled down should be readable after the handoff")
+}
+
+func TestConnectionHandoffWithoutConnections(t *testing.T) {
+	ctx := context.Background()
+	app := newHandoffApp(t, nil)
+
+	h := NewConnectionHandoff()
+	n, err := h.Take(ctx, app.listener.Addr().String(), func() {})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	n, err = h.HandBack(ctx, app.listener.Addr().String())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	_, err = h.Take(ctx, filepath.Join(t.TempDir(), "missing.sock"), func() {})
+	assert.Error(t, err)
+}
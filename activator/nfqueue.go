@@ -0,0 +1,245 @@
+package activator
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/florianl/go-nfqueue"
+)
+
+const (
+	nfqueueTable       = "filter"
+	nfqueueChain       = "INPUT"
+	nfqueueMaxQueueLen = 1024
+	nfqueueComment     = "zeropod activator"
+	// nfqueueCopyRange is the number of bytes of each packet that are copied
+	// to userspace. This covers the IPv4 header with all options or the IPv6
+	// header plus the ports of the TCP header, which is all we need to know
+	// about the connection.
+	nfqueueCopyRange = 64
+)
+
+// NFQueueServer is an activator that does not rely on the eBPF redirector.
+// Instead, new connections to the ports of the checkpointed container are
+// queued to userspace using an iptables NFQUEUE rule in the network namespace
+// of the pod. On the first queued packet, the container is restored, the
+// rules are removed and the queued packets are reinjected so they are
+// accepted directly by the restored process. This can be used in
+// environments where the redirect does not work, e.g. with certain CNI
+// plugins.
+type NFQueueServer struct {
+	ns       ns.NetNS
+	ports    []uint16
+	queueNum uint16
+	queue    verdictQueue
+	onAccept OnAccept
+	onConn   ConnectionFunc
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	started  bool
+}
+
+var _ Activator = &NFQueueServer{}
+
+// verdictQueue is the part of the nfqueue used to handle queued packets.
+type verdictQueue interface {
+	SetVerdict(id uint32, verdict int) error
+	Close() error
+}
+
+func NewNFQueueServer(ctx context.Context, nn ns.NetNS) (*NFQueueServer, error) {
+	return &NFQueueServer{ns: nn}, nil
+}
+
+// SetConnectionFunc sets a func that is called for every queued connection.
+// It needs to be called before the server is started.
+func (s *NFQueueServer) SetConnectionFunc(f ConnectionFunc) {
+	s.onConn = f
+}
+
+func (s *NFQueueServer) Start(ctx context.Context, ports []uint16, onAccept OnAccept) error {
+	if len(ports) == 0 {
+		return fmt.Errorf("no ports to activate")
+	}
+	for _, port := range ports {
+		if port == 0 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+
+	s.ports = ports
+	s.onAccept = onAccept
+	// the queue number only has to be unique within the network namespace of
+	// the pod. As this is also true for the ports, we simply use the first
+	// port of the container.
+	s.queueNum = ports[0]
+
+	queue, err := nfqueue.Open(&nfqueue.Config{
+		NetNS:        int(s.ns.Fd()),
+		NfQueue:      s.queueNum,
+		MaxQueueLen:  nfqueueMaxQueueLen,
+		Copymode:     nfqueue.NfQnlCopyPacket,
+		MaxPacketLen: nfqueueCopyRange,
+		WriteTimeout: time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to open nfqueue: %w", err)
+	}
+
+	queueCtx, cancel := context.WithCancel(ctx)
+	if err := queue.RegisterWithErrorFunc(queueCtx, s.handlePacket(queueCtx), func(err error) int {
+		if queueCtx.Err() != nil {
+			return 1
+		}
+		log.G(ctx).Errorf("error receiving from nfqueue: %s", err)
+		return 0
+	}); err != nil {
+		cancel()
+		queue.Close()
+		return fmt.Errorf("unable to register nfqueue: %w", err)
+	}
+	s.queue = queue
+	s.cancel = cancel
+
+	log.G(ctx).Debugf("queueing ports %v to nfqueue %d", ports, s.queueNum)
+	if err := s.Reset(); err != nil {
+		return err
+	}
+
+	s.started = true
+	return nil
+}
+
+// handlePacket handles a queued packet. As packets are handled one after
+// another, all packets that arrive during the restore are queued by the
+// kernel and accepted once the restore is done.
+func (s *NFQueueServer) handlePacket(ctx context.Context) nfqueue.HookFunc {
+	return func(a nfqueue.Attribute) int {
+		if a.PacketID == nil {
+			return 0
+		}
+
+		// only SYN packets are queued, so every packet is a new connection.
+		if s.onConn != nil && a.Payload != nil {
+			if port, ok := tcpDstPort(*a.Payload); ok {
+				s.onConn(port)
+			}
+		}
+
+		if err := s.onAccept(); err != nil {
+			log.G(ctx).Errorf("accept function: %s", err)
+		}
+
+		if err := s.queue.SetVerdict(*a.PacketID, nfqueue.NfAccept); err != nil {
+			log.G(ctx).Errorf("unable to set verdict for packet %d: %s", *a.PacketID, err)
+		}
+
+		return 0
+	}
+}
+
+func (s *NFQueueServer) Started() bool {
+	return s.started
+}
+
+// Reset adds the NFQUEUE rules for all ports.
+func (s *NFQueueServer) Reset() error {
+	return s.iptables(func(ipt *iptables.IPTables, rule []string) error {
+		exists, err := ipt.Exists(nfqueueTable, nfqueueChain, rule...)
+		if err != nil || exists {
+			return err
+		}
+		return ipt.Insert(nfqueueTable, nfqueueChain, 1, rule...)
+	})
+}
+
+// DisableRedirects removes the NFQUEUE rules of all ports.
+func (s *NFQueueServer) DisableRedirects() error {
+	return s.iptables(func(ipt *iptables.IPTables, rule []string) error {
+		return ipt.DeleteIfExists(nfqueueTable, nfqueueChain, rule...)
+	})
+}
+
+func (s *NFQueueServer) Stop(ctx context.Context) {
+	log.G(ctx).Debugf("stopping nfqueue activator")
+
+	if err := s.DisableRedirects(); err != nil {
+		log.G(ctx).Errorf("unable to remove nfqueue rules: %s", err)
+	}
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	if s.queue != nil {
+		if err := s.queue.Close(); err != nil {
+			log.G(ctx).Errorf("unable to close nfqueue: %s", err)
+		}
+	}
+
+	log.G(ctx).Debugf("nfqueue activator stopped")
+}
+
+// iptables calls f with the NFQUEUE rule of each port in the network
+// namespace of the pod.
+func (s *NFQueueServer) iptables(f func(ipt *iptables.IPTables, rule []string) error) error {
+	// iptables is not safe to be called concurrently on the same namespace.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ns.Do(func(_ ns.NetNS) error {
+		ipt, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
+		if err != nil {
+			return fmt.Errorf("unable to initialize iptables: %w", err)
+		}
+
+		for _, port := range s.ports {
+			if err := f(ipt, s.rule(port)); err != nil {
+				return fmt.Errorf("unable to update nfqueue rule of port %d: %w", port, err)
+			}
+		}
+		return nil
+	})
+}
+
+// rule returns the iptables rule to queue new connections to port. The
+// rule bypasses the queue if there is no process listening on it, so
+// connections are not dropped if the shim is gone.
+func (s *NFQueueServer) rule(port uint16) []string {
+	return []string{
+		"-p", "tcp", "--dport", strconv.Itoa(int(port)), "--syn",
+		"-m", "comment", "--comment", nfqueueComment,
+		"-j", "NFQUEUE", "--queue-num", strconv.Itoa(int(s.queueNum)), "--queue-bypass",
+	}
+}
+
+// tcpDstPort returns the destination port of an IPv4 or IPv6 packet
+// containing a TCP segment.
+func tcpDstPort(packet []byte) (uint16, bool) {
+	if len(packet) == 0 {
+		return 0, false
+	}
+
+	var offset int
+	switch packet[0] >> 4 {
+	case 4:
+		offset = int(packet[0]&0x0f) * 4
+	case 6:
+		// the queued SYN packets don't have any extension headers.
+		offset = 40
+	default:
+		return 0, false
+	}
+
+	if len(packet) < offset+4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(packet[offset+2 : offset+4]), true
+}
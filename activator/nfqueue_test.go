@@ -0,0 +1,157 @@
+package activator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containernetworking/plugins/pkg/testutils"
+	"github.com/florianl/go-nfqueue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+func TestNFQueueActivator(t *testing.T) {
+	if _, err := exec.LookPath("iptables"); err != nil {
+		t.Skip("skipping nfqueue test as iptables is not available")
+	}
+
+	nn, err := testutils.NewNS()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		nn.Close()
+		testutils.UnmountNS(nn)
+	})
+
+	require.NoError(t, nn.Do(func(_ ns.NetNS) error {
+		lo, err := netlink.LinkByName("lo")
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(lo)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	port := 8080
+	response := "ok"
+
+	s, err := NewNFQueueServer(ctx, nn)
+	require.NoError(t, err)
+
+	once := sync.Once{}
+	err = s.Start(ctx, []uint16{uint16(port)}, func() error {
+		once.Do(func() {
+			// simulate a delay until our server is started
+			time.Sleep(time.Millisecond * 200)
+			require.NoError(t, nn.Do(func(_ ns.NetNS) error {
+				l, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+				if err != nil {
+					return err
+				}
+				srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, response)
+				})}
+				go srv.Serve(l)
+				t.Cleanup(func() {
+					srv.Close()
+				})
+				return nil
+			}))
+
+			if err := s.DisableRedirects(); err != nil {
+				t.Errorf("could not disable redirects: %s", err)
+			}
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		s.Stop(ctx)
+		cancel()
+	})
+
+	parallelReqs := 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallelReqs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, nn.Do(func(_ ns.NetNS) error {
+				c := &http.Client{Timeout: time.Second}
+				resp, err := c.Get(fmt.Sprintf("http://localhost:%d", port))
+				if err != nil {
+					return err
+				}
+				b, err := io.ReadAll(resp.Body)
+				if err != nil {
+					return err
+				}
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, response, string(b))
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTCPDstPort(t *testing.T) {
+	ipv4 := make([]byte, 24)
+	ipv4[0] = 0x45
+	ipv4[22], ipv4[23] = 0x1f, 0x90
+	port, ok := tcpDstPort(ipv4)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(8080), port)
+
+	ipv6 := make([]byte, 44)
+	ipv6[0] = 0x60
+	ipv6[42], ipv6[43] = 0x00, 0x50
+	port, ok = tcpDstPort(ipv6)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(80), port)
+
+	_, ok = tcpDstPort(ipv4[:21])
+	assert.False(t, ok, "truncated packet should not be parsed")
+}
+
+type fakeVerdictQueue struct {
+	verdicts map[uint32]int
+}
+
+func (q *fakeVerdictQueue) SetVerdict(id uint32, verdict int) error {
+	q.verdicts[id] = verdict
+	return nil
+}
+
+func (q *fakeVerdictQueue) Close() error { return nil }
+
+func TestHandlePacket(t *testing.T) {
+	queue := &fakeVerdictQueue{verdicts: map[uint32]int{}}
+	accepted := 0
+	var ports []uint16
+	s := &NFQueueServer{
+		queue:    queue,
+		onAccept: func() error { accepted++; return nil },
+		onConn:   func(port uint16) { ports = append(ports, port) },
+	}
+
+	// SYN to port 8080 truncated to the copy range of the queue.
+	packet := make([]byte, nfqueueCopyRange)
+	packet[0] = 0x45
+	packet[22], packet[23] = 0x1f, 0x90
+	id := uint32(1)
+	assert.Equal(t, 0, s.handlePacket(context.Background())(nfqueue.Attribute{PacketID: &id, Payload: &packet}))
+
+	assert.Equal(t, 1, accepted)
+	assert.Equal(t, []uint16{8080}, ports)
+	assert.Equal(t, map[uint32]int{id: nfqueue.NfAccept}, queue.verdicts)
+}
@@ -11,6 +11,14 @@ type ShimService interface {
 	Metrics(context.Context, *MetricsRequest) (*MetricsResponse, error)
 	GetStatus(context.Context, *ContainerRequest) (*ContainerStatus, error)
 	SubscribeStatus(context.Context, *SubscribeStatusRequest, Shim_SubscribeStatusServer) error
+	ListContainers(context.Context, *ListContainersRequest) (*ListContainersResponse, error)
+	ForceRestore(context.Context, *ContainerRequest) (*ContainerStatus, error)
+	ForceScaleDown(context.Context, *ContainerRequest) (*ContainerStatus, error)
+	SetScalingEnabled(context.Context, *SetScalingEnabledRequest) (*ContainerStatus, error)
+	GetCRIULogs(context.Context, *ContainerRequest) (*CRIULogs, error)
+	ExportCheckpoint(context.Context, *CheckpointArchiveRequest) (*ContainerStatus, error)
+	ImportCheckpoint(context.Context, *CheckpointArchiveRequest) (*ContainerStatus, error)
+	GetScaleEvents(context.Context, *ContainerRequest) (*ScaleEvents, error)
 }
 
 type Shim_SubscribeStatusServer interface {
@@ -43,6 +51,62 @@ func RegisterShimService(srv *ttrpc.Server, svc ShimService) {
 				}
 				return svc.GetStatus(ctx, &req)
 			},
+			"ListContainers": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req ListContainersRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.ListContainers(ctx, &req)
+			},
+			"ForceRestore": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req ContainerRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.ForceRestore(ctx, &req)
+			},
+			"ForceScaleDown": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req ContainerRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.ForceScaleDown(ctx, &req)
+			},
+			"SetScalingEnabled": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req SetScalingEnabledRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.SetScalingEnabled(ctx, &req)
+			},
+			"GetCRIULogs": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req ContainerRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.GetCRIULogs(ctx, &req)
+			},
+			"ExportCheckpoint": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req CheckpointArchiveRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.ExportCheckpoint(ctx, &req)
+			},
+			"ImportCheckpoint": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req CheckpointArchiveRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.ImportCheckpoint(ctx, &req)
+			},
+			"GetScaleEvents": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req ContainerRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.GetScaleEvents(ctx, &req)
+			},
 		},
 		Streams: map[string]ttrpc.Stream{
 			"SubscribeStatus": {
@@ -64,6 +128,14 @@ type ShimClient interface {
 	Metrics(context.Context, *MetricsRequest) (*MetricsResponse, error)
 	GetStatus(context.Context, *ContainerRequest) (*ContainerStatus, error)
 	SubscribeStatus(context.Context, *SubscribeStatusRequest) (Shim_SubscribeStatusClient, error)
+	ListContainers(context.Context, *ListContainersRequest) (*ListContainersResponse, error)
+	ForceRestore(context.Context, *ContainerRequest) (*ContainerStatus, error)
+	ForceScaleDown(context.Context, *ContainerRequest) (*ContainerStatus, error)
+	SetScalingEnabled(context.Context, *SetScalingEnabledRequest) (*ContainerStatus, error)
+	GetCRIULogs(context.Context, *ContainerRequest) (*CRIULogs, error)
+	ExportCheckpoint(context.Context, *CheckpointArchiveRequest) (*ContainerStatus, error)
+	ImportCheckpoint(context.Context, *CheckpointArchiveRequest) (*ContainerStatus, error)
+	GetScaleEvents(context.Context, *ContainerRequest) (*ScaleEvents, error)
 }
 
 type shimClient struct {
@@ -120,3 +192,67 @@ func (x *shimSubscribeStatusClient) Recv() (*ContainerStatus, error) {
 	}
 	return m, nil
 }
+
+func (c *shimClient) ListContainers(ctx context.Context, req *ListContainersRequest) (*ListContainersResponse, error) {
+	var resp ListContainersResponse
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "ListContainers", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) ForceRestore(ctx context.Context, req *ContainerRequest) (*ContainerStatus, error) {
+	var resp ContainerStatus
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "ForceRestore", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) ForceScaleDown(ctx context.Context, req *ContainerRequest) (*ContainerStatus, error) {
+	var resp ContainerStatus
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "ForceScaleDown", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) SetScalingEnabled(ctx context.Context, req *SetScalingEnabledRequest) (*ContainerStatus, error) {
+	var resp ContainerStatus
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "SetScalingEnabled", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) GetCRIULogs(ctx context.Context, req *ContainerRequest) (*CRIULogs, error) {
+	var resp CRIULogs
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "GetCRIULogs", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) ExportCheckpoint(ctx context.Context, req *CheckpointArchiveRequest) (*ContainerStatus, error) {
+	var resp ContainerStatus
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "ExportCheckpoint", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) ImportCheckpoint(ctx context.Context, req *CheckpointArchiveRequest) (*ContainerStatus, error) {
+	var resp ContainerStatus
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "ImportCheckpoint", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimClient) GetScaleEvents(ctx context.Context, req *ContainerRequest) (*ScaleEvents, error) {
+	var resp ScaleEvents
+	if err := c.client.Call(ctx, "zeropod.shim.v1.Shim", "GetScaleEvents", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
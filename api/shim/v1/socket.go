@@ -0,0 +1,5 @@
+package v1
+
+// ShimSocketPath is the directory in which every zeropod shim exposes the
+// socket of its shim API.
+const ShimSocketPath = "/run/zeropod/s/"
@@ -0,0 +1,252 @@
+// Package client provides a client for the introspection/admin API of the
+// zeropod shims running on a node. As every shim exposes its own socket, the
+// client connects to all shim sockets found in the shim socket path and finds
+// the shim responsible for a container by its ID.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/ttrpc"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	ErrContainerNotFound = errors.New("zeropod container not found")
+	errShimUnavailable   = errors.New("shim unavailable")
+)
+
+type Client struct {
+	socketPath string
+}
+
+type Option func(*Client)
+
+// WithSocketPath configures the path that is searched for shim sockets.
+// Defaults to the shim socket path of the zeropod shim.
+func WithSocketPath(path string) Option {
+	return func(c *Client) {
+		c.socketPath = path
+	}
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{socketPath: v1.ShimSocketPath}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListContainers returns the status of all zeropod containers on the node.
+// Shims that are not running anymore are skipped.
+func (c *Client) ListContainers(ctx context.Context) ([]*v1.ContainerStatus, error) {
+	socks, err := c.sockets()
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []*v1.ContainerStatus{}
+	for _, sock := range socks {
+		if err := c.withShim(ctx, sock, func(shim v1.ShimClient) error {
+			resp, err := shim.ListContainers(ctx, &v1.ListContainersRequest{Empty: &emptypb.Empty{}})
+			if err != nil {
+				return err
+			}
+			containers = append(containers, resp.Containers...)
+			return nil
+		}); err != nil {
+			if shimUnavailable(err) {
+				continue
+			}
+			return nil, fmt.Errorf("listing containers of shim %s: %w", sock, err)
+		}
+	}
+
+	return containers, nil
+}
+
+// ScaleDownCandidates returns the running zeropod containers on the node that
+// have scaling enabled, ordered by their scale down priority. Containers with
+// a lower priority come first and should be scaled down first, e.g. when the
+// node is under resource pressure.
+func (c *Client) ScaleDownCandidates(ctx context.Context) ([]*v1.ContainerStatus, error) {
+	containers, err := c.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*v1.ContainerStatus{}
+	for _, container := range containers {
+		if container.Phase != v1.ContainerPhase_RUNNING || !container.ScalingEnabled {
+			continue
+		}
+		candidates = append(candidates, container)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].ScaleDownPriority < candidates[j].ScaleDownPriority
+	})
+
+	return candidates, nil
+}
+
+// GetStatus returns the status of the zeropod container with the supplied id.
+func (c *Client) GetStatus(ctx context.Context, id string) (*v1.ContainerStatus, error) {
+	var status *v1.ContainerStatus
+	return status, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		status, err = shim.GetStatus(ctx, &v1.ContainerRequest{Id: id})
+		return err
+	})
+}
+
+// ForceRestore restores the scaled down zeropod container with the supplied
+// id immediately.
+func (c *Client) ForceRestore(ctx context.Context, id string) (*v1.ContainerStatus, error) {
+	var status *v1.ContainerStatus
+	return status, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		status, err = shim.ForceRestore(ctx, &v1.ContainerRequest{Id: id})
+		return err
+	})
+}
+
+// ForceScaleDown scales down the running zeropod container with the supplied
+// id immediately.
+func (c *Client) ForceScaleDown(ctx context.Context, id string) (*v1.ContainerStatus, error) {
+	var status *v1.ContainerStatus
+	return status, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		status, err = shim.ForceScaleDown(ctx, &v1.ContainerRequest{Id: id})
+		return err
+	})
+}
+
+// SetScalingEnabled enables or disables automatic scaling of the zeropod
+// container with the supplied id.
+func (c *Client) SetScalingEnabled(ctx context.Context, id string, enabled bool) (*v1.ContainerStatus, error) {
+	var status *v1.ContainerStatus
+	return status, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		status, err = shim.SetScalingEnabled(ctx, &v1.SetScalingEnabledRequest{Id: id, Enabled: enabled})
+		return err
+	})
+}
+
+// GetCRIULogs returns the CRIU logs of the last dump and restore of the
+// zeropod container with the supplied id.
+func (c *Client) GetCRIULogs(ctx context.Context, id string) (*v1.CRIULogs, error) {
+	var logs *v1.CRIULogs
+	return logs, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		logs, err = shim.GetCRIULogs(ctx, &v1.ContainerRequest{Id: id})
+		return err
+	})
+}
+
+// GetScaleEvents returns the most recent scale events of the zeropod
+// container with the supplied id, oldest first. It's empty unless the scale
+// event history is enabled for the container.
+func (c *Client) GetScaleEvents(ctx context.Context, id string) (*v1.ScaleEvents, error) {
+	var events *v1.ScaleEvents
+	return events, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		events, err = shim.GetScaleEvents(ctx, &v1.ContainerRequest{Id: id})
+		return err
+	})
+}
+
+// ExportCheckpoint writes the checkpoint of the scaled down zeropod container
+// with the supplied id to an archive at path. The path is on the node of the
+// container.
+func (c *Client) ExportCheckpoint(ctx context.Context, id, path string) (*v1.ContainerStatus, error) {
+	var status *v1.ContainerStatus
+	return status, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		status, err = shim.ExportCheckpoint(ctx, &v1.CheckpointArchiveRequest{Id: id, Path: path})
+		return err
+	})
+}
+
+// ImportCheckpoint replaces the checkpoint of the scaled down zeropod
+// container with the supplied id with the archive at path, which has been
+// created by ExportCheckpoint. The path is on the node of the container.
+func (c *Client) ImportCheckpoint(ctx context.Context, id, path string) (*v1.ContainerStatus, error) {
+	var status *v1.ContainerStatus
+	return status, c.withContainerShim(ctx, id, func(shim v1.ShimClient) (err error) {
+		status, err = shim.ImportCheckpoint(ctx, &v1.CheckpointArchiveRequest{Id: id, Path: path})
+		return err
+	})
+}
+
+// withContainerShim calls f with a client of the shim that is responsible for
+// the container with the supplied id.
+func (c *Client) withContainerShim(ctx context.Context, id string, f func(v1.ShimClient) error) error {
+	socks, err := c.sockets()
+	if err != nil {
+		return err
+	}
+
+	for _, sock := range socks {
+		found := false
+		if err := c.withShim(ctx, sock, func(shim v1.ShimClient) error {
+			if _, err := shim.GetStatus(ctx, &v1.ContainerRequest{Id: id}); err != nil {
+				if errdefs.IsNotFound(errdefs.FromGRPC(err)) {
+					return nil
+				}
+				return err
+			}
+			found = true
+			return f(shim)
+		}); err != nil {
+			if shimUnavailable(err) {
+				continue
+			}
+			return errdefs.FromGRPC(err)
+		}
+		if found {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+}
+
+func (c *Client) withShim(ctx context.Context, sock string, f func(v1.ShimClient) error) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", sock)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errShimUnavailable, err)
+	}
+	client := ttrpc.NewClient(conn)
+	defer client.Close()
+
+	return f(v1.NewShimClient(client))
+}
+
+// shimUnavailable returns true if err is caused by a shim socket that can't
+// be dialed. A shim that has crashed leaves its socket behind, which should
+// not prevent reaching the shims that are still running.
+func shimUnavailable(err error) bool {
+	return errors.Is(err, errShimUnavailable) &&
+		(errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT))
+}
+
+func (c *Client) sockets() ([]string, error) {
+	entries, err := os.ReadDir(c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing shim sockets in %s: %w", c.socketPath, err)
+	}
+
+	socks := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		socks = append(socks, filepath.Join(c.socketPath, entry.Name()))
+	}
+	return socks, nil
+}
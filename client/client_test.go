@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/ttrpc"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeShim struct {
+	containers map[string]*v1.ContainerStatus
+}
+
+func (s *fakeShim) Metrics(context.Context, *v1.MetricsRequest) (*v1.MetricsResponse, error) {
+	return &v1.MetricsResponse{}, nil
+}
+
+func (s *fakeShim) SubscribeStatus(context.Context, *v1.SubscribeStatusRequest, v1.Shim_SubscribeStatusServer) error {
+	return nil
+}
+
+func (s *fakeShim) GetStatus(ctx context.Context, req *v1.ContainerRequest) (*v1.ContainerStatus, error) {
+	return s.get(req.Id)
+}
+
+func (s *fakeShim) ListContainers(context.Context, *v1.ListContainersRequest) (*v1.ListContainersResponse, error) {
+	resp := &v1.ListContainersResponse{}
+	for _, status := range s.containers {
+		resp.Containers = append(resp.Containers, status)
+	}
+	return resp, nil
+}
+
+func (s *fakeShim) ForceRestore(ctx context.Context, req *v1.ContainerRequest) (*v1.ContainerStatus, error) {
+	status, err := s.get(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if status.Phase == v1.ContainerPhase_RUNNING {
+		return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "container is already restored")
+	}
+	status.Phase = v1.ContainerPhase_RUNNING
+	return status, nil
+}
+
+func (s *fakeShim) ForceScaleDown(ctx context.Context, req *v1.ContainerRequest) (*v1.ContainerStatus, error) {
+	status, err := s.get(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	status.Phase = v1.ContainerPhase_SCALED_DOWN
+	return status, nil
+}
+
+func (s *fakeShim) SetScalingEnabled(ctx context.Context, req *v1.SetScalingEnabledRequest) (*v1.ContainerStatus, error) {
+	status, err := s.get(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	status.ScalingEnabled = req.Enabled
+	return status, nil
+}
+
+func (s *fakeShim) GetCRIULogs(ctx context.Context, req *v1.ContainerRequest) (*v1.CRIULogs, error) {
+	if _, err := s.get(req.Id); err != nil {
+		return nil, err
+	}
+	return &v1.CRIULogs{Id: req.Id, RestoreLog: []byte("restore of " + req.Id)}, nil
+}
+
+func (s *fakeShim) ExportCheckpoint(ctx context.Context, req *v1.CheckpointArchiveRequest) (*v1.ContainerStatus, error) {
+	return s.get(req.Id)
+}
+
+func (s *fakeShim) ImportCheckpoint(ctx context.Context, req *v1.CheckpointArchiveRequest) (*v1.ContainerStatus, error) {
+	return s.get(req.Id)
+}
+
+func (s *fakeShim) GetScaleEvents(ctx context.Context, req *v1.ContainerRequest) (*v1.ScaleEvents, error) {
+	if _, err := s.get(req.Id); err != nil {
+		return nil, err
+	}
+	return &v1.ScaleEvents{Id: req.Id, Events: []*v1.ScaleEvent{{Type: v1.ScaleEventType_RESTORE, Cause: "connection"}}}, nil
+}
+
+func (s *fakeShim) get(id string) (*v1.ContainerStatus, error) {
+	status, ok := s.containers[id]
+	if !ok {
+		return nil, errdefs.ToGRPCf(errdefs.ErrNotFound, "could not find zeropod container with id: %s", id)
+	}
+	return status, nil
+}
+
+func startFakeShim(t *testing.T, sock string, shim *fakeShim) {
+	srv, err := ttrpc.NewServer()
+	require.NoError(t, err)
+	v1.RegisterShimService(srv, shim)
+
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+
+	go srv.Serve(context.Background(), l)
+	t.Cleanup(func() {
+		srv.Close()
+	})
+}
+
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	startFakeShim(t, filepath.Join(dir, "shim1.sock"), &fakeShim{
+		containers: map[string]*v1.ContainerStatus{
+			"a": {Id: "a", Name: "nginx", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true},
+		},
+	})
+	startFakeShim(t, filepath.Join(dir, "shim2.sock"), &fakeShim{
+		containers: map[string]*v1.ContainerStatus{
+			"b": {Id: "b", Name: "redis", Phase: v1.ContainerPhase_SCALED_DOWN, ScalingEnabled: true},
+		},
+	})
+
+	ctx := context.Background()
+	c := New(WithSocketPath(dir))
+
+	containers, err := c.ListContainers(ctx)
+	require.NoError(t, err)
+	ids := []string{}
+	for _, container := range containers {
+		ids = append(ids, container.Id)
+	}
+	assert.ElementsMatch(t, []string{"a", "b"}, ids)
+
+	status, err := c.ForceRestore(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, v1.ContainerPhase_RUNNING, status.Phase)
+
+	_, err = c.ForceRestore(ctx, "a")
+	assert.True(t, errdefs.IsFailedPrecondition(err))
+
+	status, err = c.ForceScaleDown(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, v1.ContainerPhase_SCALED_DOWN, status.Phase)
+
+	status, err = c.SetScalingEnabled(ctx, "b", false)
+	require.NoError(t, err)
+	assert.False(t, status.ScalingEnabled)
+
+	status, err = c.GetStatus(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "redis", status.Name)
+
+	_, err = c.GetStatus(ctx, "c")
+	assert.ErrorIs(t, err, ErrContainerNotFound)
+
+	logs, err := c.GetCRIULogs(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, "restore of b", string(logs.RestoreLog))
+
+	events, err := c.GetScaleEvents(ctx, "b")
+	require.NoError(t, err)
+	require.Len(t, events.Events, 1)
+	assert.Equal(t, v1.ScaleEventType_RESTORE, events.Events[0].Type)
+}
+
+func TestScaleDownCandidates(t *testing.T) {
+	dir := t.TempDir()
+	startFakeShim(t, filepath.Join(dir, "shim1.sock"), &fakeShim{
+		containers: map[string]*v1.ContainerStatus{
+			"high":     {Id: "high", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true, ScaleDownPriority: 10},
+			"disabled": {Id: "disabled", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: false, ScaleDownPriority: -10},
+		},
+	})
+	startFakeShim(t, filepath.Join(dir, "shim2.sock"), &fakeShim{
+		containers: map[string]*v1.ContainerStatus{
+			"low":         {Id: "low", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true, ScaleDownPriority: -5},
+			"default":     {Id: "default", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true},
+			"scaled-down": {Id: "scaled-down", Phase: v1.ContainerPhase_SCALED_DOWN, ScalingEnabled: true, ScaleDownPriority: -20},
+		},
+	})
+
+	candidates, err := New(WithSocketPath(dir)).ScaleDownCandidates(context.Background())
+	require.NoError(t, err)
+
+	ids := []string{}
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.Id)
+	}
+	assert.Equal(t, []string{"low", "default", "high"}, ids)
+}
+
+func TestClientWithDeadShim(t *testing.T) {
+	dir := t.TempDir()
+	// a crashed shim leaves its socket behind.
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(dir, "shim0.sock"), Net: "unix"})
+	require.NoError(t, err)
+	l.SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+	startFakeShim(t, filepath.Join(dir, "shim1.sock"), &fakeShim{
+		containers: map[string]*v1.ContainerStatus{
+			"a": {Id: "a", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true},
+		},
+	})
+
+	ctx := context.Background()
+	c := New(WithSocketPath(dir))
+
+	containers, err := c.ListContainers(ctx)
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "a", containers[0].Id)
+
+	candidates, err := c.ScaleDownCandidates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, candidates, 1)
+
+	status, err := c.ForceScaleDown(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, v1.ContainerPhase_SCALED_DOWN, status.Phase)
+
+	_, err = c.GetStatus(ctx, "b")
+	assert.ErrorIs(t, err, ErrContainerNotFound)
+}
@@ -25,11 +25,13 @@ import (
 )
 
 var (
-	criuImage      = flag.String("criu-image", "ghcr.io/ctrox/zeropod-criu:v3.19", "criu image to use.")
-	runtime        = flag.String("runtime", "containerd", "specifies which runtime to configure. containerd/k3s/rke2")
-	hostOptPath    = flag.String("host-opt-path", "/opt/zeropod", "path where zeropod binaries are stored on the host")
-	uninstall      = flag.Bool("uninstall", false, "uninstalls zeropod by cleaning up all the files the installer created")
-	installTimeout = flag.Duration("timeout", time.Minute, "duration the installer waits for the installation to complete")
+	criuImage        = flag.String("criu-image", "ghcr.io/ctrox/zeropod-criu:v3.19", "criu image to use.")
+	runtime          = flag.String("runtime", "containerd", "specifies which runtime to configure. containerd/k3s/rke2")
+	hostOptPath      = flag.String("host-opt-path", "/opt/zeropod", "path where zeropod binaries are stored on the host")
+	uninstall        = flag.Bool("uninstall", false, "uninstalls zeropod by cleaning up all the files the installer created")
+	installTimeout   = flag.Duration("timeout", time.Minute, "duration the installer waits for the installation to complete")
+	annotationPrefix = flag.String("annotation-prefix", zeropod.DefaultAnnotationPrefix, fmt.Sprintf("prefix of the annotations passed to the shim, needs to match %s of the shim if set", zeropod.EnvAnnotationPrefix))
+	nodeLabel        = flag.Bool("node-label", true, fmt.Sprintf("only schedule pods with the zeropod runtime class to nodes with the label %s=true", zeropod.NodeLabel))
 )
 
 type containerRuntime string
@@ -72,6 +74,55 @@ network-lock skip
     "zeropod.ctrox.dev/scaledown-duration",
     "zeropod.ctrox.dev/disable-checkpointing",
     "zeropod.ctrox.dev/pre-dump",
+    "zeropod.ctrox.dev/restore-timeout",
+    "zeropod.ctrox.dev/log-path",
+    "zeropod.ctrox.dev/activator-backend",
+    "zeropod.ctrox.dev/refresh-dns-config",
+    "zeropod.ctrox.dev/keep-timer-on-exec",
+    "zeropod.ctrox.dev/restore-siblings",
+    "zeropod.ctrox.dev/scaledown-strategy",
+    "zeropod.ctrox.dev/verify-checkpoint",
+    "zeropod.ctrox.dev/scaledown-priority",
+    "zeropod.ctrox.dev/restore-signal",
+    "zeropod.ctrox.dev/scaledown-trigger",
+    "zeropod.ctrox.dev/handoff-grace-period",
+    "zeropod.ctrox.dev/criu-ghost-limit",
+    "zeropod.ctrox.dev/restore-retries",
+    "zeropod.ctrox.dev/restore-failure-policy",
+    "zeropod.ctrox.dev/activator-metering",
+    "zeropod.ctrox.dev/max-scaled-lifetime",
+    "zeropod.ctrox.dev/eager-checkpoint",
+    "zeropod.ctrox.dev/http-holding-timeout",
+    "zeropod.ctrox.dev/post-restore-command",
+    "zeropod.ctrox.dev/log-buffer-size",
+    "zeropod.ctrox.dev/max-log-line-size",
+    "zeropod.ctrox.dev/restore-on-stop",
+    "zeropod.ctrox.dev/restore-retry-backoff",
+    "zeropod.ctrox.dev/scale-event-history",
+    "zeropod.ctrox.dev/wake-signals",
+    "zeropod.ctrox.dev/reclaim-sandbox",
+    "zeropod.ctrox.dev/criu-page-server",
+    "zeropod.ctrox.dev/max-disk-write-rate",
+    "zeropod.ctrox.dev/min-memory",
+    "zeropod.ctrox.dev/prefetch-interval",
+    "zeropod.ctrox.dev/timer-mode",
+    "zeropod.ctrox.dev/original-dst-ports",
+    "zeropod.ctrox.dev/redirect-cooldown",
+    "zeropod.ctrox.dev/readiness-probe",
+    "zeropod.ctrox.dev/criu-pre-dump-mode",
+    "zeropod.ctrox.dev/criu-auto-dedup",
+    "zeropod.ctrox.dev/activity-check-interval",
+    "zeropod.ctrox.dev/reload-mounts",
+    "zeropod.ctrox.dev/checkpoint-rootfs",
+    "zeropod.ctrox.dev/restore-health-check",
+    "zeropod.ctrox.dev/uncheckpointable-policy",
+    "zeropod.ctrox.dev/numa-affinity",
+    "zeropod.ctrox.dev/cold-start-command",
+    "zeropod.ctrox.dev/criu-manage-cgroups",
+    "zeropod.ctrox.dev/connection-handoff-socket",
+    "zeropod.ctrox.dev/startup-grace",
+    "vcluster.loft.sh/name",
+    "vcluster.loft.sh/namespace",
     "io.containerd.runc.v2.group"
   ]
 `
@@ -109,7 +160,7 @@ func main() {
 
 	log.Println("installed runtime")
 
-	if err := installRuntimeClass(ctx, client); err != nil {
+	if err := installRuntimeClass(ctx, client, *nodeLabel); err != nil {
 		log.Fatalf("error installing zeropod runtimeClass: %s", err)
 	}
 
@@ -265,7 +316,9 @@ func configureContainerd(runtime containerRuntime, containerdConfig string) (res
 		optPath = containerdOptPath
 	}
 
-	if _, err := cfg.WriteString(fmt.Sprintf(runtimeConfig, strings.TrimSuffix(optPath, "/"))); err != nil {
+	config := fmt.Sprintf(runtimeConfig, strings.TrimSuffix(optPath, "/"))
+	config = strings.ReplaceAll(config, zeropod.DefaultAnnotationPrefix, strings.TrimSuffix(*annotationPrefix, "/")+"/")
+	if _, err := cfg.WriteString(config); err != nil {
 		return false, err
 	}
 
@@ -323,20 +376,32 @@ func optConfigured(containerdConfig string) (bool, string, error) {
 	return false, "", nil
 }
 
-func installRuntimeClass(ctx context.Context, client kubernetes.Interface) error {
+func installRuntimeClass(ctx context.Context, client kubernetes.Interface, nodeLabel bool) error {
 	runtimeClass := &nodev1.RuntimeClass{
 		ObjectMeta: v1.ObjectMeta{Name: runtimeClassName},
 		Handler:    runtimeHandler,
-		Scheduling: &nodev1.Scheduling{NodeSelector: map[string]string{zeropod.NodeLabel: "true"}},
+	}
+	if nodeLabel {
+		runtimeClass.Scheduling = &nodev1.Scheduling{NodeSelector: map[string]string{zeropod.NodeLabel: "true"}}
 	}
 
-	if _, err := client.NodeV1().RuntimeClasses().Create(ctx, runtimeClass, v1.CreateOptions{}); err != nil {
-		if !kerrors.IsAlreadyExists(err) {
-			return err
-		}
+	_, err := client.NodeV1().RuntimeClasses().Create(ctx, runtimeClass, v1.CreateOptions{})
+	if err == nil || !kerrors.IsAlreadyExists(err) {
+		return err
 	}
 
-	return nil
+	// the node label requirement might have been changed since the runtime
+	// class has been created.
+	existing, err := client.NodeV1().RuntimeClasses().Get(ctx, runtimeClassName, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if nodeLabel == (existing.Scheduling != nil) {
+		return nil
+	}
+	existing.Scheduling = runtimeClass.Scheduling
+	_, err = client.NodeV1().RuntimeClasses().Update(ctx, existing, v1.UpdateOptions{})
+	return err
 }
 
 func removeRuntimeClass(ctx context.Context, client kubernetes.Interface) error {
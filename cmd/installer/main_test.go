@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/ctrox/zeropod/zeropod"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 // kindContainerdConfig was extracted from a Kind cluster
@@ -73,3 +78,35 @@ func TestConfigureContainerd(t *testing.T) {
 	assert.NotEmpty(t, newFile)
 	assert.True(t, restart)
 }
+
+func TestConfigureContainerdAnnotationPrefix(t *testing.T) {
+	defer func(prefix string) { *annotationPrefix = prefix }(*annotationPrefix)
+	*annotationPrefix = "zeropod.example.com"
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(kindContainerdConfig), os.ModePerm))
+
+	_, err := configureContainerd(runtimeContainerd, configFile)
+	require.NoError(t, err)
+
+	newFile, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(newFile), `"zeropod.example.com/ports-map"`)
+	assert.NotContains(t, string(newFile), zeropod.DefaultAnnotationPrefix)
+}
+
+func TestInstallRuntimeClass(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+
+	require.NoError(t, installRuntimeClass(ctx, client, true))
+	rc, err := client.NodeV1().RuntimeClasses().Get(ctx, runtimeClassName, v1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, rc.Scheduling)
+	assert.Equal(t, map[string]string{zeropod.NodeLabel: "true"}, rc.Scheduling.NodeSelector)
+
+	require.NoError(t, installRuntimeClass(ctx, client, false))
+	rc, err = client.NodeV1().RuntimeClasses().Get(ctx, runtimeClassName, v1.GetOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, rc.Scheduling, "node label requirement should have been removed")
+}
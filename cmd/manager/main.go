@@ -19,7 +19,16 @@ var (
 	debug          = flag.Bool("debug", false, "enable debug logs")
 	inPlaceScaling = flag.Bool("in-place-scaling", false,
 		"enable in-place resource scaling, requires InPlacePodVerticalScaling feature flag")
-	statusLabels = flag.Bool("status-labels", false, "update pod labels to reflect container status")
+	statusLabels        = flag.Bool("status-labels", false, "update pod labels to reflect container status")
+	activityAnnotations = flag.Bool("activity-annotations", false,
+		"update pod annotations with the last activity of the containers")
+	evictionAnnotations = flag.Bool("eviction-annotations", false,
+		"annotate pods with containers that have been scaled down for longer than their max scaled lifetime")
+	restoreConditions = flag.Bool("restore-conditions", false,
+		"set the ZeropodRestoreHealthy pod condition to reflect the result of the last restore")
+	scaleDownOnCordon = flag.Bool("scale-down-on-cordon", false,
+		"scale down all running containers on the node once it has been cordoned")
+	nodeName = flag.String("node-name", os.Getenv("NODE_NAME"), "name of the node the manager is running on")
 )
 
 func main() {
@@ -48,6 +57,15 @@ func main() {
 	if *statusLabels {
 		podHandlers = append(podHandlers, manager.NewPodLabeller())
 	}
+	if *activityAnnotations {
+		podHandlers = append(podHandlers, manager.NewActivityAnnotator())
+	}
+	if *evictionAnnotations {
+		podHandlers = append(podHandlers, manager.NewEvictionAnnotator())
+	}
+	if *restoreConditions {
+		podHandlers = append(podHandlers, manager.NewRestoreConditioner())
+	}
 	if *inPlaceScaling {
 		podHandlers = append(podHandlers, manager.NewPodScaler())
 	}
@@ -57,6 +75,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *scaleDownOnCordon {
+		cordonScaler, err := manager.NewCordonScaler(*nodeName)
+		if err != nil {
+			slog.Error("creating cordon scaler", "err", err)
+			os.Exit(1)
+		}
+		go cordonScaler.Start(ctx)
+	}
+
 	server := &http.Server{Addr: *metricsAddr}
 	http.HandleFunc("/metrics", manager.Handler)
 
@@ -192,6 +192,40 @@ func TestE2E(t *testing.T) {
 		}, time.Minute, time.Second)
 	})
 
+	t.Run("init process reaps children after restore", func(t *testing.T) {
+		// the nginx master process is PID 1 of the container and spawns the
+		// workers before the checkpoint.
+		pod := testPod(scaleDownAfter(0))
+		cleanupPod := createPodAndWait(t, ctx, client, pod)
+		defer cleanupPod()
+
+		require.Eventually(t, func() bool {
+			checkpointed, err := isCheckpointed(t, client, cfg, pod)
+			if err != nil {
+				t.Logf("error checking if checkpointed: %s", err)
+				return false
+			}
+			return checkpointed
+		}, time.Minute, time.Second)
+
+		// the exec restores the container and kills one of the workers
+		// spawned before the checkpoint.
+		stdout, stderr, err := podExec(cfg, pod,
+			`tr -d '\0' < /proc/1/cmdline; for d in /proc/[0-9]*; do if grep -qs "^nginx: worker" $d/cmdline; then kill ${d#/proc/}; break; fi; done`)
+		require.NoError(t, err)
+		t.Log(stdout, stderr)
+		assert.Contains(t, stdout, "nginx: master process")
+
+		require.Eventually(t, func() bool {
+			stdout, _, err := podExec(cfg, pod, `grep -ls "^State:.*Z" /proc/[0-9]*/status | wc -l`)
+			if err != nil {
+				t.Logf("error counting zombie processes: %s", err)
+				return false
+			}
+			return strings.TrimSpace(stdout) == "0"
+		}, time.Minute, time.Second, "exited worker should have been reaped by the restored init process")
+	})
+
 	t.Run("delete in restored state", func(t *testing.T) {
 		// as we want to delete the pod when it is in a restored state, we
 		// first need to make sure it has checkpointed at least once.
@@ -0,0 +1,49 @@
+package manager
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ActivityAnnotationKeyPrefix = "activity.zeropod.ctrox.dev"
+)
+
+// ActivityAnnotator sets the time of the last activity of the containers as
+// annotations on the pod. This allows replica-level autoscalers (e.g. an
+// external scaler of KEDA) to take the activity of zeropod containers into
+// account.
+type ActivityAnnotator struct {
+	log *slog.Logger
+}
+
+func NewActivityAnnotator() *ActivityAnnotator {
+	log := slog.With("component", "activityannotator")
+	log.Info("init")
+	return &ActivityAnnotator{log: log}
+}
+
+func (aa *ActivityAnnotator) Handle(ctx context.Context, status *v1.ContainerStatus, pod *corev1.Pod) error {
+	// shims that don't report any activity yet are ignored.
+	if status.LastActivity == nil {
+		return nil
+	}
+
+	aa.log.Debug("activity event", "container", status.Name, "pod", status.PodName,
+		"namespace", status.PodNamespace, "last_activity", status.LastActivity.AsTime())
+	aa.setAnnotation(pod, status)
+	return nil
+}
+
+func (aa *ActivityAnnotator) setAnnotation(pod *corev1.Pod, status *v1.ContainerStatus) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[path.Join(ActivityAnnotationKeyPrefix, status.Name)] =
+		status.LastActivity.AsTime().UTC().Format(time.RFC3339)
+}
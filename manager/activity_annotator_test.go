@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestActivityAnnotator(t *testing.T) {
+	lastActivity := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := map[string]struct {
+		lastActivity *timestamppb.Timestamp
+		beforeEvent  map[string]string
+		expected     map[string]string
+	}{
+		"no annotations set": {
+			lastActivity: timestamppb.New(lastActivity),
+			expected: map[string]string{
+				"activity.zeropod.ctrox.dev/first-container": "2024-01-02T03:04:05Z",
+			},
+		},
+		"existing annotations are kept": {
+			lastActivity: timestamppb.New(lastActivity),
+			beforeEvent:  map[string]string{"existing": "annotation"},
+			expected: map[string]string{
+				"existing": "annotation",
+				"activity.zeropod.ctrox.dev/first-container": "2024-01-02T03:04:05Z",
+			},
+		},
+		"activity annotation is updated": {
+			lastActivity: timestamppb.New(lastActivity),
+			beforeEvent: map[string]string{
+				"activity.zeropod.ctrox.dev/first-container": "2024-01-01T00:00:00Z",
+			},
+			expected: map[string]string{
+				"activity.zeropod.ctrox.dev/first-container": "2024-01-02T03:04:05Z",
+			},
+		},
+		"no activity reported": {
+			beforeEvent: map[string]string{"existing": "annotation"},
+			expected:    map[string]string{"existing": "annotation"},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			pod := newPod(nil)
+			pod.SetAnnotations(tc.beforeEvent)
+
+			require.NoError(t, NewActivityAnnotator().Handle(
+				context.Background(),
+				&v1.ContainerStatus{
+					Name:         pod.Spec.Containers[0].Name,
+					PodName:      pod.Name,
+					PodNamespace: pod.Namespace,
+					Phase:        v1.ContainerPhase_RUNNING,
+					LastActivity: tc.lastActivity,
+				},
+				pod,
+			))
+
+			assert.Equal(t, tc.expected, pod.GetAnnotations())
+		})
+	}
+}
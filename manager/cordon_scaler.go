@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	shimclient "github.com/ctrox/zeropod/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+const cordonCheckInterval = 10 * time.Second
+
+// CordonScaler scales down all running containers on the node once it has
+// been cordoned (e.g. for maintenance), instead of waiting for their scale
+// down durations. This frees up the memory of the node and makes the pods
+// quicker to evict.
+type CordonScaler struct {
+	log       *slog.Logger
+	kube      client.Client
+	nodeName  string
+	cordoned  bool
+	scaleDown func(context.Context) error
+}
+
+func NewCordonScaler(nodeName string) (*CordonScaler, error) {
+	if nodeName == "" {
+		return nil, fmt.Errorf("node name is required to scale down on cordon")
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("getting client config: %w", err)
+	}
+	kube, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	return &CordonScaler{
+		log:       slog.With("node", nodeName),
+		kube:      kube,
+		nodeName:  nodeName,
+		scaleDown: scaleDownAllContainers,
+	}, nil
+}
+
+// Start checks the node for being cordoned until ctx is done.
+func (cs *CordonScaler) Start(ctx context.Context) {
+	ticker := time.NewTicker(cordonCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := cs.check(ctx); err != nil {
+			cs.log.Error("checking node for cordon", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check scales down the containers if the node has been cordoned since the
+// last check. Containers that are restored while the node stays cordoned
+// are scaled down as usual.
+func (cs *CordonScaler) check(ctx context.Context) error {
+	node := &corev1.Node{}
+	if err := cs.kube.Get(ctx, types.NamespacedName{Name: cs.nodeName}, node); err != nil {
+		return fmt.Errorf("getting node: %w", err)
+	}
+
+	cordoned := node.Spec.Unschedulable
+	if !cordoned || cs.cordoned {
+		cs.cordoned = cordoned
+		return nil
+	}
+
+	cs.log.Info("node has been cordoned, scaling down containers")
+	if err := cs.scaleDown(ctx); err != nil {
+		return err
+	}
+	cs.cordoned = true
+	return nil
+}
+
+// containerScaler scales down containers of the shims on the node. It's
+// implemented by the zeropod client.
+type containerScaler interface {
+	ScaleDownCandidates(ctx context.Context) ([]*v1.ContainerStatus, error)
+	ForceScaleDown(ctx context.Context, id string) (*v1.ContainerStatus, error)
+}
+
+// scaleDownAllContainers scales down the running containers of all shims on
+// the node.
+func scaleDownAllContainers(ctx context.Context) error {
+	return scaleDownContainers(ctx, slog.Default(), shimclient.New())
+}
+
+func scaleDownContainers(ctx context.Context, log *slog.Logger, scaler containerScaler) error {
+	candidates, err := scaler.ScaleDownCandidates(ctx)
+	if err != nil {
+		return fmt.Errorf("listing containers: %w", err)
+	}
+
+	for _, container := range candidates {
+		clog := log.With("container", container.Name, "pod", container.PodName, "namespace", container.PodNamespace)
+		if _, err := scaler.ForceScaleDown(ctx, container.Id); err != nil {
+			clog.Error("scaling down container", "err", err)
+			continue
+		}
+		clog.Info("scaled down container")
+	}
+
+	return nil
+}
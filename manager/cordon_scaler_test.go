@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCordonScaler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+	kube := fake.NewClientBuilder().WithScheme(scheme).WithObjects(node).Build()
+
+	scaleDowns := 0
+	cs := &CordonScaler{
+		log:      slog.Default(),
+		kube:     kube,
+		nodeName: node.Name,
+		scaleDown: func(context.Context) error {
+			scaleDowns++
+			return nil
+		},
+	}
+
+	require.NoError(t, cs.check(ctx))
+	assert.Equal(t, 0, scaleDowns, "schedulable node should not scale down")
+
+	node.Spec.Unschedulable = true
+	require.NoError(t, kube.Update(ctx, node))
+	require.NoError(t, cs.check(ctx))
+	require.NoError(t, cs.check(ctx))
+	assert.Equal(t, 1, scaleDowns, "containers should be scaled down once per cordon")
+
+	node.Spec.Unschedulable = false
+	require.NoError(t, kube.Update(ctx, node))
+	require.NoError(t, cs.check(ctx))
+	node.Spec.Unschedulable = true
+	require.NoError(t, kube.Update(ctx, node))
+	require.NoError(t, cs.check(ctx))
+	assert.Equal(t, 2, scaleDowns, "cordoning the node again should scale down again")
+}
+
+type fakeScaler struct {
+	candidates []*v1.ContainerStatus
+	scaledDown []string
+}
+
+func (f *fakeScaler) ScaleDownCandidates(context.Context) ([]*v1.ContainerStatus, error) {
+	return f.candidates, nil
+}
+
+func (f *fakeScaler) ForceScaleDown(_ context.Context, id string) (*v1.ContainerStatus, error) {
+	if id == "failing" {
+		return nil, errors.New("scale down failed")
+	}
+	f.scaledDown = append(f.scaledDown, id)
+	return &v1.ContainerStatus{Id: id, Phase: v1.ContainerPhase_SCALED_DOWN}, nil
+}
+
+func TestScaleDownContainers(t *testing.T) {
+	scaler := &fakeScaler{candidates: []*v1.ContainerStatus{
+		{Id: "first", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true},
+		{Id: "failing", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true},
+		{Id: "second", Phase: v1.ContainerPhase_RUNNING, ScalingEnabled: true},
+	}}
+
+	require.NoError(t, scaleDownContainers(context.Background(), slog.Default(), scaler))
+	assert.Equal(t, []string{"first", "second"}, scaler.scaledDown, "a failed scale down should not stop the others")
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	EvictionAnnotationKeyPrefix = "eviction.zeropod.ctrox.dev"
+)
+
+// EvictionAnnotator marks pods whose containers have been scaled down for
+// longer than their max scaled lifetime with an annotation. As the eviction
+// of such pods depends on the workload (e.g. a deployment would just
+// recreate it), deleting them is left to an external controller.
+type EvictionAnnotator struct {
+	log *slog.Logger
+	now func() time.Time
+}
+
+func NewEvictionAnnotator() *EvictionAnnotator {
+	log := slog.With("component", "evictionannotator")
+	log.Info("init")
+	return &EvictionAnnotator{log: log, now: time.Now}
+}
+
+func (ea *EvictionAnnotator) Handle(ctx context.Context, status *v1.ContainerStatus, pod *corev1.Pod) error {
+	key := path.Join(EvictionAnnotationKeyPrefix, status.Name)
+	if !status.EvictionRequested {
+		delete(pod.Annotations, key)
+		return nil
+	}
+
+	if _, ok := pod.Annotations[key]; ok {
+		return nil
+	}
+
+	ea.log.Info("eviction requested", "container", status.Name, "pod", status.PodName,
+		"namespace", status.PodNamespace)
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[key] = ea.now().UTC().Format(time.RFC3339)
+	return nil
+}
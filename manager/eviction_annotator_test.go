@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionAnnotator(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := map[string]struct {
+		evictionRequested bool
+		beforeEvent       map[string]string
+		expected          map[string]string
+	}{
+		"eviction requested": {
+			evictionRequested: true,
+			expected: map[string]string{
+				"eviction.zeropod.ctrox.dev/first-container": "2024-01-02T03:04:05Z",
+			},
+		},
+		"time of first request is kept": {
+			evictionRequested: true,
+			beforeEvent: map[string]string{
+				"eviction.zeropod.ctrox.dev/first-container": "2024-01-01T00:00:00Z",
+			},
+			expected: map[string]string{
+				"eviction.zeropod.ctrox.dev/first-container": "2024-01-01T00:00:00Z",
+			},
+		},
+		"annotation is removed after restore": {
+			beforeEvent: map[string]string{
+				"existing": "annotation",
+				"eviction.zeropod.ctrox.dev/first-container": "2024-01-01T00:00:00Z",
+			},
+			expected: map[string]string{"existing": "annotation"},
+		},
+		"no eviction requested": {
+			beforeEvent: nil,
+			expected:    nil,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			pod := newPod(nil)
+			pod.SetAnnotations(tc.beforeEvent)
+
+			ea := NewEvictionAnnotator()
+			ea.now = func() time.Time { return now }
+			require.NoError(t, ea.Handle(
+				context.Background(),
+				&v1.ContainerStatus{
+					Name:              pod.Spec.Containers[0].Name,
+					PodName:           pod.Name,
+					PodNamespace:      pod.Namespace,
+					Phase:             v1.ContainerPhase_SCALED_DOWN,
+					EvictionRequested: tc.evictionRequested,
+				},
+				pod,
+			))
+
+			assert.Equal(t, tc.expected, pod.GetAnnotations())
+		})
+	}
+}
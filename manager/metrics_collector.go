@@ -12,7 +12,6 @@ import (
 
 	"github.com/containerd/ttrpc"
 	v1 "github.com/ctrox/zeropod/api/shim/v1"
-	"github.com/ctrox/zeropod/runc/task"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 	"golang.org/x/exp/maps"
@@ -25,15 +24,15 @@ func Handler(w http.ResponseWriter, req *http.Request) {
 // fetchMetricsAndMerge gets metrics from each socket, merges them together
 // and writes them to w.
 func fetchMetricsAndMerge(w io.Writer) {
-	socks, err := os.ReadDir(task.ShimSocketPath)
+	socks, err := os.ReadDir(v1.ShimSocketPath)
 	if err != nil {
-		slog.Error("error listing file in shim socket path", "path", task.ShimSocketPath, "err", err)
+		slog.Error("error listing file in shim socket path", "path", v1.ShimSocketPath, "err", err)
 		return
 	}
 
 	mfs := map[string]*dto.MetricFamily{}
 	for _, sock := range socks {
-		sockName := filepath.Join(task.ShimSocketPath, sock.Name())
+		sockName := filepath.Join(v1.ShimSocketPath, sock.Name())
 		slog.Debug("getting metrics", "name", sockName)
 
 		shimMetrics, err := getMetricsOverTTRPC(context.Background(), sockName)
@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	RestoreHealthyCondition corev1.PodConditionType = "ZeropodRestoreHealthy"
+	ReasonRestoreSucceeded                          = "RestoreSucceeded"
+	ReasonRestoreFailed                             = "RestoreFailed"
+)
+
+// RestoreConditioner reflects the result of the last restore of a container
+// in the ZeropodRestoreHealthy condition of its pod, so controllers and
+// alerts can act on failing restores without parsing events. A pod has a
+// single condition, so with multiple zeropod containers it reflects the
+// container that has been restored last.
+type RestoreConditioner struct {
+	log *slog.Logger
+	now func() time.Time
+}
+
+func NewRestoreConditioner() *RestoreConditioner {
+	log := slog.With("component", "restoreconditioner")
+	log.Info("init")
+	return &RestoreConditioner{log: log, now: time.Now}
+}
+
+func (rc *RestoreConditioner) Handle(ctx context.Context, status *v1.ContainerStatus, pod *corev1.Pod) error {
+	if status.LastRestoreAt == nil {
+		// the container has never been restored.
+		return nil
+	}
+
+	// the API stores times with a precision of seconds, truncating it
+	// avoids updates of the pod status if nothing has changed.
+	restoredAt := metav1.NewTime(status.LastRestoreAt.AsTime().Truncate(time.Second))
+	condition := corev1.PodCondition{
+		Type:          RestoreHealthyCondition,
+		Status:        corev1.ConditionTrue,
+		Reason:        ReasonRestoreSucceeded,
+		Message:       fmt.Sprintf("last restore of container %s succeeded", status.Name),
+		LastProbeTime: restoredAt,
+	}
+	if status.LastRestoreError != "" {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = ReasonRestoreFailed
+		condition.Message = fmt.Sprintf("last restore of container %s failed: %s", status.Name, status.LastRestoreError)
+	}
+
+	if rc.setCondition(pod, condition) {
+		rc.log.Info("restore condition changed", "container", status.Name, "pod", status.PodName,
+			"namespace", status.PodNamespace, "status", condition.Status)
+	}
+	return nil
+}
+
+// setCondition sets the condition on the pod and returns true if its status
+// changed. The transition time is kept as long as the status stays the same.
+func (rc *RestoreConditioner) setCondition(pod *corev1.Pod, condition corev1.PodCondition) bool {
+	for i, existing := range pod.Status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.NewTime(rc.now())
+		}
+		pod.Status.Conditions[i] = condition
+		return existing.Status != condition.Status
+	}
+
+	condition.LastTransitionTime = metav1.NewTime(rc.now())
+	pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	return true
+}
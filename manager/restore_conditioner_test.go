@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRestoreConditioner(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-time.Hour))
+
+	cases := map[string]struct {
+		lastRestoreError string
+		neverRestored    bool
+		beforeEvent      []corev1.PodCondition
+		expectedStatus   corev1.ConditionStatus
+		expectedReason   string
+		expectedTransit  metav1.Time
+	}{
+		"restore failed": {
+			lastRestoreError: "criu failed",
+			expectedStatus:   corev1.ConditionFalse,
+			expectedReason:   ReasonRestoreFailed,
+			expectedTransit:  metav1.NewTime(now),
+		},
+		"restore succeeded": {
+			expectedStatus:  corev1.ConditionTrue,
+			expectedReason:  ReasonRestoreSucceeded,
+			expectedTransit: metav1.NewTime(now),
+		},
+		"transition time is kept": {
+			lastRestoreError: "criu failed",
+			beforeEvent: []corev1.PodCondition{{
+				Type: RestoreHealthyCondition, Status: corev1.ConditionFalse, LastTransitionTime: earlier,
+			}},
+			expectedStatus:  corev1.ConditionFalse,
+			expectedReason:  ReasonRestoreFailed,
+			expectedTransit: earlier,
+		},
+		"recovered": {
+			beforeEvent: []corev1.PodCondition{{
+				Type: RestoreHealthyCondition, Status: corev1.ConditionFalse, LastTransitionTime: earlier,
+			}},
+			expectedStatus:  corev1.ConditionTrue,
+			expectedReason:  ReasonRestoreSucceeded,
+			expectedTransit: metav1.NewTime(now),
+		},
+		"never restored": {
+			neverRestored: true,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			pod := newPod(nil)
+			pod.Status.Conditions = tc.beforeEvent
+
+			status := &v1.ContainerStatus{
+				Name:             pod.Spec.Containers[0].Name,
+				PodName:          pod.Name,
+				PodNamespace:     pod.Namespace,
+				LastRestoreAt:    timestamppb.New(now),
+				LastRestoreError: tc.lastRestoreError,
+			}
+			if tc.neverRestored {
+				status.LastRestoreAt = nil
+			}
+
+			rc := NewRestoreConditioner()
+			rc.now = func() time.Time { return now }
+			require.NoError(t, rc.Handle(context.Background(), status, pod))
+
+			if tc.neverRestored {
+				assert.Empty(t, pod.Status.Conditions)
+				return
+			}
+			require.Len(t, pod.Status.Conditions, 1)
+			condition := pod.Status.Conditions[0]
+			assert.Equal(t, tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			assert.Contains(t, condition.Message, tc.lastRestoreError)
+			assert.True(t, tc.expectedTransit.Equal(&condition.LastTransitionTime))
+		})
+	}
+}
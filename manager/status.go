@@ -12,14 +12,15 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/containerd/ttrpc"
 	v1 "github.com/ctrox/zeropod/api/shim/v1"
-	"github.com/ctrox/zeropod/runc/task"
 	"github.com/fsnotify/fsnotify"
 	"google.golang.org/protobuf/types/known/emptypb"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -38,7 +39,8 @@ var connectBackoff = wait.Backoff{
 type PodHandler interface {
 	// Handle a status update with the associated pod. Changes made to
 	// the pod object will be applied after all handlers have been called.
-	// Pod status updates are ignored and won't be applied.
+	// Pod status updates are ignored and won't be applied, except for
+	// changes to the pod conditions.
 	Handle(context.Context, *v1.ContainerStatus, *corev1.Pod) error
 }
 
@@ -59,13 +61,13 @@ func StartSubscribers(ctx context.Context, podHandlers ...PodHandler) error {
 		return fmt.Errorf("creating client: %w", err)
 	}
 
-	if _, err := os.Stat(task.ShimSocketPath); errors.Is(err, os.ErrNotExist) {
-		if err := os.Mkdir(task.ShimSocketPath, os.ModePerm); err != nil {
+	if _, err := os.Stat(v1.ShimSocketPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.Mkdir(v1.ShimSocketPath, os.ModePerm); err != nil {
 			return err
 		}
 	}
 
-	socks, err := os.ReadDir(task.ShimSocketPath)
+	socks, err := os.ReadDir(v1.ShimSocketPath)
 	if err != nil {
 		return fmt.Errorf("error listing file in shim socket path: %s", err)
 	}
@@ -73,7 +75,7 @@ func StartSubscribers(ctx context.Context, podHandlers ...PodHandler) error {
 	for _, sock := range socks {
 		sock := sock
 		go func() {
-			if err := subscribe(ctx, filepath.Join(task.ShimSocketPath, sock.Name()), kube, podHandlers); err != nil {
+			if err := subscribe(ctx, filepath.Join(v1.ShimSocketPath, sock.Name()), kube, podHandlers); err != nil {
 				slog.Error("error subscribing", "sock", sock.Name(), "err", err)
 			}
 		}()
@@ -168,11 +170,15 @@ func (s *subscriber) handlePod(ctx context.Context, status *v1.ContainerStatus)
 		return fmt.Errorf("getting pod: %w", err)
 	}
 
+	conditions := slices.Clone(pod.Status.Conditions)
 	for _, p := range s.podHandlers {
 		if err := p.Handle(ctx, status, pod); err != nil {
 			return err
 		}
 	}
+	// the update overwrites the status of the pod object with the one of
+	// the API server.
+	newConditions := pod.Status.Conditions
 
 	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		return s.kube.Update(ctx, pod)
@@ -182,6 +188,16 @@ func (s *subscriber) handlePod(ctx context.Context, status *v1.ContainerStatus)
 		}
 		return err
 	}
+
+	if equality.Semantic.DeepEqual(conditions, newConditions) {
+		return nil
+	}
+	pod.Status.Conditions = newConditions
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return s.kube.Status().Update(ctx, pod)
+	}); err != nil {
+		return fmt.Errorf("updating pod conditions: %w", err)
+	}
 	return nil
 }
 
@@ -192,7 +208,7 @@ func watchForShims(ctx context.Context, kube client.Client, podHandlers []PodHan
 	}
 	defer watcher.Close()
 
-	if err := watcher.Add(task.ShimSocketPath); err != nil {
+	if err := watcher.Add(v1.ShimSocketPath); err != nil {
 		return err
 	}
 
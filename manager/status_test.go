@@ -22,6 +22,15 @@ func (fh *annotationHandler) Handle(ctx context.Context, status *v1.ContainerSta
 	return nil
 }
 
+type conditionHandler struct {
+	condition corev1.PodCondition
+}
+
+func (ch *conditionHandler) Handle(ctx context.Context, status *v1.ContainerStatus, pod *corev1.Pod) error {
+	pod.Status.Conditions = append(pod.Status.Conditions, ch.condition)
+	return nil
+}
+
 func TestOnStatus(t *testing.T) {
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 
@@ -33,6 +42,7 @@ func TestOnStatus(t *testing.T) {
 		statusEventPhase v1.ContainerPhase
 		beforeEvent      map[string]string
 		expected         map[string]string
+		expectConditions []corev1.PodCondition
 		podHandlers      []PodHandler
 	}{
 		"pod is updated when we have a pod handler": {
@@ -41,12 +51,19 @@ func TestOnStatus(t *testing.T) {
 			beforeEvent:      map[string]string{"some": "annotation"},
 			expected:         map[string]string{"new": "annotation"},
 		},
+		"pod conditions are updated": {
+			statusEventPhase: v1.ContainerPhase_RUNNING,
+			podHandlers: []PodHandler{&conditionHandler{condition: corev1.PodCondition{
+				Type: RestoreHealthyCondition, Status: corev1.ConditionTrue,
+			}}},
+			expectConditions: []corev1.PodCondition{{Type: RestoreHealthyCondition, Status: corev1.ConditionTrue}},
+		},
 	}
 
 	for name, tc := range cases {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
-			client := fake.NewClientBuilder().WithScheme(scheme).Build()
+			client := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&corev1.Pod{}).Build()
 			pod := newPod(nil)
 			pod.SetAnnotations(tc.beforeEvent)
 
@@ -72,6 +89,7 @@ func TestOnStatus(t *testing.T) {
 			}
 
 			assert.Equal(t, pod.GetAnnotations(), tc.expected)
+			assert.Equal(t, tc.expectConditions, pod.Status.Conditions)
 		})
 	}
 }
@@ -0,0 +1,111 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/pkg/oom"
+	"github.com/containerd/containerd/runtime/v2/shim"
+	"github.com/containerd/log"
+)
+
+// oomPublisher wraps the shim publisher that is passed to the OOM watcher.
+// When a container is checkpointed, its cgroup is removed and recreated on
+// restore. Depending on the cgroup version, the OOM watcher might still
+// notice the removal of the old cgroup after the container has already been
+// scaled down (or even restored), which results in a bogus OOM event. The
+// oomPublisher discards OOM events of subscriptions that have been removed
+// from the oomWatcher and of containers for which ignore returns true.
+type oomPublisher struct {
+	shim.Publisher
+	watcher *oomWatcher
+	ignore  func(id string) bool
+}
+
+func (p *oomPublisher) Publish(ctx context.Context, topic string, event events.Event) error {
+	oom, ok := event.(*eventstypes.TaskOOM)
+	if !ok {
+		return p.Publisher.Publish(ctx, topic, event)
+	}
+
+	id, subscribed := p.watcher.containerID(oom.ContainerID)
+	if !subscribed {
+		log.G(ctx).Infof("discarding OOM event of removed cgroup of container: %s", id)
+		return nil
+	}
+	if p.ignore(id) {
+		log.G(ctx).Infof("discarding OOM event of scaled down container: %s", id)
+		return nil
+	}
+
+	return p.Publisher.Publish(ctx, topic, &eventstypes.TaskOOM{ContainerID: id})
+}
+
+// oomWatcher wraps the OOM watcher of containerd, which has no way to stop
+// watching a cgroup. Every cgroup is added to it under a key that is unique
+// to the subscription, so events of the cgroup a container had before it has
+// been checkpointed can be told apart from the cgroup it has after the
+// restore.
+type oomWatcher struct {
+	oom.Watcher
+
+	mu            sync.Mutex
+	next          uint64
+	subscriptions map[string]oomSubscription
+}
+
+type oomSubscription struct {
+	key    string
+	cgroup interface{}
+}
+
+func newOOMWatcher() *oomWatcher {
+	return &oomWatcher{subscriptions: make(map[string]oomSubscription)}
+}
+
+// Add watches the cgroup of the container with the supplied id. It replaces
+// any previous subscription of the container.
+func (w *oomWatcher) Add(id string, cg interface{}) error {
+	w.mu.Lock()
+	w.next++
+	key := fmt.Sprintf("%s/%d", id, w.next)
+	w.mu.Unlock()
+
+	if err := w.Watcher.Add(key, cg); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscriptions[id] = oomSubscription{key: key, cgroup: cg}
+	return nil
+}
+
+// Remove removes the subscription of the container with the supplied id if
+// it's watching cg. The subscription of a restored container is kept, even
+// if the exit of its checkpointed process is only processed afterwards.
+func (w *oomWatcher) Remove(id string, cg interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sub, ok := w.subscriptions[id]; ok && sub.cgroup == cg {
+		delete(w.subscriptions, id)
+	}
+}
+
+// containerID returns the id of the container that the subscription key
+// belongs to and whether the subscription is still current.
+func (w *oomWatcher) containerID(key string) (string, bool) {
+	id := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		id = key[:i]
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	sub, ok := w.subscriptions[id]
+	return id, ok && sub.key == key
+}
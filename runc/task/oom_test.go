@@ -0,0 +1,152 @@
+package task
+
+import (
+	"context"
+	"testing"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/pkg/process"
+	"github.com/containerd/containerd/pkg/stdio"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/ctrox/zeropod/zeropod"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePublisher struct {
+	published []events.Event
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, topic string, event events.Event) error {
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	return nil
+}
+
+func TestOOMPublisher(t *testing.T) {
+	scaledDown := map[string]bool{"scaled-down": true, "running": false}
+
+	cases := map[string]struct {
+		topic     string
+		event     events.Event
+		published bool
+	}{
+		"oom of running container is published": {
+			topic:     runtime.TaskOOMEventTopic,
+			event:     &eventstypes.TaskOOM{ContainerID: "running"},
+			published: true,
+		},
+		"oom of scaled down container is discarded": {
+			topic:     runtime.TaskOOMEventTopic,
+			event:     &eventstypes.TaskOOM{ContainerID: "scaled-down"},
+			published: false,
+		},
+		"oom of unknown container is published": {
+			topic:     runtime.TaskOOMEventTopic,
+			event:     &eventstypes.TaskOOM{ContainerID: "unknown"},
+			published: true,
+		},
+		"other events of scaled down container are published": {
+			topic:     runtime.TaskExitEventTopic,
+			event:     &eventstypes.TaskExit{ContainerID: "scaled-down"},
+			published: true,
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			fp := &fakePublisher{}
+			watcher := newOOMWatcher()
+			watcher.Watcher = &fakeOOMWatcher{added: map[string]interface{}{}}
+			p := &oomPublisher{Publisher: fp, watcher: watcher, ignore: func(id string) bool {
+				return scaledDown[id]
+			}}
+
+			event := tc.event
+			if oom, ok := tc.event.(*eventstypes.TaskOOM); ok {
+				require.NoError(t, watcher.Add(oom.ContainerID, nil))
+				event = &eventstypes.TaskOOM{ContainerID: watcher.subscriptions[oom.ContainerID].key}
+			}
+			assert.NoError(t, p.Publish(context.Background(), tc.topic, event))
+			if tc.published {
+				assert.Equal(t, []events.Event{tc.event}, fp.published)
+			} else {
+				assert.Empty(t, fp.published)
+			}
+		})
+	}
+}
+
+type fakeOOMWatcher struct {
+	added map[string]interface{}
+}
+
+func (f *fakeOOMWatcher) Close() error            { return nil }
+func (f *fakeOOMWatcher) Run(ctx context.Context) {}
+func (f *fakeOOMWatcher) Add(id string, cg interface{}) error {
+	f.added[id] = cg
+	return nil
+}
+
+type fakeProcess struct {
+	process.Process
+	pid     int
+	signals []uint32
+}
+
+func (p *fakeProcess) Pid() int { return p.pid }
+
+func (p *fakeProcess) Stdio() stdio.Stdio { return stdio.Stdio{} }
+
+func (p *fakeProcess) Kill(ctx context.Context, signal uint32, all bool) error {
+	p.signals = append(p.signals, signal)
+	return nil
+}
+
+func TestOOMSubscriptionAfterRestore(t *testing.T) {
+	fw := &fakeOOMWatcher{added: map[string]interface{}{}}
+	watcher := newOOMWatcher()
+	watcher.Watcher = fw
+	fp := &fakePublisher{}
+	publisher := &oomPublisher{Publisher: fp, watcher: watcher, ignore: func(string) bool { return false }}
+
+	zeropodContainer := &zeropod.Container{}
+	w := &wrapper{
+		service: &service{
+			context:    context.Background(),
+			containers: map[string]*runc.Container{},
+			ep:         watcher,
+		},
+		zeropodContainers: map[string]*zeropod.Container{"foo": zeropodContainer},
+		oomWatcher:        watcher,
+	}
+
+	// the cgroup of the container is added on start.
+	require.NoError(t, w.ep.Add("foo", nil))
+	require.Len(t, fw.added, 1)
+	oldKey := watcher.subscriptions["foo"].key
+
+	// the process exits as it has been checkpointed.
+	zeropodContainer.AddCheckpointedPID(1)
+	cp := containerProcess{Container: &runc.Container{ID: "foo"}, Process: &fakeProcess{pid: 1}}
+	assert.True(t, w.preventExit(cp))
+	assert.Empty(t, watcher.subscriptions, "subscription of the checkpointed container should be removed")
+
+	w.postRestore(&runc.Container{ID: "foo"}, nil)
+	require.Len(t, fw.added, 2)
+	newKey := watcher.subscriptions["foo"].key
+	assert.NotEqual(t, oldKey, newKey, "restored container should be added with a new subscription")
+
+	ctx := context.Background()
+	require.NoError(t, publisher.Publish(ctx, runtime.TaskOOMEventTopic, &eventstypes.TaskOOM{ContainerID: oldKey}))
+	assert.Empty(t, fp.published, "OOM event of the old cgroup should be discarded")
+
+	require.NoError(t, publisher.Publish(ctx, runtime.TaskOOMEventTopic, &eventstypes.TaskOOM{ContainerID: newKey}))
+	assert.Equal(t, []events.Event{&eventstypes.TaskOOM{ContainerID: "foo"}}, fp.published)
+}
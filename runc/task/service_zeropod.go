@@ -2,13 +2,17 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path"
+	"strconv"
 	"sync"
 	"time"
 
 	v1 "github.com/ctrox/zeropod/api/shim/v1"
 	"github.com/ctrox/zeropod/zeropod"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/containerd/cgroups"
@@ -32,51 +36,125 @@ var (
 	_ = (taskAPI.TaskService)(&wrapper{})
 )
 
-func NewZeropodService(ctx context.Context, publisher shim.Publisher, sd shutdown.Service) (taskAPI.TaskService, error) {
-	var (
-		ep  oom.Watcher
-		err error
-	)
-	if cgroups.Mode() == cgroups.Unified {
-		ep, err = oomv2.New(publisher)
-	} else {
-		ep, err = oomv1.New(publisher)
+const (
+	// EnvEventsBufferSize can be set on the shim to configure the size of the
+	// event queues of the shim.
+	EnvEventsBufferSize     = "ZEROPOD_EVENTS_BUFFER_SIZE"
+	defaultEventsBufferSize = 128
+	// EnvExitsBufferSize can be set on the shim to configure the size of the
+	// queue of process exits. By default, the buffer of the reaper
+	// subscription is used.
+	EnvExitsBufferSize     = "ZEROPOD_EXITS_BUFFER_SIZE"
+	defaultExitsBufferSize = 32
+	// restoreFailedExitStatus is reported as the exit status of containers
+	// that have been marked as failed as they could not be restored.
+	restoreFailedExitStatus = 1
+)
+
+// eventsBufferSize returns the configured size of the event queues or the
+// default if it's not set or invalid.
+func eventsBufferSize(ctx context.Context) int {
+	return bufferSizeFromEnv(ctx, EnvEventsBufferSize, defaultEventsBufferSize)
+}
+
+// exitsBufferSize returns the configured size of the exit queue or the
+// default if it's not set or invalid.
+func exitsBufferSize(ctx context.Context) int {
+	return bufferSizeFromEnv(ctx, EnvExitsBufferSize, defaultExitsBufferSize)
+}
+
+func bufferSizeFromEnv(ctx context.Context, env string, def int) int {
+	value := os.Getenv(env)
+	if value == "" {
+		return def
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		log.G(ctx).Warnf("invalid %s %q, using default of %d", env, value, def)
+		return def
+	}
+
+	return size
+}
+
+// subscribeExits subscribes to the exits of the reaper. The buffer of the
+// subscription has a fixed size, so for larger sizes the exits are relayed
+// to a channel with the requested size. While a subscription is full, the
+// reaper waits for up to a second per exit before reaping further processes.
+func subscribeExits(size int) chan runcC.Exit {
+	sub := reaper.Default.Subscribe()
+	if size <= defaultExitsBufferSize {
+		return sub
 	}
+
+	ec := make(chan runcC.Exit, size)
+	go func() {
+		defer close(ec)
+		for e := range sub {
+			ec <- e
+		}
+	}()
+	return ec
+}
+
+func NewZeropodService(ctx context.Context, publisher shim.Publisher, sd shutdown.Service) (taskAPI.TaskService, error) {
+	address, err := shim.ReadAddress("address")
 	if err != nil {
 		return nil, err
 	}
-	go ep.Run(ctx)
+
+	bufferSize := eventsBufferSize(ctx)
 	s := &service{
 		context:         ctx,
-		events:          make(chan interface{}, 128),
-		ec:              reaper.Default.Subscribe(),
-		ep:              ep,
+		events:          make(chan interface{}, bufferSize),
+		ec:              subscribeExits(exitsBufferSize(ctx)),
 		shutdown:        sd,
 		containers:      make(map[string]*runc.Container),
 		running:         make(map[int][]containerProcess),
 		exitSubscribers: make(map[*map[int][]runcC.Exit]struct{}),
 	}
+	lockWait := zeropod.NewCheckpointRestoreLockWait(path.Base(address))
 	w := &wrapper{
 		service:           s,
-		checkpointRestore: sync.Mutex{},
+		checkpointRestore: zeropod.NewTimedMutex(lockWait),
+		lockWait:          lockWait,
+		exitLag:           zeropod.NewExitProcessingLag(path.Base(address)),
+		sandboxes:         make(map[string]string),
 		zeropodContainers: make(map[string]*zeropod.Container),
-		zeropodEvents:     make(chan *v1.ContainerStatus, 128),
+		zeropodEvents:     make(chan *v1.ContainerStatus, bufferSize),
+	}
+
+	var ep oom.Watcher
+	oomWatcher := newOOMWatcher()
+	oomPublisher := &oomPublisher{Publisher: publisher, watcher: oomWatcher, ignore: w.scaledDown}
+	if cgroups.Mode() == cgroups.Unified {
+		ep, err = oomv2.New(oomPublisher)
+	} else {
+		ep, err = oomv1.New(oomPublisher)
+	}
+	if err != nil {
+		return nil, err
 	}
+	oomWatcher.Watcher = ep
+	go ep.Run(ctx)
+	s.ep = oomWatcher
+	w.oomWatcher = oomWatcher
+
 	go w.processExits()
 	runcC.Monitor = reaper.Default
 	if err := w.initPlatform(); err != nil {
 		return nil, fmt.Errorf("failed to initialized platform behavior: %w", err)
 	}
 	go w.forward(ctx, publisher)
+	go zeropod.WatchKillSwitch(ctx, func(disabled bool) {
+		w.setNodeScalingDisabled(ctx, disabled)
+	})
 	sd.RegisterCallback(func(context.Context) error {
 		close(w.events)
 		return nil
 	})
 
-	address, err := shim.ReadAddress("address")
-	if err != nil {
-		return nil, err
-	}
 	sd.RegisterCallback(func(context.Context) error {
 		if err := shim.RemoveSocket(shimSocketAddress(address)); err != nil {
 			log.G(ctx).Errorf("removing zeropod socket: %s", err)
@@ -84,7 +162,7 @@ func NewZeropodService(ctx context.Context, publisher shim.Publisher, sd shutdow
 		return shim.RemoveSocket(address)
 	})
 
-	go startShimServer(ctx, address, w.zeropodEvents)
+	go startShimServer(ctx, address, w)
 
 	return w, nil
 }
@@ -93,9 +171,14 @@ type wrapper struct {
 	*service
 
 	mut               sync.Mutex
-	checkpointRestore sync.Mutex
+	checkpointRestore *zeropod.TimedMutex
+	lockWait          prometheus.Histogram
+	exitLag           prometheus.Histogram
+	// sandboxes maps pod UIDs to the id of their sandbox container.
+	sandboxes         map[string]string
 	zeropodContainers map[string]*zeropod.Container
 	zeropodEvents     chan *v1.ContainerStatus
+	oomWatcher        *oomWatcher
 }
 
 func (w *wrapper) RegisterTTRPC(server *ttrpc.Server) error {
@@ -103,14 +186,17 @@ func (w *wrapper) RegisterTTRPC(server *ttrpc.Server) error {
 	return nil
 }
 
+func (w *wrapper) Create(ctx context.Context, r *taskAPI.CreateTaskRequest) (*taskAPI.CreateTaskResponse, error) {
+	if err := zeropod.PrepareTimeNamespace(ctx, r.Bundle); err != nil {
+		return nil, fmt.Errorf("preparing time namespace: %w", err)
+	}
+
+	return w.service.Create(ctx, r)
+}
+
 func (w *wrapper) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.StartResponse, error) {
 	log.G(ctx).Infof("start called in zeropod service %s, %s", r.ID, r.ExecID)
 
-	resp, err := w.service.Start(ctx, r)
-	if err != nil {
-		return nil, err
-	}
-
 	container, err := w.getContainer(r.ID)
 	if err != nil {
 		return nil, err
@@ -121,7 +207,22 @@ func (w *wrapper) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.
 		return nil, err
 	}
 
+	// the config is parsed before starting the process so a zeropod
+	// container with invalid annotations does not start at all. The error is
+	// passed on to the kubelet, which shows it as an event of the pod. The
+	// sandbox and all other containers of the pod are not managed by zeropod
+	// and are started regardless.
 	cfg, err := zeropod.NewConfig(ctx, spec)
+	if err != nil {
+		if len(r.ExecID) != 0 || !zeropod.IsZeropodSpec(spec) {
+			log.G(ctx).Warnf("ignoring invalid zeropod config of container %s: %s", r.ID, err)
+			return w.service.Start(ctx, r)
+		}
+		log.G(ctx).Errorf("invalid zeropod config: %s", err)
+		return nil, errdefs.ToGRPCf(errdefs.ErrInvalidArgument, "invalid zeropod config: %s", err)
+	}
+
+	resp, err := w.service.Start(ctx, r)
 	if err != nil {
 		return nil, err
 	}
@@ -132,6 +233,9 @@ func (w *wrapper) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.
 	// if we have a sandbox container, an exec ID is set or the container does
 	// not match the configured one(s) we should not do anything further with
 	// the container.
+	if cfg.ContainerType == annotations.ContainerTypeSandbox && len(r.ExecID) == 0 {
+		w.sandboxes[cfg.PodUID] = r.ID
+	}
 	if cfg.ContainerType == annotations.ContainerTypeSandbox ||
 		len(r.ExecID) != 0 ||
 		!cfg.IsZeropodContainer() {
@@ -139,9 +243,34 @@ func (w *wrapper) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.
 		return resp, nil
 	}
 
+	if _, err := w.addZeropodContainer(ctx, r.ID, cfg, container); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// addZeropodContainer creates the zeropod container for the started
+// container with the supplied id and schedules its scale down. If a zeropod
+// container already exists for the id (e.g. as containerd retried the
+// start), the existing one is returned instead of creating a duplicate. It
+// needs to be called with w.mut held.
+func (w *wrapper) addZeropodContainer(ctx context.Context, id string, cfg *zeropod.Config, container *runc.Container) (*zeropod.Container, error) {
+	if existing, ok := w.zeropodContainers[id]; ok {
+		log.G(ctx).Infof("zeropod container %s already exists, not creating it again", id)
+		return existing, nil
+	}
+
+	if cfg.ContainerName == "" {
+		// containers that have not been created through CRI don't have a
+		// name, the id is used instead so they can be told apart in logs
+		// and metrics.
+		cfg.ContainerName = id
+	}
+
 	log.G(ctx).Infof("creating zeropod container: %s", cfg.ContainerName)
 
-	zeropodContainer, err := zeropod.New(w.context, cfg, &w.checkpointRestore, container, w.platform, w.zeropodEvents)
+	zeropodContainer, err := zeropod.New(w.context, cfg, w.checkpointRestore, container, w.platform, w.zeropodEvents)
 	if err != nil {
 		return nil, fmt.Errorf("error creating scaled container: %w", err)
 	}
@@ -154,7 +283,23 @@ func (w *wrapper) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.
 		w.postRestore(c, handleStarted)
 	})
 
-	w.zeropodContainers[r.ID] = zeropodContainer
+	zeropodContainer.RegisterExists(func() bool {
+		return w.containerExists(id)
+	})
+
+	zeropodContainer.RegisterRestoreSiblings(func(ctx context.Context) {
+		w.restoreSiblings(ctx, zeropodContainer)
+	})
+
+	zeropodContainer.RegisterReclaimSandbox(func(ctx context.Context) {
+		w.reclaimSandbox(ctx, cfg.PodUID, zeropodContainer)
+	})
+
+	zeropodContainer.RegisterFail(func(ctx context.Context) {
+		w.failContainer(ctx, zeropodContainer)
+	})
+
+	w.zeropodContainers[id] = zeropodContainer
 
 	w.shutdown.RegisterCallback(func(ctx context.Context) error {
 		// stop server on shutdown
@@ -166,7 +311,7 @@ func (w *wrapper) Start(ctx context.Context, r *taskAPI.StartRequest) (*taskAPI.
 		return nil, err
 	}
 
-	return resp, err
+	return zeropodContainer, nil
 }
 
 func (w *wrapper) getZeropodContainer(id string) (*zeropod.Container, bool) {
@@ -176,6 +321,103 @@ func (w *wrapper) getZeropodContainer(id string) (*zeropod.Container, bool) {
 	return container, ok
 }
 
+// containerExists returns true if the container with the supplied id is still
+// known to zeropod and the task service.
+func (w *wrapper) containerExists(id string) bool {
+	if _, ok := w.getZeropodContainer(id); !ok {
+		return false
+	}
+	_, err := w.getContainer(id)
+	return err == nil
+}
+
+// restoreSiblings restores all scaled down zeropod containers that belong to
+// the same pod as the supplied container.
+func (w *wrapper) restoreSiblings(ctx context.Context, container *zeropod.Container) {
+	siblings := []*zeropod.Container{}
+	w.mut.Lock()
+	for _, zeropodContainer := range w.zeropodContainers {
+		if zeropodContainer.SiblingOf(container) && zeropodContainer.ScaledDown() {
+			siblings = append(siblings, zeropodContainer)
+		}
+	}
+	w.mut.Unlock()
+
+	for _, sibling := range siblings {
+		log.G(ctx).Infof("restoring sibling container %s of %s", sibling.ID(), container.ID())
+		if err := sibling.RestoreSibling(ctx); err != nil && !errors.Is(err, zeropod.ErrAlreadyRestored) {
+			log.G(ctx).Errorf("unable to restore sibling container %s: %s", sibling.ID(), err)
+		}
+	}
+}
+
+// setNodeScalingDisabled applies the state of the kill switch of the node to
+// all zeropod containers of the shim.
+func (w *wrapper) setNodeScalingDisabled(ctx context.Context, disabled bool) {
+	w.mut.Lock()
+	containers := make([]*zeropod.Container, 0, len(w.zeropodContainers))
+	for _, zeropodContainer := range w.zeropodContainers {
+		containers = append(containers, zeropodContainer)
+	}
+	w.mut.Unlock()
+
+	for _, zeropodContainer := range containers {
+		go zeropodContainer.SetNodeScalingDisabled(ctx, disabled)
+	}
+}
+
+// reclaimSandbox reclaims the memory of the sandbox container of the pod with
+// the supplied UID if all zeropod containers of the pod are scaled down.
+func (w *wrapper) reclaimSandbox(ctx context.Context, podUID string, container *zeropod.Container) {
+	w.mut.Lock()
+	sandboxID, ok := w.sandboxes[podUID]
+	for _, zeropodContainer := range w.zeropodContainers {
+		if zeropodContainer.SiblingOf(container) && !zeropodContainer.ScaledDown() {
+			ok = false
+		}
+	}
+	w.mut.Unlock()
+	if !ok {
+		return
+	}
+
+	// the sandbox might have been deleted in the meantime.
+	sandbox, err := w.getContainer(sandboxID)
+	if err != nil {
+		return
+	}
+	p, err := sandbox.Process("")
+	if err != nil {
+		log.G(ctx).Errorf("unable to get process of sandbox container %s: %s", sandboxID, err)
+		return
+	}
+
+	log.G(ctx).Infof("all zeropod containers of pod are scaled down, reclaiming memory of sandbox container %s", sandboxID)
+	zeropod.ReclaimSandboxMemory(ctx, p.Pid())
+}
+
+// failContainer reports the exit of the scaled down container to containerd,
+// so it's restarted by the kubelet.
+func (w *wrapper) failContainer(ctx context.Context, zeropodContainer *zeropod.Container) {
+	container, err := w.getContainer(zeropodContainer.ID())
+	if err != nil {
+		log.G(ctx).Errorf("unable to mark container %s as failed: %s", zeropodContainer.ID(), err)
+		return
+	}
+
+	p := zeropodContainer.InitialProcess()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handleProcessExit(runcC.Exit{Pid: p.Pid(), Status: restoreFailedExitStatus}, container, p)
+}
+
+// scaledDown returns true if the container with the supplied id is a zeropod
+// container that is currently scaled down.
+func (w *wrapper) scaledDown(id string) bool {
+	zeropodContainer, ok := w.getZeropodContainer(id)
+	return ok && zeropodContainer.ScaledDown()
+}
+
 func (w *wrapper) Exec(ctx context.Context, r *taskAPI.ExecProcessRequest) (*emptypb.Empty, error) {
 	zeropodContainer, ok := w.getZeropodContainer(r.ID)
 	if !ok {
@@ -183,6 +425,8 @@ func (w *wrapper) Exec(ctx context.Context, r *taskAPI.ExecProcessRequest) (*emp
 	}
 
 	zeropodContainer.CancelScaleDown()
+	zeropodContainer.AddPendingExec(r.ExecID)
+	defer zeropodContainer.RecordExec()
 
 	// restore it for exec in case we are scaled down
 	if zeropodContainer.ScaledDown() {
@@ -191,16 +435,29 @@ func (w *wrapper) Exec(ctx context.Context, r *taskAPI.ExecProcessRequest) (*emp
 
 		_, p, err := zeropodContainer.Restore(ctx)
 		if err != nil {
-			// restore failed, this is currently unrecoverable, so we shutdown
-			// our shim and let containerd recreate it.
-			log.G(ctx).Fatalf("error restoring container, exiting shim: %s", err)
-			os.Exit(1)
+			zeropodContainer.RemovePendingExec(r.ExecID)
+			zeropodContainer.RecordScaleEvent(v1.ScaleEventType_RESTORE_FAILED, zeropod.ScaleEventCauseExec, err.Error())
+			zeropodContainer.RestoreFailed(ctx, err)
+			return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "unable to restore container for exec: %s", err)
 		}
 
 		log.G(ctx).Printf("restored process for exec: %d in %s", p.Pid(), time.Since(beforeRestore))
+		zeropodContainer.RecordScaleEvent(v1.ScaleEventType_RESTORE, zeropod.ScaleEventCauseExec,
+			fmt.Sprintf("restored in %s", time.Since(beforeRestore)))
+		zeropodContainer.RestoreSiblings(ctx)
 	}
 
-	return w.service.Exec(ctx, r)
+	resp, err := w.service.Exec(ctx, r)
+	if err != nil {
+		// the exec has not been created, so there won't be a delete for it.
+		if zeropodContainer.RemovePendingExec(r.ExecID) == 0 {
+			if err := zeropodContainer.ScheduleScaleDownAfterExec(); err != nil {
+				log.G(ctx).Errorf("unable to schedule scale down: %s", err)
+			}
+		}
+		return nil, err
+	}
+	return resp, nil
 }
 
 func (w *wrapper) Pids(ctx context.Context, r *taskAPI.PidsRequest) (*taskAPI.PidsResponse, error) {
@@ -215,6 +472,20 @@ func (w *wrapper) Pids(ctx context.Context, r *taskAPI.PidsRequest) (*taskAPI.Pi
 	return w.service.Pids(ctx, r)
 }
 
+func (w *wrapper) CloseIO(ctx context.Context, r *taskAPI.CloseIORequest) (*emptypb.Empty, error) {
+	zeropodContainer, ok := w.getZeropodContainer(r.ID)
+	if ok && len(r.ExecID) == 0 && r.Stdin {
+		// the stdin of the initial process can't be reopened on restore.
+		zeropodContainer.SetStdinClosed()
+		if zeropodContainer.ScaledDown() {
+			// there is no process to close the stdin of.
+			return &emptypb.Empty{}, nil
+		}
+	}
+
+	return w.service.CloseIO(ctx, r)
+}
+
 func (w *wrapper) Delete(ctx context.Context, r *taskAPI.DeleteRequest) (*taskAPI.DeleteResponse, error) {
 	zeropodContainer, ok := w.getZeropodContainer(r.ID)
 	if !ok {
@@ -222,18 +493,42 @@ func (w *wrapper) Delete(ctx context.Context, r *taskAPI.DeleteRequest) (*taskAP
 	}
 
 	if len(r.ExecID) != 0 {
-		// on delete of an exec container we want to schedule scaling down again.
-		if err := zeropodContainer.ScheduleScaleDown(); err != nil {
+		zeropodContainer.RecordExec()
+		// on delete of the last running exec we want to schedule scaling
+		// down again. Overlapping execs would otherwise schedule it while
+		// another exec is still running.
+		if pending := zeropodContainer.RemovePendingExec(r.ExecID); pending > 0 {
+			log.G(ctx).Infof("%d execs still pending, not scheduling scale down", pending)
+			return w.service.Delete(ctx, r)
+		}
+		if err := zeropodContainer.ScheduleScaleDownAfterExec(); err != nil {
 			return nil, err
 		}
+		return w.service.Delete(ctx, r)
+	}
+
+	container, err := w.getContainer(r.ID)
+	if err != nil {
+		return nil, err
 	}
-	return w.service.Delete(ctx, r)
+	resp, err := w.service.Delete(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	w.oomWatcher.Remove(r.ID, container.Cgroup())
+
+	// the container is gone, make sure a pending scale down does not operate
+	// on it anymore.
+	zeropodContainer.CancelScaleDown()
+	w.mut.Lock()
+	delete(w.zeropodContainers, r.ID)
+	w.mut.Unlock()
+
+	return resp, nil
 }
 
 func (w *wrapper) Kill(ctx context.Context, r *taskAPI.KillRequest) (*emptypb.Empty, error) {
-	// our container might be just in the process of checkpoint/restore, so we
-	// ensure that has finished.
-	w.checkpointRestore.Lock()
+	w.lockForSignal(ctx, r)
 	defer w.checkpointRestore.Unlock()
 
 	zeropodContainer, ok := w.getZeropodContainer(r.ID)
@@ -241,6 +536,23 @@ func (w *wrapper) Kill(ctx context.Context, r *taskAPI.KillRequest) (*emptypb.Em
 		return w.service.Kill(ctx, r)
 	}
 
+	if len(r.ExecID) == 0 && zeropodContainer.WakeSignal(r.Signal) {
+		// the process handles wake signals without terminating, so the
+		// container keeps being scaled. It has already been restored if it
+		// was scaled down.
+		if zeropodContainer.ScaledDown() {
+			return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "container %s has not been restored for signal %d", r.ID, r.Signal)
+		}
+		log.G(ctx).Infof("delivering wake signal %d to container %s", r.Signal, r.ID)
+		if err := zeropodContainer.Process().Kill(ctx, r.Signal, r.All); err != nil {
+			return nil, errdefs.ToGRPC(err)
+		}
+		if err := zeropodContainer.ScheduleScaleDown(); err != nil {
+			return nil, err
+		}
+		return empty, nil
+	}
+
 	if len(r.ExecID) == 0 && zeropodContainer.ScaledDown() {
 		log.G(ctx).Infof("requested scaled down process %d to be killed", zeropodContainer.Process().Pid())
 		zeropodContainer.Process().SetExited(0)
@@ -264,8 +576,36 @@ func (w *wrapper) Kill(ctx context.Context, r *taskAPI.KillRequest) (*emptypb.Em
 	return w.service.Kill(ctx, r)
 }
 
+// lockForSignal acquires the checkpoint/restore lock to deliver the signal of
+// the kill request. Wake signals and graceful stop signals can be handled by
+// the process if it's restored first. This needs to happen before acquiring
+// the lock as the restore acquires it on its own. If the container has been
+// scaled down again in the meantime, it's restored once more so the signal is
+// not lost.
+func (w *wrapper) lockForSignal(ctx context.Context, r *taskAPI.KillRequest) {
+	for {
+		zeropodContainer, ok := w.getZeropodContainer(r.ID)
+		if !ok || len(r.ExecID) != 0 {
+			w.checkpointRestore.Lock()
+			return
+		}
+
+		err := zeropodContainer.RestoreForSignal(ctx, r.Signal)
+		if err != nil {
+			log.G(ctx).Errorf("unable to restore container for signal: %s", err)
+		}
+
+		w.checkpointRestore.Lock()
+		if err != nil || !zeropodContainer.ScaledDown() || !zeropodContainer.RestoresForSignal(r.Signal) {
+			return
+		}
+		w.checkpointRestore.Unlock()
+	}
+}
+
 func (w *wrapper) processExits() {
 	for e := range w.ec {
+		w.exitLag.Observe(time.Since(e.Timestamp).Seconds())
 		w.lifecycleMu.Lock()
 		cps := w.running[e.Pid]
 		w.lifecycleMu.Unlock()
@@ -311,12 +651,16 @@ func (w *wrapper) preventExit(cp containerProcess) bool {
 	if ok {
 		if zeropodContainer.ScaledDown() {
 			log.G(w.context).Infof("not setting exited because process has scaled down: %v", cp.Process.Pid())
+			// the cgroup of the process is removed with it, the restored
+			// process is added to the OOM watcher with its new cgroup.
+			w.oomWatcher.Remove(cp.Container.ID, cp.Container.Cgroup())
 			return true
 		}
 
 		if zeropodContainer.CheckpointedPID(cp.Process.Pid()) {
 			log.G(w.context).Infof("not setting exited because process has been checkpointed: %v", cp.Process.Pid())
 			zeropodContainer.DeleteCheckpointedPID(cp.Process.Pid())
+			w.oomWatcher.Remove(cp.Container.ID, cp.Container.Cgroup())
 			return true
 		}
 
@@ -340,15 +684,22 @@ func (w *wrapper) preRestore() zeropod.HandleStartedFunc {
 }
 
 // postRestore replaces the container in the task service. This is important
-// to call after restore since the container object will have changed.
-// Additionally, this also calls the passed in handleStarted to make sure we
-// monitor the process exits of the newly restored process.
+// to call after restore since the container object will have changed. It
+// also adds the restored cgroup to the OOM watcher. Additionally, this also
+// calls the passed in handleStarted to make sure we monitor the process exits
+// of the newly restored process.
 func (w *wrapper) postRestore(container *runc.Container, handleStarted zeropod.HandleStartedFunc) {
 	w.mu.Lock()
 	p, _ := container.Process("")
 	w.containers[container.ID] = container
 	w.mu.Unlock()
 
+	// the cgroup has been recreated during restore, so we need to add it to
+	// the OOM watcher again.
+	if err := w.ep.Add(container.ID, container.Cgroup()); err != nil {
+		log.G(w.context).WithError(err).Error("add cg to OOM monitor")
+	}
+
 	if handleStarted != nil {
 		handleStarted(container, p, false)
 	}
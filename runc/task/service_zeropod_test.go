@@ -0,0 +1,97 @@
+package task
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/ctrox/zeropod/zeropod"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerExists(t *testing.T) {
+	w := &wrapper{
+		service: &service{
+			containers: map[string]*runc.Container{
+				"exists":      {ID: "exists"},
+				"not-zeropod": {ID: "not-zeropod"},
+			},
+		},
+		zeropodContainers: map[string]*zeropod.Container{
+			"exists":          {},
+			"deleted-in-task": {},
+		},
+	}
+
+	assert.True(t, w.containerExists("exists"))
+	assert.False(t, w.containerExists("not-zeropod"))
+	assert.False(t, w.containerExists("deleted-in-task"))
+	assert.False(t, w.containerExists("unknown"))
+}
+
+func TestEventsBufferSize(t *testing.T) {
+	for value, expected := range map[string]int{
+		"":    defaultEventsBufferSize,
+		"512": 512,
+		"0":   defaultEventsBufferSize,
+		"foo": defaultEventsBufferSize,
+	} {
+		t.Setenv(EnvEventsBufferSize, value)
+		assert.Equal(t, expected, eventsBufferSize(context.Background()), "value %q", value)
+	}
+}
+
+func TestExitsBufferSize(t *testing.T) {
+	for value, expected := range map[string]int{
+		"":     defaultExitsBufferSize,
+		"1024": 1024,
+		"-1":   defaultExitsBufferSize,
+	} {
+		t.Setenv(EnvExitsBufferSize, value)
+		assert.Equal(t, expected, exitsBufferSize(context.Background()), "value %q", value)
+	}
+}
+
+func TestAddZeropodContainerExisting(t *testing.T) {
+	existing := &zeropod.Container{}
+	w := &wrapper{
+		zeropodContainers: map[string]*zeropod.Container{"foo": existing},
+	}
+
+	c, err := w.addZeropodContainer(context.Background(), "foo", &zeropod.Config{}, &runc.Container{ID: "foo"})
+	assert.NoError(t, err)
+	assert.Same(t, existing, c, "existing zeropod container should be returned")
+	assert.Len(t, w.zeropodContainers, 1)
+}
+
+func TestKillWakeSignal(t *testing.T) {
+	ctx := context.Background()
+	lock := zeropod.NewTimedMutex(prometheus.NewHistogram(prometheus.HistogramOpts{Name: "lock_wait"}))
+	restored := &fakeProcess{pid: 2}
+	cp := &zeropod.FakeCheckpointer{Container: &runc.Container{ID: "foo"}, Process: restored}
+	cfg := &zeropod.Config{
+		ScaleDownDuration: time.Minute,
+		Ports:             []uint16{80},
+		WakeSignals:       []syscall.Signal{syscall.SIGUSR1},
+	}
+	zeropodContainer := zeropod.NewFakeContainer(ctx, cfg, lock, &fakeProcess{pid: 1}, cp)
+	t.Cleanup(zeropodContainer.CancelScaleDown)
+	w := &wrapper{
+		checkpointRestore: lock,
+		zeropodContainers: map[string]*zeropod.Container{"foo": zeropodContainer},
+	}
+	require.NoError(t, zeropodContainer.ForceScaleDown(ctx))
+
+	_, err := w.Kill(ctx, &taskAPI.KillRequest{ID: "foo", Signal: uint32(syscall.SIGUSR1)})
+	require.NoError(t, err)
+	assert.False(t, zeropodContainer.ScaledDown(), "container should be restored for the wake signal")
+	assert.Equal(t, []uint32{uint32(syscall.SIGUSR1)}, restored.signals)
+
+	require.NoError(t, zeropodContainer.ForceScaleDown(ctx), "container should still be scaled after the wake signal")
+	assert.True(t, zeropodContainer.ScaledDown())
+}
@@ -2,11 +2,15 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/runtime/v2/shim"
 	"github.com/containerd/log"
 	"github.com/containerd/ttrpc"
@@ -15,13 +19,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-const ShimSocketPath = "/run/zeropod/s/"
-
 func shimSocketAddress(containerdSocket string) string {
-	return fmt.Sprintf("unix://%s.sock", filepath.Join(ShimSocketPath, path.Base(containerdSocket)))
+	return fmt.Sprintf("unix://%s.sock", filepath.Join(v1.ShimSocketPath, path.Base(containerdSocket)))
 }
 
-func startShimServer(ctx context.Context, id string, events chan *v1.ContainerStatus) {
+func startShimServer(ctx context.Context, id string, task *wrapper) {
 	socket := shimSocketAddress(id)
 	listener, err := shim.NewSocket(socket)
 	if err != nil {
@@ -59,7 +61,16 @@ func startShimServer(ctx context.Context, id string, events chan *v1.ContainerSt
 	}
 	defer s.Close()
 
-	v1.RegisterShimService(s, &shimService{metrics: zeropod.NewRegistry(), events: events})
+	metrics := zeropod.NewRegistry()
+	metrics.MustRegister(
+		zeropod.NewEventsQueueLength(path.Base(id), "task", func() int { return len(task.events) }),
+		zeropod.NewEventsQueueLength(path.Base(id), "status", func() int { return len(task.zeropodEvents) }),
+		zeropod.NewCheckpointsQueued(path.Base(id)),
+		zeropod.NewRestoreBudgetUtilization(path.Base(id)),
+		task.lockWait,
+		task.exitLag,
+	)
+	v1.RegisterShimService(s, &shimService{metrics: metrics, task: task, events: task.zeropodEvents})
 
 	defer func() {
 		s.Close()
@@ -74,10 +85,10 @@ func startShimServer(ctx context.Context, id string, events chan *v1.ContainerSt
 }
 
 // shimService is an extension to the shim task service to provide
-// zeropod-specific functions like metrics.
+// zeropod-specific functions like metrics and the introspection/admin API.
 type shimService struct {
 	metrics *prometheus.Registry
-	task    wrapper
+	task    *wrapper
 	events  chan *v1.ContainerStatus
 }
 
@@ -97,16 +108,154 @@ func (s *shimService) SubscribeStatus(ctx context.Context, _ *v1.SubscribeStatus
 
 // GetStatus returns the status of a zeropod container.
 func (s *shimService) GetStatus(ctx context.Context, req *v1.ContainerRequest) (*v1.ContainerStatus, error) {
-	container, ok := s.task.zeropodContainers[req.Id]
-	if !ok {
-		return nil, fmt.Errorf("could not find zeropod container with id: %s", req.Id)
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return container.Status(), nil
+}
+
+// ListContainers returns the status of all zeropod containers of the shim.
+func (s *shimService) ListContainers(ctx context.Context, _ *v1.ListContainersRequest) (*v1.ListContainersResponse, error) {
+	s.task.mut.Lock()
+	defer s.task.mut.Unlock()
+
+	resp := &v1.ListContainersResponse{}
+	for _, container := range s.task.zeropodContainers {
+		resp.Containers = append(resp.Containers, container.Status())
+	}
+	slices.SortFunc(resp.Containers, func(a, b *v1.ContainerStatus) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	return resp, nil
+}
+
+// ForceRestore restores a scaled down zeropod container.
+func (s *shimService) ForceRestore(ctx context.Context, req *v1.ContainerRequest) (*v1.ContainerStatus, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	log.G(ctx).Infof("force restoring container %s", req.Id)
+	if err := container.ForceRestore(ctx); err != nil {
+		if errors.Is(err, zeropod.ErrAlreadyRestored) {
+			return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "%s", err)
+		}
+		return nil, err
+	}
+
+	return container.Status(), nil
+}
+
+// ForceScaleDown scales down a running zeropod container.
+func (s *shimService) ForceScaleDown(ctx context.Context, req *v1.ContainerRequest) (*v1.ContainerStatus, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	log.G(ctx).Infof("force scaling down container %s", req.Id)
+	if err := container.ForceScaleDown(ctx); err != nil {
+		if errors.Is(err, zeropod.ErrAlreadyScaledDown) || errors.Is(err, zeropod.ErrPrivileged) {
+			return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "%s", err)
+		}
+		return nil, err
+	}
+
+	return container.Status(), nil
+}
+
+// SetScalingEnabled enables or disables automatic scaling of a zeropod
+// container.
+func (s *shimService) SetScalingEnabled(ctx context.Context, req *v1.SetScalingEnabledRequest) (*v1.ContainerStatus, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	log.G(ctx).Infof("setting scaling enabled of container %s to %t", req.Id, req.Enabled)
+	if err := container.SetScalingEnabled(req.Enabled); err != nil {
+		if errors.Is(err, zeropod.ErrPrivileged) {
+			return nil, errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "%s", err)
+		}
+		return nil, err
 	}
 
 	return container.Status(), nil
 }
 
+// GetCRIULogs returns the CRIU logs of the last dump and restore of a zeropod
+// container.
+func (s *shimService) GetCRIULogs(ctx context.Context, req *v1.ContainerRequest) (*v1.CRIULogs, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	dumpLog, restoreLog := container.CRIULogs()
+	return &v1.CRIULogs{Id: req.Id, DumpLog: dumpLog, RestoreLog: restoreLog}, nil
+}
+
+// GetScaleEvents returns the most recent scale events of a zeropod container.
+func (s *shimService) GetScaleEvents(ctx context.Context, req *v1.ContainerRequest) (*v1.ScaleEvents, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.ScaleEvents{Id: req.Id, Events: container.ScaleEvents()}, nil
+}
+
+func (s *shimService) getContainer(id string) (*zeropod.Container, error) {
+	container, ok := s.task.getZeropodContainer(id)
+	if !ok {
+		return nil, errdefs.ToGRPCf(errdefs.ErrNotFound, "could not find zeropod container with id: %s", id)
+	}
+	return container, nil
+}
+
 // Metrics returns metrics of the zeropod shim instance.
 func (s *shimService) Metrics(context.Context, *v1.MetricsRequest) (*v1.MetricsResponse, error) {
 	mfs, err := s.metrics.Gather()
 	return &v1.MetricsResponse{Metrics: mfs}, err
 }
+
+// ExportCheckpoint writes the checkpoint of a scaled down zeropod container
+// to an archive on the node.
+func (s *shimService) ExportCheckpoint(ctx context.Context, req *v1.CheckpointArchiveRequest) (*v1.ContainerStatus, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := container.ExportCheckpoint(ctx, req.Path); err != nil {
+		return nil, checkpointArchiveError(err)
+	}
+
+	return container.Status(), nil
+}
+
+// ImportCheckpoint replaces the checkpoint of a scaled down zeropod container
+// with an archive on the node that has been exported before.
+func (s *shimService) ImportCheckpoint(ctx context.Context, req *v1.CheckpointArchiveRequest) (*v1.ContainerStatus, error) {
+	container, err := s.getContainer(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := container.ImportCheckpoint(ctx, req.Path); err != nil {
+		return nil, checkpointArchiveError(err)
+	}
+
+	return container.Status(), nil
+}
+
+func checkpointArchiveError(err error) error {
+	if errors.Is(err, zeropod.ErrNotCheckpointed) || errors.Is(err, zeropod.ErrNotScaledDown) {
+		return errdefs.ToGRPCf(errdefs.ErrFailedPrecondition, "%s", err)
+	}
+	return err
+}
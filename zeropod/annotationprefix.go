@@ -0,0 +1,65 @@
+package zeropod
+
+import (
+	"os"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const (
+	// DefaultAnnotationPrefix is the prefix of all annotations configuring
+	// zeropod containers.
+	DefaultAnnotationPrefix = "zeropod.ctrox.dev/"
+
+	// EnvAnnotationPrefix can be set on the shim to use a different prefix
+	// than DefaultAnnotationPrefix for the annotations (e.g. for forks that
+	// use their own domain). Annotations with the default prefix are ignored
+	// if it's set.
+	EnvAnnotationPrefix = "ZEROPOD_ANNOTATION_PREFIX"
+)
+
+var annotationPrefix = annotationPrefixFromEnv()
+
+func annotationPrefixFromEnv() string {
+	value := os.Getenv(EnvAnnotationPrefix)
+	if value == "" {
+		return DefaultAnnotationPrefix
+	}
+
+	prefix := strings.TrimSuffix(value, "/")
+	if prefix == "" || strings.Contains(prefix, "/") {
+		log.L.Warnf("ignoring invalid %s %q, it needs to be a domain like %s", EnvAnnotationPrefix, value, DefaultAnnotationPrefix)
+		return DefaultAnnotationPrefix
+	}
+	return prefix + "/"
+}
+
+// withDefaultPrefix returns the annotations with the configured prefix
+// replaced by the default one, which is used to decode the annotations.
+func withDefaultPrefix(annotations map[string]string, prefix string) map[string]string {
+	if prefix == DefaultAnnotationPrefix {
+		return annotations
+	}
+
+	translated := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		if strings.HasPrefix(key, DefaultAnnotationPrefix) {
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			key = DefaultAnnotationPrefix + name
+		}
+		translated[key] = value
+	}
+	return translated
+}
+
+// AnnotationKey returns the supplied annotation key with the configured
+// prefix of the shim.
+func AnnotationKey(key string) string {
+	if name, ok := strings.CutPrefix(key, DefaultAnnotationPrefix); ok {
+		return annotationPrefix + name
+	}
+	return key
+}
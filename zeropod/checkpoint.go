@@ -5,18 +5,57 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/containerd/containerd/pkg/process"
 	runcC "github.com/containerd/go-runc"
 	"github.com/containerd/log"
 	"github.com/ctrox/zeropod/activator"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
 )
 
-const retryInterval = time.Second
+const (
+	retryInterval = time.Second
+	// EnvVerifyCheckpointCommand can be set to a command that is run to
+	// verify checkpoints if verification is enabled for a container. As the
+	// command runs on the host, it's configured on the shim (it's inherited
+	// from containerd) and not on the pod.
+	EnvVerifyCheckpointCommand = "ZEROPOD_VERIFY_CHECKPOINT_COMMAND"
+	verifyCheckpointTimeout    = 30 * time.Second
+)
+
+var (
+	errCheckpointVerification = errors.New("checkpoint verification failed")
+	// requiredCheckpointImages are contained in every complete checkpoint.
+	requiredCheckpointImages = []string{"inventory.img", "pstree.img"}
+)
+
+func (c *Container) scaleDown(ctx context.Context, cause string) error {
+	if c.cfg.ScaleDownStrategy == ScaleDownStrategyReclaim {
+		if err := c.reclaim(ctx); err != nil {
+			return err
+		}
+		c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN, cause, "reclaimed memory")
+		return nil
+	}
+
+	if !c.cfg.DisableCheckpointing {
+		// wait for a slot before anything is prepared, so the container
+		// keeps running as usual while the scale down is queued.
+		release, err := acquireCheckpointSlot(ctx)
+		if err != nil {
+			return fmt.Errorf("acquiring checkpoint slot: %w", err)
+		}
+		defer release()
+	}
+
+	if len(c.cfg.Ports) != 0 {
+		c.checkPortsListening(ctx)
+	}
 
-func (c *Container) scaleDown(ctx context.Context) error {
 	if err := c.startActivator(ctx); err != nil {
 		if errors.Is(err, errNoPortsDetected) {
 			log.G(ctx).Infof("no ports detected, rescheduling scale down in %s", retryInterval)
@@ -31,36 +70,128 @@ func (c *Container) scaleDown(ctx context.Context) error {
 		return err
 	}
 
-	if err := c.activator.Reset(); err != nil {
-		return err
+	if !c.cfg.DisableCheckpointing && c.cfg.SharedPIDNamespace() {
+		// CRIU restores the processes with their original pids, which might
+		// have been taken by processes of the other containers of the pod in
+		// the meantime, so the restore could fail at any time. The container
+		// is started from scratch on restore instead.
+		log.G(ctx).Infof("container shares the pid namespace of the pod, scaling down without checkpoint")
+		c.coldStart = true
+	} else if !c.cfg.DisableCheckpointing {
+		fds, err := uncheckpointableFDs(c.process.Pid())
+		if err != nil {
+			log.G(ctx).Warnf("unable to check open files of process before checkpointing: %s", err)
+		}
+		// a container that is scaled down without a checkpoint needs to be
+		// started from scratch on restore.
+		c.coldStart = len(fds) > 0 && c.cfg.Uncheckpointable == UncheckpointablePolicyColdStart
+		if c.coldStart {
+			log.G(ctx).Warnf("process has open files that CRIU can't checkpoint: %s, scaling down without checkpoint",
+				strings.Join(fds, ", "))
+		} else if len(fds) > 0 {
+			log.G(ctx).Errorf("process has open files that CRIU can't checkpoint: %s, keeping container running and rescheduling scale down",
+				strings.Join(fds, ", "))
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_FAILED, cause,
+				fmt.Sprintf("open files can't be checkpointed: %s", strings.Join(fds, ", ")))
+			return c.rollbackScaleDown(ctx)
+		}
 	}
 
+	c.saveCPUSet(ctx)
+	c.saveNUMAPlacement(ctx)
+	c.saveOOMScoreAdj(ctx)
+	c.saveMountsDigest(ctx)
+	c.savePIDNamespaceInit(ctx)
+	// the tracker does not know about the process once it's removed.
+	c.setLastActivity(c.LastActivity())
 	if err := c.tracker.RemovePid(uint32(c.process.Pid())); err != nil {
 		// key could not exist, just log the error for now
 		log.G(ctx).Errorf("unable to remove pid %d: %s", c.process.Pid(), err)
 	}
 
-	if c.cfg.DisableCheckpointing {
+	if c.cfg.DisableCheckpointing || c.coldStart {
 		if err := c.kill(ctx); err != nil {
 			return err
 		}
+		c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN, cause, "killed")
 		return nil
 	}
 
 	if err := c.checkpoint(ctx); err != nil {
+		if errors.Is(err, errCheckpointVerification) {
+			log.G(ctx).Errorf("%s, keeping container running and rescheduling scale down", err)
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_FAILED, cause, err.Error())
+			return c.rollbackScaleDown(ctx)
+		}
+		if errors.Is(err, errCheckpointDiskFull) {
+			log.G(ctx).Errorf("checkpoint failed as the %s, keeping container running and rescheduling scale down", err)
+			checkpointDiskFull.With(c.labels()).Inc()
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_FAILED, cause, err.Error())
+			return c.rollbackScaleDown(ctx)
+		}
 		return err
 	}
 
+	c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN, cause, "checkpointed")
 	return nil
 }
 
-func (c *Container) kill(ctx context.Context) error {
+// rollbackScaleDown reverts the preparations for the scale down of a
+// container whose process is still running.
+func (c *Container) rollbackScaleDown(ctx context.Context) error {
+	c.handBackConnections(ctx, c.process.Pid())
+	if err := c.disableRedirects(); err != nil {
+		return fmt.Errorf("could not disable redirects: %w", err)
+	}
+
+	if err := c.tracker.TrackPid(uint32(c.process.Pid())); err != nil {
+		log.G(ctx).Errorf("unable to track pid %d: %s", c.process.Pid(), err)
+	}
+
+	return c.ScheduleScaleDown()
+}
+
+// handOff enables the redirects of the activator so new connections reach the
+// activator instead of the process that is about to be stopped. Restores of
+// the container wait for the handoff lock, so connections arriving in the
+// meantime only restore the container once it has been scaled down instead
+// of connecting to the stopping process. The grace period gives the process
+// time to accept connections that reached it just before. It's waited for
+// before the checkpointRestore lock is taken, as that lock is shared by all
+// containers of the shim. Once no new connections reach the process, its
+// established connections are taken over if a connection handoff socket is
+// configured, which is done before taking the lock as well. handOff returns
+// with both locks held, they are released by the returned func.
+func (c *Container) handOff(ctx context.Context) (func(), error) {
+	c.handoffMu.Lock()
+	if err := c.activator.Reset(); err != nil {
+		c.handoffMu.Unlock()
+		return nil, err
+	}
+
+	if c.cfg.HandoffGracePeriod > 0 {
+		log.G(ctx).Infof("waiting %s for the process to accept pending connections", c.cfg.HandoffGracePeriod)
+		time.Sleep(c.cfg.HandoffGracePeriod)
+	}
+
+	c.takeConnections(ctx)
 	c.checkpointRestore.Lock()
-	defer c.checkpointRestore.Unlock()
-	log.G(ctx).Infof("checkpointing is disabled, scaling down by killing")
-	c.AddCheckpointedPID(c.Pid())
+	return func() {
+		c.checkpointRestore.Unlock()
+		c.handoffMu.Unlock()
+	}, nil
+}
+
+func (c *Container) kill(ctx context.Context) error {
+	unlock, err := c.handOff(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	log.G(ctx).Infof("scaling down by killing")
+	c.AddCheckpointedPID(c.process.Pid())
 
-	if err := c.process.Kill(ctx, 9, false); err != nil {
+	if err := c.checkpointer.Kill(ctx); err != nil {
 		return err
 	}
 	c.SetScaledDown(true)
@@ -68,16 +199,47 @@ func (c *Container) kill(ctx context.Context) error {
 }
 
 func (c *Container) checkpoint(ctx context.Context) error {
-	c.checkpointRestore.Lock()
-	defer c.checkpointRestore.Unlock()
+	unlock, err := c.handOff(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	pid := c.process.Pid()
+	c.AddCheckpointedPID(pid)
+	if err := c.checkpointer.Checkpoint(ctx); err != nil {
+		if errors.Is(err, errCheckpointVerification) || errors.Is(err, errCheckpointDiskFull) {
+			// the process is still running, so it should not be considered
+			// checkpointed anymore.
+			c.DeleteCheckpointedPID(pid)
+		}
+		return err
+	}
+
+	c.SetScaledDown(true)
+	return nil
+}
+
+// Checkpoint checkpoints the container using runc and CRIU.
+func (c *criuCheckpointer) Checkpoint(ctx context.Context) error {
+	defer throttleCRIU(ctx)()
+	defer c.withCRIUConfig(ctx)()
 
 	snapshotDir := snapshotDir(c.Bundle)
 
-	if err := os.RemoveAll(snapshotDir); err != nil {
+	// an eager pre-dump is only used as the parent of the first checkpoint.
+	eagerPreDumped := c.eagerPreDumped
+	c.eagerPreDumped = false
+	cleanupDir := snapshotDir
+	if eagerPreDumped {
+		cleanupDir = containerDir(c.Bundle)
+	}
+	workDir := path.Join(snapshotDir, "work")
+	retainCRIULogs(ctx, c.Bundle, workDir, "dump")
+	if err := os.RemoveAll(cleanupDir); err != nil {
 		return fmt.Errorf("unable to prepare snapshot dir: %w", err)
 	}
 
-	workDir := path.Join(snapshotDir, "work")
 	log.G(ctx).Infof("checkpointing process %d of container to %s", c.process.Pid(), snapshotDir)
 
 	initProcess, ok := c.process.(*process.Init)
@@ -85,56 +247,167 @@ func (c *Container) checkpoint(ctx context.Context) error {
 		return fmt.Errorf("process is not of type %T, got %T", process.Init{}, c.process)
 	}
 
-	opts := &runcC.CheckpointOpts{
-		WorkDir:                  workDir,
-		AllowOpenTCP:             true,
-		AllowExternalUnixSockets: true,
-		AllowTerminal:            false,
-		FileLocks:                true,
-		EmptyNamespaces:          []string{},
-	}
-
-	if c.cfg.PreDump {
-		// for the pre-dump we set the ImagePath to be a sub-path of our container image path
-		opts.ImagePath = preDumpDir(c.Bundle)
-
-		beforePreDump := time.Now()
-		if err := initProcess.Runtime().Checkpoint(ctx, c.ID(), opts, runcC.PreDump); err != nil {
-			log.G(ctx).Errorf("error pre-dumping container: %s", err)
-			b, err := os.ReadFile(path.Join(workDir, "dump.log"))
-			if err != nil {
-				log.G(ctx).Errorf("error reading dump.log: %s", err)
-			}
-			log.G(ctx).Errorf("dump.log: %s", b)
+	opts := checkpointOpts(workDir, c.cfg)
+	if c.cfg.PreDump && !eagerPreDumped {
+		if err := c.preDump(ctx, initProcess, opts); err != nil {
 			return err
 		}
-
-		log.G(ctx).Infof("pre-dumping done in %s", time.Since(beforePreDump))
 	}
 
-	if c.cfg.PreDump {
+	if c.cfg.PreDump || eagerPreDumped {
 		// ParentPath is the relative path from the ImagePath to the pre-dump dir.
 		opts.ParentPath = relativePreDumpDir()
 	}
 
-	c.AddCheckpointedPID(c.Pid())
 	// ImagePath is always the same, regardless of pre-dump
 	opts.ImagePath = containerDir(c.Bundle)
 
+	actions := []runcC.CheckpointAction{}
+	if c.cfg.VerifyCheckpoint {
+		// keep the process running until the checkpoint has been verified
+		// so we can roll back if it's not valid.
+		actions = append(actions, runcC.LeaveRunning)
+	}
+
 	beforeCheckpoint := time.Now()
-	if err := initProcess.Runtime().Checkpoint(ctx, c.ID(), opts); err != nil {
+	if err := initProcess.Runtime().Checkpoint(ctx, c.ID(), opts, actions...); err != nil {
 		log.G(ctx).Errorf("error checkpointing container: %s", err)
-		b, err := os.ReadFile(path.Join(workDir, "dump.log"))
-		if err != nil {
-			log.G(ctx).Errorf("error reading dump.log: %s", err)
+		dumpLog := c.readCRIULog(ctx, path.Join(workDir, dumpLogFile))
+		log.G(ctx).Errorf("dump.log: %s", dumpLog)
+		if isDiskFull(err, dumpLog) {
+			// CRIU resumes the process if the dump fails.
+			cleanupAfterDiskFull(ctx, c.Bundle)
+			return fmt.Errorf("%w: %w", errCheckpointDiskFull, err)
 		}
-		log.G(ctx).Errorf("dump.log: %s", b)
 		return err
 	}
-
-	c.SetScaledDown(true)
+	c.readCRIULog(ctx, path.Join(workDir, dumpLogFile))
 	checkpointDuration.With(c.labels()).Observe(time.Since(beforeCheckpoint).Seconds())
 	log.G(ctx).Infof("checkpointing done in %s", time.Since(beforeCheckpoint))
+	c.logIfSlow(ctx, "checkpoint", time.Since(beforeCheckpoint))
+
+	if c.cfg.VerifyCheckpoint {
+		if err := verifyCheckpoint(ctx, opts.ImagePath); err != nil {
+			return fmt.Errorf("%w: %w", errCheckpointVerification, err)
+		}
+
+		// stop the process that has been left running, the same way as
+		// runc does after a regular checkpoint.
+		if err := initProcess.Runtime().Delete(ctx, c.ID(), &runcC.DeleteOpts{Force: true}); err != nil {
+			return fmt.Errorf("unable to stop container after checkpoint: %w", err)
+		}
+	}
+
+	if err := c.saveCheckpointMetadata(); err != nil {
+		log.G(ctx).Errorf("unable to save checkpoint metadata: %s", err)
+	}
+
+	if c.cfg.CheckpointRootfs {
+		if err := saveRootfsDiff(ctx, c.Bundle); err != nil {
+			log.G(ctx).Errorf("unable to save rootfs diff: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// PreDump pre-dumps the memory of the running container, so the first
+// checkpoint only needs to dump the memory that has changed since.
+func (c *criuCheckpointer) PreDump(ctx context.Context) error {
+	defer throttleCRIU(ctx)()
+	defer c.withCRIUConfig(ctx)()
+
+	snapshotDir := snapshotDir(c.Bundle)
+	if err := os.RemoveAll(snapshotDir); err != nil {
+		return fmt.Errorf("unable to prepare snapshot dir: %w", err)
+	}
+
+	initProcess, ok := c.process.(*process.Init)
+	if !ok {
+		return fmt.Errorf("process is not of type %T, got %T", process.Init{}, c.process)
+	}
+
+	if err := c.preDump(ctx, initProcess, checkpointOpts(path.Join(snapshotDir, "work"), c.cfg)); err != nil {
+		return err
+	}
+	c.eagerPreDumped = true
+	return nil
+}
+
+// checkpointOpts returns the options for a dump or pre-dump. If a page server
+// is set, CRIU sends the memory pages to it instead of writing them to the
+// image dir.
+func checkpointOpts(workDir string, cfg *Config) *runcC.CheckpointOpts {
+	return &runcC.CheckpointOpts{
+		WorkDir:                  workDir,
+		CriuPageServer:           cfg.CRIUPageServer,
+		Cgroups:                  runcC.CgroupMode(cfg.CRIUManageCgroups),
+		AllowOpenTCP:             true,
+		AllowExternalUnixSockets: true,
+		AllowTerminal:            false,
+		FileLocks:                true,
+		EmptyNamespaces:          []string{},
+	}
+}
+
+// preDump pre-dumps the memory of the process to the pre-dump dir while it
+// keeps running.
+func (c *Container) preDump(ctx context.Context, initProcess *process.Init, opts *runcC.CheckpointOpts) error {
+	// for the pre-dump we set the ImagePath to be a sub-path of our container image path
+	opts.ImagePath = preDumpDir(c.Bundle)
+
+	beforePreDump := time.Now()
+	if err := initProcess.Runtime().Checkpoint(ctx, c.ID(), opts, runcC.PreDump); err != nil {
+		log.G(ctx).Errorf("error pre-dumping container: %s", err)
+		dumpLog := c.readCRIULog(ctx, path.Join(opts.WorkDir, dumpLogFile))
+		log.G(ctx).Errorf("dump.log: %s", dumpLog)
+		if isDiskFull(err, dumpLog) {
+			cleanupAfterDiskFull(ctx, c.Bundle)
+			return fmt.Errorf("%w: %w", errCheckpointDiskFull, err)
+		}
+		return err
+	}
+
+	log.G(ctx).Infof("pre-dumping done in %s", time.Since(beforePreDump))
+	return nil
+}
+
+// checkCheckpointImages returns an error if one of the images that every
+// complete checkpoint contains is missing or empty in imagePath.
+func checkCheckpointImages(imagePath string) error {
+	for _, image := range requiredCheckpointImages {
+		info, err := os.Stat(path.Join(imagePath, image))
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return fmt.Errorf("checkpoint image %s is empty", image)
+		}
+	}
+	return nil
+}
+
+// verifyCheckpoint does a basic integrity check of the checkpoint images in
+// imagePath. If EnvVerifyCheckpointCommand is set, the command is run with
+// the image path as last argument and the checkpoint is considered invalid
+// if it fails.
+func verifyCheckpoint(ctx context.Context, imagePath string) error {
+	if err := checkCheckpointImages(imagePath); err != nil {
+		return err
+	}
+
+	command := strings.Fields(os.Getenv(EnvVerifyCheckpointCommand))
+	if len(command) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyCheckpointTimeout)
+	defer cancel()
+
+	args := append(command[1:], imagePath)
+	if out, err := exec.CommandContext(ctx, command[0], args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("verify command %q failed: %w: %s", command, err, out)
+	}
 
 	return nil
 }
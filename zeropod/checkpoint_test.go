@@ -0,0 +1,67 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCheckpoint(t *testing.T) {
+	tests := map[string]struct {
+		images    map[string]string
+		command   string
+		expectErr bool
+	}{
+		"valid checkpoint": {
+			images: map[string]string{"inventory.img": "x", "pstree.img": "x"},
+		},
+		"missing image": {
+			images:    map[string]string{"inventory.img": "x"},
+			expectErr: true,
+		},
+		"empty image": {
+			images:    map[string]string{"inventory.img": "x", "pstree.img": ""},
+			expectErr: true,
+		},
+		"verify command succeeds": {
+			images:  map[string]string{"inventory.img": "x", "pstree.img": "x"},
+			command: "test -d",
+		},
+		"verify command fails": {
+			images:    map[string]string{"inventory.img": "x", "pstree.img": "x"},
+			command:   "test -f",
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(EnvVerifyCheckpointCommand, tc.command)
+			dir := t.TempDir()
+			for image, content := range tc.images {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, image), []byte(content), 0o644))
+			}
+
+			err := verifyCheckpoint(context.Background(), dir)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckpointOpts(t *testing.T) {
+	opts := checkpointOpts("/work", &Config{})
+	assert.Empty(t, opts.Cgroups, "runc should use its default mode")
+
+	opts = checkpointOpts("/work", &Config{CRIUPageServer: "10.0.0.5:9876", CRIUManageCgroups: CRIUManageCgroupsModeIgnore})
+	assert.Equal(t, "/work", opts.WorkDir)
+	assert.Equal(t, "10.0.0.5:9876", opts.CriuPageServer)
+	assert.EqualValues(t, CRIUManageCgroupsModeIgnore, opts.Cgroups)
+}
@@ -0,0 +1,93 @@
+package zeropod
+
+import (
+	"context"
+	"time"
+
+	"github.com/containerd/containerd/pkg/process"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/containerd/log"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+)
+
+// Checkpointer performs the operations on the process of a container that
+// require runc and CRIU. The scaling logic of the container is independent
+// of it, which allows testing it with a fake Checkpointer. All methods are
+// called with the checkpoint/restore lock held.
+type Checkpointer interface {
+	// Checkpoint checkpoints the process of the container and stops it.
+	Checkpoint(ctx context.Context) error
+	// Kill stops the process of the container without a checkpoint.
+	Kill(ctx context.Context) error
+	// Restore restores the process of the container from the checkpoint, or
+	// starts it from scratch if checkpoint is false.
+	Restore(ctx context.Context, checkpoint bool) (*runc.Container, process.Process, HandleStartedFunc, error)
+}
+
+// PreDumper is implemented by Checkpointers that can dump the memory of the
+// running process ahead of the checkpoint, which then only needs to dump the
+// memory that has changed since.
+type PreDumper interface {
+	PreDump(ctx context.Context) error
+}
+
+// RestoreCleaner is implemented by Checkpointers that leave state behind when
+// a restore is aborted because it has reached the restore timeout.
+type RestoreCleaner interface {
+	// CleanupRestore cleans up the aborted restore, so the container can be
+	// restored again.
+	CleanupRestore(ctx context.Context)
+}
+
+var (
+	_ Checkpointer   = &criuCheckpointer{}
+	_ PreDumper      = &criuCheckpointer{}
+	_ RestoreCleaner = &criuCheckpointer{}
+)
+
+// criuCheckpointer is the Checkpointer used by default. It checkpoints and
+// restores containers using runc and CRIU.
+type criuCheckpointer struct {
+	*Container
+}
+
+// Kill kills the process of the container.
+func (c *criuCheckpointer) Kill(ctx context.Context) error {
+	return c.process.Kill(ctx, 9, false)
+}
+
+// SetCheckpointer replaces the Checkpointer of the container.
+func (c *Container) SetCheckpointer(cp Checkpointer) {
+	c.checkpointer = cp
+}
+
+// scheduleEagerCheckpoint pre-dumps the container in the background once the
+// eager checkpoint delay is up, so the first scale down is faster.
+func (c *Container) scheduleEagerCheckpoint(ctx context.Context) {
+	if c.cfg.EagerCheckpoint == 0 || c.CheckpointMode() != v1.CheckpointMode_CHECKPOINT {
+		return
+	}
+
+	c.eagerCheckpointTimer = time.AfterFunc(c.cfg.EagerCheckpoint, func() {
+		if err := c.eagerCheckpoint(ctx); err != nil {
+			log.G(ctx).Errorf("eager checkpoint failed: %s", err)
+		}
+	})
+}
+
+func (c *Container) eagerCheckpoint(ctx context.Context) error {
+	preDumper, ok := c.checkpointer.(PreDumper)
+	if !ok {
+		return nil
+	}
+
+	c.checkpointRestore.Lock()
+	defer c.checkpointRestore.Unlock()
+	// the container might have been scaled down or deleted in the meantime.
+	if c.ScaledDown() || !c.Exists() {
+		return nil
+	}
+
+	log.G(ctx).Infof("eagerly pre-dumping container %s", c.ID())
+	return preDumper.PreDump(ctx)
+}
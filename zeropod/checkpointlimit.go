@@ -0,0 +1,126 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/containerd/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// EnvMaxConcurrentCheckpoints can be set on the shim to limit the amount
+	// of checkpoints that run at the same time on the node. Scale downs
+	// beyond the limit wait until a running checkpoint has finished.
+	EnvMaxConcurrentCheckpoints = "ZEROPOD_MAX_CONCURRENT_CHECKPOINTS"
+
+	checkpointSlotInterval = 100 * time.Millisecond
+)
+
+var (
+	// checkpointSlotsDir contains a lock file per slot. As every pod has its
+	// own shim, the slots are shared through the filesystem of the node.
+	checkpointSlotsDir = "/run/zeropod/checkpoint-slots"
+	checkpointLimit    = checkpointSlotsFromEnv()
+)
+
+// checkpointSlots is a semaphore limiting the amount of concurrent
+// checkpoints of all shims on the node. A slot is held by an exclusive flock
+// on its file, so it's released by the kernel even if the shim crashes.
+type checkpointSlots struct {
+	dir    string
+	limit  int
+	queued atomic.Int64
+}
+
+func checkpointSlotsFromEnv() *checkpointSlots {
+	value := os.Getenv(EnvMaxConcurrentCheckpoints)
+	if value == "" {
+		return nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		log.L.Warnf("ignoring invalid %s %q, it needs to be a positive number", EnvMaxConcurrentCheckpoints, value)
+		return nil
+	}
+
+	return &checkpointSlots{dir: checkpointSlotsDir, limit: limit}
+}
+
+// acquire waits for a free slot and returns a func that releases it.
+func (s *checkpointSlots) acquire(ctx context.Context) (func(), error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating checkpoint slots dir: %w", err)
+	}
+
+	s.queued.Add(1)
+	defer s.queued.Add(-1)
+
+	logged := false
+	for {
+		for i := 0; i < s.limit; i++ {
+			f, err := s.tryLock(i)
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				return func() { f.Close() }, nil
+			}
+		}
+
+		if !logged {
+			log.G(ctx).Infof("waiting for one of %d concurrent checkpoints to finish", s.limit)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(checkpointSlotInterval):
+		}
+	}
+}
+
+// tryLock locks the slot with the supplied index without blocking. It
+// returns nil if the slot is held by someone else.
+func (s *checkpointSlots) tryLock(slot int) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, strconv.Itoa(slot)), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening checkpoint slot: %w", err)
+	}
+
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		f.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("locking checkpoint slot: %w", err)
+	}
+
+	return f, nil
+}
+
+// acquireCheckpointSlot waits until the checkpoint is allowed to run and
+// returns a func that needs to be called once it's done. If no limit is
+// configured, it returns immediately.
+func acquireCheckpointSlot(ctx context.Context) (func(), error) {
+	if checkpointLimit == nil {
+		return func() {}, nil
+	}
+	return checkpointLimit.acquire(ctx)
+}
+
+// queuedCheckpoints returns the amount of checkpoints of the shim that are
+// waiting for a slot.
+func queuedCheckpoints() int {
+	if checkpointLimit == nil {
+		return 0
+	}
+	return int(checkpointLimit.queued.Load())
+}
@@ -0,0 +1,54 @@
+package zeropod
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointSlots(t *testing.T) {
+	ctx := context.Background()
+	slots := &checkpointSlots{dir: t.TempDir(), limit: 1}
+
+	release, err := slots.acquire(ctx)
+	require.NoError(t, err)
+
+	acquired := make(chan func())
+	go func() {
+		release, err := slots.acquire(ctx)
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+
+	assert.Eventually(t, func() bool {
+		return slots.queued.Load() == 1
+	}, time.Second, checkpointSlotInterval/10)
+	select {
+	case <-acquired:
+		t.Fatal("slot should not be acquired while the limit is reached")
+	case <-time.After(checkpointSlotInterval * 2):
+	}
+
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("slot should be acquired once it has been released")
+	}
+	assert.Zero(t, slots.queued.Load())
+
+	// slots are shared with other shims through the lock files.
+	other := &checkpointSlots{dir: slots.dir, limit: 1}
+	release, err = other.acquire(ctx)
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, checkpointSlotInterval)
+	defer cancel()
+	_, err = slots.acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,108 @@
+package zeropod
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/containerd/containerd/pkg/cri/annotations"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const checkpointMetadataFile = "metadata.json"
+
+// checkpointMetadata identifies the image and process spec a checkpoint has
+// been taken from. Restoring a checkpoint into a container with a different
+// image or process spec would bring back the state of the old image.
+type checkpointMetadata struct {
+	Image      string `json:"image"`
+	SpecDigest string `json:"specDigest"`
+}
+
+func newCheckpointMetadata(spec *specs.Spec) (checkpointMetadata, error) {
+	meta := checkpointMetadata{}
+	if spec == nil {
+		return meta, nil
+	}
+
+	meta.Image = spec.Annotations[annotations.ImageName]
+	if spec.Process != nil {
+		// the args, env and working dir of the process are taken from the
+		// image config unless overridden in the pod spec.
+		b, err := json.Marshal(spec.Process)
+		if err != nil {
+			return meta, err
+		}
+		digest := sha256.Sum256(b)
+		meta.SpecDigest = "sha256:" + hex.EncodeToString(digest[:])
+	}
+
+	return meta, nil
+}
+
+func checkpointMetadataPath(bundle string) string {
+	return path.Join(snapshotDir(bundle), checkpointMetadataFile)
+}
+
+func writeCheckpointMetadata(file string, meta checkpointMetadata) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o644)
+}
+
+func readCheckpointMetadata(file string) (checkpointMetadata, error) {
+	meta := checkpointMetadata{}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return meta, err
+	}
+	return meta, json.Unmarshal(b, &meta)
+}
+
+// saveCheckpointMetadata stores the metadata of the container alongside its
+// checkpoint.
+func (c *Container) saveCheckpointMetadata() error {
+	meta, err := newCheckpointMetadata(c.cfg.spec)
+	if err != nil {
+		return err
+	}
+	return writeCheckpointMetadata(checkpointMetadataPath(c.Bundle), meta)
+}
+
+// checkpointOutdated returns an error if the checkpoint of the container has
+// been taken from a different image or process spec than the one of the
+// container. Checkpoints without metadata are assumed to be up to date.
+func (c *Container) checkpointOutdated() error {
+	stored, err := readCheckpointMetadata(checkpointMetadataPath(c.Bundle))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading checkpoint metadata: %w", err)
+	}
+
+	return stored.matches(c.cfg.spec)
+}
+
+// matches returns an error if the checkpoint with the stored metadata has been
+// taken from a different image or process spec than spec.
+func (stored checkpointMetadata) matches(spec *specs.Spec) error {
+	current, err := newCheckpointMetadata(spec)
+	if err != nil {
+		return err
+	}
+
+	if stored.Image != current.Image {
+		return fmt.Errorf("checkpoint has been taken from image %q, container runs image %q", stored.Image, current.Image)
+	}
+	if stored.SpecDigest != current.SpecDigest {
+		return fmt.Errorf("process spec of the container has changed since the checkpoint")
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package zeropod
+
+import (
+	"os"
+	"testing"
+
+	"github.com/containerd/containerd/pkg/cri/annotations"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointOutdated(t *testing.T) {
+	newSpec := func(image string, args ...string) *specs.Spec {
+		return &specs.Spec{
+			Annotations: map[string]string{annotations.ImageName: image},
+			Process:     &specs.Process{Args: args},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		checkpointSpec *specs.Spec
+		spec           *specs.Spec
+		expectOutdated bool
+	}{
+		"same spec": {
+			checkpointSpec: newSpec("nginx:1.27", "nginx"),
+			spec:           newSpec("nginx:1.27", "nginx"),
+		},
+		"no metadata": {
+			spec: newSpec("nginx:1.27", "nginx"),
+		},
+		"image changed": {
+			checkpointSpec: newSpec("nginx:1.27", "nginx"),
+			spec:           newSpec("nginx:1.28", "nginx"),
+			expectOutdated: true,
+		},
+		"process changed": {
+			checkpointSpec: newSpec("nginx:1.27", "nginx"),
+			spec:           newSpec("nginx:1.27", "nginx", "-g", "daemon off;"),
+			expectOutdated: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bundle := t.TempDir()
+			require.NoError(t, os.MkdirAll(snapshotDir(bundle), 0o755))
+			if tc.checkpointSpec != nil {
+				meta, err := newCheckpointMetadata(tc.checkpointSpec)
+				require.NoError(t, err)
+				require.NoError(t, writeCheckpointMetadata(checkpointMetadataPath(bundle), meta))
+			}
+
+			c := &Container{Container: &runc.Container{Bundle: bundle}, cfg: &Config{spec: tc.spec}}
+			if tc.expectOutdated {
+				assert.Error(t, c.checkpointOutdated())
+			} else {
+				assert.NoError(t, c.checkpointOutdated())
+			}
+		})
+	}
+}
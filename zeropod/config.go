@@ -3,26 +3,81 @@ package zeropod
 import (
 	"context"
 	"fmt"
+	"net"
+	"path"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/pkg/cri/annotations"
 	"github.com/containerd/log"
+	"github.com/ctrox/zeropod/activator"
 	"github.com/mitchellh/mapstructure"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
-	NodeLabel                        = "zeropod.ctrox.dev/node"
-	PortsAnnotationKey               = "zeropod.ctrox.dev/ports-map"
-	ContainerNamesAnnotationKey      = "zeropod.ctrox.dev/container-names"
-	ScaleDownDurationAnnotationKey   = "zeropod.ctrox.dev/scaledown-duration"
-	DisableCheckpoiningAnnotationKey = "zeropod.ctrox.dev/disable-checkpointing"
-	PreDumpAnnotationKey             = "zeropod.ctrox.dev/pre-dump"
-	CRIContainerNameAnnotation       = "io.kubernetes.cri.container-name"
-	CRIContainerTypeAnnotation       = "io.kubernetes.cri.container-type"
+	NodeLabel                         = "zeropod.ctrox.dev/node"
+	PortsAnnotationKey                = "zeropod.ctrox.dev/ports-map"
+	ContainerNamesAnnotationKey       = "zeropod.ctrox.dev/container-names"
+	ScaleDownDurationAnnotationKey    = "zeropod.ctrox.dev/scaledown-duration"
+	DisableCheckpoiningAnnotationKey  = "zeropod.ctrox.dev/disable-checkpointing"
+	PreDumpAnnotationKey              = "zeropod.ctrox.dev/pre-dump"
+	RestoreTimeoutAnnotationKey       = "zeropod.ctrox.dev/restore-timeout"
+	LogPathAnnotationKey              = "zeropod.ctrox.dev/log-path"
+	ActivatorBackendAnnotationKey     = "zeropod.ctrox.dev/activator-backend"
+	RefreshDNSConfigAnnotationKey     = "zeropod.ctrox.dev/refresh-dns-config"
+	KeepTimerOnExecAnnotationKey      = "zeropod.ctrox.dev/keep-timer-on-exec"
+	RestoreSiblingsAnnotationKey      = "zeropod.ctrox.dev/restore-siblings"
+	ScaleDownStrategyAnnotationKey    = "zeropod.ctrox.dev/scaledown-strategy"
+	VerifyCheckpointAnnotationKey     = "zeropod.ctrox.dev/verify-checkpoint"
+	ScaleDownPriorityAnnotationKey    = "zeropod.ctrox.dev/scaledown-priority"
+	RestoreSignalAnnotationKey        = "zeropod.ctrox.dev/restore-signal"
+	ScaleDownTriggerAnnotationKey     = "zeropod.ctrox.dev/scaledown-trigger"
+	HandoffGracePeriodAnnotationKey   = "zeropod.ctrox.dev/handoff-grace-period"
+	CRIUGhostLimitAnnotationKey       = "zeropod.ctrox.dev/criu-ghost-limit"
+	RestoreRetriesAnnotationKey       = "zeropod.ctrox.dev/restore-retries"
+	RestoreFailurePolicyAnnotationKey = "zeropod.ctrox.dev/restore-failure-policy"
+	ActivatorMeteringAnnotationKey    = "zeropod.ctrox.dev/activator-metering"
+	MaxScaledLifetimeAnnotationKey    = "zeropod.ctrox.dev/max-scaled-lifetime"
+	EagerCheckpointAnnotationKey      = "zeropod.ctrox.dev/eager-checkpoint"
+	HTTPHoldingTimeoutAnnotationKey   = "zeropod.ctrox.dev/http-holding-timeout"
+	PostRestoreCommandAnnotationKey   = "zeropod.ctrox.dev/post-restore-command"
+	LogBufferSizeAnnotationKey        = "zeropod.ctrox.dev/log-buffer-size"
+	MaxLogLineSizeAnnotationKey       = "zeropod.ctrox.dev/max-log-line-size"
+	RestoreOnStopAnnotationKey        = "zeropod.ctrox.dev/restore-on-stop"
+	RestoreRetryBackoffAnnotationKey  = "zeropod.ctrox.dev/restore-retry-backoff"
+	ScaleEventHistoryAnnotationKey    = "zeropod.ctrox.dev/scale-event-history"
+	WakeSignalsAnnotationKey          = "zeropod.ctrox.dev/wake-signals"
+	ReclaimSandboxAnnotationKey       = "zeropod.ctrox.dev/reclaim-sandbox"
+	CRIUPageServerAnnotationKey       = "zeropod.ctrox.dev/criu-page-server"
+	MaxDiskWriteRateAnnotationKey     = "zeropod.ctrox.dev/max-disk-write-rate"
+	MinMemoryAnnotationKey            = "zeropod.ctrox.dev/min-memory"
+	PrefetchIntervalAnnotationKey     = "zeropod.ctrox.dev/prefetch-interval"
+	TimerModeAnnotationKey            = "zeropod.ctrox.dev/timer-mode"
+	OriginalDstPortsAnnotationKey     = "zeropod.ctrox.dev/original-dst-ports"
+	RedirectCooldownAnnotationKey     = "zeropod.ctrox.dev/redirect-cooldown"
+	ReadinessProbeAnnotationKey       = "zeropod.ctrox.dev/readiness-probe"
+	CRIUPreDumpModeAnnotationKey      = "zeropod.ctrox.dev/criu-pre-dump-mode"
+	CRIUAutoDedupAnnotationKey        = "zeropod.ctrox.dev/criu-auto-dedup"
+	ActivityIntervalAnnotationKey     = "zeropod.ctrox.dev/activity-check-interval"
+	ReloadMountsAnnotationKey         = "zeropod.ctrox.dev/reload-mounts"
+	CheckpointRootfsAnnotationKey     = "zeropod.ctrox.dev/checkpoint-rootfs"
+	RestoreHealthCheckAnnotationKey   = "zeropod.ctrox.dev/restore-health-check"
+	UncheckpointableAnnotationKey     = "zeropod.ctrox.dev/uncheckpointable-policy"
+	NUMAAffinityAnnotationKey         = "zeropod.ctrox.dev/numa-affinity"
+	ColdStartCommandAnnotationKey     = "zeropod.ctrox.dev/cold-start-command"
+	CRIUManageCgroupsAnnotationKey    = "zeropod.ctrox.dev/criu-manage-cgroups"
+	ConnectionHandoffAnnotationKey    = "zeropod.ctrox.dev/connection-handoff-socket"
+	StartupGraceAnnotationKey         = "zeropod.ctrox.dev/startup-grace"
+	CRIContainerNameAnnotation        = "io.kubernetes.cri.container-name"
+	CRIContainerTypeAnnotation        = "io.kubernetes.cri.container-type"
 
 	defaultScaleDownDuration = time.Minute
 	containersDelim          = ","
@@ -30,6 +85,130 @@ const (
 	mappingDelim             = ";"
 	mapDelim                 = "="
 	defaultContainerdNS      = "k8s.io"
+
+	// defaultMaxLogLineSize is the default of containerd, which writes the
+	// container logs read by the kubelet.
+	defaultMaxLogLineSize = 16 * 1024
+
+	// maxHandoffGracePeriod limits the handoff grace period. The process is
+	// only given time to accept connections that already reached it, and
+	// the container can't be restored while waiting.
+	maxHandoffGracePeriod = 10 * time.Second
+)
+
+// VClusterPodNameAnnotationKey and VClusterPodNamespaceAnnotationKey are set
+// by the vcluster syncer on host pods and contain the name and namespace of
+// the pod within the virtual cluster.
+const (
+	VClusterPodNameAnnotationKey      = "vcluster.loft.sh/name"
+	VClusterPodNamespaceAnnotationKey = "vcluster.loft.sh/namespace"
+)
+
+// ScaleDownStrategy defines what happens to a container on scale down.
+type ScaleDownStrategy string
+
+const (
+	// ScaleDownStrategyCheckpoint checkpoints the container and stops the
+	// process. It's restored on the next incoming connection.
+	ScaleDownStrategyCheckpoint ScaleDownStrategy = "checkpoint"
+	// ScaleDownStrategyReclaim keeps the process running but reclaims as much
+	// of its memory as possible (e.g. page cache) using the cgroup v2
+	// memory.reclaim interface.
+	ScaleDownStrategyReclaim ScaleDownStrategy = "reclaim"
+)
+
+// ScaleDownTrigger defines when a container is scaled down.
+type ScaleDownTrigger string
+
+const (
+	// ScaleDownTriggerIdleTimer scales down once there has been no network
+	// activity for the scale down duration.
+	ScaleDownTriggerIdleTimer ScaleDownTrigger = "idle-timer"
+	// ScaleDownTriggerConnectionIdle scales down once the scale down duration
+	// is up and the process does not have any established connections.
+	ScaleDownTriggerConnectionIdle ScaleDownTrigger = "connection-idle"
+	// ScaleDownTriggerCPUIdle scales down once the process has been idle in
+	// terms of CPU usage for the scale down duration.
+	ScaleDownTriggerCPUIdle ScaleDownTrigger = "cpu-idle"
+	// ScaleDownTriggerSchedule scales down once the scale down duration is
+	// up, regardless of any activity.
+	ScaleDownTriggerSchedule ScaleDownTrigger = "schedule"
+)
+
+// RestoreFailurePolicy defines what happens if a container can't be restored.
+type RestoreFailurePolicy string
+
+const (
+	// RestoreFailurePolicyExit exits the shim, which makes containerd
+	// recreate it.
+	RestoreFailurePolicyExit RestoreFailurePolicy = "exit"
+	// RestoreFailurePolicyFail marks the container as failed, so it's
+	// restarted by the kubelet according to the restart policy of the pod.
+	RestoreFailurePolicyFail RestoreFailurePolicy = "fail"
+	// RestoreFailurePolicyColdStart starts the container from scratch
+	// without the checkpoint.
+	RestoreFailurePolicyColdStart RestoreFailurePolicy = "cold-start"
+	// RestoreFailurePolicyRetry keeps the container scaled down, so the next
+	// connection retries the restore.
+	RestoreFailurePolicyRetry RestoreFailurePolicy = "retry"
+)
+
+// UncheckpointablePolicy defines what happens on scale down if the process
+// has open files that CRIU can't checkpoint.
+type UncheckpointablePolicy string
+
+const (
+	// UncheckpointablePolicyKeepRunning keeps the container running and
+	// reschedules the scale down.
+	UncheckpointablePolicyKeepRunning UncheckpointablePolicy = "keep-running"
+	// UncheckpointablePolicyColdStart kills the process on scale down and
+	// starts the container from scratch on restore.
+	UncheckpointablePolicyColdStart UncheckpointablePolicy = "cold-start"
+)
+
+// CRIUPreDumpMode defines how CRIU reads the memory of the process in a
+// pre-dump.
+type CRIUPreDumpMode string
+
+const (
+	// CRIUPreDumpModeSplice splices the memory pages into pipes, which is
+	// the default of CRIU.
+	CRIUPreDumpModeSplice CRIUPreDumpMode = "splice"
+	// CRIUPreDumpModeRead reads the memory pages using process_vm_readv.
+	CRIUPreDumpModeRead CRIUPreDumpMode = "read"
+)
+
+// CRIUManageCgroupsMode defines how CRIU handles the cgroups of the
+// container when it's checkpointed. See https://criu.org/CGroups for details.
+type CRIUManageCgroupsMode string
+
+const (
+	// CRIUManageCgroupsModeSoft restores the cgroup properties only if the
+	// cgroups have to be created. This is the default of runc.
+	CRIUManageCgroupsModeSoft CRIUManageCgroupsMode = "soft"
+	// CRIUManageCgroupsModeFull always restores all cgroup properties.
+	CRIUManageCgroupsModeFull CRIUManageCgroupsMode = "full"
+	// CRIUManageCgroupsModeStrict fails if the cgroup properties do not
+	// match.
+	CRIUManageCgroupsModeStrict CRIUManageCgroupsMode = "strict"
+	// CRIUManageCgroupsModeIgnore does not dump the cgroups at all and
+	// leaves them to the runtime.
+	CRIUManageCgroupsModeIgnore CRIUManageCgroupsMode = "ignore"
+)
+
+// TimerMode defines how the clocks of a container behave while it's scaled
+// down.
+type TimerMode string
+
+const (
+	// TimerModeWallClock keeps the monotonic clocks of the container running
+	// while it's scaled down, so deadlines that passed in the meantime
+	// expire right after the restore.
+	TimerModeWallClock TimerMode = "wall-clock"
+	// TimerModePaused runs the container in its own time namespace, which
+	// CRIU restores with the monotonic clocks at the time of the checkpoint.
+	// Timers continue as if no time has passed while scaled down.
+	TimerModePaused TimerMode = "paused"
 )
 
 type annotationConfig struct {
@@ -38,6 +217,55 @@ type annotationConfig struct {
 	ScaledownDuration     string `mapstructure:"zeropod.ctrox.dev/scaledown-duration"`
 	DisableCheckpointing  string `mapstructure:"zeropod.ctrox.dev/disable-checkpointing"`
 	PreDump               string `mapstructure:"zeropod.ctrox.dev/pre-dump"`
+	RestoreTimeout        string `mapstructure:"zeropod.ctrox.dev/restore-timeout"`
+	LogPath               string `mapstructure:"zeropod.ctrox.dev/log-path"`
+	ActivatorBackend      string `mapstructure:"zeropod.ctrox.dev/activator-backend"`
+	RefreshDNSConfig      string `mapstructure:"zeropod.ctrox.dev/refresh-dns-config"`
+	KeepTimerOnExec       string `mapstructure:"zeropod.ctrox.dev/keep-timer-on-exec"`
+	RestoreSiblings       string `mapstructure:"zeropod.ctrox.dev/restore-siblings"`
+	ScaleDownStrategy     string `mapstructure:"zeropod.ctrox.dev/scaledown-strategy"`
+	VerifyCheckpoint      string `mapstructure:"zeropod.ctrox.dev/verify-checkpoint"`
+	ScaleDownPriority     string `mapstructure:"zeropod.ctrox.dev/scaledown-priority"`
+	RestoreSignal         string `mapstructure:"zeropod.ctrox.dev/restore-signal"`
+	ScaleDownTrigger      string `mapstructure:"zeropod.ctrox.dev/scaledown-trigger"`
+	HandoffGracePeriod    string `mapstructure:"zeropod.ctrox.dev/handoff-grace-period"`
+	CRIUGhostLimit        string `mapstructure:"zeropod.ctrox.dev/criu-ghost-limit"`
+	RestoreRetries        string `mapstructure:"zeropod.ctrox.dev/restore-retries"`
+	RestoreFailurePolicy  string `mapstructure:"zeropod.ctrox.dev/restore-failure-policy"`
+	ActivatorMetering     string `mapstructure:"zeropod.ctrox.dev/activator-metering"`
+	MaxScaledLifetime     string `mapstructure:"zeropod.ctrox.dev/max-scaled-lifetime"`
+	EagerCheckpoint       string `mapstructure:"zeropod.ctrox.dev/eager-checkpoint"`
+	HTTPHoldingTimeout    string `mapstructure:"zeropod.ctrox.dev/http-holding-timeout"`
+	PostRestoreCommand    string `mapstructure:"zeropod.ctrox.dev/post-restore-command"`
+	LogBufferSize         string `mapstructure:"zeropod.ctrox.dev/log-buffer-size"`
+	MaxLogLineSize        string `mapstructure:"zeropod.ctrox.dev/max-log-line-size"`
+	RestoreOnStop         string `mapstructure:"zeropod.ctrox.dev/restore-on-stop"`
+	RestoreRetryBackoff   string `mapstructure:"zeropod.ctrox.dev/restore-retry-backoff"`
+	ScaleEventHistory     string `mapstructure:"zeropod.ctrox.dev/scale-event-history"`
+	WakeSignals           string `mapstructure:"zeropod.ctrox.dev/wake-signals"`
+	ReclaimSandbox        string `mapstructure:"zeropod.ctrox.dev/reclaim-sandbox"`
+	CRIUPageServer        string `mapstructure:"zeropod.ctrox.dev/criu-page-server"`
+	MaxDiskWriteRate      string `mapstructure:"zeropod.ctrox.dev/max-disk-write-rate"`
+	MinMemory             string `mapstructure:"zeropod.ctrox.dev/min-memory"`
+	PrefetchInterval      string `mapstructure:"zeropod.ctrox.dev/prefetch-interval"`
+	TimerMode             string `mapstructure:"zeropod.ctrox.dev/timer-mode"`
+	OriginalDstPorts      string `mapstructure:"zeropod.ctrox.dev/original-dst-ports"`
+	RedirectCooldown      string `mapstructure:"zeropod.ctrox.dev/redirect-cooldown"`
+	ReadinessProbe        string `mapstructure:"zeropod.ctrox.dev/readiness-probe"`
+	CRIUPreDumpMode       string `mapstructure:"zeropod.ctrox.dev/criu-pre-dump-mode"`
+	CRIUAutoDedup         string `mapstructure:"zeropod.ctrox.dev/criu-auto-dedup"`
+	ActivityCheckInterval string `mapstructure:"zeropod.ctrox.dev/activity-check-interval"`
+	ReloadMounts          string `mapstructure:"zeropod.ctrox.dev/reload-mounts"`
+	CheckpointRootfs      string `mapstructure:"zeropod.ctrox.dev/checkpoint-rootfs"`
+	RestoreHealthCheck    string `mapstructure:"zeropod.ctrox.dev/restore-health-check"`
+	Uncheckpointable      string `mapstructure:"zeropod.ctrox.dev/uncheckpointable-policy"`
+	NUMAAffinity          string `mapstructure:"zeropod.ctrox.dev/numa-affinity"`
+	ColdStartCommand      string `mapstructure:"zeropod.ctrox.dev/cold-start-command"`
+	CRIUManageCgroups     string `mapstructure:"zeropod.ctrox.dev/criu-manage-cgroups"`
+	ConnectionHandoff     string `mapstructure:"zeropod.ctrox.dev/connection-handoff-socket"`
+	StartupGrace          string `mapstructure:"zeropod.ctrox.dev/startup-grace"`
+	VClusterPodName       string `mapstructure:"vcluster.loft.sh/name"`
+	VClusterPodNamespace  string `mapstructure:"vcluster.loft.sh/namespace"`
 	ContainerName         string `mapstructure:"io.kubernetes.cri.container-name"`
 	ContainerType         string `mapstructure:"io.kubernetes.cri.container-type"`
 	PodName               string `mapstructure:"io.kubernetes.cri.sandbox-name"`
@@ -51,69 +279,147 @@ type Config struct {
 	ScaleDownDuration     time.Duration
 	DisableCheckpointing  bool
 	PreDump               bool
+	RestoreTimeout        time.Duration
+	LogPath               string
+	ActivatorBackend      activator.Backend
+	RefreshDNSConfig      bool
+	KeepTimerOnExec       bool
+	RestoreSiblings       bool
+	ScaleDownStrategy     ScaleDownStrategy
+	VerifyCheckpoint      bool
+	ScaleDownPriority     int32
+	RestoreSignal         syscall.Signal
+	ScaleDownTrigger      ScaleDownTrigger
+	HandoffGracePeriod    time.Duration
+	CRIUGhostLimit        int64
+	RestoreRetries        int
+	RestoreFailurePolicy  RestoreFailurePolicy
+	ActivatorMetering     bool
+	MaxScaledLifetime     time.Duration
+	EagerCheckpoint       time.Duration
+	HTTPHoldingTimeouts   map[uint16]time.Duration
+	PostRestoreCommand    []string
+	LogBufferSize         int64
+	MaxLogLineSize        int64
+	RestoreOnStop         bool
+	RestoreRetryBackoff   time.Duration
+	ScaleEventHistorySize int
+	WakeSignals           []syscall.Signal
+	ReclaimSandbox        bool
+	CRIUPageServer        string
+	MaxDiskWriteRate      int64
+	MinMemory             int64
+	PrefetchInterval      time.Duration
+	TimerMode             TimerMode
+	OriginalDstPorts      []uint16
+	RedirectCooldown      time.Duration
+	ReadinessProbe        *ReadinessProbe
+	CRIUPreDumpMode       CRIUPreDumpMode
+	CRIUAutoDedup         bool
+	ActivityCheckInterval time.Duration
+	ReloadMounts          []string
+	CheckpointRootfs      bool
+	RestoreHealthCheck    *ReadinessProbe
+	Uncheckpointable      UncheckpointablePolicy
+	NUMAAffinity          bool
+	ColdStartCommand      []string
+	CRIUManageCgroups     CRIUManageCgroupsMode
+	ConnectionHandoff     string
+	StartupGrace          time.Duration
 	ContainerName         string
 	ContainerType         string
 	PodName               string
 	PodNamespace          string
 	PodUID                string
+	VClusterPodName       string
+	VClusterPodNamespace  string
 	ContainerdNamespace   string
 	spec                  *specs.Spec
+	annotated             bool
 }
 
 // NewConfig uses the annotations from the container spec to create a new
 // typed ZeropodConfig config.
 func NewConfig(ctx context.Context, spec *specs.Spec) (*Config, error) {
+	annotations := withDefaultPrefix(spec.Annotations, annotationPrefix)
 	cfg := &annotationConfig{}
-	if err := mapstructure.Decode(spec.Annotations, cfg); err != nil {
+	if err := mapstructure.Decode(annotations, cfg); err != nil {
 		return nil, err
 	}
 
 	var err error
 	var containerPorts []uint16
 	if len(cfg.PortMap) != 0 {
+		// containers of a pod have unique names, but the annotation could
+		// still list a container more than once. The ports of all its
+		// entries are used in the order they are listed.
+		entries := 0
+		otherPorts := map[uint16]string{}
 		for _, mapping := range strings.Split(cfg.PortMap, mappingDelim) {
 			namePorts := strings.Split(mapping, mapDelim)
 			if len(namePorts) != 2 {
-				return nil, fmt.Errorf("invalid port map, the format needs to be name=port")
+				return nil, annotationError(PortsAnnotationKey, fmt.Errorf("invalid port map, the format needs to be name=port"))
 			}
 
 			name, ports := namePorts[0], namePorts[1]
-			if name != cfg.ContainerName {
-				continue
+			if name == cfg.ContainerName {
+				entries++
 			}
 
 			for _, port := range strings.Split(ports, portsDelim) {
 				p, err := strconv.ParseUint(port, 10, 16)
+				if name != cfg.ContainerName {
+					// the ports of other containers are validated by their
+					// own config.
+					if err == nil {
+						otherPorts[uint16(p)] = name
+					}
+					continue
+				}
 				if err != nil {
-					return nil, err
+					return nil, annotationError(PortsAnnotationKey, err)
+				}
+				if p == 0 {
+					return nil, annotationError(PortsAnnotationKey, fmt.Errorf("invalid port map, port of container %s must not be 0", name))
+				}
+				if !slices.Contains(containerPorts, uint16(p)) {
+					containerPorts = append(containerPorts, uint16(p))
 				}
-				containerPorts = append(containerPorts, uint16(p))
+			}
+		}
+
+		if entries > 1 {
+			log.G(ctx).Warnf("container %s is listed %d times in %s, using the ports of all entries",
+				cfg.ContainerName, entries, AnnotationKey(PortsAnnotationKey))
+		}
+		for _, port := range containerPorts {
+			if other, ok := otherPorts[port]; ok {
+				// all containers of a pod share the network namespace, so
+				// only one of them can actually listen on the port.
+				log.G(ctx).Warnf("port %d of container %s is also mapped to container %s in %s",
+					port, cfg.ContainerName, other, AnnotationKey(PortsAnnotationKey))
 			}
 		}
 	}
 
 	dur := defaultScaleDownDuration
 	if len(cfg.ScaledownDuration) != 0 {
-		dur, err = time.ParseDuration(cfg.ScaledownDuration)
+		dur, err = parseDurationAnnotation(ScaleDownDurationAnnotationKey, cfg.ScaledownDuration, true)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	disableCheckpointing := false
-	if len(cfg.DisableCheckpointing) != 0 {
-		disableCheckpointing, err = strconv.ParseBool(cfg.DisableCheckpointing)
-		if err != nil {
-			return nil, err
-		}
-
+	disableCheckpointing, err := parseBoolAnnotation(DisableCheckpoiningAnnotationKey, cfg.DisableCheckpointing)
+	if err != nil {
+		return nil, err
 	}
 
 	preDump := false
 	if len(cfg.PreDump) != 0 {
-		preDump, err = strconv.ParseBool(cfg.PreDump)
+		preDump, err = parseContainerBool(cfg.PreDump, cfg.ContainerName)
 		if err != nil {
-			return nil, err
+			return nil, annotationError(PreDumpAnnotationKey, err)
 		}
 		if preDump && runtime.GOARCH == "arm64" {
 			// disable pre-dump on arm64
@@ -123,9 +429,419 @@ func NewConfig(ctx context.Context, spec *specs.Spec) (*Config, error) {
 		}
 	}
 
+	logPath := ""
+	if len(cfg.LogPath) != 0 {
+		logPath, err = parseContainerLogPath(cfg.LogPath, cfg.ContainerName)
+		if err != nil {
+			return nil, annotationError(LogPathAnnotationKey, err)
+		}
+	}
+
+	restoreTimeout, err := parsePositiveDurationAnnotation(RestoreTimeoutAnnotationKey, cfg.RestoreTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	activatorBackend := activator.BackendRedirect
+	if len(cfg.ActivatorBackend) != 0 {
+		activatorBackend = activator.Backend(cfg.ActivatorBackend)
+		switch activatorBackend {
+		case activator.BackendRedirect, activator.BackendNFQueue:
+		default:
+			return nil, annotationError(ActivatorBackendAnnotationKey, fmt.Errorf("invalid activator backend %q, must be one of %q, %q",
+				activatorBackend, activator.BackendRedirect, activator.BackendNFQueue))
+		}
+	}
+
+	refreshDNSConfig, err := parseBoolAnnotation(RefreshDNSConfigAnnotationKey, cfg.RefreshDNSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	keepTimerOnExec := false
+	if len(cfg.KeepTimerOnExec) != 0 {
+		keepTimerOnExec, err = parseContainerBool(cfg.KeepTimerOnExec, cfg.ContainerName)
+		if err != nil {
+			return nil, annotationError(KeepTimerOnExecAnnotationKey, err)
+		}
+	}
+
+	restoreSiblings, err := parseBoolAnnotation(RestoreSiblingsAnnotationKey, cfg.RestoreSiblings)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleDownStrategy := ScaleDownStrategyCheckpoint
+	if len(cfg.ScaleDownStrategy) != 0 {
+		scaleDownStrategy = ScaleDownStrategy(cfg.ScaleDownStrategy)
+		switch scaleDownStrategy {
+		case ScaleDownStrategyCheckpoint, ScaleDownStrategyReclaim:
+		default:
+			return nil, annotationError(ScaleDownStrategyAnnotationKey, fmt.Errorf("invalid scale down strategy %q, must be one of %q, %q",
+				scaleDownStrategy, ScaleDownStrategyCheckpoint, ScaleDownStrategyReclaim))
+		}
+	}
+
+	scaleDownTrigger := ScaleDownTriggerIdleTimer
+	if len(cfg.ScaleDownTrigger) != 0 {
+		scaleDownTrigger = ScaleDownTrigger(cfg.ScaleDownTrigger)
+		switch scaleDownTrigger {
+		case ScaleDownTriggerIdleTimer, ScaleDownTriggerConnectionIdle,
+			ScaleDownTriggerCPUIdle, ScaleDownTriggerSchedule:
+		default:
+			return nil, annotationError(ScaleDownTriggerAnnotationKey, fmt.Errorf("invalid scale down trigger %q, must be one of %q, %q, %q, %q",
+				scaleDownTrigger, ScaleDownTriggerIdleTimer, ScaleDownTriggerConnectionIdle,
+				ScaleDownTriggerCPUIdle, ScaleDownTriggerSchedule))
+		}
+	}
+
+	handoffGracePeriod, err := parseDurationAnnotation(HandoffGracePeriodAnnotationKey, cfg.HandoffGracePeriod, false)
+	if err != nil {
+		return nil, err
+	}
+	if handoffGracePeriod > maxHandoffGracePeriod {
+		return nil, annotationError(HandoffGracePeriodAnnotationKey,
+			fmt.Errorf("handoff grace period can't be longer than %s, got %s", maxHandoffGracePeriod, handoffGracePeriod))
+	}
+
+	redirectCooldown, err := parseDurationAnnotation(RedirectCooldownAnnotationKey, cfg.RedirectCooldown, false)
+	if err != nil {
+		return nil, err
+	}
+
+	startupGrace, err := parseDurationAnnotation(StartupGraceAnnotationKey, cfg.StartupGrace, false)
+	if err != nil {
+		return nil, err
+	}
+
+	activityCheckInterval, err := parsePositiveDurationAnnotation(ActivityIntervalAnnotationKey, cfg.ActivityCheckInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	reloadMounts := []string{}
+	if len(cfg.ReloadMounts) != 0 {
+		reloadMounts = strings.Split(cfg.ReloadMounts, containersDelim)
+		for _, dest := range reloadMounts {
+			if !path.IsAbs(dest) {
+				return nil, annotationError(ReloadMountsAnnotationKey,
+					fmt.Errorf("mount destination %q needs to be an absolute path", dest))
+			}
+		}
+	}
+
+	if len(cfg.ConnectionHandoff) != 0 && !path.IsAbs(cfg.ConnectionHandoff) {
+		return nil, annotationError(ConnectionHandoffAnnotationKey,
+			fmt.Errorf("handoff socket %q needs to be an absolute path", cfg.ConnectionHandoff))
+	}
+
+	checkpointRootfs, err := parseBoolAnnotation(CheckpointRootfsAnnotationKey, cfg.CheckpointRootfs)
+	if err != nil {
+		return nil, err
+	}
+
+	var readinessProbe *ReadinessProbe
+	if len(cfg.ReadinessProbe) != 0 {
+		readinessProbe, err = parseReadinessProbe(cfg.ReadinessProbe)
+		if err != nil {
+			return nil, annotationError(ReadinessProbeAnnotationKey, err)
+		}
+	}
+
+	var restoreHealthCheck *ReadinessProbe
+	if len(cfg.RestoreHealthCheck) != 0 {
+		restoreHealthCheck, err = parseReadinessProbe(cfg.RestoreHealthCheck)
+		if err != nil {
+			return nil, annotationError(RestoreHealthCheckAnnotationKey, err)
+		}
+	}
+
+	numaAffinity, err := parseBoolAnnotation(NUMAAffinityAnnotationKey, cfg.NUMAAffinity)
+	if err != nil {
+		return nil, err
+	}
+
+	uncheckpointable := UncheckpointablePolicyKeepRunning
+	if len(cfg.Uncheckpointable) != 0 {
+		uncheckpointable = UncheckpointablePolicy(cfg.Uncheckpointable)
+		switch uncheckpointable {
+		case UncheckpointablePolicyKeepRunning, UncheckpointablePolicyColdStart:
+		default:
+			return nil, annotationError(UncheckpointableAnnotationKey, fmt.Errorf("invalid uncheckpointable policy %q, must be one of %q, %q",
+				uncheckpointable, UncheckpointablePolicyKeepRunning, UncheckpointablePolicyColdStart))
+		}
+	}
+
+	var criuGhostLimit int64
+	if len(cfg.CRIUGhostLimit) != 0 {
+		quantity, err := resource.ParseQuantity(cfg.CRIUGhostLimit)
+		if err != nil {
+			return nil, annotationError(CRIUGhostLimitAnnotationKey, fmt.Errorf("invalid ghost limit: %w", err))
+		}
+		criuGhostLimit = quantity.Value()
+		if criuGhostLimit <= 0 {
+			return nil, annotationError(CRIUGhostLimitAnnotationKey, fmt.Errorf("ghost limit needs to be positive, got %s", cfg.CRIUGhostLimit))
+		}
+	}
+
+	criuPreDumpMode := CRIUPreDumpModeSplice
+	if len(cfg.CRIUPreDumpMode) != 0 {
+		criuPreDumpMode = CRIUPreDumpMode(cfg.CRIUPreDumpMode)
+		switch criuPreDumpMode {
+		case CRIUPreDumpModeSplice, CRIUPreDumpModeRead:
+		default:
+			return nil, annotationError(CRIUPreDumpModeAnnotationKey, fmt.Errorf("invalid pre-dump mode %q, must be one of %q, %q",
+				criuPreDumpMode, CRIUPreDumpModeSplice, CRIUPreDumpModeRead))
+		}
+	}
+
+	var criuManageCgroups CRIUManageCgroupsMode
+	if len(cfg.CRIUManageCgroups) != 0 {
+		criuManageCgroups = CRIUManageCgroupsMode(cfg.CRIUManageCgroups)
+		switch criuManageCgroups {
+		case CRIUManageCgroupsModeSoft, CRIUManageCgroupsModeFull, CRIUManageCgroupsModeStrict, CRIUManageCgroupsModeIgnore:
+		default:
+			return nil, annotationError(CRIUManageCgroupsAnnotationKey, fmt.Errorf("invalid manage cgroups mode %q, must be one of %q, %q, %q, %q",
+				criuManageCgroups, CRIUManageCgroupsModeSoft, CRIUManageCgroupsModeFull, CRIUManageCgroupsModeStrict, CRIUManageCgroupsModeIgnore))
+		}
+	}
+
+	criuAutoDedup, err := parseBoolAnnotation(CRIUAutoDedupAnnotationKey, cfg.CRIUAutoDedup)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.CRIUPageServer) != 0 {
+		_, port, err := net.SplitHostPort(cfg.CRIUPageServer)
+		if err != nil {
+			return nil, annotationError(CRIUPageServerAnnotationKey, err)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, annotationError(CRIUPageServerAnnotationKey, fmt.Errorf("invalid port %q", port))
+		}
+	}
+
+	restoreRetries := 0
+	if len(cfg.RestoreRetries) != 0 {
+		restoreRetries, err = strconv.Atoi(cfg.RestoreRetries)
+		if err != nil {
+			return nil, annotationError(RestoreRetriesAnnotationKey, err)
+		}
+		if restoreRetries < 0 {
+			return nil, annotationError(RestoreRetriesAnnotationKey, fmt.Errorf("restore retries can't be negative, got %d", restoreRetries))
+		}
+	}
+
+	restoreRetryBackoff, err := parsePositiveDurationAnnotation(RestoreRetryBackoffAnnotationKey, cfg.RestoreRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	scaleEventHistorySize := 0
+	if len(cfg.ScaleEventHistory) != 0 {
+		scaleEventHistorySize, err = strconv.Atoi(cfg.ScaleEventHistory)
+		if err != nil {
+			return nil, annotationError(ScaleEventHistoryAnnotationKey, err)
+		}
+		if scaleEventHistorySize < 0 {
+			return nil, annotationError(ScaleEventHistoryAnnotationKey,
+				fmt.Errorf("scale event history can't be negative, got %d", scaleEventHistorySize))
+		}
+	}
+
+	restoreFailurePolicy := RestoreFailurePolicyExit
+	if len(cfg.RestoreFailurePolicy) != 0 {
+		restoreFailurePolicy = RestoreFailurePolicy(cfg.RestoreFailurePolicy)
+		switch restoreFailurePolicy {
+		case RestoreFailurePolicyExit, RestoreFailurePolicyFail, RestoreFailurePolicyColdStart, RestoreFailurePolicyRetry:
+		default:
+			return nil, annotationError(RestoreFailurePolicyAnnotationKey, fmt.Errorf("invalid restore failure policy %q, must be one of %q, %q, %q, %q",
+				restoreFailurePolicy, RestoreFailurePolicyExit, RestoreFailurePolicyFail, RestoreFailurePolicyColdStart, RestoreFailurePolicyRetry))
+		}
+	}
+
+	activatorMetering, err := parseBoolAnnotation(ActivatorMeteringAnnotationKey, cfg.ActivatorMetering)
+	if err != nil {
+		return nil, err
+	}
+	if activatorMetering && activatorBackend != activator.BackendRedirect {
+		return nil, annotationError(ActivatorMeteringAnnotationKey, fmt.Errorf("activator metering is only supported with the %q activator backend", activator.BackendRedirect))
+	}
+
+	maxScaledLifetime, err := parsePositiveDurationAnnotation(MaxScaledLifetimeAnnotationKey, cfg.MaxScaledLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	eagerCheckpoint, err := parsePositiveDurationAnnotation(EagerCheckpointAnnotationKey, cfg.EagerCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	if eagerCheckpoint != 0 && runtime.GOARCH == "arm64" {
+		// the eager checkpoint is a pre-dump, see above.
+		log.G(ctx).Warnf("disabling eager checkpoint: it was requested but is not supported on %s", runtime.GOARCH)
+		eagerCheckpoint = 0
+	}
+
+	httpHoldingTimeouts := map[uint16]time.Duration{}
+	if len(cfg.HTTPHoldingTimeout) != 0 {
+		if activatorBackend != activator.BackendRedirect {
+			return nil, annotationError(HTTPHoldingTimeoutAnnotationKey, fmt.Errorf("http holding timeout is only supported with the %q activator backend", activator.BackendRedirect))
+		}
+		httpHoldingTimeouts, err = parsePortDurations(cfg.HTTPHoldingTimeout)
+		if err != nil {
+			return nil, annotationError(HTTPHoldingTimeoutAnnotationKey, err)
+		}
+	}
+
+	var originalDstPorts []uint16
+	if len(cfg.OriginalDstPorts) != 0 {
+		if activatorBackend != activator.BackendRedirect {
+			return nil, annotationError(OriginalDstPortsAnnotationKey, fmt.Errorf("original destination ports are only supported with the %q activator backend", activator.BackendRedirect))
+		}
+		for _, port := range strings.Split(cfg.OriginalDstPorts, portsDelim) {
+			p, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				return nil, annotationError(OriginalDstPortsAnnotationKey, err)
+			}
+			if p == 0 {
+				return nil, annotationError(OriginalDstPortsAnnotationKey, fmt.Errorf("port must not be 0"))
+			}
+			// without a ports-map, the ports are detected once the
+			// container is running.
+			if len(containerPorts) != 0 && !slices.Contains(containerPorts, uint16(p)) {
+				return nil, annotationError(OriginalDstPortsAnnotationKey, fmt.Errorf("port %d is not a port of container %s in %s",
+					p, cfg.ContainerName, AnnotationKey(PortsAnnotationKey)))
+			}
+			originalDstPorts = append(originalDstPorts, uint16(p))
+		}
+	}
+
+	var postRestoreCommand []string
+	if len(cfg.PostRestoreCommand) != 0 {
+		postRestoreCommand = strings.Fields(cfg.PostRestoreCommand)
+		if len(postRestoreCommand) == 0 {
+			return nil, annotationError(PostRestoreCommandAnnotationKey, fmt.Errorf("post restore command is empty"))
+		}
+	}
+
+	var coldStartCommand []string
+	if len(cfg.ColdStartCommand) != 0 {
+		coldStartCommand = strings.Fields(cfg.ColdStartCommand)
+		if len(coldStartCommand) == 0 {
+			return nil, annotationError(ColdStartCommandAnnotationKey, fmt.Errorf("cold start command is empty"))
+		}
+	}
+
+	var logBufferSize int64
+	if len(cfg.LogBufferSize) != 0 {
+		logBufferSize, err = parsePositiveQuantity(cfg.LogBufferSize)
+		if err != nil {
+			return nil, annotationError(LogBufferSizeAnnotationKey, fmt.Errorf("invalid log buffer size: %w", err))
+		}
+	}
+
+	maxLogLineSize := int64(defaultMaxLogLineSize)
+	if len(cfg.MaxLogLineSize) != 0 {
+		maxLogLineSize, err = parsePositiveQuantity(cfg.MaxLogLineSize)
+		if err != nil {
+			return nil, annotationError(MaxLogLineSizeAnnotationKey, fmt.Errorf("invalid max log line size: %w", err))
+		}
+	}
+
+	restoreOnStop, err := parseBoolAnnotation(RestoreOnStopAnnotationKey, cfg.RestoreOnStop)
+	if err != nil {
+		return nil, err
+	}
+
+	prefetchInterval, err := parsePositiveDurationAnnotation(PrefetchIntervalAnnotationKey, cfg.PrefetchInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	timerMode := TimerModeWallClock
+	if len(cfg.TimerMode) != 0 {
+		timerMode = TimerMode(cfg.TimerMode)
+		switch timerMode {
+		case TimerModeWallClock, TimerModePaused:
+		default:
+			return nil, annotationError(TimerModeAnnotationKey, fmt.Errorf("invalid timer mode %q, must be one of %q, %q",
+				timerMode, TimerModeWallClock, TimerModePaused))
+		}
+	}
+
+	var maxDiskWriteRate int64
+	if len(cfg.MaxDiskWriteRate) != 0 {
+		maxDiskWriteRate, err = parsePositiveQuantity(cfg.MaxDiskWriteRate)
+		if err != nil {
+			return nil, annotationError(MaxDiskWriteRateAnnotationKey, err)
+		}
+	}
+
+	var minMemory int64
+	if len(cfg.MinMemory) != 0 {
+		minMemory, err = parsePositiveQuantity(cfg.MinMemory)
+		if err != nil {
+			return nil, annotationError(MinMemoryAnnotationKey, err)
+		}
+	}
+
+	reclaimSandbox, err := parseBoolAnnotation(ReclaimSandboxAnnotationKey, cfg.ReclaimSandbox)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyCheckpoint := false
+	if len(cfg.VerifyCheckpoint) != 0 {
+		verifyCheckpoint, err = parseContainerBool(cfg.VerifyCheckpoint, cfg.ContainerName)
+		if err != nil {
+			return nil, annotationError(VerifyCheckpointAnnotationKey, err)
+		}
+	}
+
+	var scaleDownPriority int64
+	if len(cfg.ScaleDownPriority) != 0 {
+		scaleDownPriority, err = strconv.ParseInt(cfg.ScaleDownPriority, 10, 32)
+		if err != nil {
+			return nil, annotationError(ScaleDownPriorityAnnotationKey, err)
+		}
+	}
+
+	var restoreSignal syscall.Signal
+	if len(cfg.RestoreSignal) != 0 {
+		restoreSignal, err = parseSignal(cfg.RestoreSignal)
+		if err != nil {
+			return nil, annotationError(RestoreSignalAnnotationKey, err)
+		}
+	}
+	if len(reloadMounts) != 0 && restoreSignal == 0 {
+		return nil, annotationError(ReloadMountsAnnotationKey,
+			fmt.Errorf("the process is told to reload mounts with the restore signal, %s needs to be set", AnnotationKey(RestoreSignalAnnotationKey)))
+	}
+
+	wakeSignals := []syscall.Signal{}
+	if len(cfg.WakeSignals) != 0 {
+		for _, value := range strings.Split(cfg.WakeSignals, containersDelim) {
+			sig, err := parseSignal(strings.TrimSpace(value))
+			if err != nil {
+				return nil, annotationError(WakeSignalsAnnotationKey, err)
+			}
+			if sig == syscall.SIGKILL || sig == syscall.SIGSTOP {
+				return nil, annotationError(WakeSignalsAnnotationKey,
+					fmt.Errorf("%s can't be handled by the process", unix.SignalName(sig)))
+			}
+			wakeSignals = append(wakeSignals, sig)
+		}
+	}
+
 	containerNames := []string{}
 	if len(cfg.ZeropodContainerNames) != 0 {
 		containerNames = strings.Split(cfg.ZeropodContainerNames, containersDelim)
+		for _, pattern := range containerNames {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, annotationError(ContainerNamesAnnotationKey, fmt.Errorf("invalid container name pattern %q: %w", pattern, err))
+			}
+		}
 	}
 
 	ns, ok := namespaces.Namespace(ctx)
@@ -138,20 +854,278 @@ func NewConfig(ctx context.Context, spec *specs.Spec) (*Config, error) {
 		ScaleDownDuration:     dur,
 		DisableCheckpointing:  disableCheckpointing,
 		PreDump:               preDump,
+		RestoreTimeout:        restoreTimeout,
+		LogPath:               logPath,
+		ActivatorBackend:      activatorBackend,
+		RefreshDNSConfig:      refreshDNSConfig,
+		KeepTimerOnExec:       keepTimerOnExec,
+		RestoreSiblings:       restoreSiblings,
+		ScaleDownStrategy:     scaleDownStrategy,
+		VerifyCheckpoint:      verifyCheckpoint,
+		ScaleDownPriority:     int32(scaleDownPriority),
+		RestoreSignal:         restoreSignal,
+		ScaleDownTrigger:      scaleDownTrigger,
+		HandoffGracePeriod:    handoffGracePeriod,
+		CRIUGhostLimit:        criuGhostLimit,
+		RestoreRetries:        restoreRetries,
+		RestoreFailurePolicy:  restoreFailurePolicy,
+		ActivatorMetering:     activatorMetering,
+		MaxScaledLifetime:     maxScaledLifetime,
+		EagerCheckpoint:       eagerCheckpoint,
+		HTTPHoldingTimeouts:   httpHoldingTimeouts,
+		PostRestoreCommand:    postRestoreCommand,
+		LogBufferSize:         logBufferSize,
+		MaxLogLineSize:        maxLogLineSize,
+		RestoreOnStop:         restoreOnStop,
+		RestoreRetryBackoff:   restoreRetryBackoff,
+		ScaleEventHistorySize: scaleEventHistorySize,
+		WakeSignals:           wakeSignals,
+		ReclaimSandbox:        reclaimSandbox,
+		CRIUPageServer:        cfg.CRIUPageServer,
+		MaxDiskWriteRate:      maxDiskWriteRate,
+		MinMemory:             minMemory,
+		PrefetchInterval:      prefetchInterval,
+		TimerMode:             timerMode,
+		OriginalDstPorts:      originalDstPorts,
+		RedirectCooldown:      redirectCooldown,
+		ReadinessProbe:        readinessProbe,
+		CRIUPreDumpMode:       criuPreDumpMode,
+		CRIUAutoDedup:         criuAutoDedup,
+		ActivityCheckInterval: activityCheckInterval,
+		ReloadMounts:          reloadMounts,
+		CheckpointRootfs:      checkpointRootfs,
+		RestoreHealthCheck:    restoreHealthCheck,
+		Uncheckpointable:      uncheckpointable,
+		NUMAAffinity:          numaAffinity,
+		ColdStartCommand:      coldStartCommand,
+		CRIUManageCgroups:     criuManageCgroups,
+		ConnectionHandoff:     cfg.ConnectionHandoff,
+		StartupGrace:          startupGrace,
 		ZeropodContainerNames: containerNames,
 		ContainerName:         cfg.ContainerName,
 		ContainerType:         cfg.ContainerType,
 		PodName:               cfg.PodName,
 		PodNamespace:          cfg.PodNamespace,
 		PodUID:                cfg.PodUID,
+		VClusterPodName:       cfg.VClusterPodName,
+		VClusterPodNamespace:  cfg.VClusterPodNamespace,
 		ContainerdNamespace:   ns,
 		spec:                  spec,
+		annotated:             hasZeropodAnnotations(annotations),
 	}, nil
 }
 
+// ConfigError is returned by NewConfig if an annotation of the pod contains
+// an invalid value.
+type ConfigError struct {
+	Annotation string
+	Err        error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid annotation %s: %s", e.Annotation, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func annotationError(annotation string, err error) error {
+	return &ConfigError{Annotation: AnnotationKey(annotation), Err: err}
+}
+
+// parseDurationAnnotation parses the value of the duration annotation with
+// the supplied key. An empty value is a zero duration.
+func parseDurationAnnotation(key, value string, allowNegative bool) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, annotationError(key, err)
+	}
+	if d < 0 && !allowNegative {
+		return 0, annotationError(key, fmt.Errorf("duration can't be negative, got %s", d))
+	}
+	return d, nil
+}
+
+// parsePositiveDurationAnnotation is like parseDurationAnnotation but a
+// value that is set needs to be positive.
+func parsePositiveDurationAnnotation(key, value string) (time.Duration, error) {
+	d, err := parseDurationAnnotation(key, value, false)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) != 0 && d == 0 {
+		return 0, annotationError(key, fmt.Errorf("duration needs to be positive, got %s", d))
+	}
+	return d, nil
+}
+
+// parseBoolAnnotation parses the value of the bool annotation with the
+// supplied key. An empty value is false.
+func parseBoolAnnotation(key, value string) (bool, error) {
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, annotationError(key, err)
+	}
+	return b, nil
+}
+
+// parseContainerBool parses a bool annotation that can be configured per
+// container. It's either a bool that applies to all containers of the pod or
+// a map of container names to bools (name=bool;name2=bool). Containers
+// missing from the map are false. If a container is listed more than once,
+// the first entry is used.
+func parseContainerBool(value, containerName string) (bool, error) {
+	if !strings.Contains(value, mapDelim) {
+		return strconv.ParseBool(value)
+	}
+
+	for _, mapping := range strings.Split(value, mappingDelim) {
+		nameValue := strings.Split(mapping, mapDelim)
+		if len(nameValue) != 2 {
+			return false, fmt.Errorf("invalid map %q, the format needs to be name=bool", value)
+		}
+
+		name, b := nameValue[0], nameValue[1]
+		if name != containerName {
+			continue
+		}
+
+		return strconv.ParseBool(b)
+	}
+
+	return false, nil
+}
+
+// parseContainerLogPath parses a map of container names to absolute log
+// paths (name=path;name2=path). Containers missing from the map use the
+// default log path.
+func parseContainerLogPath(value, containerName string) (string, error) {
+	for _, mapping := range strings.Split(value, mappingDelim) {
+		namePath := strings.SplitN(mapping, mapDelim, 2)
+		if len(namePath) != 2 {
+			return "", fmt.Errorf("invalid log path map, the format needs to be name=path")
+		}
+
+		name, logPath := namePath[0], namePath[1]
+		if name != containerName {
+			continue
+		}
+		if !path.IsAbs(logPath) {
+			return "", fmt.Errorf("log path of container %s needs to be absolute, got %q", name, logPath)
+		}
+		return logPath, nil
+	}
+
+	return "", nil
+}
+
+// parsePortDurations parses a map of ports to positive durations
+// (port=duration;port2=duration).
+func parsePortDurations(value string) (map[uint16]time.Duration, error) {
+	durations := map[uint16]time.Duration{}
+	for _, mapping := range strings.Split(value, mappingDelim) {
+		portDuration := strings.Split(mapping, mapDelim)
+		if len(portDuration) != 2 {
+			return nil, fmt.Errorf("invalid map %q, the format needs to be port=duration", value)
+		}
+
+		port, err := strconv.ParseUint(portDuration[0], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		duration, err := time.ParseDuration(portDuration[1])
+		if err != nil {
+			return nil, err
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("duration of port %d needs to be positive, got %s", port, duration)
+		}
+
+		durations[uint16(port)] = duration
+	}
+
+	return durations, nil
+}
+
+// parsePositiveQuantity parses a quantity (e.g. 64Ki) that needs to be
+// positive.
+func parsePositiveQuantity(value string) (int64, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Value() <= 0 {
+		return 0, fmt.Errorf("needs to be positive, got %s", value)
+	}
+	return quantity.Value(), nil
+}
+
+// parseSignal parses a signal by its name (e.g. SIGHUP or HUP) or number.
+func parseSignal(value string) (syscall.Signal, error) {
+	if num, err := strconv.Atoi(value); err == nil {
+		if unix.SignalName(syscall.Signal(num)) == "" {
+			return 0, fmt.Errorf("invalid signal %q", value)
+		}
+		return syscall.Signal(num), nil
+	}
+
+	name := strings.ToUpper(value)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("invalid signal %q", value)
+	}
+	return sig, nil
+}
+
+// VirtualPodName returns the name of the pod as seen by the user. If the pod
+// has been synced by vcluster, this is the name within the virtual cluster,
+// otherwise it's the same as PodName. It's only meant for reporting (e.g.
+// metrics), anything interacting with the node or the host cluster (e.g. the
+// pod log dir or the status the manager patches the pod with) needs to use
+// PodName and PodNamespace.
+func (cfg Config) VirtualPodName() string {
+	if cfg.VClusterPodName != "" {
+		return cfg.VClusterPodName
+	}
+	return cfg.PodName
+}
+
+// VirtualPodNamespace returns the namespace of the pod as seen by the user.
+// If the pod has been synced by vcluster, this is the namespace within the
+// virtual cluster, otherwise it's the same as PodNamespace.
+func (cfg Config) VirtualPodNamespace() string {
+	if cfg.VClusterPodNamespace != "" {
+		return cfg.VClusterPodNamespace
+	}
+	return cfg.PodNamespace
+}
+
+// IsZeropodContainer returns true if the container matches any of the
+// configured container names. The names can be glob patterns (e.g. worker-*)
+// as supported by path.Match.
 func (cfg Config) IsZeropodContainer() bool {
-	for _, n := range cfg.ZeropodContainerNames {
-		if n == cfg.ContainerName {
+	if cfg.ContainerName == "" {
+		// without the CRI annotations (e.g. containers created with ctr)
+		// there is no name to match, so only containers that have been
+		// annotated for zeropod explicitly are considered.
+		return cfg.annotated
+	}
+
+	for _, pattern := range cfg.ZeropodContainerNames {
+		// the patterns have been validated when parsing the config
+		if ok, _ := path.Match(pattern, cfg.ContainerName); ok {
 			return true
 		}
 	}
@@ -159,3 +1133,77 @@ func (cfg Config) IsZeropodContainer() bool {
 	// if there is none specified, every one of them is considered.
 	return len(cfg.ZeropodContainerNames) == 0
 }
+
+// IsZeropodSpec returns true if the container of the spec would be selected
+// by IsZeropodContainer. Unlike NewConfig, it only looks at the annotations
+// that select the containers, so it can be used to decide if an invalid
+// config concerns the container at all. If the container names themselves
+// are invalid, every container of the pod is considered selected.
+func IsZeropodSpec(spec *specs.Spec) bool {
+	specAnnotations := withDefaultPrefix(spec.Annotations, annotationPrefix)
+	cfg := &annotationConfig{}
+	if err := mapstructure.Decode(specAnnotations, cfg); err != nil {
+		return true
+	}
+	if cfg.ContainerType == annotations.ContainerTypeSandbox {
+		return false
+	}
+
+	containerNames := []string{}
+	if len(cfg.ZeropodContainerNames) != 0 {
+		containerNames = strings.Split(cfg.ZeropodContainerNames, containersDelim)
+		for _, pattern := range containerNames {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return true
+			}
+		}
+	}
+
+	return Config{
+		ZeropodContainerNames: containerNames,
+		ContainerName:         cfg.ContainerName,
+		annotated:             hasZeropodAnnotations(specAnnotations),
+	}.IsZeropodContainer()
+}
+
+// SharedPIDNamespace returns true if the container joins an existing pid
+// namespace instead of getting its own, which is the case if the pod shares
+// the process namespace between its containers.
+func (cfg Config) SharedPIDNamespace() bool {
+	if cfg.spec == nil || cfg.spec.Linux == nil {
+		return false
+	}
+
+	for _, ns := range cfg.spec.Linux.Namespaces {
+		if ns.Type == specs.PIDNamespace {
+			return ns.Path != ""
+		}
+	}
+	return false
+}
+
+// hasZeropodAnnotations returns true if any of the annotations has the
+// default prefix.
+func hasZeropodAnnotations(annotations map[string]string) bool {
+	for key := range annotations {
+		if strings.HasPrefix(key, DefaultAnnotationPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Privileged returns true if the container runs in privileged mode. The OCI
+// spec does not have a privileged flag, so it's detected by the properties
+// containerd sets for privileged containers: no masked and readonly paths
+// and CAP_SYS_ADMIN in the bounding set.
+func (cfg Config) Privileged() bool {
+	if cfg.spec == nil || cfg.spec.Linux == nil || cfg.spec.Process == nil ||
+		cfg.spec.Process.Capabilities == nil {
+		return false
+	}
+
+	return len(cfg.spec.Linux.MaskedPaths) == 0 &&
+		len(cfg.spec.Linux.ReadonlyPaths) == 0 &&
+		slices.Contains(cfg.spec.Process.Capabilities.Bounding, "CAP_SYS_ADMIN")
+}
@@ -3,9 +3,12 @@ package zeropod
 import (
 	"context"
 	"runtime"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/ctrox/zeropod/activator"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,8 +16,10 @@ import (
 
 func TestNewConfig(t *testing.T) {
 	tests := map[string]struct {
-		annotations map[string]string
-		assertCfg   func(t *testing.T, cfg *Config)
+		annotations        map[string]string
+		expectErr          bool
+		expectedAnnotation string
+		assertCfg          func(t *testing.T, cfg *Config)
 	}{
 		"ports": {
 			annotations: map[string]string{
@@ -25,6 +30,49 @@ func TestNewConfig(t *testing.T) {
 				assert.Equal(t, []uint16{80, 81}, cfg.Ports)
 			},
 		},
+		"privileged ports": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PortsAnnotationKey:         "container1=80,443",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []uint16{80, 443}, cfg.Ports)
+			},
+		},
+		"duplicate container in ports": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PortsAnnotationKey:         "container1=80,81;container0=8080;container1=81,443",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []uint16{80, 81, 443}, cfg.Ports)
+			},
+		},
+		"invalid ports of other container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PortsAnnotationKey:         "container0=http;container1=80",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []uint16{80}, cfg.Ports)
+			},
+		},
+		"invalid port": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PortsAnnotationKey:         "container1=0",
+			},
+			expectErr:          true,
+			expectedAnnotation: PortsAnnotationKey,
+		},
+		"invalid port map format": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PortsAnnotationKey:         "container1:80",
+			},
+			expectErr:          true,
+			expectedAnnotation: PortsAnnotationKey,
+		},
 		"container names": {
 			annotations: map[string]string{
 				CRIContainerNameAnnotation:  "container1",
@@ -36,6 +84,14 @@ func TestNewConfig(t *testing.T) {
 					cfg.ZeropodContainerNames)
 			},
 		},
+		"invalid container name pattern": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:  "container1",
+				ContainerNamesAnnotationKey: "container[",
+			},
+			expectErr:          true,
+			expectedAnnotation: ContainerNamesAnnotationKey,
+		},
 		"scaledown duration": {
 			annotations: map[string]string{
 				ScaleDownDurationAnnotationKey: "5m",
@@ -76,18 +132,1128 @@ func TestNewConfig(t *testing.T) {
 				assert.False(t, cfg.PreDump)
 			},
 		},
-	}
-
-	for name, tc := range tests {
-		t.Run(name, func(t *testing.T) {
-			if tc.annotations[PreDumpAnnotationKey] == "true" && runtime.GOARCH == "arm64" {
-				t.Skip("skipping pre-dump test as it's not supported on arm64")
-			}
-			cfg, err := NewConfig(context.Background(), &specs.Spec{
-				Annotations: tc.annotations,
-			})
-			require.NoError(t, err)
-			tc.assertCfg(t, cfg)
+		"predump per container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PreDumpAnnotationKey:       "container0=false;container1=true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.PreDump)
+			},
+		},
+		"predump disabled per container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container0",
+				PreDumpAnnotationKey:       "container0=false;container1=true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.False(t, cfg.PreDump)
+			},
+		},
+		"predump of container missing in map": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container2",
+				PreDumpAnnotationKey:       "container0=false;container1=true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.False(t, cfg.PreDump)
+			},
+		},
+		"invalid predump map": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				PreDumpAnnotationKey:       "container0=false;container1",
+			},
+			expectErr: true,
+		},
+		"restore timeout": {
+			annotations: map[string]string{
+				RestoreTimeoutAnnotationKey: "30s",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Second*30, cfg.RestoreTimeout)
+			},
+		},
+		"negative restore timeout": {
+			annotations: map[string]string{
+				RestoreTimeoutAnnotationKey: "-30s",
+			},
+			expectErr:          true,
+			expectedAnnotation: RestoreTimeoutAnnotationKey,
+		},
+		"no restore timeout by default": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Zero(t, cfg.RestoreTimeout)
+			},
+		},
+		"activator backend": {
+			annotations: map[string]string{
+				ActivatorBackendAnnotationKey: "nfqueue",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, activator.BackendNFQueue, cfg.ActivatorBackend)
+			},
+		},
+		"redirect activator backend by default": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, activator.BackendRedirect, cfg.ActivatorBackend)
+			},
+		},
+		"invalid activator backend": {
+			annotations: map[string]string{
+				ActivatorBackendAnnotationKey: "foo",
+			},
+			expectErr: true,
+		},
+		"log path": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				LogPathAnnotationKey:       "container1=/var/log/custom/container1.log;container2=/var/log/custom/container2.log",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "/var/log/custom/container1.log", cfg.LogPath)
+			},
+		},
+		"log path of other container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				LogPathAnnotationKey:       "container2=/var/log/custom/container2.log",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Empty(t, cfg.LogPath)
+			},
+		},
+		"invalid log path map": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				LogPathAnnotationKey:       "/var/log/custom/container.log",
+			},
+			expectErr:          true,
+			expectedAnnotation: LogPathAnnotationKey,
+		},
+		"relative log path": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "container1",
+				LogPathAnnotationKey:       "container1=container.log",
+			},
+			expectErr:          true,
+			expectedAnnotation: LogPathAnnotationKey,
+		},
+		"refresh dns config": {
+			annotations: map[string]string{
+				RefreshDNSConfigAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.RefreshDNSConfig)
+			},
+		},
+		"vcluster pod identity": {
+			annotations: map[string]string{
+				"io.kubernetes.cri.sandbox-name":      "nginx-x-default-x-vcluster",
+				"io.kubernetes.cri.sandbox-namespace": "vcluster",
+				VClusterPodNameAnnotationKey:          "nginx",
+				VClusterPodNamespaceAnnotationKey:     "default",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "nginx-x-default-x-vcluster", cfg.PodName)
+				assert.Equal(t, "vcluster", cfg.PodNamespace)
+				assert.Equal(t, "nginx", cfg.VirtualPodName())
+				assert.Equal(t, "default", cfg.VirtualPodNamespace())
+			},
+		},
+		"host pod identity without vcluster": {
+			annotations: map[string]string{
+				"io.kubernetes.cri.sandbox-name":      "nginx",
+				"io.kubernetes.cri.sandbox-namespace": "default",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "nginx", cfg.VirtualPodName())
+				assert.Equal(t, "default", cfg.VirtualPodNamespace())
+			},
+		},
+		"keep timer on exec": {
+			annotations: map[string]string{
+				KeepTimerOnExecAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.KeepTimerOnExec)
+			},
+		},
+		"keep timer on exec per container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:   "nginx",
+				KeepTimerOnExecAnnotationKey: "nginx=true;sidecar=false",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.KeepTimerOnExec)
+			},
+		},
+		"restore siblings": {
+			annotations: map[string]string{
+				RestoreSiblingsAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.RestoreSiblings)
+			},
+		},
+		"checkpoint scale down strategy by default": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, ScaleDownStrategyCheckpoint, cfg.ScaleDownStrategy)
+			},
+		},
+		"reclaim scale down strategy": {
+			annotations: map[string]string{
+				ScaleDownStrategyAnnotationKey: "reclaim",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, ScaleDownStrategyReclaim, cfg.ScaleDownStrategy)
+			},
+		},
+		"invalid scale down strategy": {
+			annotations: map[string]string{
+				ScaleDownStrategyAnnotationKey: "foo",
+			},
+			expectErr: true,
+		},
+		"idle timer scale down trigger by default": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, ScaleDownTriggerIdleTimer, cfg.ScaleDownTrigger)
+			},
+		},
+		"cpu idle scale down trigger": {
+			annotations: map[string]string{
+				ScaleDownTriggerAnnotationKey: "cpu-idle",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, ScaleDownTriggerCPUIdle, cfg.ScaleDownTrigger)
+			},
+		},
+		"invalid scale down trigger": {
+			annotations: map[string]string{
+				ScaleDownTriggerAnnotationKey: "foo",
+			},
+			expectErr: true,
+		},
+		"handoff grace period": {
+			annotations: map[string]string{
+				HandoffGracePeriodAnnotationKey: "100ms",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Millisecond*100, cfg.HandoffGracePeriod)
+			},
+		},
+		"invalid handoff grace period": {
+			annotations: map[string]string{
+				HandoffGracePeriodAnnotationKey: "foo",
+			},
+			expectErr: true,
+		},
+		"handoff grace period too long": {
+			annotations: map[string]string{
+				HandoffGracePeriodAnnotationKey: "1m",
+			},
+			expectErr:          true,
+			expectedAnnotation: HandoffGracePeriodAnnotationKey,
+		},
+		"criu ghost limit": {
+			annotations: map[string]string{
+				CRIUGhostLimitAnnotationKey: "100Mi",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, int64(100<<20), cfg.CRIUGhostLimit)
+			},
+		},
+		"invalid criu ghost limit": {
+			annotations: map[string]string{
+				CRIUGhostLimitAnnotationKey: "-1Mi",
+			},
+			expectErr: true,
+		},
+		"restore retries": {
+			annotations: map[string]string{
+				RestoreRetriesAnnotationKey:       "3",
+				RestoreFailurePolicyAnnotationKey: "cold-start",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 3, cfg.RestoreRetries)
+				assert.Equal(t, RestoreFailurePolicyColdStart, cfg.RestoreFailurePolicy)
+			},
+		},
+		"exit on restore failure by default": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Zero(t, cfg.RestoreRetries)
+				assert.Equal(t, RestoreFailurePolicyExit, cfg.RestoreFailurePolicy)
+			},
+		},
+		"negative restore retries": {
+			annotations: map[string]string{
+				RestoreRetriesAnnotationKey: "-1",
+			},
+			expectErr: true,
+		},
+		"activator metering": {
+			annotations: map[string]string{
+				ActivatorMeteringAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.ActivatorMetering)
+			},
+		},
+		"activator metering with nfqueue backend": {
+			annotations: map[string]string{
+				ActivatorMeteringAnnotationKey: "true",
+				ActivatorBackendAnnotationKey:  "nfqueue",
+			},
+			expectErr: true,
+		},
+		"max scaled lifetime": {
+			annotations: map[string]string{
+				MaxScaledLifetimeAnnotationKey: "168h",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Hour*168, cfg.MaxScaledLifetime)
+			},
+		},
+		"invalid max scaled lifetime": {
+			annotations: map[string]string{
+				MaxScaledLifetimeAnnotationKey: "0s",
+			},
+			expectErr:          true,
+			expectedAnnotation: MaxScaledLifetimeAnnotationKey,
+		},
+		"eager checkpoint": {
+			annotations: map[string]string{
+				EagerCheckpointAnnotationKey: "30s",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				if runtime.GOARCH == "arm64" {
+					assert.Zero(t, cfg.EagerCheckpoint)
+					return
+				}
+				assert.Equal(t, time.Second*30, cfg.EagerCheckpoint)
+			},
+		},
+		"invalid eager checkpoint": {
+			annotations: map[string]string{
+				EagerCheckpointAnnotationKey: "true",
+			},
+			expectErr:          true,
+			expectedAnnotation: EagerCheckpointAnnotationKey,
+		},
+		"http holding timeout": {
+			annotations: map[string]string{
+				HTTPHoldingTimeoutAnnotationKey: "80=2s;8080=500ms",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, map[uint16]time.Duration{
+					80:   time.Second * 2,
+					8080: time.Millisecond * 500,
+				}, cfg.HTTPHoldingTimeouts)
+			},
+		},
+		"invalid http holding timeout": {
+			annotations: map[string]string{
+				HTTPHoldingTimeoutAnnotationKey: "80=0s",
+			},
+			expectErr:          true,
+			expectedAnnotation: HTTPHoldingTimeoutAnnotationKey,
+		},
+		"http holding timeout with nfqueue backend": {
+			annotations: map[string]string{
+				HTTPHoldingTimeoutAnnotationKey: "80=2s",
+				ActivatorBackendAnnotationKey:   "nfqueue",
+			},
+			expectErr:          true,
+			expectedAnnotation: HTTPHoldingTimeoutAnnotationKey,
+		},
+		"post restore command": {
+			annotations: map[string]string{
+				PostRestoreCommandAnnotationKey: "nscd --invalidate hosts",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"nscd", "--invalidate", "hosts"}, cfg.PostRestoreCommand)
+			},
+		},
+		"empty post restore command": {
+			annotations: map[string]string{
+				PostRestoreCommandAnnotationKey: " ",
+			},
+			expectErr:          true,
+			expectedAnnotation: PostRestoreCommandAnnotationKey,
+		},
+		"log buffer size": {
+			annotations: map[string]string{
+				LogBufferSizeAnnotationKey:  "1Mi",
+				MaxLogLineSizeAnnotationKey: "64Ki",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, int64(1024*1024), cfg.LogBufferSize)
+				assert.Equal(t, int64(64*1024), cfg.MaxLogLineSize)
+			},
+		},
+		"default log buffer size": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Zero(t, cfg.LogBufferSize)
+				assert.Equal(t, int64(defaultMaxLogLineSize), cfg.MaxLogLineSize)
+			},
+		},
+		"invalid max log line size": {
+			annotations: map[string]string{
+				MaxLogLineSizeAnnotationKey: "0",
+			},
+			expectErr:          true,
+			expectedAnnotation: MaxLogLineSizeAnnotationKey,
+		},
+		"restore on stop": {
+			annotations: map[string]string{
+				RestoreOnStopAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.RestoreOnStop)
+			},
+		},
+		"invalid restore on stop": {
+			annotations: map[string]string{
+				RestoreOnStopAnnotationKey: "foo",
+			},
+			expectErr:          true,
+			expectedAnnotation: RestoreOnStopAnnotationKey,
+		},
+		"restore retry backoff": {
+			annotations: map[string]string{
+				RestoreRetryBackoffAnnotationKey:  "500ms",
+				RestoreFailurePolicyAnnotationKey: "retry",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Millisecond*500, cfg.RestoreRetryBackoff)
+				assert.Equal(t, RestoreFailurePolicyRetry, cfg.RestoreFailurePolicy)
+			},
+		},
+		"invalid restore retry backoff": {
+			annotations: map[string]string{
+				RestoreRetryBackoffAnnotationKey: "-1s",
+			},
+			expectErr:          true,
+			expectedAnnotation: RestoreRetryBackoffAnnotationKey,
+		},
+		"scale event history": {
+			annotations: map[string]string{
+				ScaleEventHistoryAnnotationKey: "20",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 20, cfg.ScaleEventHistorySize)
+			},
+		},
+		"invalid scale event history": {
+			annotations: map[string]string{
+				ScaleEventHistoryAnnotationKey: "-1",
+			},
+			expectErr:          true,
+			expectedAnnotation: ScaleEventHistoryAnnotationKey,
+		},
+		"wake signals": {
+			annotations: map[string]string{
+				WakeSignalsAnnotationKey: "SIGUSR1, hup,12",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []syscall.Signal{syscall.SIGUSR1, syscall.SIGHUP, syscall.SIGUSR2}, cfg.WakeSignals)
+			},
+		},
+		"wake signal that can't be handled": {
+			annotations: map[string]string{
+				WakeSignalsAnnotationKey: "SIGUSR1,SIGKILL",
+			},
+			expectErr:          true,
+			expectedAnnotation: WakeSignalsAnnotationKey,
+		},
+		"reclaim sandbox": {
+			annotations: map[string]string{
+				ReclaimSandboxAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.ReclaimSandbox)
+			},
+		},
+		"invalid reclaim sandbox": {
+			annotations: map[string]string{
+				ReclaimSandboxAnnotationKey: "maybe",
+			},
+			expectErr:          true,
+			expectedAnnotation: ReclaimSandboxAnnotationKey,
+		},
+		"criu page server": {
+			annotations: map[string]string{
+				CRIUPageServerAnnotationKey: "10.0.0.5:9876",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "10.0.0.5:9876", cfg.CRIUPageServer)
+			},
+		},
+		"criu page server without port": {
+			annotations: map[string]string{
+				CRIUPageServerAnnotationKey: "10.0.0.5",
+			},
+			expectErr:          true,
+			expectedAnnotation: CRIUPageServerAnnotationKey,
+		},
+		"criu page server with invalid port": {
+			annotations: map[string]string{
+				CRIUPageServerAnnotationKey: "10.0.0.5:99999",
+			},
+			expectErr:          true,
+			expectedAnnotation: CRIUPageServerAnnotationKey,
+		},
+		"max disk write rate": {
+			annotations: map[string]string{
+				MaxDiskWriteRateAnnotationKey: "10Mi",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, int64(10<<20), cfg.MaxDiskWriteRate)
+			},
+		},
+		"invalid max disk write rate": {
+			annotations: map[string]string{
+				MaxDiskWriteRateAnnotationKey: "0",
+			},
+			expectErr:          true,
+			expectedAnnotation: MaxDiskWriteRateAnnotationKey,
+		},
+		"min memory": {
+			annotations: map[string]string{
+				MinMemoryAnnotationKey: "64Mi",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, int64(64<<20), cfg.MinMemory)
+			},
+		},
+		"invalid min memory": {
+			annotations: map[string]string{
+				MinMemoryAnnotationKey: "-1",
+			},
+			expectErr:          true,
+			expectedAnnotation: MinMemoryAnnotationKey,
+		},
+		"prefetch interval": {
+			annotations: map[string]string{
+				PrefetchIntervalAnnotationKey: "10m",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Minute*10, cfg.PrefetchInterval)
+			},
+		},
+		"invalid prefetch interval": {
+			annotations: map[string]string{
+				PrefetchIntervalAnnotationKey: "0s",
+			},
+			expectErr:          true,
+			expectedAnnotation: PrefetchIntervalAnnotationKey,
+		},
+		"timer mode": {
+			annotations: map[string]string{
+				TimerModeAnnotationKey: "paused",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, TimerModePaused, cfg.TimerMode)
+			},
+		},
+		"default timer mode": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, TimerModeWallClock, cfg.TimerMode)
+			},
+		},
+		"invalid timer mode": {
+			annotations: map[string]string{
+				TimerModeAnnotationKey: "fast",
+			},
+			expectErr:          true,
+			expectedAnnotation: TimerModeAnnotationKey,
+		},
+		"original dst ports": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:    "container1",
+				PortsAnnotationKey:            "container1=80,8080",
+				OriginalDstPortsAnnotationKey: "80,8080",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []uint16{80, 8080}, cfg.OriginalDstPorts)
+			},
+		},
+		"invalid original dst ports": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:    "container1",
+				PortsAnnotationKey:            "container1=80",
+				OriginalDstPortsAnnotationKey: "80,http",
+			},
+			expectErr:          true,
+			expectedAnnotation: OriginalDstPortsAnnotationKey,
+		},
+		"original dst port 0": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:    "container1",
+				PortsAnnotationKey:            "container1=80",
+				OriginalDstPortsAnnotationKey: "0",
+			},
+			expectErr:          true,
+			expectedAnnotation: OriginalDstPortsAnnotationKey,
+		},
+		"original dst ports with detected ports": {
+			annotations: map[string]string{
+				OriginalDstPortsAnnotationKey: "80",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []uint16{80}, cfg.OriginalDstPorts)
+			},
+		},
+		"original dst port of other container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:    "container1",
+				PortsAnnotationKey:            "container0=8080;container1=80",
+				OriginalDstPortsAnnotationKey: "80,8080",
+			},
+			expectErr:          true,
+			expectedAnnotation: OriginalDstPortsAnnotationKey,
+		},
+		"redirect cooldown": {
+			annotations: map[string]string{
+				RedirectCooldownAnnotationKey: "500ms",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Millisecond*500, cfg.RedirectCooldown)
+			},
+		},
+		"invalid redirect cooldown": {
+			annotations: map[string]string{
+				RedirectCooldownAnnotationKey: "-1s",
+			},
+			expectErr:          true,
+			expectedAnnotation: RedirectCooldownAnnotationKey,
+		},
+		"startup grace": {
+			annotations: map[string]string{
+				StartupGraceAnnotationKey: "2m",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Minute*2, cfg.StartupGrace)
+			},
+		},
+		"invalid startup grace": {
+			annotations: map[string]string{
+				StartupGraceAnnotationKey: "-1s",
+			},
+			expectErr:          true,
+			expectedAnnotation: StartupGraceAnnotationKey,
+		},
+		"activity check interval": {
+			annotations: map[string]string{
+				ActivityIntervalAnnotationKey: "30s",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, time.Second*30, cfg.ActivityCheckInterval)
+			},
+		},
+		"invalid activity check interval": {
+			annotations: map[string]string{
+				ActivityIntervalAnnotationKey: "0s",
+			},
+			expectErr:          true,
+			expectedAnnotation: ActivityIntervalAnnotationKey,
+		},
+		"reload mounts": {
+			annotations: map[string]string{
+				ReloadMountsAnnotationKey:  "/etc/config,/etc/app.yaml",
+				RestoreSignalAnnotationKey: "SIGHUP",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"/etc/config", "/etc/app.yaml"}, cfg.ReloadMounts)
+			},
+		},
+		"invalid reload mounts": {
+			annotations: map[string]string{
+				ReloadMountsAnnotationKey:  "etc/config",
+				RestoreSignalAnnotationKey: "SIGHUP",
+			},
+			expectErr:          true,
+			expectedAnnotation: ReloadMountsAnnotationKey,
+		},
+		"reload mounts without restore signal": {
+			annotations: map[string]string{
+				ReloadMountsAnnotationKey: "/etc/config",
+			},
+			expectErr:          true,
+			expectedAnnotation: ReloadMountsAnnotationKey,
+		},
+		"checkpoint rootfs": {
+			annotations: map[string]string{
+				CheckpointRootfsAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.CheckpointRootfs)
+			},
+		},
+		"invalid checkpoint rootfs": {
+			annotations: map[string]string{
+				CheckpointRootfsAnnotationKey: "sometimes",
+			},
+			expectErr:          true,
+			expectedAnnotation: CheckpointRootfsAnnotationKey,
+		},
+		"restore health check": {
+			annotations: map[string]string{
+				RestoreHealthCheckAnnotationKey: "tcp:8080",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, &ReadinessProbe{Type: ReadinessProbeTCP, Port: 8080, Path: "/"}, cfg.RestoreHealthCheck)
+			},
+		},
+		"invalid restore health check": {
+			annotations: map[string]string{
+				RestoreHealthCheckAnnotationKey: "exec:true",
+			},
+			expectErr:          true,
+			expectedAnnotation: RestoreHealthCheckAnnotationKey,
+		},
+		"numa affinity": {
+			annotations: map[string]string{
+				NUMAAffinityAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.NUMAAffinity)
+			},
+		},
+		"invalid numa affinity": {
+			annotations: map[string]string{
+				NUMAAffinityAnnotationKey: "maybe",
+			},
+			expectErr:          true,
+			expectedAnnotation: NUMAAffinityAnnotationKey,
+		},
+		"cold start command": {
+			annotations: map[string]string{
+				ColdStartCommandAnnotationKey: "/warmup.sh --all",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, []string{"/warmup.sh", "--all"}, cfg.ColdStartCommand)
+			},
+		},
+		"empty cold start command": {
+			annotations: map[string]string{
+				ColdStartCommandAnnotationKey: " ",
+			},
+			expectErr:          true,
+			expectedAnnotation: ColdStartCommandAnnotationKey,
+		},
+		"uncheckpointable policy": {
+			annotations: map[string]string{
+				UncheckpointableAnnotationKey: "cold-start",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, UncheckpointablePolicyColdStart, cfg.Uncheckpointable)
+			},
+		},
+		"invalid uncheckpointable policy": {
+			annotations: map[string]string{
+				UncheckpointableAnnotationKey: "ignore",
+			},
+			expectErr:          true,
+			expectedAnnotation: UncheckpointableAnnotationKey,
+		},
+		"readiness probe": {
+			annotations: map[string]string{
+				ReadinessProbeAnnotationKey: "http:8080/healthz",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, &ReadinessProbe{Type: ReadinessProbeHTTP, Port: 8080, Path: "/healthz"}, cfg.ReadinessProbe)
+			},
+		},
+		"invalid readiness probe": {
+			annotations: map[string]string{
+				ReadinessProbeAnnotationKey: "exec:true",
+			},
+			expectErr:          true,
+			expectedAnnotation: ReadinessProbeAnnotationKey,
+		},
+		"criu performance options": {
+			annotations: map[string]string{
+				CRIUPreDumpModeAnnotationKey: "read",
+				CRIUAutoDedupAnnotationKey:   "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, CRIUPreDumpModeRead, cfg.CRIUPreDumpMode)
+				assert.True(t, cfg.CRIUAutoDedup)
+			},
+		},
+		"connection handoff": {
+			annotations: map[string]string{
+				ConnectionHandoffAnnotationKey: "/run/handoff.sock",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "/run/handoff.sock", cfg.ConnectionHandoff)
+			},
+		},
+		"relative connection handoff socket": {
+			annotations: map[string]string{
+				ConnectionHandoffAnnotationKey: "run/handoff.sock",
+			},
+			expectErr:          true,
+			expectedAnnotation: ConnectionHandoffAnnotationKey,
+		},
+		"criu manage cgroups": {
+			annotations: map[string]string{
+				CRIUManageCgroupsAnnotationKey: "ignore",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, CRIUManageCgroupsModeIgnore, cfg.CRIUManageCgroups)
+			},
+		},
+		"invalid criu manage cgroups": {
+			annotations: map[string]string{
+				CRIUManageCgroupsAnnotationKey: "props",
+			},
+			expectErr:          true,
+			expectedAnnotation: CRIUManageCgroupsAnnotationKey,
+		},
+		"invalid criu pre-dump mode": {
+			annotations: map[string]string{
+				CRIUPreDumpModeAnnotationKey: "mmap",
+			},
+			expectErr:          true,
+			expectedAnnotation: CRIUPreDumpModeAnnotationKey,
+		},
+		"invalid restore failure policy": {
+			annotations: map[string]string{
+				RestoreFailurePolicyAnnotationKey: "foo",
+			},
+			expectErr: true,
+		},
+		"verify checkpoint": {
+			annotations: map[string]string{
+				VerifyCheckpointAnnotationKey: "true",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.True(t, cfg.VerifyCheckpoint)
+			},
+		},
+		"scale down priority": {
+			annotations: map[string]string{
+				ScaleDownPriorityAnnotationKey: "-10",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, int32(-10), cfg.ScaleDownPriority)
+			},
+		},
+		"invalid scale down priority": {
+			annotations: map[string]string{
+				ScaleDownPriorityAnnotationKey: "high",
+			},
+			expectErr: true,
+		},
+		"restore signal": {
+			annotations: map[string]string{
+				RestoreSignalAnnotationKey: "SIGHUP",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, syscall.SIGHUP, cfg.RestoreSignal)
+			},
+		},
+		"restore signal without prefix": {
+			annotations: map[string]string{
+				RestoreSignalAnnotationKey: "usr1",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, syscall.SIGUSR1, cfg.RestoreSignal)
+			},
+		},
+		"restore signal number": {
+			annotations: map[string]string{
+				RestoreSignalAnnotationKey: "1",
+			},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, syscall.SIGHUP, cfg.RestoreSignal)
+			},
+		},
+		"no restore signal by default": {
+			annotations: map[string]string{},
+			assertCfg: func(t *testing.T, cfg *Config) {
+				assert.Zero(t, cfg.RestoreSignal)
+			},
+		},
+		"invalid restore signal": {
+			annotations: map[string]string{
+				RestoreSignalAnnotationKey: "SIGFOO",
+			},
+			expectErr: true,
+		},
+		"invalid refresh dns config": {
+			annotations: map[string]string{
+				RefreshDNSConfigAnnotationKey: "foo",
+			},
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if strings.Contains(tc.annotations[PreDumpAnnotationKey], "true") && runtime.GOARCH == "arm64" {
+				t.Skip("skipping pre-dump test as it's not supported on arm64")
+			}
+			cfg, err := NewConfig(context.Background(), &specs.Spec{
+				Annotations: tc.annotations,
+			})
+			if tc.expectErr {
+				assert.Error(t, err)
+				if tc.expectedAnnotation != "" {
+					var cfgErr *ConfigError
+					require.ErrorAs(t, err, &cfgErr)
+					assert.Equal(t, tc.expectedAnnotation, cfgErr.Annotation)
+					assert.Contains(t, err.Error(), tc.expectedAnnotation)
+				}
+				return
+			}
+			require.NoError(t, err)
+			tc.assertCfg(t, cfg)
+		})
+	}
+}
+
+func TestIsZeropodContainer(t *testing.T) {
+	tests := map[string]struct {
+		containerNames string
+		containerName  string
+		expected       bool
+	}{
+		"all containers by default": {
+			containerNames: "",
+			containerName:  "nginx",
+			expected:       true,
+		},
+		"exact match": {
+			containerNames: "nginx,sidecar",
+			containerName:  "sidecar",
+			expected:       true,
+		},
+		"no match": {
+			containerNames: "nginx,sidecar",
+			containerName:  "nginx-sidecar",
+			expected:       false,
+		},
+		"glob match": {
+			containerNames: "nginx,worker-*",
+			containerName:  "worker-a1b2c",
+			expected:       true,
+		},
+		"glob no match": {
+			containerNames: "worker-?",
+			containerName:  "worker-10",
+			expected:       false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{CRIContainerNameAnnotation: tc.containerName}
+			if tc.containerNames != "" {
+				annotations[ContainerNamesAnnotationKey] = tc.containerNames
+			}
+			cfg, err := NewConfig(context.Background(), &specs.Spec{Annotations: annotations})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg.IsZeropodContainer())
+		})
+	}
+}
+
+func TestIsZeropodContainerWithoutCRI(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		"no annotations": {
+			annotations: nil,
+			expected:    false,
+		},
+		"unrelated annotations": {
+			annotations: map[string]string{"org.opencontainers.image.title": "nginx"},
+			expected:    false,
+		},
+		"zeropod annotations": {
+			annotations: map[string]string{ScaleDownDurationAnnotationKey: "10s"},
+			expected:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := NewConfig(context.Background(), &specs.Spec{Annotations: tc.annotations})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg.IsZeropodContainer())
+		})
+	}
+}
+
+func TestIsZeropodSpec(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		expected    bool
+	}{
+		"selected container with invalid config": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:     "nginx",
+				ContainerNamesAnnotationKey:    "nginx",
+				ScaleDownDurationAnnotationKey: "invalid",
+			},
+			expected: true,
+		},
+		"other container with invalid config": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:     "sidecar",
+				ContainerNamesAnnotationKey:    "nginx",
+				ScaleDownDurationAnnotationKey: "invalid",
+			},
+			expected: false,
+		},
+		"sandbox with invalid config": {
+			annotations: map[string]string{
+				CRIContainerTypeAnnotation:     "sandbox",
+				ScaleDownDurationAnnotationKey: "invalid",
+			},
+			expected: false,
+		},
+		"invalid container names": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:  "sidecar",
+				ContainerNamesAnnotationKey: "[",
+			},
+			expected: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsZeropodSpec(&specs.Spec{Annotations: tc.annotations}))
+		})
+	}
+}
+
+func TestAnnotationPrefix(t *testing.T) {
+	defer func(prefix string) { annotationPrefix = prefix }(annotationPrefix)
+	annotationPrefix = "zeropod.example.com/"
+
+	cfg, err := NewConfig(context.Background(), &specs.Spec{Annotations: map[string]string{
+		CRIContainerNameAnnotation:               "nginx",
+		"zeropod.example.com/scaledown-duration": "5m",
+		"zeropod.example.com/ports-map":          "nginx=8080",
+		// annotations with the default prefix are ignored.
+		KeepTimerOnExecAnnotationKey: "true",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute*5, cfg.ScaleDownDuration)
+	assert.Equal(t, []uint16{8080}, cfg.Ports)
+	assert.False(t, cfg.KeepTimerOnExec)
+
+	_, err = NewConfig(context.Background(), &specs.Spec{Annotations: map[string]string{
+		"zeropod.example.com/scaledown-duration": "foo",
+	}})
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	assert.Equal(t, "zeropod.example.com/scaledown-duration", cfgErr.Annotation)
+
+	_, err = NewConfig(context.Background(), &specs.Spec{Annotations: map[string]string{
+		"zeropod.example.com/reload-mounts": "/etc/config",
+	}})
+	require.ErrorAs(t, err, &cfgErr)
+	assert.Contains(t, err.Error(), "zeropod.example.com/restore-signal needs to be set")
+}
+
+func TestPrivileged(t *testing.T) {
+	privileged := func() *specs.Spec {
+		return &specs.Spec{
+			Process: &specs.Process{Capabilities: &specs.LinuxCapabilities{
+				Bounding: []string{"CAP_CHOWN", "CAP_SYS_ADMIN"},
+			}},
+			Linux: &specs.Linux{},
+		}
+	}
+
+	tests := map[string]struct {
+		spec     func() *specs.Spec
+		expected bool
+	}{
+		"privileged": {
+			spec:     privileged,
+			expected: true,
+		},
+		"masked paths": {
+			spec: func() *specs.Spec {
+				spec := privileged()
+				spec.Linux.MaskedPaths = []string{"/proc/kcore"}
+				return spec
+			},
+		},
+		"readonly paths": {
+			spec: func() *specs.Spec {
+				spec := privileged()
+				spec.Linux.ReadonlyPaths = []string{"/proc/sys"}
+				return spec
+			},
+		},
+		"without CAP_SYS_ADMIN": {
+			spec: func() *specs.Spec {
+				spec := privileged()
+				spec.Process.Capabilities.Bounding = []string{"CAP_CHOWN"}
+				return spec
+			},
+		},
+		"without process": {
+			spec: func() *specs.Spec {
+				spec := privileged()
+				spec.Process = nil
+				return spec
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := NewConfig(context.Background(), tc.spec())
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg.Privileged())
+		})
+	}
+}
+
+func TestSharedPIDNamespace(t *testing.T) {
+	tests := map[string]struct {
+		namespaces []specs.LinuxNamespace
+		expected   bool
+	}{
+		"own pid namespace": {
+			namespaces: []specs.LinuxNamespace{{Type: specs.PIDNamespace}},
+			expected:   false,
+		},
+		"shared pid namespace": {
+			namespaces: []specs.LinuxNamespace{{Type: specs.PIDNamespace, Path: "/proc/1234/ns/pid"}},
+			expected:   true,
+		},
+		"shared network namespace only": {
+			namespaces: []specs.LinuxNamespace{
+				{Type: specs.NetworkNamespace, Path: "/proc/1234/ns/net"},
+				{Type: specs.PIDNamespace},
+			},
+			expected: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := NewConfig(context.Background(), &specs.Spec{Linux: &specs.Linux{Namespaces: tc.namespaces}})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg.SharedPIDNamespace())
 		})
 	}
 }
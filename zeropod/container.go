@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/containerd/containerd/errdefs"
@@ -18,6 +21,8 @@ import (
 	"github.com/ctrox/zeropod/activator"
 	v1 "github.com/ctrox/zeropod/api/shim/v1"
 	"github.com/ctrox/zeropod/socket"
+	"golang.org/x/sys/unix"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type HandleStartedFunc func(*runc.Container, process.Process, bool)
@@ -25,30 +30,90 @@ type HandleStartedFunc func(*runc.Container, process.Process, bool)
 type Container struct {
 	*runc.Container
 
-	context          context.Context
-	activator        *activator.Server
-	cfg              *Config
-	initialProcess   process.Process
-	process          process.Process
-	cgroup           any
-	logPath          string
-	scaledDown       bool
-	netNS            ns.NetNS
-	scaleDownTimer   *time.Timer
-	platform         stdio.Platform
-	tracker          socket.Tracker
-	preRestore       func() HandleStartedFunc
-	postRestore      func(*runc.Container, HandleStartedFunc)
-	events           chan *v1.ContainerStatus
-	checkpointedPIDs map[int]struct{}
-	pidsMu           sync.Mutex
+	context              context.Context
+	activator            activator.Activator
+	connHandoff          *activator.ConnectionHandoff
+	cfg                  *Config
+	initialProcess       process.Process
+	process              process.Process
+	cgroup               any
+	logPath              string
+	scaledDown           bool
+	scalingDisabled      atomic.Bool
+	stopped              atomic.Bool
+	coldStart            bool
+	startedFresh         bool
+	netNS                ns.NetNS
+	scaleDownTimer       *time.Timer
+	evictionTimer        *time.Timer
+	prefetchTimer        *time.Timer
+	eagerCheckpointTimer *time.Timer
+	eagerPreDumped       bool
+	ready                atomic.Bool
+	stdinClosed          atomic.Bool
+	evictionRequested    atomic.Bool
+	trigger              scaleDownTrigger
+	diskWrites           *rateMeter
+	memoryUsage          func() (uint64, error)
+	scaleDownAt          time.Time
+	lastReclaim          time.Time
+	redirectsDisabledAt  time.Time
+	cpuset               cpuset
+	oomScoreAdj          *int
+	reloadMountsDigest   string
+	pidNamespaceInit     bool
+	startedAt            time.Time
+	lastActivity         atomic.Int64
+	criuLogs             criuLogs
+	scaleEvents          scaleEventHistory
+	platform             stdio.Platform
+	tracker              socket.Tracker
+	checkpointer         Checkpointer
+	preRestore           func() HandleStartedFunc
+	postRestore          func(*runc.Container, HandleStartedFunc)
+	exists               func() bool
+	restoreSiblings      func(context.Context)
+	reclaimSandbox       func(context.Context)
+	fail                 func(context.Context)
+	events               chan *v1.ContainerStatus
+	checkpointedPIDs     map[int]struct{}
+	pidsMu               sync.Mutex
+	pendingExecs         map[string]struct{}
+	execsMu              sync.Mutex
+	// scaleDownMu guards scaleDownTimer, scaleDownAt and scaleDownGen, which
+	// are accessed by the timer callbacks as well as the shim requests.
+	// scaleDownGen is increased on every schedule and cancel, so a callback
+	// can tell if its scale down is still pending.
+	scaleDownMu  sync.Mutex
+	scaleDownGen uint64
+	// prefetchMu guards prefetchTimer and prefetchGen the same way for the
+	// prefetch of the checkpoint images.
+	prefetchMu  sync.Mutex
+	prefetchGen uint64
+	// evictionMu guards evictionTimer and evictionGen the same way for the
+	// eviction request.
+	evictionMu  sync.Mutex
+	evictionGen uint64
+	// restoreStatusMu guards the restore fields of the status, which are
+	// read by status requests while the restore is running. restoreGen is
+	// increased on every finished restore, so the restore health check can
+	// tell if its restore is still the last one.
+	restoreStatusMu        sync.Mutex
+	restoreStartedAt       time.Time
+	restoreImagesSizeBytes int64
+	lastRestoreAt          time.Time
+	lastRestoreError       string
+	restoreGen             uint64
+	// handoffMu is held from the handoff to the activator until the scale
+	// down is done, restores of the container wait for it.
+	handoffMu sync.Mutex
 	// mutex to lock during checkpoint/restore operations since concurrent
 	// restores can cause cgroup confusion. This mutex is shared between all
 	// containers.
-	checkpointRestore *sync.Mutex
+	checkpointRestore sync.Locker
 }
 
-func New(ctx context.Context, cfg *Config, cr *sync.Mutex, container *runc.Container, pt stdio.Platform, events chan *v1.ContainerStatus) (*Container, error) {
+func New(ctx context.Context, cfg *Config, cr sync.Locker, container *runc.Container, pt stdio.Platform, events chan *v1.ContainerStatus) (*Container, error) {
 	p, err := container.Process("")
 	if err != nil {
 		return nil, errdefs.ToGRPC(err)
@@ -94,12 +159,31 @@ func New(ctx context.Context, cfg *Config, cr *sync.Mutex, container *runc.Conta
 		checkpointRestore: cr,
 		events:            events,
 		checkpointedPIDs:  map[int]struct{}{},
+		startedAt:         time.Now(),
+	}
+	c.checkpointer = &criuCheckpointer{Container: c}
+	c.trigger = newScaleDownTrigger(c)
+	c.diskWrites = newDiskWriteMeter(c)
+	c.setLastActivity(time.Now())
+
+	if c.checkpointingUnsupported() {
+		// CRIU can't dump the devices and mounts of privileged containers, so
+		// we keep them running instead of failing on every scale down.
+		log.G(ctx).Warnf("disabling scaling of container %s: privileged containers can't be checkpointed, use the %q scale down strategy or disable checkpointing",
+			cfg.ContainerName, ScaleDownStrategyReclaim)
+		c.scalingDisabled.Store(true)
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonPrivileged)).Set(1)
 	}
 
 	running.With(c.labels()).Set(1)
 	c.sendEvent(c.Status())
 
-	return c, c.initActivator(ctx)
+	if err := c.initActivator(ctx); err != nil {
+		return c, err
+	}
+	c.scheduleEagerCheckpoint(ctx)
+
+	return c, nil
 }
 
 func (c *Container) ScheduleScaleDown() error {
@@ -110,34 +194,112 @@ func (c *Container) scheduleScaleDownIn(in time.Duration) error {
 	// cancel any potential pending scaledonws
 	c.CancelScaleDown()
 
+	if c.scalingDisabled.Load() {
+		log.G(c.context).Info("scaling is disabled, not scheduling scale down")
+		return nil
+	}
+
+	if nodeScalingDisabled.Load() {
+		log.G(c.context).Info("scaling is disabled by the kill switch of the node, not scheduling scale down")
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonKillSwitch)).Set(1)
+		return nil
+	}
+
+	if c.cfg.ReadinessProbe != nil && !c.ready.Load() {
+		c.awaitReadiness()
+		return nil
+	}
+
+	if c.cfg.ActivatorMetering {
+		// start metering right away, the process might not be listening yet
+		// in which case it's tried again once the scale down is due.
+		if _, err := c.meteredActivity(); err != nil {
+			log.G(c.context).Warnf("unable to start activator metering: %s", err)
+		}
+	}
+
+	c.trigger.reset()
+	c.resetDiskWrites()
+
 	log.G(c.context).Infof("scheduling scale down in %s", in)
-	timer := time.AfterFunc(in, func() {
-		last, err := c.tracker.LastActivity(uint32(c.process.Pid()))
-		if errors.Is(err, socket.NoActivityRecordedErr{}) {
-			log.G(c.context).Info(err)
-		} else if err != nil {
-			log.G(c.context).Errorf("unable to get last TCP activity from tracker: %s", err)
-		} else {
-			log.G(c.context).Infof("last activity was %s ago", time.Since(last))
-
-			if time.Since(last) < c.cfg.ScaleDownDuration {
-				// we want to delay the scaledown by c.cfg.ScaleDownDuration
-				// after the last activity
-				delay := c.cfg.ScaleDownDuration - time.Since(last)
-				// do not schedule into the past :)
-				if delay < 0 {
-					return
-				}
-
-				log.G(c.context).Infof("delaying scale down by %s", delay)
-				c.scaleDownTimer.Reset(delay)
-				return
+	c.scaleDownMu.Lock()
+	defer c.scaleDownMu.Unlock()
+	c.scaleDownGen++
+	gen := c.scaleDownGen
+	c.scaleDownAt = time.Now().Add(in)
+	c.scaleDownTimer = time.AfterFunc(in, func() {
+		// the scale down might have been cancelled or rescheduled after the
+		// timer fired.
+		if !c.scaleDownPending(gen) {
+			return
+		}
+
+		// the container might have been deleted while the scale down was
+		// pending, in which case there is nothing left to scale down.
+		if !c.Exists() {
+			log.G(c.context).Infof("container %s does not exist anymore, cancelling scale down", c.ID())
+			return
+		}
+
+		// the kill switch might have been set after the scale down has
+		// been scheduled.
+		if nodeScalingDisabled.Load() {
+			log.G(c.context).Info("scaling is disabled by the kill switch of the node, not scaling down")
+			return
+		}
+
+		// the last exec to complete schedules the scale down again.
+		if n := c.PendingExecs(); n > 0 {
+			log.G(c.context).Infof("%d execs are still running, not scaling down", n)
+			return
+		}
+
+		if grace := c.startupGraceRemaining(); grace > 0 {
+			log.G(c.context).Infof("delaying scale down by %s until the startup grace is over", grace)
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_DELAYED, ScaleEventCauseStartup, fmt.Sprintf("delayed by %s", grace))
+			c.delayScaleDown(gen, grace)
+			return
+		}
+
+		if delay := c.trigger.delay(c.context); delay > 0 {
+			log.G(c.context).Infof("delaying scale down by %s", delay)
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_DELAYED, c.scaleDownCause(), fmt.Sprintf("delayed by %s", delay))
+			c.delayScaleDown(gen, delay)
+			// there has been activity, keep subscribers up to date with it.
+			c.sendEvent(c.Status())
+			return
+		}
+
+		if c.heavyDiskWrites(c.context) {
+			log.G(c.context).Infof("deferring scale down by %s because of heavy disk writes", c.cfg.ScaleDownDuration)
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_DELAYED, ScaleEventCauseDiskWrites,
+				fmt.Sprintf("delayed by %s", c.cfg.ScaleDownDuration))
+			if err := c.ScheduleScaleDown(); err != nil {
+				log.G(c.context).Errorf("unable to reschedule scale down: %s", err)
 			}
+			return
+		}
+
+		if c.smallMemory(c.context) {
+			log.G(c.context).Infof("deferring scale down by %s because of small memory usage", c.cfg.ScaleDownDuration)
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_DELAYED, ScaleEventCauseMinMemory,
+				fmt.Sprintf("delayed by %s", c.cfg.ScaleDownDuration))
+			if err := c.ScheduleScaleDown(); err != nil {
+				log.G(c.context).Errorf("unable to reschedule scale down: %s", err)
+			}
+			return
+		}
+
+		if cooldown := c.redirectCooldownRemaining(); cooldown > 0 {
+			log.G(c.context).Infof("delaying scale down by %s until the redirect cooldown is over", cooldown)
+			c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN_DELAYED, ScaleEventCauseCooldown, fmt.Sprintf("delayed by %s", cooldown))
+			c.delayScaleDown(gen, cooldown)
+			return
 		}
 
 		log.G(c.context).Info("scaling down after scale down duration is up")
 
-		if err := c.scaleDown(c.context); err != nil {
+		if err := c.scaleDown(c.context, c.scaleDownCause()); err != nil {
 			// checkpointing failed, this is currently unrecoverable, so we
 			// shutdown our shim and let containerd recreate it.
 			log.G(c.context).Fatalf("scale down failed: %s", err)
@@ -145,23 +307,189 @@ func (c *Container) scheduleScaleDownIn(in time.Duration) error {
 		}
 
 	})
-	c.scaleDownTimer = timer
 	return nil
 }
 
+// scaleDownPending returns true if the scale down with the supplied
+// generation has neither been cancelled nor rescheduled.
+func (c *Container) scaleDownPending(gen uint64) bool {
+	c.scaleDownMu.Lock()
+	defer c.scaleDownMu.Unlock()
+	return c.scaleDownGen == gen
+}
+
+// delayScaleDown moves the scale down with the supplied generation to in
+// from now, unless it has been cancelled or rescheduled in the meantime.
+func (c *Container) delayScaleDown(gen uint64, in time.Duration) {
+	c.scaleDownMu.Lock()
+	defer c.scaleDownMu.Unlock()
+	if c.scaleDownGen != gen {
+		return
+	}
+	c.scaleDownAt = time.Now().Add(in)
+	c.scaleDownTimer.Reset(in)
+}
+
+// ScheduleScaleDownAfterExec schedules the scale down once an exec has
+// completed. By default the exec counts as activity and the full scale down
+// duration starts again. If KeepTimerOnExec is enabled, the scale down is
+// scheduled at the time it was due before the exec, or immediately if that
+// time has already passed (e.g. if the container was restored for the exec).
+func (c *Container) ScheduleScaleDownAfterExec() error {
+	if !c.cfg.KeepTimerOnExec {
+		return c.ScheduleScaleDown()
+	}
+
+	c.scaleDownMu.Lock()
+	scaleDownAt := c.scaleDownAt
+	c.scaleDownMu.Unlock()
+	return c.scheduleScaleDownIn(max(time.Until(scaleDownAt), 0))
+}
+
 func (c *Container) CancelScaleDown() {
+	c.scaleDownMu.Lock()
+	defer c.scaleDownMu.Unlock()
+	c.scaleDownGen++
 	if c.scaleDownTimer == nil {
 		return
 	}
 	c.scaleDownTimer.Stop()
 }
 
+// ForceScaleDown scales down the container immediately, regardless of the
+// configured scale down duration.
+func (c *Container) ForceScaleDown(ctx context.Context) error {
+	if c.ScaledDown() {
+		return ErrAlreadyScaledDown
+	}
+	if c.checkpointingUnsupported() {
+		return ErrPrivileged
+	}
+
+	c.CancelScaleDown()
+	ctx = detachedContext(ctx)
+	return c.scaleDown(ctx, ScaleEventCauseForced)
+}
+
+// ForceRestore restores the scaled down container immediately without
+// waiting for an incoming connection.
+func (c *Container) ForceRestore(ctx context.Context) error {
+	return c.forceRestore(ctx, ScaleEventCauseForced)
+}
+
+// RestoreSibling restores the scaled down container because a sibling
+// container of the same pod has been restored.
+func (c *Container) RestoreSibling(ctx context.Context) error {
+	return c.forceRestore(ctx, ScaleEventCauseSibling)
+}
+
+func (c *Container) forceRestore(ctx context.Context, cause string) error {
+	if !c.ScaledDown() {
+		return ErrAlreadyRestored
+	}
+
+	ctx = detachedContext(ctx)
+	return c.restoreHandler(ctx, cause)()
+}
+
+// RestoreForSignal restores the scaled down container if the supplied signal
+// should be handled by the process itself instead of being dropped. This is
+// the case for the configured wake signals, after which the container is
+// scaled down again as usual, and for stop signals if restore on stop is
+// enabled (e.g. to shut down gracefully). SIGKILL can't be handled by the
+// process, so the container is not restored for it.
+//
+// A signal that arrives while the container is being checkpointed waits for
+// the checkpoint to finish, so it's delivered to the restored process instead
+// of being lost. Signals that are already pending in the kernel at the time
+// of the checkpoint are preserved by CRIU.
+func (c *Container) RestoreForSignal(ctx context.Context, signal uint32) error {
+	if !c.RestoresForSignal(signal) {
+		return nil
+	}
+
+	c.checkpointRestore.Lock()
+	c.checkpointRestore.Unlock()
+	if !c.ScaledDown() {
+		return nil
+	}
+
+	sig := syscall.Signal(signal)
+	if c.WakeSignal(signal) {
+		log.G(ctx).Infof("restoring scaled down container to deliver %s", unix.SignalName(sig))
+		if err := c.forceRestore(ctx, ScaleEventCauseSignal); err != nil && !errors.Is(err, ErrAlreadyRestored) {
+			return err
+		}
+		return nil
+	}
+
+	log.G(ctx).Infof("restoring scaled down container to handle %s", unix.SignalName(sig))
+	ctx = detachedContext(ctx)
+	beforeRestore := time.Now()
+	if _, _, err := c.Restore(ctx); err != nil {
+		if errors.Is(err, ErrAlreadyRestored) {
+			return nil
+		}
+		c.RecordScaleEvent(v1.ScaleEventType_RESTORE_FAILED, ScaleEventCauseStop, err.Error())
+		return err
+	}
+	c.RecordScaleEvent(v1.ScaleEventType_RESTORE, ScaleEventCauseStop, fmt.Sprintf("restored in %s", time.Since(beforeRestore)))
+
+	return nil
+}
+
+// RestoresForSignal returns true if the container is restored to handle the
+// supplied signal while it's scaled down.
+func (c *Container) RestoresForSignal(signal uint32) bool {
+	if c.WakeSignal(signal) {
+		return true
+	}
+	return c.cfg.RestoreOnStop && syscall.Signal(signal) != syscall.SIGKILL
+}
+
+// WakeSignal returns true if the signal is one of the configured wake
+// signals, which the process handles without terminating.
+func (c *Container) WakeSignal(signal uint32) bool {
+	return slices.Contains(c.cfg.WakeSignals, syscall.Signal(signal))
+}
+
+// SetScalingEnabled enables or disables automatic scale down of the
+// container. Disabling scaling cancels any pending scale down but does not
+// restore an already scaled down container.
+func (c *Container) SetScalingEnabled(enabled bool) error {
+	if enabled && c.checkpointingUnsupported() {
+		return ErrPrivileged
+	}
+
+	c.scalingDisabled.Store(!enabled)
+	if !enabled {
+		c.CancelScaleDown()
+		return nil
+	}
+
+	if c.ScaledDown() {
+		return nil
+	}
+	return c.ScheduleScaleDown()
+}
+
+func (c *Container) ScalingEnabled() bool {
+	return !c.scalingDisabled.Load()
+}
+
 func (c *Container) SetScaledDown(scaledDown bool) {
 	c.scaledDown = scaledDown
 	if scaledDown {
 		running.With(c.labels()).Set(0)
 		lastCheckpointTime.With(c.labels()).Set(float64(time.Now().UnixNano()))
+		c.scheduleEviction()
+		c.schedulePrefetch()
+		c.ReclaimSandbox(c.context)
 	} else {
+		c.cancelEviction()
+		c.cancelPrefetch()
+		// the container is only restored if there is activity.
+		c.setLastActivity(time.Now())
 		running.With(c.labels()).Set(1)
 		lastRestoreTime.With(c.labels()).Set(float64(time.Now().UnixNano()))
 	}
@@ -173,12 +501,170 @@ func (c *Container) Status() *v1.ContainerStatus {
 	if c.ScaledDown() {
 		phase = v1.ContainerPhase_SCALED_DOWN
 	}
-	return &v1.ContainerStatus{
-		Id:           c.ID(),
-		Name:         c.cfg.ContainerName,
-		PodName:      c.cfg.PodName,
-		PodNamespace: c.cfg.PodNamespace,
-		Phase:        phase,
+	status := &v1.ContainerStatus{
+		Id:                c.ID(),
+		Name:              c.cfg.ContainerName,
+		PodName:           c.cfg.PodName,
+		PodNamespace:      c.cfg.PodNamespace,
+		Phase:             phase,
+		ScalingEnabled:    c.ScalingEnabled(),
+		ScaleDownPriority: c.cfg.ScaleDownPriority,
+		StartedAt:         timestamppb.New(c.startedAt),
+		CheckpointMode:    c.CheckpointMode(),
+		LastActivity:      timestamppb.New(c.LastActivity()),
+		EvictionRequested: c.evictionRequested.Load(),
+	}
+	c.restoreStatusMu.Lock()
+	if !c.restoreStartedAt.IsZero() {
+		status.RestoreStartedAt = timestamppb.New(c.restoreStartedAt)
+		status.RestoreImagesSizeBytes = c.restoreImagesSizeBytes
+	}
+	if !c.lastRestoreAt.IsZero() {
+		status.LastRestoreAt = timestamppb.New(c.lastRestoreAt)
+		status.LastRestoreError = c.lastRestoreError
+	}
+	c.restoreStatusMu.Unlock()
+	return status
+}
+
+// scheduleEviction requests the eviction of the pod once the container has
+// been scaled down for longer than the max scaled lifetime.
+func (c *Container) scheduleEviction() {
+	if c.cfg.MaxScaledLifetime == 0 {
+		return
+	}
+
+	c.evictionMu.Lock()
+	defer c.evictionMu.Unlock()
+	c.stopEvictionTimer()
+	gen := c.evictionGen
+	c.evictionTimer = time.AfterFunc(c.cfg.MaxScaledLifetime, func() {
+		if !c.requestEviction(gen) {
+			return
+		}
+		log.G(c.context).Infof("container %s has been scaled down for %s, requesting eviction",
+			c.ID(), c.cfg.MaxScaledLifetime)
+		c.sendEvent(c.Status())
+	})
+}
+
+// requestEviction marks the container for eviction unless the eviction with
+// the supplied generation has been cancelled or the container has been
+// stopped in the meantime.
+func (c *Container) requestEviction(gen uint64) bool {
+	c.evictionMu.Lock()
+	defer c.evictionMu.Unlock()
+	if c.evictionGen != gen || c.stopped.Load() {
+		return false
+	}
+	c.evictionRequested.Store(true)
+	return true
+}
+
+func (c *Container) cancelEviction() {
+	c.evictionMu.Lock()
+	defer c.evictionMu.Unlock()
+	c.stopEvictionTimer()
+	c.evictionRequested.Store(false)
+}
+
+// stopEvictionTimer stops the pending eviction request and increases the
+// generation, so a timer that has already fired does not request it. It
+// needs to be called with evictionMu held.
+func (c *Container) stopEvictionTimer() {
+	c.evictionGen++
+	if c.evictionTimer != nil {
+		c.evictionTimer.Stop()
+	}
+}
+
+// LastActivity returns the time of the last observed activity of the
+// container. While the process is running, this is the last network activity
+// recorded by the tracker or the activator if metering is enabled. While
+// it's scaled down, it's the last activity before the scale down.
+func (c *Container) LastActivity() time.Time {
+	last := c.recordedActivity()
+	if c.ScaledDown() || c.process == nil {
+		return last
+	}
+
+	var (
+		observed time.Time
+		err      error
+	)
+	if meter, ok := c.activator.(activator.Meter); ok && c.cfg.ActivatorMetering && c.activator.Started() {
+		observed = meter.Activity().LastActivity
+	} else if c.tracker != nil {
+		observed, err = c.tracker.LastActivity(uint32(c.process.Pid()))
+	}
+	if err == nil && observed.After(last) {
+		return observed
+	}
+	return last
+}
+
+// recordedActivity returns the activity recorded by setLastActivity. It's
+// stored as unix nanos since it's recorded by shim requests as well as the
+// scale down and restore.
+func (c *Container) recordedActivity() time.Time {
+	nanos := c.lastActivity.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (c *Container) setLastActivity(t time.Time) {
+	c.lastActivity.Store(t.UnixNano())
+}
+
+// AddPendingExec registers an exec that has been started in the container.
+// The container is not scaled down as long as execs are pending.
+func (c *Container) AddPendingExec(execID string) {
+	c.execsMu.Lock()
+	defer c.execsMu.Unlock()
+	if c.pendingExecs == nil {
+		c.pendingExecs = map[string]struct{}{}
+	}
+	c.pendingExecs[execID] = struct{}{}
+}
+
+// RemovePendingExec removes a completed exec and returns the amount of execs
+// that are still pending.
+func (c *Container) RemovePendingExec(execID string) int {
+	c.execsMu.Lock()
+	defer c.execsMu.Unlock()
+	delete(c.pendingExecs, execID)
+	return len(c.pendingExecs)
+}
+
+// PendingExecs returns the amount of execs that have not completed yet.
+func (c *Container) PendingExecs() int {
+	c.execsMu.Lock()
+	defer c.execsMu.Unlock()
+	return len(c.pendingExecs)
+}
+
+// RecordExec records an exec into the container as activity and informs
+// subscribers about it. Like for the scale down timer, execs don't count as
+// activity if the timer is kept on exec.
+func (c *Container) RecordExec() {
+	if c.cfg.KeepTimerOnExec {
+		return
+	}
+	c.setLastActivity(time.Now())
+	c.sendEvent(c.Status())
+}
+
+// CheckpointMode returns the effective way the container is scaled down.
+func (c *Container) CheckpointMode() v1.CheckpointMode {
+	switch {
+	case c.cfg.ScaleDownStrategy == ScaleDownStrategyReclaim:
+		return v1.CheckpointMode_RECLAIM
+	case c.cfg.DisableCheckpointing, c.cfg.SharedPIDNamespace():
+		return v1.CheckpointMode_DISABLED
+	default:
+		return v1.CheckpointMode_CHECKPOINT
 	}
 }
 
@@ -218,6 +704,9 @@ func (c *Container) CheckpointedPID(pid int) bool {
 func (c *Container) AddCheckpointedPID(pid int) {
 	c.pidsMu.Lock()
 	defer c.pidsMu.Unlock()
+	if c.checkpointedPIDs == nil {
+		c.checkpointedPIDs = map[int]struct{}{}
+	}
 	c.checkpointedPIDs[pid] = struct{}{}
 }
 
@@ -229,11 +718,20 @@ func (c *Container) DeleteCheckpointedPID(pid int) {
 }
 
 func (c *Container) Stop(ctx context.Context) {
+	c.stopped.Store(true)
 	c.CancelScaleDown()
+	c.cancelEviction()
+	c.cancelPrefetch()
+	if c.eagerCheckpointTimer != nil {
+		c.eagerCheckpointTimer.Stop()
+	}
 	if err := c.tracker.Close(); err != nil {
 		log.G(ctx).Errorf("unable to close tracker: %s", err)
 	}
 	c.StopActivator(ctx)
+	if c.connHandoff != nil {
+		c.connHandoff.Close()
+	}
 	c.deleteMetrics()
 }
 
@@ -249,7 +747,83 @@ func (c *Container) RegisterPostRestore(f func(*runc.Container, HandleStartedFun
 	c.postRestore = f
 }
 
-var errNoPortsDetected = errors.New("no listening ports detected")
+// RegisterExists registers a func that reports if the container still exists
+// in the task service.
+func (c *Container) RegisterExists(f func() bool) {
+	c.exists = f
+}
+
+// Exists returns true if the container still exists. If no exists func has
+// been registered, the container is assumed to exist.
+func (c *Container) Exists() bool {
+	if c.exists == nil {
+		return true
+	}
+	return c.exists()
+}
+
+// RegisterFail registers a func that marks the container as failed.
+func (c *Container) RegisterFail(f func(context.Context)) {
+	c.fail = f
+}
+
+// RegisterRestoreSiblings registers a func that restores the scaled down
+// sibling containers of the container.
+func (c *Container) RegisterRestoreSiblings(f func(context.Context)) {
+	c.restoreSiblings = f
+}
+
+// RegisterReclaimSandbox registers a func that reclaims the memory of the
+// sandbox container of the pod once all of its zeropod containers have been
+// scaled down.
+func (c *Container) RegisterReclaimSandbox(f func(context.Context)) {
+	c.reclaimSandbox = f
+}
+
+// ReclaimSandbox reclaims the memory of the sandbox container of the pod in
+// the background if enabled in the config. The sandbox (pause) container is
+// not scaled down itself, but its memory is not needed while all containers
+// of the pod are scaled down.
+func (c *Container) ReclaimSandbox(ctx context.Context) {
+	if !c.cfg.ReclaimSandbox || c.reclaimSandbox == nil {
+		return
+	}
+
+	ctx = detachedContext(ctx)
+	go c.reclaimSandbox(ctx)
+}
+
+// RestoreSiblings restores the scaled down sibling containers in the
+// background if enabled in the config. This allows containers of a pod that
+// communicate over sockets the activator does not know about (e.g. abstract
+// unix sockets) to come back together. Only the restore is coordinated, the
+// siblings are still scaled down on their own.
+func (c *Container) RestoreSiblings(ctx context.Context) {
+	if !c.cfg.RestoreSiblings || c.restoreSiblings == nil {
+		return
+	}
+
+	ctx = detachedContext(ctx)
+	go c.restoreSiblings(ctx)
+}
+
+// SiblingOf returns true if the container is a different container of the
+// same pod as other.
+func (c *Container) SiblingOf(other *Container) bool {
+	return c != other && c.cfg.PodUID == other.cfg.PodUID
+}
+
+var (
+	errNoPortsDetected   = errors.New("no listening ports detected")
+	ErrAlreadyScaledDown = errors.New("container is already scaled down")
+	ErrPrivileged        = errors.New("privileged containers can't be checkpointed")
+)
+
+// checkpointingUnsupported returns true if the container would be
+// checkpointed on scale down but that is not supported for it.
+func (c *Container) checkpointingUnsupported() bool {
+	return c.CheckpointMode() == v1.CheckpointMode_CHECKPOINT && c.cfg.Privileged()
+}
 
 func (c *Container) initActivator(ctx context.Context) error {
 	// we already have an activator
@@ -257,15 +831,116 @@ func (c *Container) initActivator(ctx context.Context) error {
 		return nil
 	}
 
-	srv, err := activator.NewServer(ctx, c.netNS)
+	var (
+		srv activator.Activator
+		err error
+	)
+	switch c.cfg.ActivatorBackend {
+	case activator.BackendNFQueue:
+		var server *activator.NFQueueServer
+		if server, err = activator.NewNFQueueServer(ctx, c.netNS); err == nil {
+			server.SetConnectionFunc(c.countActivatorConnection)
+			srv = server
+		}
+	default:
+		var server *activator.Server
+		if server, err = activator.NewServer(ctx, c.netNS); err == nil {
+			if c.cfg.ActivatorMetering {
+				server.EnableMetering()
+			}
+			for port, timeout := range c.cfg.HTTPHoldingTimeouts {
+				server.SetHTTPHoldingTimeout(port, timeout)
+			}
+			for _, port := range c.cfg.OriginalDstPorts {
+				server.SetOriginalDestination(port)
+			}
+			server.SetListenerStateFunc(c.setActivatorListening)
+			server.SetConnectionFunc(c.countActivatorConnection)
+			srv = server
+		}
+	}
 	if err != nil {
 		return err
 	}
 	c.activator = srv
 
+	// report ports that never receive any connections as well.
+	for _, port := range c.cfg.Ports {
+		activatorConnections.With(c.portLabels(port))
+	}
+
 	return nil
 }
 
+func (c *Container) setActivatorListening(port uint16, listening bool) {
+	value := 0.0
+	if listening {
+		value = 1
+	}
+	activatorListening.With(c.portLabels(port)).Set(value)
+}
+
+func (c *Container) countActivatorConnection(port uint16) {
+	activatorConnections.With(c.portLabels(port)).Inc()
+}
+
+// activatorStarted updates the activator listening metric after the
+// activator has been started. The listeners of the redirect backend report
+// their state on their own, other backends are listening once started.
+func (c *Container) activatorStarted(err error) {
+	_, listeners := c.activator.(*activator.Server)
+	for _, port := range c.cfg.Ports {
+		if err != nil || !listeners {
+			c.setActivatorListening(port, err == nil)
+		}
+	}
+}
+
+// disableRedirects makes traffic go to the running process directly. With
+// activator metering, the activator stays in the data path instead, so the
+// redirects are kept.
+func (c *Container) disableRedirects() error {
+	if c.cfg.ActivatorMetering {
+		return nil
+	}
+	return c.activator.DisableRedirects()
+}
+
+// startupGraceRemaining returns how long scale downs of the container are
+// still suppressed after it has been started.
+func (c *Container) startupGraceRemaining() time.Duration {
+	if c.cfg.StartupGrace <= 0 {
+		return 0
+	}
+	return max(c.cfg.StartupGrace-time.Since(c.startedAt), 0)
+}
+
+// redirectCooldownRemaining returns how long the redirects of the activator
+// should stay disabled after the last restore. This gives the restored
+// process time to bind its listeners before the activator could take over
+// its ports again.
+func (c *Container) redirectCooldownRemaining() time.Duration {
+	if c.cfg.RedirectCooldown <= 0 || c.redirectsDisabledAt.IsZero() {
+		return 0
+	}
+	return max(c.cfg.RedirectCooldown-time.Since(c.redirectsDisabledAt), 0)
+}
+
+// meteredActivity returns the activity metered by the activator. As the
+// activator needs to be in the data path to meter anything, it's started if
+// that has not happened yet.
+func (c *Container) meteredActivity() (activator.Activity, error) {
+	if err := c.startActivator(c.context); err != nil {
+		return activator.Activity{}, err
+	}
+
+	meter, ok := c.activator.(activator.Meter)
+	if !ok {
+		return activator.Activity{}, fmt.Errorf("activator backend %s does not support metering", c.cfg.ActivatorBackend)
+	}
+	return meter.Activity(), nil
+}
+
 // startActivator starts the activator
 func (c *Container) startActivator(ctx context.Context) error {
 	if c.activator.Started() {
@@ -289,12 +964,13 @@ func (c *Container) startActivator(ctx context.Context) error {
 
 	log.G(ctx).Infof("starting activator with ports: %v", c.cfg.Ports)
 
-	// create a new context in order to not run into deadline of parent context
-	ctx = log.WithLogger(context.Background(), log.G(ctx).WithField("runtime", RuntimeName))
+	ctx = detachedContext(ctx)
 
 	log.G(ctx).Infof("starting activator with config: %v", c.cfg)
 
-	if err := c.activator.Start(ctx, c.cfg.Ports, c.restoreHandler(ctx)); err != nil {
+	err := c.activator.Start(ctx, c.cfg.Ports, c.restoreHandler(ctx, ScaleEventCauseConnection))
+	c.activatorStarted(err)
+	if err != nil {
 		if errors.Is(err, activator.ErrMapNotFound) {
 			return err
 		}
@@ -307,7 +983,7 @@ func (c *Container) startActivator(ctx context.Context) error {
 	return nil
 }
 
-func (c *Container) restoreHandler(ctx context.Context) activator.OnAccept {
+func (c *Container) restoreHandler(ctx context.Context, cause string) activator.OnAccept {
 	return func() error {
 		log.G(ctx).Printf("got a request")
 
@@ -318,23 +994,36 @@ func (c *Container) restoreHandler(ctx context.Context) activator.OnAccept {
 				log.G(ctx).Info("container is already restored, ignoring request")
 				return nil
 			}
-			// restore failed, this is currently unrecoverable, so we shutdown
-			// our shim and let containerd recreate it.
-			log.G(ctx).Fatalf("error restoring container, exiting shim: %s", err)
-			os.Exit(1)
+			if errors.Is(err, ErrContainerStopped) {
+				log.G(ctx).Info("container has been stopped, ignoring request")
+				return nil
+			}
+			c.RecordScaleEvent(v1.ScaleEventType_RESTORE_FAILED, cause, err.Error())
+			c.RestoreFailed(ctx, err)
+			return err
 		}
 		c.Container = restoredContainer
+		c.RecordScaleEvent(v1.ScaleEventType_RESTORE, cause, fmt.Sprintf("restored in %s", time.Since(beforeRestore)))
 
 		if err := c.tracker.TrackPid(uint32(p.Pid())); err != nil {
 			log.G(ctx).Errorf("unable to track pid %d: %s", p.Pid(), err)
 		}
 
 		log.G(ctx).Printf("restored process: %d in %s", p.Pid(), time.Since(beforeRestore))
+		c.RestoreSiblings(ctx)
 
 		return c.ScheduleScaleDown()
 	}
 }
 
+// detachedContext returns a context with the logger of ctx that is not
+// cancelled with it. Scaling operations and the restored process outlive the
+// request that triggered them, so they should not run into the deadline of
+// the parent context.
+func detachedContext(ctx context.Context) context.Context {
+	return log.WithLogger(context.Background(), log.G(ctx).WithField("runtime", RuntimeName))
+}
+
 func snapshotDir(bundle string) string {
 	return path.Join(bundle, "work", "snapshots")
 }
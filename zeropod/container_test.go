@@ -0,0 +1,649 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/pkg/process"
+	"github.com/containerd/containerd/pkg/stdio"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/ctrox/zeropod/socket"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestScaleDownOfDeletedContainer(t *testing.T) {
+	deleted := atomic.Bool{}
+	existsCalled := atomic.Int32{}
+
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{ScaleDownDuration: time.Minute},
+		trigger:   scheduleTrigger{},
+	}
+	c.RegisterExists(func() bool {
+		existsCalled.Add(1)
+		return !deleted.Load()
+	})
+
+	require.NoError(t, c.scheduleScaleDownIn(time.Millisecond*50))
+	// delete the container while the scale down is pending. If the scale down
+	// would not be cancelled, it would operate on a container that is only
+	// partially set up and panic.
+	deleted.Store(true)
+
+	assert.Eventually(t, func() bool {
+		return existsCalled.Load() == 1
+	}, time.Second, time.Millisecond*10)
+	assert.False(t, c.ScaledDown())
+}
+
+func TestSetScalingEnabled(t *testing.T) {
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{ScaleDownDuration: time.Minute},
+		trigger:   scheduleTrigger{},
+	}
+
+	require.NoError(t, c.SetScalingEnabled(false))
+	assert.False(t, c.ScalingEnabled())
+	require.NoError(t, c.ScheduleScaleDown())
+	assert.Nil(t, c.scaleDownTimer, "scale down should not be scheduled when scaling is disabled")
+
+	require.NoError(t, c.SetScalingEnabled(true))
+	assert.True(t, c.ScalingEnabled())
+	assert.NotNil(t, c.scaleDownTimer, "scale down should be scheduled when scaling is enabled")
+	c.CancelScaleDown()
+}
+
+func TestPrivilegedContainer(t *testing.T) {
+	spec := &specs.Spec{
+		Process: &specs.Process{Capabilities: &specs.LinuxCapabilities{
+			Bounding: []string{"CAP_SYS_ADMIN"},
+		}},
+		Linux: &specs.Linux{},
+	}
+
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{ScaleDownDuration: time.Minute, spec: spec},
+		trigger:   scheduleTrigger{},
+	}
+	c.scalingDisabled.Store(true)
+	assert.ErrorIs(t, c.SetScalingEnabled(true), ErrPrivileged)
+	assert.False(t, c.ScalingEnabled())
+	assert.ErrorIs(t, c.ForceScaleDown(context.Background()), ErrPrivileged)
+
+	// reclaiming memory does not involve CRIU.
+	c.cfg.ScaleDownStrategy = ScaleDownStrategyReclaim
+	require.NoError(t, c.SetScalingEnabled(true))
+	assert.True(t, c.ScalingEnabled())
+	c.CancelScaleDown()
+}
+
+func TestScheduleScaleDownAfterExec(t *testing.T) {
+	tests := map[string]struct {
+		keepTimerOnExec bool
+		scheduledIn     time.Duration
+		expectedIn      time.Duration
+	}{
+		"exec resets timer": {
+			keepTimerOnExec: false,
+			scheduledIn:     time.Second * 10,
+			expectedIn:      time.Minute,
+		},
+		"exec keeps timer": {
+			keepTimerOnExec: true,
+			scheduledIn:     time.Second * 10,
+			expectedIn:      time.Second * 10,
+		},
+		"exec keeps timer that is already due": {
+			keepTimerOnExec: true,
+			scheduledIn:     0,
+			expectedIn:      0,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Container{
+				Container: &runc.Container{ID: "foo"},
+				context:   context.Background(),
+				cfg: &Config{
+					ScaleDownDuration: time.Minute,
+					KeepTimerOnExec:   tc.keepTimerOnExec,
+				},
+				trigger: scheduleTrigger{},
+			}
+			// make sure the scheduled scale downs never actually run.
+			c.RegisterExists(func() bool { return false })
+
+			require.NoError(t, c.scheduleScaleDownIn(tc.scheduledIn))
+			// exec cancels the scale down while it's running
+			c.CancelScaleDown()
+
+			require.NoError(t, c.ScheduleScaleDownAfterExec())
+			t.Cleanup(c.CancelScaleDown)
+			assert.WithinDuration(t, time.Now().Add(tc.expectedIn), c.scaleDownAt, time.Millisecond*100)
+		})
+	}
+}
+
+type countingTrigger struct {
+	delays atomic.Int32
+}
+
+func (t *countingTrigger) reset() {}
+
+func (t *countingTrigger) delay(ctx context.Context) time.Duration {
+	t.delays.Add(1)
+	return time.Hour
+}
+
+func TestOverlappingExecs(t *testing.T) {
+	trigger := &countingTrigger{}
+	c, _ := newFakeContainer(t, &Config{
+		ScaleDownDuration: time.Minute,
+		KeepTimerOnExec:   true,
+	})
+	c.trigger = trigger
+	c.RegisterExists(func() bool { return true })
+
+	c.AddPendingExec("a")
+	c.AddPendingExec("b")
+	assert.Equal(t, 2, c.PendingExecs())
+
+	// a scale down that is due while execs are running is skipped.
+	require.NoError(t, c.scheduleScaleDownIn(0))
+	time.Sleep(time.Millisecond * 50)
+	assert.Zero(t, trigger.delays.Load())
+
+	assert.Equal(t, 1, c.RemovePendingExec("a"))
+	// removing an exec twice does not affect the other pending execs.
+	assert.Equal(t, 1, c.RemovePendingExec("a"))
+	assert.Equal(t, 0, c.RemovePendingExec("b"))
+
+	require.NoError(t, c.ScheduleScaleDownAfterExec())
+	assert.Eventually(t, func() bool {
+		return trigger.delays.Load() == 1
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestSiblingOf(t *testing.T) {
+	c := &Container{cfg: &Config{PodUID: "a"}}
+	sibling := &Container{cfg: &Config{PodUID: "a"}}
+	otherPod := &Container{cfg: &Config{PodUID: "b"}}
+
+	assert.True(t, c.SiblingOf(sibling))
+	assert.True(t, sibling.SiblingOf(c))
+	assert.False(t, c.SiblingOf(c))
+	assert.False(t, c.SiblingOf(otherPod))
+}
+
+func TestStatusStartedAt(t *testing.T) {
+	startedAt := time.Now().Add(-time.Hour)
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{},
+		startedAt: startedAt,
+	}
+
+	// the original start time should be preserved across scale cycles.
+	for _, scaledDown := range []bool{true, false} {
+		c.SetScaledDown(scaledDown)
+		assert.True(t, startedAt.Equal(c.Status().StartedAt.AsTime()))
+	}
+}
+
+func TestCheckpointMode(t *testing.T) {
+	tests := map[string]struct {
+		cfg      *Config
+		expected v1.CheckpointMode
+	}{
+		"checkpoint": {
+			cfg:      &Config{ScaleDownStrategy: ScaleDownStrategyCheckpoint},
+			expected: v1.CheckpointMode_CHECKPOINT,
+		},
+		"checkpointing disabled": {
+			cfg:      &Config{ScaleDownStrategy: ScaleDownStrategyCheckpoint, DisableCheckpointing: true},
+			expected: v1.CheckpointMode_DISABLED,
+		},
+		"reclaim": {
+			cfg:      &Config{ScaleDownStrategy: ScaleDownStrategyReclaim},
+			expected: v1.CheckpointMode_RECLAIM,
+		},
+		"shared pid namespace": {
+			cfg: &Config{ScaleDownStrategy: ScaleDownStrategyCheckpoint, spec: &specs.Spec{Linux: &specs.Linux{
+				Namespaces: []specs.LinuxNamespace{{Type: specs.PIDNamespace, Path: "/proc/1/ns/pid"}},
+			}}},
+			expected: v1.CheckpointMode_DISABLED,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Container{Container: &runc.Container{ID: "foo"}, cfg: tc.cfg}
+			assert.Equal(t, tc.expected, c.Status().CheckpointMode)
+			assert.Equal(t, strings.ToLower(tc.expected.String()), c.labels()[labelCheckpointMode])
+		})
+	}
+}
+
+func TestVClusterIdentity(t *testing.T) {
+	cfg, err := NewConfig(context.Background(), &specs.Spec{
+		Annotations: map[string]string{
+			CRIContainerNameAnnotation:            "nginx",
+			CRIContainerTypeAnnotation:            "container",
+			"io.kubernetes.cri.sandbox-name":      "nginx-x-default-x-vcluster",
+			"io.kubernetes.cri.sandbox-namespace": "vcluster",
+			"io.kubernetes.cri.sandbox-uid":       "1234",
+			VClusterPodNameAnnotationKey:          "nginx",
+			VClusterPodNamespaceAnnotationKey:     "default",
+		},
+	})
+	require.NoError(t, err)
+	c := &Container{Container: &runc.Container{ID: "foo"}, cfg: cfg}
+
+	// the manager patches the pod on the host with the status.
+	status := c.Status()
+	assert.Equal(t, "nginx-x-default-x-vcluster", status.PodName)
+	assert.Equal(t, "vcluster", status.PodNamespace)
+	assert.Equal(t, "/var/log/pods/vcluster_nginx-x-default-x-vcluster_1234/nginx", podLogDir(cfg))
+	assert.Equal(t, defaultContainerdNS, cfg.ContainerdNamespace)
+
+	// metrics are reported with the pod as seen within the virtual cluster.
+	assert.Equal(t, "nginx", c.labels()[LabelPodName])
+	assert.Equal(t, "default", c.labels()[LabelPodNamespace])
+}
+
+func TestLastActivity(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{},
+		process:   &fakeProcess{pid: 1},
+		tracker:   socket.NewNoopTracker(time.Minute),
+	}
+	c.setLastActivity(started)
+
+	// the noop tracker reports activity a scale down duration ago.
+	assert.InDelta(t, time.Minute, time.Since(c.LastActivity()), float64(time.Second))
+	assert.Equal(t, c.LastActivity().Unix(), c.Status().LastActivity.AsTime().Unix())
+
+	c.scaledDown = true
+	assert.True(t, started.Equal(c.LastActivity()), "last activity before scale down should be reported")
+
+	c.SetScaledDown(false)
+	assert.WithinDuration(t, time.Now(), c.LastActivity(), time.Second, "restore should count as activity")
+
+	c.setLastActivity(started)
+	c.cfg.KeepTimerOnExec = true
+	c.RecordExec()
+	assert.True(t, started.Equal(c.recordedActivity()), "exec should not count as activity if the timer is kept")
+
+	c.cfg.KeepTimerOnExec = false
+	c.RecordExec()
+	assert.WithinDuration(t, time.Now(), c.recordedActivity(), time.Second, "exec should count as activity")
+}
+
+func TestRedirectCooldownRemaining(t *testing.T) {
+	c := &Container{cfg: &Config{RedirectCooldown: time.Second}}
+	assert.Zero(t, c.redirectCooldownRemaining(), "there is no cooldown before the first restore")
+
+	c.redirectsDisabledAt = time.Now()
+	assert.InDelta(t, time.Second, c.redirectCooldownRemaining(), float64(time.Millisecond*100))
+
+	c.redirectsDisabledAt = time.Now().Add(-time.Minute)
+	assert.Zero(t, c.redirectCooldownRemaining())
+
+	c.cfg.RedirectCooldown = 0
+	c.redirectsDisabledAt = time.Now()
+	assert.Zero(t, c.redirectCooldownRemaining())
+}
+
+func TestStartupGraceRemaining(t *testing.T) {
+	c := &Container{cfg: &Config{StartupGrace: time.Second}, startedAt: time.Now()}
+	assert.InDelta(t, time.Second, c.startupGraceRemaining(), float64(time.Millisecond*100))
+
+	c.startedAt = time.Now().Add(-time.Minute)
+	assert.Zero(t, c.startupGraceRemaining())
+
+	c.cfg.StartupGrace = 0
+	c.startedAt = time.Now()
+	assert.Zero(t, c.startupGraceRemaining())
+}
+
+func TestMaxScaledLifetime(t *testing.T) {
+	events := make(chan *v1.ContainerStatus, 10)
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{MaxScaledLifetime: time.Millisecond * 50},
+		events:    events,
+	}
+
+	c.SetScaledDown(true)
+	assert.False(t, (<-events).EvictionRequested)
+	select {
+	case status := <-events:
+		assert.True(t, status.EvictionRequested)
+	case <-time.After(time.Second):
+		t.Fatal("eviction has not been requested")
+	}
+
+	c.SetScaledDown(false)
+	assert.False(t, (<-events).EvictionRequested, "restore should cancel the eviction request")
+
+	// restoring before the lifetime is up should not request an eviction.
+	c.SetScaledDown(true)
+	<-events
+	c.SetScaledDown(false)
+	<-events
+	time.Sleep(time.Millisecond * 100)
+	assert.Empty(t, events)
+
+	// a timer that fires while the container is being stopped does not
+	// request the eviction of the deleted container.
+	c.SetScaledDown(true)
+	<-events
+	c.stopped.Store(true)
+	c.evictionMu.Lock()
+	gen := c.evictionGen
+	c.evictionMu.Unlock()
+	assert.False(t, c.requestEviction(gen))
+	assert.False(t, c.evictionRequested.Load())
+	c.cancelEviction()
+	assert.False(t, c.requestEviction(gen), "cancelled eviction should not be requested")
+}
+
+func TestEagerCheckpoint(t *testing.T) {
+	for name, scaledDown := range map[string]bool{
+		"running":     false,
+		"scaled down": true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, cp := newFakeContainer(t, &Config{EagerCheckpoint: time.Millisecond * 10})
+			c.scaledDown = scaledDown
+
+			c.scheduleEagerCheckpoint(context.Background())
+			time.Sleep(time.Millisecond * 100)
+			if scaledDown {
+				assert.Zero(t, cp.PreDumps(), "scaled down container should not be pre-dumped")
+				return
+			}
+			assert.Equal(t, 1, cp.PreDumps())
+		})
+	}
+}
+
+// fakeProcess is a process that only knows its pid and stdio.
+type fakeProcess struct {
+	process.Process
+	pid   int
+	stdio stdio.Stdio
+}
+
+func (p *fakeProcess) Stdio() stdio.Stdio {
+	return p.stdio
+}
+
+func (p *fakeProcess) Pid() int {
+	return p.pid
+}
+
+// newFakeContainer returns a running container with the supplied config that
+// is scaled by a FakeCheckpointer. The process of the container has pid 1234,
+// the process restored by the checkpointer pid 5678.
+func newFakeContainer(t *testing.T, cfg *Config) (*Container, *FakeCheckpointer) {
+	t.Helper()
+	cp := &FakeCheckpointer{Container: &runc.Container{ID: "foo"}, Process: &fakeProcess{pid: 5678}}
+	c := NewFakeContainer(context.Background(), cfg, &sync.Mutex{}, &fakeProcess{pid: 1234}, cp)
+	t.Cleanup(c.CancelScaleDown)
+	return c, cp
+}
+
+func TestScaleWithFakeCheckpointer(t *testing.T) {
+	for name, disableCheckpointing := range map[string]bool{
+		"checkpoint": false,
+		"kill":       true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c, cp := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, DisableCheckpointing: disableCheckpointing})
+			act := c.activator.(*FakeActivator)
+			pid := c.Process().Pid()
+
+			require.NoError(t, c.ForceScaleDown(ctx))
+			assert.True(t, c.ScaledDown())
+			assert.True(t, act.started)
+			assert.True(t, act.redirects, "traffic should be redirected to the activator")
+			assert.True(t, c.CheckpointedPID(pid), "exit of the process should be ignored")
+
+			checkpoints, kills, restores := cp.Calls()
+			if disableCheckpointing {
+				assert.Equal(t, 1, kills)
+				assert.Zero(t, checkpoints)
+			} else {
+				assert.Equal(t, 1, checkpoints)
+				assert.Zero(t, kills)
+			}
+			assert.Zero(t, restores)
+
+			require.NoError(t, c.ForceRestore(ctx))
+			assert.False(t, c.ScaledDown())
+			assert.False(t, act.redirects, "traffic should go to the process directly")
+			assert.Equal(t, cp.Process.Pid(), c.Process().Pid())
+			assert.NotNil(t, c.scaleDownTimer, "scale down should be scheduled after restore")
+			c.CancelScaleDown()
+
+			_, _, restores = cp.Calls()
+			assert.Equal(t, 1, restores)
+			assert.ErrorIs(t, c.ForceRestore(ctx), ErrAlreadyRestored)
+		})
+	}
+}
+
+func TestScaleDownSharedPIDNamespace(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, spec: &specs.Spec{Linux: &specs.Linux{
+		Namespaces: []specs.LinuxNamespace{{Type: specs.PIDNamespace, Path: "/proc/1/ns/pid"}},
+	}}})
+
+	require.NoError(t, c.ForceScaleDown(ctx))
+	checkpoints, kills, _ := cp.Calls()
+	assert.Zero(t, checkpoints, "container sharing the pid namespace should not be checkpointed")
+	assert.Equal(t, 1, kills)
+
+	require.NoError(t, c.ForceRestore(ctx))
+	c.CancelScaleDown()
+	assert.Equal(t, 1, cp.ColdStarts(), "container should be started from scratch")
+}
+
+func TestStatusLastRestore(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, RestoreFailurePolicy: RestoreFailurePolicyRetry})
+	t.Cleanup(c.deleteMetrics)
+	cp.RestoreErr = errors.New("restore failed")
+	assert.Nil(t, c.Status().LastRestoreAt, "container has never been restored")
+
+	require.NoError(t, c.ForceScaleDown(ctx))
+	assert.Error(t, c.ForceRestore(ctx))
+	assert.NotNil(t, c.Status().LastRestoreAt)
+	assert.Contains(t, c.Status().LastRestoreError, "restore failed")
+
+	cp.RestoreErr = nil
+	require.NoError(t, c.ForceRestore(ctx))
+	c.CancelScaleDown()
+	assert.NotNil(t, c.Status().LastRestoreAt)
+	assert.Empty(t, c.Status().LastRestoreError)
+}
+
+func TestScaleDownUncheckpointableColdStart(t *testing.T) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("unable to create packet socket: %s", err)
+	}
+	t.Cleanup(func() { unix.Close(fd) })
+
+	ctx := context.Background()
+	// the test process has the packet socket open.
+	p := &fakeProcess{pid: os.Getpid()}
+	c, cp := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, Uncheckpointable: UncheckpointablePolicyColdStart})
+	c.process, c.initialProcess, cp.Process = p, p, p
+
+	require.NoError(t, c.ForceScaleDown(ctx))
+	assert.True(t, c.ScaledDown())
+	checkpoints, kills, _ := cp.Calls()
+	assert.Zero(t, checkpoints)
+	assert.Equal(t, 1, kills)
+
+	require.NoError(t, c.ForceRestore(ctx))
+	c.CancelScaleDown()
+	assert.Equal(t, 1, cp.ColdStarts(), "container should be started without checkpoint")
+	assert.False(t, c.coldStart)
+}
+
+func TestRestoreForSignal(t *testing.T) {
+	tests := map[string]struct {
+		restoreOnStop   bool
+		wakeSignals     []syscall.Signal
+		signal          syscall.Signal
+		expectRestored  bool
+		expectScaleDown bool
+	}{
+		"graceful signal": {
+			restoreOnStop:  true,
+			signal:         syscall.SIGTERM,
+			expectRestored: true,
+		},
+		"kill signal": {
+			restoreOnStop:  true,
+			signal:         syscall.SIGKILL,
+			expectRestored: false,
+		},
+		"restore on stop disabled": {
+			restoreOnStop:  false,
+			signal:         syscall.SIGTERM,
+			expectRestored: false,
+		},
+		"wake signal": {
+			wakeSignals:     []syscall.Signal{syscall.SIGUSR1},
+			signal:          syscall.SIGUSR1,
+			expectRestored:  true,
+			expectScaleDown: true,
+		},
+		"other signal than wake signal": {
+			wakeSignals:    []syscall.Signal{syscall.SIGUSR1},
+			signal:         syscall.SIGUSR2,
+			expectRestored: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c, cp := newFakeContainer(t, &Config{
+				ScaleDownDuration: time.Minute,
+				Ports:             []uint16{80},
+				RestoreOnStop:     tc.restoreOnStop,
+				WakeSignals:       tc.wakeSignals,
+			})
+			require.NoError(t, c.ForceScaleDown(ctx))
+
+			require.NoError(t, c.RestoreForSignal(ctx, uint32(tc.signal)))
+			assert.Equal(t, !tc.expectRestored, c.ScaledDown())
+			_, _, restores := cp.Calls()
+			if tc.expectRestored {
+				assert.Equal(t, 1, restores)
+				if tc.expectScaleDown {
+					assert.NotNil(t, c.scaleDownTimer, "container should be scaled down again after a wake signal")
+				} else {
+					assert.Nil(t, c.scaleDownTimer, "container restored for stop should not be scaled down again")
+				}
+			} else {
+				assert.Zero(t, restores)
+			}
+		})
+	}
+}
+
+func TestSignalDuringCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, WakeSignals: []syscall.Signal{syscall.SIGUSR1}})
+	require.NoError(t, c.ForceScaleDown(ctx))
+
+	// simulate a checkpoint that is still in progress while the signal is
+	// sent.
+	c.checkpointRestore.Lock()
+	c.scaledDown = false
+	done := make(chan error)
+	go func() {
+		done <- c.RestoreForSignal(ctx, uint32(syscall.SIGUSR1))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.scaledDown = true
+	c.checkpointRestore.Unlock()
+
+	require.NoError(t, <-done)
+	assert.False(t, c.ScaledDown(), "container should be restored for the signal sent during checkpoint")
+	_, _, restores := cp.Calls()
+	assert.Equal(t, 1, restores)
+	assert.True(t, c.RestoresForSignal(uint32(syscall.SIGUSR1)))
+	assert.False(t, c.RestoresForSignal(uint32(syscall.SIGTERM)))
+}
+
+func TestHandoffGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, HandoffGracePeriod: 200 * time.Millisecond})
+	sibling := NewFakeContainer(ctx, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{81}}, c.checkpointRestore,
+		&fakeProcess{pid: 4321}, &FakeCheckpointer{Container: &runc.Container{ID: "bar"}, Process: &fakeProcess{pid: 8765}})
+	t.Cleanup(sibling.CancelScaleDown)
+	require.NoError(t, sibling.ForceScaleDown(ctx))
+
+	done := make(chan error)
+	go func() {
+		done <- c.ForceScaleDown(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	beforeRestore := time.Now()
+	require.NoError(t, sibling.ForceRestore(ctx))
+	assert.Less(t, time.Since(beforeRestore), 100*time.Millisecond, "sibling should not wait for the grace period")
+	select {
+	case <-done:
+		t.Fatal("container should still be in the grace period")
+	default:
+	}
+
+	require.NoError(t, <-done)
+	assert.True(t, c.ScaledDown())
+}
+
+func TestCheckpointVerificationWithFakeCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}})
+	cp.Err = errCheckpointVerification
+	act := c.activator.(*FakeActivator)
+
+	require.NoError(t, c.ForceScaleDown(ctx))
+	assert.False(t, c.ScaledDown(), "container should keep running")
+	assert.False(t, c.CheckpointedPID(c.Process().Pid()))
+	assert.False(t, act.redirects, "traffic should go to the process directly")
+	assert.NotNil(t, c.scaleDownTimer, "scale down should be rescheduled")
+	c.CancelScaleDown()
+}
@@ -0,0 +1,105 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const (
+	cpusetCPUsFile = "cpuset.cpus"
+	cpusetMemsFile = "cpuset.mems"
+)
+
+// cpuset contains the CPUs and memory nodes a container is pinned to. Empty
+// values mean the container is not pinned and inherits them from its parent.
+type cpuset struct {
+	cpus string
+	mems string
+}
+
+func (s cpuset) empty() bool {
+	return s.cpus == "" && s.mems == ""
+}
+
+// saveCPUSet records the cpuset of the running container so it can be
+// applied again after a restore. runc only applies the cpuset of the spec on
+// restore, which does not contain changes made while the container was
+// running (e.g. by the static CPU manager policy of the kubelet).
+func (c *Container) saveCPUSet(ctx context.Context) {
+	cgroupPath, err := cgroupV2Path(c.process.Pid())
+	if err != nil {
+		log.G(ctx).Debugf("not saving cpuset: %s", err)
+		return
+	}
+
+	set, err := readCPUSet(cgroupPath)
+	if err != nil {
+		log.G(ctx).Errorf("unable to read cpuset of %s: %s", cgroupPath, err)
+		return
+	}
+	c.cpuset = set
+}
+
+// restoreCPUSet applies the cpuset that has been saved before the scale down
+// to the cgroup of the restored process.
+func (c *Container) restoreCPUSet(ctx context.Context, pid int) {
+	if c.cpuset.empty() {
+		return
+	}
+
+	cgroupPath, err := cgroupV2Path(pid)
+	if err != nil {
+		log.G(ctx).Errorf("unable to restore cpuset: %s", err)
+		return
+	}
+
+	if err := writeCPUSet(cgroupPath, c.cpuset); err != nil {
+		log.G(ctx).Errorf("unable to restore cpuset of %s: %s", cgroupPath, err)
+	}
+}
+
+func readCPUSet(cgroupPath string) (cpuset, error) {
+	cpus, err := readCgroupString(filepath.Join(cgroupPath, cpusetCPUsFile))
+	if err != nil {
+		return cpuset{}, err
+	}
+	mems, err := readCgroupString(filepath.Join(cgroupPath, cpusetMemsFile))
+	if err != nil {
+		return cpuset{}, err
+	}
+	return cpuset{cpus: cpus, mems: mems}, nil
+}
+
+// writeCPUSet writes the non-empty values of the cpuset to the cgroup. The
+// memory nodes are written first as the CPUs might only be allowed with them.
+func writeCPUSet(cgroupPath string, set cpuset) error {
+	if set.mems != "" {
+		if err := os.WriteFile(filepath.Join(cgroupPath, cpusetMemsFile), []byte(set.mems), 0); err != nil {
+			return err
+		}
+	}
+	if set.cpus != "" {
+		if err := os.WriteFile(filepath.Join(cgroupPath, cpusetCPUsFile), []byte(set.cpus), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCgroupString reads a cgroup interface file. A missing file is not an
+// error as the controller might not be enabled for the cgroup.
+func readCgroupString(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,36 @@
+package zeropod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUSet(t *testing.T) {
+	before := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(before, cpusetCPUsFile), []byte("2-3\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(before, cpusetMemsFile), []byte("0\n"), 0o644))
+
+	set, err := readCPUSet(before)
+	require.NoError(t, err)
+	assert.Equal(t, cpuset{cpus: "2-3", mems: "0"}, set)
+
+	// the cgroup of the restored process only has the cpuset of the spec.
+	after := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(after, cpusetCPUsFile), []byte("\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(after, cpusetMemsFile), []byte("\n"), 0o644))
+	require.NoError(t, writeCPUSet(after, set))
+
+	restored, err := readCPUSet(after)
+	require.NoError(t, err)
+	assert.Equal(t, set, restored, "restored process should be pinned to the same CPUs")
+}
+
+func TestCPUSetWithoutController(t *testing.T) {
+	set, err := readCPUSet(t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, set.empty())
+}
@@ -0,0 +1,74 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const (
+	// criuConfigFileEnv makes CRIU read an additional config file on top of
+	// the default config. As runc does not support passing arbitrary options
+	// to CRIU, this is the only way to set them per container. runc and CRIU
+	// inherit it from the shim.
+	criuConfigFileEnv  = "CRIU_CONFIG_FILE"
+	criuConfigFileName = "criu.conf"
+)
+
+// criuConfig returns the additional CRIU options of the container in the
+// format of a CRIU config file. It's empty if there are no options.
+func (c *Container) criuConfig() string {
+	options := []string{}
+	if c.cfg.CRIUGhostLimit > 0 {
+		options = append(options, fmt.Sprintf("ghost-limit %d", c.cfg.CRIUGhostLimit))
+	}
+	if c.cfg.CRIUPreDumpMode != "" && c.cfg.CRIUPreDumpMode != CRIUPreDumpModeSplice {
+		options = append(options, fmt.Sprintf("pre-dump-mode %s", c.cfg.CRIUPreDumpMode))
+	}
+	if c.cfg.CRIUAutoDedup {
+		options = append(options, "auto-dedup")
+	}
+
+	if len(options) == 0 {
+		return ""
+	}
+	return strings.Join(options, "\n") + "\n"
+}
+
+// withCRIUConfig writes the additional CRIU options of the container to a
+// config file and points CRIU to it. It returns a func that needs to be
+// called once CRIU is done. As the environment is shared by all containers
+// of the shim, it needs to be called with the checkpointRestore lock held.
+func (c *Container) withCRIUConfig(ctx context.Context) func() {
+	config := c.criuConfig()
+	if config == "" {
+		return func() {}
+	}
+
+	dir := path.Join(c.Bundle, "work")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.G(ctx).Errorf("unable to create CRIU config dir: %s", err)
+		return func() {}
+	}
+
+	file := path.Join(dir, criuConfigFileName)
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		log.G(ctx).Errorf("unable to write CRIU config: %s", err)
+		return func() {}
+	}
+
+	if err := os.Setenv(criuConfigFileEnv, file); err != nil {
+		log.G(ctx).Errorf("unable to set %s: %s", criuConfigFileEnv, err)
+		return func() {}
+	}
+
+	return func() {
+		if err := os.Unsetenv(criuConfigFileEnv); err != nil {
+			log.G(ctx).Errorf("unable to unset %s: %s", criuConfigFileEnv, err)
+		}
+	}
+}
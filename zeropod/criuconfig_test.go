@@ -0,0 +1,42 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCRIUConfig(t *testing.T) {
+	t.Setenv(criuConfigFileEnv, "")
+	ctx := context.Background()
+	c := &Container{
+		Container: &runc.Container{ID: "foo", Bundle: t.TempDir()},
+		cfg:       &Config{},
+	}
+
+	c.withCRIUConfig(ctx)()
+	assert.Empty(t, os.Getenv(criuConfigFileEnv), "no config should be set without options")
+
+	c.cfg.CRIUGhostLimit = 100 << 20
+	done := c.withCRIUConfig(ctx)
+	file := os.Getenv(criuConfigFileEnv)
+	require.NotEmpty(t, file)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "ghost-limit 104857600\n", string(b))
+
+	done()
+	assert.Empty(t, os.Getenv(criuConfigFileEnv))
+
+	c.cfg.CRIUPreDumpMode = CRIUPreDumpModeRead
+	c.cfg.CRIUAutoDedup = true
+	assert.Equal(t, "ghost-limit 104857600\npre-dump-mode read\nauto-dedup\n", c.criuConfig())
+
+	c.cfg = &Config{CRIUPreDumpMode: CRIUPreDumpModeSplice}
+	assert.Empty(t, c.criuConfig(), "default pre-dump mode should not be set")
+}
@@ -0,0 +1,152 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/log"
+)
+
+const (
+	dumpLogFile    = "dump.log"
+	restoreLogFile = "restore.log"
+	// EnvCRIULogRetention can be set on the shim to the number of previous
+	// CRIU runs (dumps and restores) of a container whose logs and stats are
+	// retained on the node for debugging. By default, they are overwritten by
+	// the next run.
+	EnvCRIULogRetention = "ZEROPOD_CRIU_LOG_RETENTION"
+	criuLogHistoryDir   = "criu-history"
+)
+
+// criuLogs retains the CRIU logs of the last dump and restore of a
+// container, so they can still be inspected after the work dir has been
+// cleaned up or overwritten.
+type criuLogs struct {
+	mu      sync.Mutex
+	dump    []byte
+	restore []byte
+}
+
+// readCRIULog reads the CRIU log at logPath and retains it as the last dump
+// or restore log of the container, depending on the file name.
+func (c *Container) readCRIULog(ctx context.Context, logPath string) []byte {
+	b, err := os.ReadFile(logPath)
+	if err != nil {
+		log.G(ctx).Errorf("error reading %s: %s", filepath.Base(logPath), err)
+	}
+
+	c.criuLogs.mu.Lock()
+	defer c.criuLogs.mu.Unlock()
+	switch filepath.Base(logPath) {
+	case dumpLogFile:
+		c.criuLogs.dump = b
+	case restoreLogFile:
+		c.criuLogs.restore = b
+	}
+
+	return b
+}
+
+// CRIULogs returns the CRIU logs of the last dump and restore of the
+// container. They are nil if there has not been any dump or restore yet.
+func (c *Container) CRIULogs() (dump []byte, restore []byte) {
+	c.criuLogs.mu.Lock()
+	defer c.criuLogs.mu.Unlock()
+	return c.criuLogs.dump, c.criuLogs.restore
+}
+
+// criuLogRetention returns the configured amount of CRIU runs to retain the
+// logs of.
+func criuLogRetention(ctx context.Context) int {
+	value := os.Getenv(EnvCRIULogRetention)
+	if value == "" {
+		return 0
+	}
+
+	retain, err := strconv.Atoi(value)
+	if err != nil || retain < 0 {
+		log.G(ctx).Warnf("invalid CRIU log retention %q, not retaining any logs", value)
+		return 0
+	}
+	return retain
+}
+
+// retainCRIULogs moves the logs and stats of the last CRIU run from workDir
+// into a new entry of the history dir of the bundle before they are
+// overwritten by the next run of the supplied kind (dump or restore). Only
+// the configured amount of entries is kept, older ones are removed.
+func retainCRIULogs(ctx context.Context, bundle, workDir, kind string) {
+	retain := criuLogRetention(ctx)
+	if retain == 0 {
+		return
+	}
+
+	historyDir := filepath.Join(bundle, "work", criuLogHistoryDir)
+
+	if err := moveCRIULogs(workDir, filepath.Join(historyDir, time.Now().UTC().Format("20060102T150405.000000000")+"-"+kind)); err != nil {
+		log.G(ctx).Errorf("unable to retain CRIU logs: %s", err)
+	}
+	if err := pruneCRIULogHistory(historyDir, retain); err != nil {
+		log.G(ctx).Errorf("unable to prune CRIU log history: %s", err)
+	}
+}
+
+// moveCRIULogs moves the logs and stats files in workDir to dst. Nothing is
+// moved if there are none.
+func moveCRIULogs(workDir, dst string) error {
+	entries, err := os.ReadDir(workDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !(strings.HasSuffix(name, ".log") || strings.HasPrefix(name, "stats-")) {
+			continue
+		}
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(workDir, name), filepath.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneCRIULogHistory removes all but the newest retain entries of the
+// history dir. The entries are named by their time, so they sort by age.
+func pruneCRIULogHistory(historyDir string, retain int) error {
+	entries, err := os.ReadDir(historyDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+
+	for len(names) > retain {
+		if err := os.RemoveAll(filepath.Join(historyDir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCRIULog(t *testing.T) {
+	dir := t.TempDir()
+	c := &Container{}
+
+	dump, restore := c.CRIULogs()
+	assert.Nil(t, dump)
+	assert.Nil(t, restore)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, dumpLogFile), []byte("dump failed"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, restoreLogFile), []byte("restore failed"), 0o644))
+
+	assert.Equal(t, "dump failed", string(c.readCRIULog(context.Background(), filepath.Join(dir, dumpLogFile))))
+	assert.Equal(t, "restore failed", string(c.readCRIULog(context.Background(), filepath.Join(dir, restoreLogFile))))
+
+	// the logs should be retained even if the files are gone
+	require.NoError(t, os.RemoveAll(dir))
+	dump, restore = c.CRIULogs()
+	assert.Equal(t, "dump failed", string(dump))
+	assert.Equal(t, "restore failed", string(restore))
+}
+
+func TestRetainCRIULogs(t *testing.T) {
+	bundle := t.TempDir()
+	workDir := filepath.Join(bundle, "work")
+	historyDir := filepath.Join(workDir, criuLogHistoryDir)
+	writeLogs := func() {
+		require.NoError(t, os.MkdirAll(workDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, restoreLogFile), []byte("restore"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "stats-restore"), []byte("stats"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "criu.conf"), []byte("conf"), 0o644))
+	}
+
+	writeLogs()
+	retainCRIULogs(context.Background(), bundle, workDir, "restore")
+	assert.NoDirExists(t, historyDir, "logs should not be retained by default")
+
+	t.Setenv(EnvCRIULogRetention, "2")
+	for i := 0; i < 3; i++ {
+		writeLogs()
+		retainCRIULogs(context.Background(), bundle, workDir, "restore")
+	}
+
+	entries, err := os.ReadDir(historyDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.FileExists(t, filepath.Join(historyDir, entries[1].Name(), restoreLogFile))
+	assert.FileExists(t, filepath.Join(historyDir, entries[1].Name(), "stats-restore"))
+	assert.NoFileExists(t, filepath.Join(historyDir, entries[1].Name(), "criu.conf"))
+	assert.NoFileExists(t, filepath.Join(workDir, restoreLogFile))
+	assert.FileExists(t, filepath.Join(workDir, "criu.conf"))
+}
@@ -0,0 +1,47 @@
+package zeropod
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/containerd/log"
+)
+
+// EnvCleanupOnDiskFull can be set on the shim to also remove the pre-dump
+// images and the retained CRIU logs of a container if its checkpoint failed
+// as the disk was full, to make room for the next attempt. The partial
+// images of the failed checkpoint are always removed.
+const EnvCleanupOnDiskFull = "ZEROPOD_CLEANUP_ON_DISK_FULL"
+
+var errCheckpointDiskFull = errors.New("disk is full")
+
+// isDiskFull returns true if the checkpoint failed with err and criuLog as
+// there was no space left on the disk. CRIU only reports the cause in its
+// log, runc just reports that CRIU failed.
+func isDiskFull(err error, criuLog []byte) bool {
+	enospc := syscall.ENOSPC.Error()
+	return errors.Is(err, syscall.ENOSPC) ||
+		strings.Contains(strings.ToLower(err.Error()), enospc) ||
+		bytes.Contains(bytes.ToLower(criuLog), []byte(enospc))
+}
+
+// cleanupAfterDiskFull removes what has been written by the failed
+// checkpoint of the container with the supplied bundle.
+func cleanupAfterDiskFull(ctx context.Context, bundle string) {
+	dirs := []string{containerDir(bundle)}
+	if cleanup, _ := strconv.ParseBool(os.Getenv(EnvCleanupOnDiskFull)); cleanup {
+		dirs = append(dirs, preDumpDir(bundle), filepath.Join(bundle, "work", criuLogHistoryDir))
+	}
+
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.G(ctx).Errorf("unable to clean up %s after disk full: %s", dir, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDiskFull(t *testing.T) {
+	criuErr := errors.New("criu failed: type NOTIFY errno 0")
+	assert.True(t, isDiskFull(fmt.Errorf("writing image: %w", syscall.ENOSPC), nil))
+	assert.True(t, isDiskFull(criuErr, []byte("(00.012345) Error (criu/page-xfer.c:123): Can't write pages: No space left on device")))
+	assert.False(t, isDiskFull(criuErr, []byte("(00.012345) Error (criu/sk-inet.c:123): Unsupported socket")))
+}
+
+func TestCleanupAfterDiskFull(t *testing.T) {
+	for name, cleanup := range map[string]bool{
+		"partial images": false,
+		"everything":     true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			bundle := t.TempDir()
+			history := filepath.Join(bundle, "work", criuLogHistoryDir)
+			for _, dir := range []string{containerDir(bundle), preDumpDir(bundle), history} {
+				require.NoError(t, os.MkdirAll(dir, 0o755))
+			}
+			t.Setenv(EnvCleanupOnDiskFull, fmt.Sprint(cleanup))
+
+			cleanupAfterDiskFull(context.Background(), bundle)
+			assert.NoDirExists(t, containerDir(bundle))
+			for _, dir := range []string{preDumpDir(bundle), history} {
+				_, err := os.Stat(dir)
+				assert.Equal(t, cleanup, os.IsNotExist(err), dir)
+			}
+		})
+	}
+}
+
+func TestScaleDownDiskFull(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{ContainerName: "disk-full", ScaleDownDuration: time.Minute, Ports: []uint16{80}})
+	t.Cleanup(c.deleteMetrics)
+	cp.Err = fmt.Errorf("%w: criu failed", errCheckpointDiskFull)
+	act := c.activator.(*FakeActivator)
+
+	require.NoError(t, c.ForceScaleDown(ctx))
+	c.CancelScaleDown()
+	assert.False(t, c.ScaledDown(), "container should keep running")
+	assert.False(t, act.redirects, "traffic should go to the process directly")
+	assert.False(t, c.CheckpointedPID(c.Process().Pid()), "exit of the process should not be ignored")
+	assert.Equal(t, 1.0, testutil.ToFloat64(checkpointDiskFull.With(c.labels())))
+}
@@ -0,0 +1,107 @@
+package zeropod
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const (
+	ioStatFile   = "io.stat"
+	ioStatWBytes = "wbytes"
+)
+
+// newDiskWriteMeter returns a meter of the rate at which the container
+// writes to disk, or nil if the disk writes are not limited.
+func newDiskWriteMeter(c *Container) *rateMeter {
+	if c.cfg.MaxDiskWriteRate <= 0 {
+		return nil
+	}
+
+	return newRateMeter(func() (float64, error) {
+		written, err := diskWritten(c.process.Pid())
+		return float64(written), err
+	})
+}
+
+// resetDiskWrites starts measuring the disk writes of the container for the
+// scale down that is being scheduled.
+func (c *Container) resetDiskWrites() {
+	if c.diskWrites == nil {
+		return
+	}
+	c.diskWrites.reset()
+}
+
+// heavyDiskWrites returns true if the container has written to disk at a
+// higher rate than the configured maximum since the scale down has been
+// scheduled. Such containers are poor candidates for a checkpoint, so the
+// decision is reported by the scaling disabled metric.
+func (c *Container) heavyDiskWrites(ctx context.Context) bool {
+	if c.diskWrites == nil {
+		return false
+	}
+
+	rate, ok, err := c.diskWrites.rate()
+	if err != nil {
+		log.G(ctx).Errorf("unable to get disk writes: %s", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	heavy := rate > float64(c.cfg.MaxDiskWriteRate)
+	if heavy {
+		log.G(ctx).Infof("container wrote %.0f bytes/s to disk, exceeding the maximum of %d bytes/s", rate, c.cfg.MaxDiskWriteRate)
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonDiskWrites)).Set(1)
+	} else {
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonDiskWrites)).Set(0)
+	}
+	return heavy
+}
+
+// diskWritten returns the total bytes written to disk by the cgroup of the
+// supplied pid.
+func diskWritten(pid int) (uint64, error) {
+	cgroupPath, err := cgroupV2Path(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return readWrittenBytes(filepath.Join(cgroupPath, ioStatFile))
+}
+
+// readWrittenBytes sums up the written bytes of all devices in the io.stat
+// file at path.
+func readWrittenBytes(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var written uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || key != ioStatWBytes {
+				continue
+			}
+
+			bytes, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			written += bytes
+		}
+	}
+
+	return written, scanner.Err()
+}
@@ -0,0 +1,43 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWrittenBytes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ioStatFile)
+	require.NoError(t, os.WriteFile(file, []byte(
+		"253:0 rbytes=4096 wbytes=1000 rios=1 wios=2 dbytes=0 dios=0\n"+
+			"8:0 rbytes=0 wbytes=500 rios=0 wios=1 dbytes=0 dios=0\n"), 0o644))
+
+	written, err := readWrittenBytes(file)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1500), written)
+
+	require.NoError(t, os.WriteFile(file, []byte(""), 0o644))
+	written, err = readWrittenBytes(file)
+	require.NoError(t, err)
+	assert.Zero(t, written)
+}
+
+func TestHeavyDiskWrites(t *testing.T) {
+	written := float64(0)
+	c := &Container{cfg: &Config{ContainerName: "disk-writes", MaxDiskWriteRate: 1024}}
+	t.Cleanup(c.deleteMetrics)
+	c.diskWrites = newRateMeter(func() (float64, error) { return written, nil })
+
+	c.diskWrites.reset()
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, c.heavyDiskWrites(context.Background()))
+
+	c.diskWrites.reset()
+	written = 1 << 20
+	assert.True(t, c.heavyDiskWrites(context.Background()))
+}
@@ -0,0 +1,176 @@
+package zeropod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containerd/log"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+)
+
+const (
+	// EnvEventSink can be set on the shim to a URL the scale events of all
+	// containers should be sent to, in addition to the event history of the
+	// shim API. The scheme of the URL selects the sink, http and https are
+	// supported out of the box and others can be added with
+	// RegisterEventSink.
+	EnvEventSink = "ZEROPOD_EVENT_SINK"
+
+	eventSinkBufferSize = 128
+	eventSinkTimeout    = 10 * time.Second
+)
+
+// ScaleEventMessage is a scale event of a container as it's sent to an
+// event sink.
+type ScaleEventMessage struct {
+	ContainerID   string    `json:"containerID"`
+	ContainerName string    `json:"containerName"`
+	PodName       string    `json:"podName"`
+	PodNamespace  string    `json:"podNamespace"`
+	Time          time.Time `json:"time"`
+	Type          string    `json:"type"`
+	Cause         string    `json:"cause"`
+	Message       string    `json:"message"`
+}
+
+// EventSink receives the scale events of all zeropod containers of the shim.
+type EventSink interface {
+	Send(ctx context.Context, event *ScaleEventMessage) error
+}
+
+// NewEventSinkFunc creates an event sink sending to the supplied URL.
+type NewEventSinkFunc func(target *url.URL) (EventSink, error)
+
+var (
+	eventSinksMu sync.Mutex
+	eventSinks   = map[string]NewEventSinkFunc{
+		"http":  newWebhookSink,
+		"https": newWebhookSink,
+	}
+
+	eventSinkOnce  sync.Once
+	eventSinkQueue chan *ScaleEventMessage
+)
+
+// RegisterEventSink registers an event sink for URLs with the supplied
+// scheme. It needs to be called before the first scale event of the shim,
+// e.g. in the init func of the package implementing the sink.
+func RegisterEventSink(scheme string, f NewEventSinkFunc) {
+	eventSinksMu.Lock()
+	defer eventSinksMu.Unlock()
+	eventSinks[scheme] = f
+}
+
+func eventSinkFromEnv() (EventSink, error) {
+	value := os.Getenv(EnvEventSink)
+	if value == "" {
+		return nil, nil
+	}
+
+	target, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", EnvEventSink, err)
+	}
+
+	eventSinksMu.Lock()
+	newSink, ok := eventSinks[target.Scheme]
+	eventSinksMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no event sink registered for scheme %q of %s", target.Scheme, EnvEventSink)
+	}
+	return newSink(target)
+}
+
+// startEventSink creates the configured event sink and starts sending the
+// queued events to it. The queue stays nil if no sink is configured.
+func startEventSink() {
+	sink, err := eventSinkFromEnv()
+	if err != nil {
+		log.L.Warnf("ignoring invalid event sink: %s", err)
+		return
+	}
+	if sink == nil {
+		return
+	}
+
+	eventSinkQueue = make(chan *ScaleEventMessage, eventSinkBufferSize)
+	go func() {
+		for event := range eventSinkQueue {
+			ctx, cancel := context.WithTimeout(context.Background(), eventSinkTimeout)
+			if err := sink.Send(ctx, event); err != nil {
+				log.L.Errorf("unable to send scale event of container %s to event sink: %s", event.ContainerID, err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// publishScaleEvent queues the scale event for the event sink. Events are
+// discarded if no sink is configured or the queue is full, so a slow sink
+// never holds up scaling.
+func (c *Container) publishScaleEvent(event *v1.ScaleEvent) {
+	eventSinkOnce.Do(startEventSink)
+	if eventSinkQueue == nil {
+		return
+	}
+
+	msg := &ScaleEventMessage{
+		ContainerID:   c.ID(),
+		ContainerName: c.cfg.ContainerName,
+		PodName:       c.cfg.VirtualPodName(),
+		PodNamespace:  c.cfg.VirtualPodNamespace(),
+		Time:          event.Time.AsTime(),
+		Type:          event.Type.String(),
+		Cause:         event.Cause,
+		Message:       event.Message,
+	}
+	select {
+	case eventSinkQueue <- msg:
+	default:
+		log.G(c.context).Warnf("event sink queue full, discarding scale event: %v", event)
+	}
+}
+
+// webhookSink posts the events as JSON to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookSink(target *url.URL) (EventSink, error) {
+	if target.Host == "" {
+		return nil, fmt.Errorf("webhook url %q has no host", target)
+	}
+	return &webhookSink{url: target.String(), client: &http.Client{}}, nil
+}
+
+func (s *webhookSink) Send(ctx context.Context, event *ScaleEventMessage) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return nil
+}
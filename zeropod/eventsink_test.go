@@ -0,0 +1,109 @@
+package zeropod
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEventSink struct{}
+
+func (fakeEventSink) Send(context.Context, *ScaleEventMessage) error { return nil }
+
+func TestEventSinkFromEnv(t *testing.T) {
+	RegisterEventSink("fake", func(*url.URL) (EventSink, error) { return fakeEventSink{}, nil })
+
+	t.Setenv(EnvEventSink, "")
+	sink, err := eventSinkFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, sink)
+
+	t.Setenv(EnvEventSink, "https://example.com/events")
+	sink, err = eventSinkFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &webhookSink{}, sink)
+
+	t.Setenv(EnvEventSink, "fake://events")
+	sink, err = eventSinkFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, fakeEventSink{}, sink)
+
+	t.Setenv(EnvEventSink, "nats://localhost:4222")
+	_, err = eventSinkFromEnv()
+	assert.Error(t, err, "no sink is registered for the scheme")
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan *ScaleEventMessage, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := &ScaleEventMessage{}
+		if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- event
+	}))
+	defer srv.Close()
+
+	target, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	sink, err := newWebhookSink(target)
+	require.NoError(t, err)
+
+	event := &ScaleEventMessage{ContainerID: "foo", Type: v1.ScaleEventType_SCALE_DOWN.String(), Cause: ScaleEventCauseForced}
+	require.NoError(t, sink.Send(context.Background(), event))
+	assert.Equal(t, event, <-received)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	target, err = url.Parse(failing.URL)
+	require.NoError(t, err)
+	sink, err = newWebhookSink(target)
+	require.NoError(t, err)
+	assert.Error(t, sink.Send(context.Background(), event))
+}
+
+func TestPublishScaleEvent(t *testing.T) {
+	eventSinkOnce.Do(func() {})
+	eventSinkQueue = make(chan *ScaleEventMessage, 1)
+	t.Cleanup(func() { eventSinkQueue = nil })
+
+	// events are published even if the history is disabled.
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		cfg:       &Config{ContainerName: "nginx", PodName: "nginx-abc", PodNamespace: "default"},
+	}
+	c.RecordScaleEvent(v1.ScaleEventType_RESTORE, ScaleEventCauseConnection, "restored in 1s")
+	assert.Empty(t, c.ScaleEvents())
+
+	select {
+	case msg := <-eventSinkQueue:
+		assert.Equal(t, "foo", msg.ContainerID)
+		assert.Equal(t, "nginx", msg.ContainerName)
+		assert.Equal(t, "nginx-abc", msg.PodName)
+		assert.Equal(t, "default", msg.PodNamespace)
+		assert.Equal(t, "RESTORE", msg.Type)
+		assert.Equal(t, ScaleEventCauseConnection, msg.Cause)
+		assert.Equal(t, "restored in 1s", msg.Message)
+		assert.WithinDuration(t, time.Now(), msg.Time, time.Minute)
+	default:
+		t.Fatal("event should have been queued")
+	}
+
+	// a full queue does not block.
+	c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN, ScaleEventCauseForced, "")
+	c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN, ScaleEventCauseForced, "")
+	assert.Len(t, eventSinkQueue, 1)
+}
@@ -0,0 +1,169 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/pkg/process"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/ctrox/zeropod/activator"
+	"github.com/ctrox/zeropod/socket"
+)
+
+var (
+	_ Checkpointer        = &FakeCheckpointer{}
+	_ PreDumper           = &FakeCheckpointer{}
+	_ RestoreCleaner      = &FakeCheckpointer{}
+	_ activator.Activator = &FakeActivator{}
+)
+
+// NewFakeContainer returns a running container with the process p that is
+// scaled by cp and redirects to a FakeActivator while it's scaled down. It
+// allows testing code that manages containers without runc and CRIU.
+func NewFakeContainer(ctx context.Context, cfg *Config, cr sync.Locker, p process.Process, cp *FakeCheckpointer) *Container {
+	c := &Container{
+		Container:         cp.Container,
+		context:           ctx,
+		cfg:               cfg,
+		process:           p,
+		initialProcess:    p,
+		activator:         &FakeActivator{},
+		tracker:           socket.NewNoopTracker(time.Minute),
+		checkpointRestore: cr,
+		checkpointedPIDs:  map[int]struct{}{},
+	}
+	c.trigger = newScaleDownTrigger(c)
+	c.diskWrites = newDiskWriteMeter(c)
+	c.SetCheckpointer(cp)
+	return c
+}
+
+// FakeActivator is an activator that only keeps track of its state.
+type FakeActivator struct {
+	started   bool
+	redirects bool
+}
+
+func (a *FakeActivator) Start(context.Context, []uint16, activator.OnAccept) error {
+	a.started = true
+	return nil
+}
+
+func (a *FakeActivator) Started() bool           { return a.started }
+func (a *FakeActivator) Reset() error            { a.redirects = true; return nil }
+func (a *FakeActivator) DisableRedirects() error { a.redirects = false; return nil }
+func (a *FakeActivator) Stop(context.Context)    {}
+
+// FakeCheckpointer is a Checkpointer that does not interact with runc or
+// CRIU at all. It allows testing the scaling logic of a container without
+// them.
+type FakeCheckpointer struct {
+	// Container and Process are returned on restore.
+	Container *runc.Container
+	Process   process.Process
+	// Err is returned by all operations if set.
+	Err error
+	// FailRestores is the amount of restores from a checkpoint that fail
+	// before they succeed.
+	FailRestores int
+	// RestoreErr is returned by restores from a checkpoint if set.
+	RestoreErr error
+	// BlockRestores makes restores from a checkpoint block until their
+	// context is done.
+	BlockRestores bool
+
+	mu          sync.Mutex
+	checkpoints int
+	kills       int
+	restores    int
+	coldStarts  int
+	preDumps    int
+	cleanups    int
+}
+
+var errFakeRestore = errors.New("fake restore failure")
+
+func (f *FakeCheckpointer) Checkpoint(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checkpoints++
+	return f.Err
+}
+
+func (f *FakeCheckpointer) PreDump(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.preDumps++
+	return f.Err
+}
+
+func (f *FakeCheckpointer) Kill(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.kills++
+	return f.Err
+}
+
+func (f *FakeCheckpointer) Restore(ctx context.Context, checkpoint bool) (*runc.Container, process.Process, HandleStartedFunc, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restores++
+	if !checkpoint {
+		f.coldStarts++
+	}
+	if checkpoint && f.BlockRestores {
+		f.mu.Unlock()
+		<-ctx.Done()
+		f.mu.Lock()
+		return nil, nil, nil, ctx.Err()
+	}
+	if f.Err != nil {
+		return nil, nil, nil, f.Err
+	}
+	if checkpoint && f.RestoreErr != nil {
+		return nil, nil, nil, f.RestoreErr
+	}
+	if checkpoint && f.FailRestores > 0 {
+		f.FailRestores--
+		return nil, nil, nil, errFakeRestore
+	}
+	return f.Container, f.Process, nil, nil
+}
+
+func (f *FakeCheckpointer) CleanupRestore(ctx context.Context) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cleanups++
+}
+
+// Calls returns the amount of checkpoints, kills and restores that have been
+// done.
+func (f *FakeCheckpointer) Calls() (checkpoints, kills, restores int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.checkpoints, f.kills, f.restores
+}
+
+// ColdStarts returns the amount of restores that have been done without a
+// checkpoint.
+func (f *FakeCheckpointer) ColdStarts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.coldStarts
+}
+
+// PreDumps returns the amount of pre-dumps that have been done.
+func (f *FakeCheckpointer) PreDumps() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.preDumps
+}
+
+// Cleanups returns the amount of aborted restores that have been cleaned up.
+func (f *FakeCheckpointer) Cleanups() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cleanups
+}
@@ -0,0 +1,126 @@
+package zeropod
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	anonInodePrefix = "anon_inode:"
+	socketPrefix    = "socket:["
+)
+
+// uncheckpointableFDTypes are the types of anonymous inode files that CRIU
+// can't dump. Event loop primitives like eventfd, signalfd, eventpoll,
+// timerfd and inotify are supported by CRIU.
+var uncheckpointableFDTypes = []string{
+	"[io_uring]", "[userfaultfd]", "[perf_event]", "bpf-prog", "kvm-vm", "kvm-vcpu",
+}
+
+// uncheckpointableSockets maps files in /proc/<pid>/net listing sockets that
+// are not checkpointed to the field containing their inode. CRIU only
+// supports a subset of raw and packet socket setups (e.g. no mapped rings),
+// which are mostly used by networking tools, and a failing dump would take
+// down the shim.
+var uncheckpointableSockets = map[string]struct {
+	kind       string
+	inodeField int
+}{
+	"packet": {kind: "packet", inodeField: 8},
+	"raw":    {kind: "raw", inodeField: 9},
+	"raw6":   {kind: "raw", inodeField: 9},
+}
+
+// uncheckpointableFDs returns the open files of the process and its children
+// that would make the checkpoint fail.
+func uncheckpointableFDs(pid int) ([]string, error) {
+	children, err := findChildren(pid)
+	if err != nil {
+		return nil, fmt.Errorf("finding child pids: %w", err)
+	}
+
+	fds := []string{}
+	for _, pid := range append([]int{pid}, children...) {
+		sockets, err := findUncheckpointableSockets(filepath.Join(procPath, strconv.Itoa(pid), "net"))
+		if err != nil {
+			return nil, fmt.Errorf("listing sockets: %w", err)
+		}
+		f, err := findUncheckpointableFDs(filepath.Join(procPath, strconv.Itoa(pid), "fd"), sockets)
+		if err != nil {
+			return nil, err
+		}
+		for _, fd := range f {
+			fds = append(fds, fmt.Sprintf("%s (pid %d)", fd, pid))
+		}
+	}
+
+	return fds, nil
+}
+
+// findUncheckpointableFDs returns the fds in fdDir that CRIU can't dump.
+// sockets maps the inodes of sockets that can't be dumped to their kind.
+func findUncheckpointableFDs(fdDir string, sockets map[string]string) ([]string, error) {
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing fds: %w", err)
+	}
+
+	fds := []string{}
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil {
+			// the fd might have been closed in the meantime.
+			continue
+		}
+
+		fdType, ok := strings.CutPrefix(target, anonInodePrefix)
+		if ok && slices.Contains(uncheckpointableFDTypes, fdType) {
+			fds = append(fds, fmt.Sprintf("fd %s is %s", entry.Name(), fdType))
+		}
+
+		inode, ok := strings.CutPrefix(target, socketPrefix)
+		if kind, found := sockets[strings.TrimSuffix(inode, "]")]; ok && found {
+			fds = append(fds, fmt.Sprintf("fd %s is a %s socket", entry.Name(), kind))
+		}
+	}
+
+	return fds, nil
+}
+
+// findUncheckpointableSockets returns the inodes of the sockets that can't be
+// checkpointed in the network namespace of netDir mapped to their kind.
+func findUncheckpointableSockets(netDir string) (map[string]string, error) {
+	sockets := map[string]string{}
+	for file, socket := range uncheckpointableSockets {
+		f, err := os.Open(filepath.Join(netDir, file))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		// skip the header
+		scanner.Scan()
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > socket.inodeField {
+				sockets[fields[socket.inodeField]] = socket.kind
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sockets, nil
+}
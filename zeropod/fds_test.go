@@ -0,0 +1,72 @@
+package zeropod
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestFindUncheckpointableFDs(t *testing.T) {
+	dir := t.TempDir()
+	for fd, target := range map[string]string{
+		"0": "/dev/null",
+		"3": "anon_inode:[eventfd]",
+		"4": "anon_inode:[signalfd]",
+		"5": "anon_inode:[eventpoll]",
+		"6": "anon_inode:[io_uring]",
+		"7": "socket:[12345]",
+		"8": "socket:[23456]",
+	} {
+		require.NoError(t, os.Symlink(target, filepath.Join(dir, fd)))
+	}
+
+	fds, err := findUncheckpointableFDs(dir, map[string]string{"23456": "packet"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"fd 6 is [io_uring]", "fd 8 is a packet socket"}, fds)
+}
+
+func TestFindUncheckpointableSockets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "packet"), []byte(
+		"sk               RefCnt Type Proto  Iface R Rmem   User   Inode\n"+
+			"ffff8880047c5800 3      3    0003   2     1    0      0      28735\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "raw"), []byte(
+		"  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n"+
+			"   1: 00000000:0001 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 28736 2 ffff888004799000 0\n"), 0o644))
+
+	sockets, err := findUncheckpointableSockets(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"28735": "packet", "28736": "raw"}, sockets)
+}
+
+func TestUncheckpointableFDsPacketSocket(t *testing.T) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("unable to create packet socket: %s", err)
+	}
+	t.Cleanup(func() { unix.Close(fd) })
+
+	fds, err := uncheckpointableFDs(os.Getpid())
+	require.NoError(t, err)
+	assert.Contains(t, fds, fmt.Sprintf("fd %d is a packet socket (pid %d)", fd, os.Getpid()))
+}
+
+func TestUncheckpointableFDsEventLoop(t *testing.T) {
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	require.NoError(t, err)
+	t.Cleanup(func() { unix.Close(efd) })
+
+	mask := unix.Sigset_t{}
+	sfd, err := unix.Signalfd(-1, &mask, unix.SFD_CLOEXEC)
+	require.NoError(t, err)
+	t.Cleanup(func() { unix.Close(sfd) })
+
+	fds, err := uncheckpointableFDs(os.Getpid())
+	require.NoError(t, err)
+	assert.Empty(t, fds, "eventfd and signalfd can be checkpointed")
+}
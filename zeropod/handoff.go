@@ -0,0 +1,63 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/ctrox/zeropod/activator"
+)
+
+// connectionHandoffTimeout limits how long a restored process has to accept
+// the handed back connections. A process that has been started from scratch
+// might need some time until it listens on the handoff socket.
+const connectionHandoffTimeout = 10 * time.Second
+
+// takeConnections takes over the established connections of the process
+// through the handoff socket of the container, so they survive the scale
+// down. Data on any of the held connections restores the container.
+func (c *Container) takeConnections(ctx context.Context) {
+	if c.cfg.ConnectionHandoff == "" {
+		return
+	}
+	if c.connHandoff == nil {
+		c.connHandoff = activator.NewConnectionHandoff()
+	}
+
+	socket := filepath.Join(procRoot(c.process.Pid()), c.cfg.ConnectionHandoff)
+	n, err := c.connHandoff.Take(ctx, socket, func() {
+		// restore in the background as the restore waits for the
+		// checkpointRestore lock, which might still be held by the scale
+		// down.
+		go func() {
+			if err := c.forceRestore(ctx, ScaleEventCauseConnection); err != nil && !errors.Is(err, ErrAlreadyRestored) {
+				log.G(ctx).Errorf("unable to restore for data on handed off connection: %s", err)
+			}
+		}()
+	})
+	if err != nil {
+		log.G(ctx).Errorf("unable to take over connections of the process: %s", err)
+	}
+	if n > 0 {
+		log.G(ctx).Infof("took over %d established connections of the process", n)
+	}
+}
+
+// handBackConnections hands the held connections back to the process with
+// the supplied pid.
+func (c *Container) handBackConnections(ctx context.Context, pid int) {
+	if c.connHandoff == nil || c.connHandoff.Len() == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectionHandoffTimeout)
+	defer cancel()
+	n, err := c.connHandoff.HandBack(ctx, filepath.Join(procRoot(pid), c.cfg.ConnectionHandoff))
+	if err != nil {
+		log.G(ctx).Errorf("unable to hand back connections, they have been closed: %s", err)
+		return
+	}
+	log.G(ctx).Infof("handed back %d established connections to the process", n)
+}
@@ -0,0 +1,140 @@
+package zeropod
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/ctrox/zeropod/activator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestConnectionHandoff(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "handoff.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	defer l.Close()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	client, err := net.Dial("tcp", tcp.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	server, err := tcp.Accept()
+	require.NoError(t, err)
+	f, err := server.(*net.TCPConn).File()
+	require.NoError(t, err)
+	server.Close()
+
+	// the process side of the handoff, it passes its connection on take
+	// and reports the amount of fds it got back on give.
+	given := make(chan int, 1)
+	go func() {
+		for {
+			conn, err := l.AcceptUnix()
+			if err != nil {
+				return
+			}
+			op := make([]byte, 1)
+			if _, err := conn.Read(op); err != nil {
+				conn.Close()
+				continue
+			}
+			switch op[0] {
+			case activator.HandoffTake:
+				_, _, _ = conn.WriteMsgUnix([]byte{op[0]}, unix.UnixRights(int(f.Fd())), nil)
+				f.Close()
+			case activator.HandoffGive:
+				oob := make([]byte, unix.CmsgSpace(4))
+				_, oobn, _, _, _ := conn.ReadMsgUnix(make([]byte, 1), oob)
+				msgs, _ := unix.ParseSocketControlMessage(oob[:oobn])
+				fds := 0
+				for _, msg := range msgs {
+					rights, _ := unix.ParseUnixRights(&msg)
+					for _, fd := range rights {
+						unix.Close(fd)
+					}
+					fds += len(rights)
+				}
+				given <- fds
+			}
+			conn.Close()
+		}
+	}()
+
+	// the root of our own process is the root of the filesystem.
+	p := &fakeProcess{pid: os.Getpid()}
+	c := &Container{
+		context: context.Background(),
+		cfg:     &Config{ConnectionHandoff: socketPath},
+		process: p,
+	}
+	t.Cleanup(func() { c.connHandoff.Close() })
+
+	c.takeConnections(context.Background())
+	require.NotNil(t, c.connHandoff)
+	assert.Equal(t, 1, c.connHandoff.Len())
+
+	c.handBackConnections(context.Background(), p.pid)
+	assert.Equal(t, 1, <-given)
+	assert.Zero(t, c.connHandoff.Len())
+}
+
+func TestConnectionHandoffDisabled(t *testing.T) {
+	c := &Container{cfg: &Config{}, process: &fakeProcess{pid: os.Getpid()}}
+	c.takeConnections(context.Background())
+	assert.Nil(t, c.connHandoff)
+	c.handBackConnections(context.Background(), os.Getpid())
+}
+
+func TestConnectionHandoffWithoutSharedLock(t *testing.T) {
+	ctx := context.Background()
+	socketPath := filepath.Join(t.TempDir(), "handoff.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	defer l.Close()
+
+	// the process is slow to pass its connections.
+	accepted, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			return
+		}
+		close(accepted)
+		<-release
+		conn.Close()
+	}()
+
+	// the root of our own process is the root of the filesystem.
+	c := NewFakeContainer(ctx, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, ConnectionHandoff: socketPath}, &sync.Mutex{},
+		&fakeProcess{pid: os.Getpid()}, &FakeCheckpointer{Container: &runc.Container{ID: "foo"}, Process: &fakeProcess{pid: 5678}})
+	t.Cleanup(c.CancelScaleDown)
+	sibling := NewFakeContainer(ctx, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{81}}, c.checkpointRestore,
+		&fakeProcess{pid: 4321}, &FakeCheckpointer{Container: &runc.Container{ID: "bar"}, Process: &fakeProcess{pid: 8765}})
+	t.Cleanup(sibling.CancelScaleDown)
+	require.NoError(t, sibling.ForceScaleDown(ctx))
+
+	done := make(chan error)
+	go func() {
+		done <- c.ForceScaleDown(ctx)
+	}()
+	<-accepted
+
+	beforeRestore := time.Now()
+	require.NoError(t, sibling.ForceRestore(ctx))
+	assert.Less(t, time.Since(beforeRestore), 100*time.Millisecond, "sibling should not wait for the connection handoff")
+
+	close(release)
+	require.NoError(t, <-done)
+	assert.True(t, c.ScaledDown())
+}
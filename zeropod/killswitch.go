@@ -0,0 +1,74 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/containerd/log"
+)
+
+const killSwitchInterval = time.Second
+
+var (
+	// killSwitchFile disables scaling of all containers on the node while it
+	// exists. As every pod has its own shim, it's shared through the
+	// filesystem of the node.
+	killSwitchFile = "/run/zeropod/scaling-disabled"
+	// nodeScalingDisabled reports if the kill switch is currently set.
+	nodeScalingDisabled atomic.Bool
+)
+
+func killSwitchSet() bool {
+	_, err := os.Stat(killSwitchFile)
+	return err == nil
+}
+
+// WatchKillSwitch checks the kill switch of the node until ctx is done and
+// calls onChange every time it's set or cleared.
+func WatchKillSwitch(ctx context.Context, onChange func(disabled bool)) {
+	ticker := time.NewTicker(killSwitchInterval)
+	defer ticker.Stop()
+
+	for {
+		disabled := killSwitchSet()
+		if nodeScalingDisabled.Swap(disabled) != disabled {
+			if disabled {
+				log.G(ctx).Warnf("kill switch %s is set, disabling scaling of all containers", killSwitchFile)
+			} else {
+				log.G(ctx).Infof("kill switch %s has been cleared, enabling scaling again", killSwitchFile)
+			}
+			onChange(disabled)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SetNodeScalingDisabled applies the state of the kill switch to the
+// container. If it's set, a scaled down container is restored and pending
+// scale downs are cancelled. Once it's cleared, the container is scaled down
+// as usual again.
+func (c *Container) SetNodeScalingDisabled(ctx context.Context, disabled bool) {
+	if !disabled {
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonKillSwitch)).Set(0)
+		if c.ScalingEnabled() && !c.ScaledDown() {
+			if err := c.ScheduleScaleDown(); err != nil {
+				log.G(ctx).Errorf("unable to schedule scale down: %s", err)
+			}
+		}
+		return
+	}
+
+	scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonKillSwitch)).Set(1)
+	c.CancelScaleDown()
+	if err := c.forceRestore(ctx, ScaleEventCauseKillSwitch); err != nil && !errors.Is(err, ErrAlreadyRestored) {
+		log.G(ctx).Errorf("unable to restore container %s: %s", c.ID(), err)
+	}
+}
@@ -0,0 +1,62 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchKillSwitch(t *testing.T) {
+	defer func(file string) { killSwitchFile = file }(killSwitchFile)
+	killSwitchFile = filepath.Join(t.TempDir(), "scaling-disabled")
+	t.Cleanup(func() { nodeScalingDisabled.Store(false) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan bool, 2)
+	go WatchKillSwitch(ctx, func(disabled bool) { changes <- disabled })
+
+	require.NoError(t, os.WriteFile(killSwitchFile, nil, 0o644))
+	select {
+	case disabled := <-changes:
+		assert.True(t, disabled)
+		assert.True(t, nodeScalingDisabled.Load())
+	case <-time.After(killSwitchInterval * 3):
+		t.Fatal("kill switch should have been set")
+	}
+
+	require.NoError(t, os.Remove(killSwitchFile))
+	select {
+	case disabled := <-changes:
+		assert.False(t, disabled)
+		assert.False(t, nodeScalingDisabled.Load())
+	case <-time.After(killSwitchInterval * 3):
+		t.Fatal("kill switch should have been cleared")
+	}
+}
+
+func TestSetNodeScalingDisabled(t *testing.T) {
+	t.Cleanup(func() { nodeScalingDisabled.Store(false) })
+	ctx := context.Background()
+	c, _ := newFakeContainer(t, &Config{ContainerName: "kill-switch", ScaleDownDuration: time.Minute, Ports: []uint16{80}})
+	t.Cleanup(c.deleteMetrics)
+	require.NoError(t, c.ForceScaleDown(ctx))
+
+	nodeScalingDisabled.Store(true)
+	c.SetNodeScalingDisabled(ctx, true)
+	assert.False(t, c.ScaledDown(), "container should be restored")
+	assert.Nil(t, c.scaleDownTimer, "scale down should not be scheduled while the kill switch is set")
+	assert.Equal(t, float64(1), testutil.ToFloat64(scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonKillSwitch))))
+
+	nodeScalingDisabled.Store(false)
+	c.SetNodeScalingDisabled(ctx, false)
+	assert.NotNil(t, c.scaleDownTimer, "scale down should be scheduled once the kill switch is cleared")
+	c.CancelScaleDown()
+	assert.Equal(t, float64(0), testutil.ToFloat64(scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonKillSwitch))))
+}
@@ -0,0 +1,28 @@
+package zeropod
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TimedMutex is a mutex that reports the time spent waiting to acquire it.
+// It's used for the checkpoint/restore lock that is shared by all containers
+// of a shim, to tell when the serialization adds to the restore latency.
+type TimedMutex struct {
+	sync.Mutex
+	wait prometheus.Observer
+}
+
+// NewTimedMutex returns a mutex that observes the time spent waiting in Lock
+// in seconds with wait.
+func NewTimedMutex(wait prometheus.Observer) *TimedMutex {
+	return &TimedMutex{wait: wait}
+}
+
+func (m *TimedMutex) Lock() {
+	start := time.Now()
+	m.Mutex.Lock()
+	m.wait.Observe(time.Since(start).Seconds())
+}
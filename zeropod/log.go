@@ -5,18 +5,57 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/log"
+)
+
+const (
+	defaultPodLogDir = "/var/log/pods"
+	logFileSuffix    = ".log"
+	logFileFlags     = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	logFileMode      = 0777
+	// logRotationCheckInterval is the minimum interval in which the log file
+	// is checked for rotation.
+	logRotationCheckInterval = time.Second
 )
 
-// getLogPath gets the log path of the container by searching for the last log
-// file in the CRI pod log path.
+// getLogPath gets the log path of the container. If the log path has been
+// set explicitly using the LogPathAnnotationKey, it is used as is. Otherwise
+// the log path is resolved by searching for the log file with the highest
+// restart count in the CRI pod log path.
 // TODO: it would be nicer to get this path via annotations but it looks like
 // containerd only passes that to the sandbox container (pause). One possible
 // solution would be to implement log restoring in the sandbox container
 // instead of the zeropod.
 func getLogPath(ctx context.Context, cfg *Config) (string, error) {
-	logDir := fmt.Sprintf("/var/log/pods/%s_%s_%s/%s", cfg.PodNamespace, cfg.PodName, cfg.PodUID, cfg.ContainerName)
+	if cfg.LogPath != "" {
+		log.G(ctx).Infof("using log path from annotation: %s", cfg.LogPath)
+		return cfg.LogPath, nil
+	}
 
+	return latestLogFile(podLogDir(cfg))
+}
+
+// podLogDir returns the directory the kubelet writes the logs of the
+// container to. It's named after the pod on the host, which differs from the
+// pod as seen by the user for pods synced by vcluster.
+func podLogDir(cfg *Config) string {
+	return filepath.Join(
+		defaultPodLogDir,
+		fmt.Sprintf("%s_%s_%s", cfg.PodNamespace, cfg.PodName, cfg.PodUID),
+		cfg.ContainerName,
+	)
+}
+
+// latestLogFile returns the log file in logDir which belongs to the most
+// recent container instance. The kubelet names the log files after the
+// restart count of the container (e.g. 0.log, 1.log, ..., 10.log), so they
+// need to be compared numerically. Rotated log files (e.g.
+// 0.log.20240101-000000) are ignored.
+func latestLogFile(logDir string) (string, error) {
 	dir, err := os.Open(logDir)
 	if err != nil {
 		return "", err
@@ -27,9 +66,89 @@ func getLogPath(ctx context.Context, cfg *Config) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	sort.Slice(names, func(i, j int) bool {
-		return i < j
-	})
 
-	return filepath.Join(logDir, names[len(names)-1]), nil
+	latest, latestRestarts := "", -1
+	for _, name := range names {
+		restarts, err := strconv.Atoi(strings.TrimSuffix(name, logFileSuffix))
+		if err != nil || !strings.HasSuffix(name, logFileSuffix) {
+			continue
+		}
+		if restarts > latestRestarts {
+			latest, latestRestarts = name, restarts
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no container log file found in %s", logDir)
+	}
+
+	return filepath.Join(logDir, latest), nil
+}
+
+// rotatingLogFile is a log file that is reopened once it has been rotated.
+// The kubelet rotates container logs by renaming the log file and then
+// instructing the runtime to reopen it. As the logs of restored containers
+// are not managed by containerd anymore, we need to detect this ourselves,
+// otherwise the logs would end up in the rotated (and eventually deleted)
+// file. Writes and Close are not safe for concurrent use.
+type rotatingLogFile struct {
+	path          string
+	file          *os.File
+	checkInterval time.Duration
+	lastCheck     time.Time
+}
+
+func openRotatingLogFile(path string) (*rotatingLogFile, error) {
+	f, err := os.OpenFile(path, logFileFlags, logFileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rotatingLogFile{
+		path:          path,
+		file:          f,
+		checkInterval: logRotationCheckInterval,
+		lastCheck:     time.Now(),
+	}, nil
+}
+
+func (f *rotatingLogFile) Write(p []byte) (int, error) {
+	if time.Since(f.lastCheck) >= f.checkInterval {
+		f.lastCheck = time.Now()
+		if err := f.reopenIfRotated(); err != nil {
+			log.L.Errorf("unable to reopen rotated log file %q: %s", f.path, err)
+		}
+	}
+
+	return f.file.Write(p)
+}
+
+// reopenIfRotated reopens the log file if the file at path is not the same as
+// the currently open file anymore.
+func (f *rotatingLogFile) reopenIfRotated() error {
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(f.path)
+	if err == nil && os.SameFile(current, info) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, logFileFlags, logFileMode)
+	if err != nil {
+		return err
+	}
+
+	old := f.file
+	f.file = file
+	return old.Close()
+}
+
+func (f *rotatingLogFile) Close() error {
+	return f.file.Close()
 }
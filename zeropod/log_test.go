@@ -0,0 +1,84 @@
+package zeropod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestLogFile(t *testing.T) {
+	tests := map[string]struct {
+		files    []string
+		expected string
+		wantErr  bool
+	}{
+		"single log file": {
+			files:    []string{"0.log"},
+			expected: "0.log",
+		},
+		"restarted container": {
+			files:    []string{"0.log", "1.log", "2.log"},
+			expected: "2.log",
+		},
+		"restart count is compared numerically": {
+			files:    []string{"9.log", "10.log", "2.log"},
+			expected: "10.log",
+		},
+		"rotated log files are ignored": {
+			files:    []string{"1.log", "1.log.20240101-000000", "1.log.20240101-000000.gz"},
+			expected: "1.log",
+		},
+		"no log file": {
+			files:   []string{"foo"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, f), nil, 0o644))
+			}
+
+			logPath, err := latestLogFile(dir)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(dir, tc.expected), logPath)
+		})
+	}
+}
+
+func TestRotatingLogFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "0.log")
+	rotatedPath := filepath.Join(dir, "0.log.20240101-000000")
+
+	f, err := openRotatingLogFile(logPath)
+	require.NoError(t, err)
+	f.checkInterval = 0
+	t.Cleanup(func() { f.Close() })
+
+	_, err = f.Write([]byte("before rotation\n"))
+	require.NoError(t, err)
+
+	// rotate the same way as the kubelet does
+	require.NoError(t, os.Rename(logPath, rotatedPath))
+
+	_, err = f.Write([]byte("after rotation\n"))
+	require.NoError(t, err)
+
+	rotated, err := os.ReadFile(rotatedPath)
+	require.NoError(t, err)
+	assert.Equal(t, "before rotation\n", string(rotated))
+
+	current, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, "after rotation\n", string(current))
+}
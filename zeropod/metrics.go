@@ -1,20 +1,60 @@
 package zeropod
 
 import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
-	labelContainerName = "container"
-	LabelPodName       = "pod"
-	LabelPodNamespace  = "namespace"
-
-	MetricsNamespace         = "zeropod"
-	MetricCheckPointDuration = "checkpoint_duration_seconds"
-	MetricRestoreDuration    = "restore_duration_seconds"
-	MetricLastCheckpointTime = "last_checkpoint_time"
-	MetricLastRestoreTime    = "last_restore_time"
-	MetricRunning            = "running"
+	labelContainerName  = "container"
+	LabelPodName        = "pod"
+	LabelPodNamespace   = "namespace"
+	labelPodUID         = "pod_uid"
+	labelContainerID    = "container_id"
+	labelShim           = "shim"
+	labelQueue          = "queue"
+	labelCheckpointMode = "checkpoint_mode"
+	labelReason         = "reason"
+	labelPort           = "port"
+
+	scalingDisabledReasonPrivileged = "privileged"
+	scalingDisabledReasonDiskWrites = "disk_writes"
+	scalingDisabledReasonMinMemory  = "min_memory"
+	scalingDisabledReasonKillSwitch = "kill_switch"
+
+	// EnvMetricsExtraLabels can be set on the shim to a comma-delimited list
+	// of additional labels that should be added to all metrics. As these
+	// labels can increase the cardinality of the metrics quite a bit, they
+	// are disabled by default. Supported labels are "pod_uid" and
+	// "container_id".
+	EnvMetricsExtraLabels = "ZEROPOD_METRICS_EXTRA_LABELS"
+
+	MetricsNamespace                = "zeropod"
+	MetricCheckPointDuration        = "checkpoint_duration_seconds"
+	MetricRestoreDuration           = "restore_duration_seconds"
+	MetricLastCheckpointTime        = "last_checkpoint_time"
+	MetricLastRestoreTime           = "last_restore_time"
+	MetricRunning                   = "running"
+	MetricEventsQueueLength         = "events_queue_length"
+	MetricRestoreColdStarts         = "restore_cold_starts_total"
+	MetricScalingDisabled           = "scaling_disabled"
+	MetricActivatorListening        = "activator_listening"
+	MetricActivatorConnections      = "activator_connections_total"
+	MetricCheckpointRestoreLockWait = "checkpoint_restore_lock_wait_seconds"
+	MetricPrefetchDuration          = "checkpoint_prefetch_duration_seconds"
+	MetricPrefetchBytes             = "checkpoint_prefetch_bytes"
+	MetricPortListening             = "port_listening"
+	MetricCheckpointsQueued         = "checkpoints_queued"
+	MetricRestoresCompleted         = "restores_completed_total"
+	MetricRestoresHealthy           = "restores_healthy_total"
+	MetricExitProcessingLag         = "exit_processing_lag_seconds"
+	MetricCheckpointDiskFull        = "checkpoint_disk_full_total"
+	MetricRestoreBudgetWait         = "restore_budget_wait_seconds"
+	MetricRestoreBudgetUtilization  = "restore_budget_utilization"
 )
 
 var (
@@ -25,7 +65,9 @@ var (
 		0.2, 0.3, 0.4, 0.5, 1,
 	}
 
-	commonLabels = []string{labelContainerName, LabelPodName, LabelPodNamespace}
+	optionalLabels = []string{labelPodUID, labelContainerID}
+	extraLabels    = parseExtraLabels(os.Getenv(EnvMetricsExtraLabels))
+	commonLabels   = append([]string{labelContainerName, LabelPodName, LabelPodNamespace, labelCheckpointMode}, extraLabels...)
 
 	checkpointDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: MetricsNamespace,
@@ -41,6 +83,19 @@ var (
 		Buckets:   crBuckets,
 	}, commonLabels)
 
+	checkpointPrefetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricPrefetchDuration,
+		Help:      "The duration of the last prefetch of the checkpoint images into the page cache in seconds.",
+		Buckets:   crBuckets,
+	}, commonLabels)
+
+	checkpointPrefetchBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricPrefetchBytes,
+		Help:      "The size of the checkpoint images read by the last prefetch in bytes.",
+	}, commonLabels)
+
 	lastCheckpointTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: MetricsNamespace,
 		Name:      MetricLastCheckpointTime,
@@ -58,6 +113,61 @@ var (
 		Name:      MetricRunning,
 		Help:      "Reports if the process is currently running or checkpointed.",
 	}, commonLabels)
+
+	restoreColdStarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricRestoreColdStarts,
+		Help:      "The amount of times the container has been started without its checkpoint as the restore failed.",
+	}, commonLabels)
+
+	restoresCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricRestoresCompleted,
+		Help:      "The amount of restores of the container that have completed.",
+	}, commonLabels)
+
+	restoresHealthy = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricRestoresHealthy,
+		Help:      "The amount of restores after which the restore health check of the container succeeded.",
+	}, commonLabels)
+
+	checkpointDiskFull = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricCheckpointDiskFull,
+		Help:      "The amount of checkpoints of the container that failed as the disk was full.",
+	}, commonLabels)
+
+	restoreBudgetWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricRestoreBudgetWait,
+		Help:      "The time restores of the container waited for the restore cpu budget in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 17),
+	}, commonLabels)
+
+	scalingDisabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricScalingDisabled,
+		Help:      "Reports if scaling of the container has been disabled by zeropod, with the reason as a label.",
+	}, append([]string{labelReason}, commonLabels...))
+
+	activatorListening = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricActivatorListening,
+		Help:      "Reports if the activator is listening for connections to the port of the container.",
+	}, append([]string{labelPort}, commonLabels...))
+
+	activatorConnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricActivatorConnections,
+		Help:      "The amount of incoming connections to the port of the container that have been handled by the activator.",
+	}, append([]string{labelPort}, commonLabels...))
+
+	portListening = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: MetricsNamespace,
+		Name:      MetricPortListening,
+		Help:      "Reports if the process of the container is listening on the port before it's scaled down.",
+	}, append([]string{labelPort}, commonLabels...))
 )
 
 func NewRegistry() *prometheus.Registry {
@@ -65,18 +175,112 @@ func NewRegistry() *prometheus.Registry {
 
 	reg.MustRegister(
 		checkpointDuration, restoreDuration,
+		checkpointPrefetchDuration, checkpointPrefetchBytes,
 		lastCheckpointTime, lastRestoreTime, running,
+		restoreColdStarts, restoresCompleted, restoresHealthy, checkpointDiskFull,
+		restoreBudgetWait,
+		scalingDisabled, activatorListening, activatorConnections, portListening,
 	)
 
 	return reg
 }
 
+// parseExtraLabels parses a comma-delimited list of optional labels. Unknown
+// and duplicate labels are ignored.
+func parseExtraLabels(s string) []string {
+	labels := []string{}
+	for _, label := range strings.Split(s, ",") {
+		label = strings.TrimSpace(label)
+		if !slices.Contains(optionalLabels, label) || slices.Contains(labels, label) {
+			continue
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// NewEventsQueueLength returns a gauge that reports the amount of events
+// currently waiting in the supplied queue of a shim. A queue that is
+// constantly close to its capacity indicates that event forwarding is not
+// keeping up.
+func NewEventsQueueLength(shim, queue string, length func() int) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        MetricEventsQueueLength,
+		Help:        "The amount of events currently waiting in the queue of the shim.",
+		ConstLabels: prometheus.Labels{labelShim: shim, labelQueue: queue},
+	}, func() float64 { return float64(length()) })
+}
+
+// NewCheckpointRestoreLockWait returns a histogram of the time spent waiting
+// for the checkpoint/restore lock of a shim. As the lock is shared by all
+// containers of the shim, long waits indicate that concurrent checkpoints
+// and restores are delaying each other.
+func NewCheckpointRestoreLockWait(shim string) prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Name:        MetricCheckpointRestoreLockWait,
+		Help:        "The time spent waiting for the checkpoint/restore lock of the shim in seconds.",
+		Buckets:     prometheus.ExponentialBuckets(0.001, 2, 15),
+		ConstLabels: prometheus.Labels{labelShim: shim},
+	})
+}
+
+// NewCheckpointsQueued returns a gauge that reports the amount of scale downs
+// of a shim that are waiting for a slot of the concurrent checkpoints limit.
+func NewCheckpointsQueued(shim string) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        MetricCheckpointsQueued,
+		Help:        "The amount of checkpoints of the shim waiting for the concurrent checkpoints limit.",
+		ConstLabels: prometheus.Labels{labelShim: shim},
+	}, func() float64 { return float64(queuedCheckpoints()) })
+}
+
+// NewRestoreBudgetUtilization returns a gauge that reports the utilization
+// of the node-wide restore cpu budget as seen by the last restore of a shim.
+// A value of 1 means the budget is exhausted and restores are queued.
+func NewRestoreBudgetUtilization(shim string) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Name:        MetricRestoreBudgetUtilization,
+		Help:        "The utilization of the restore cpu budget of the node, 1 or more means it's exhausted.",
+		ConstLabels: prometheus.Labels{labelShim: shim},
+	}, restoreBudgetUtilization)
+}
+
+// NewExitProcessingLag returns a histogram of the time between a process of
+// a shim exiting and its exit being processed. Growing values indicate that
+// the shim can't keep up with the exits, e.g. because of many short-lived
+// execs.
+func NewExitProcessingLag(shim string) prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Name:        MetricExitProcessingLag,
+		Help:        "The time between the exit of a process of the shim and the processing of the exit in seconds.",
+		Buckets:     prometheus.ExponentialBuckets(0.0001, 2, 15),
+		ConstLabels: prometheus.Labels{labelShim: shim},
+	})
+}
+
 func (c *Container) labels() map[string]string {
-	return map[string]string{
-		labelContainerName: c.cfg.ContainerName,
-		LabelPodName:       c.cfg.PodName,
-		LabelPodNamespace:  c.cfg.PodNamespace,
+	labels := map[string]string{
+		labelContainerName:  c.cfg.ContainerName,
+		LabelPodName:        c.cfg.VirtualPodName(),
+		LabelPodNamespace:   c.cfg.VirtualPodNamespace(),
+		labelCheckpointMode: strings.ToLower(c.CheckpointMode().String()),
 	}
+
+	for _, label := range extraLabels {
+		switch label {
+		case labelPodUID:
+			labels[label] = c.cfg.PodUID
+		case labelContainerID:
+			labels[label] = c.ID()
+		}
+	}
+
+	return labels
 }
 
 func (c *Container) deleteMetrics() {
@@ -85,4 +289,27 @@ func (c *Container) deleteMetrics() {
 	lastCheckpointTime.Delete(c.labels())
 	lastRestoreTime.Delete(c.labels())
 	running.Delete(c.labels())
+	checkpointPrefetchDuration.Delete(c.labels())
+	checkpointPrefetchBytes.Delete(c.labels())
+	restoreColdStarts.Delete(c.labels())
+	restoresCompleted.Delete(c.labels())
+	restoresHealthy.Delete(c.labels())
+	checkpointDiskFull.Delete(c.labels())
+	restoreBudgetWait.Delete(c.labels())
+	scalingDisabled.DeletePartialMatch(c.labels())
+	activatorListening.DeletePartialMatch(c.labels())
+	activatorConnections.DeletePartialMatch(c.labels())
+	portListening.DeletePartialMatch(c.labels())
+}
+
+func (c *Container) scalingDisabledLabels(reason string) map[string]string {
+	labels := c.labels()
+	labels[labelReason] = reason
+	return labels
+}
+
+func (c *Container) portLabels(port uint16) map[string]string {
+	labels := c.labels()
+	labels[labelPort] = strconv.Itoa(int(port))
+	return labels
 }
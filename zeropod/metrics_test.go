@@ -0,0 +1,176 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtraLabels(t *testing.T) {
+	tests := map[string]struct {
+		env      string
+		expected []string
+	}{
+		"empty": {
+			env:      "",
+			expected: []string{},
+		},
+		"single label": {
+			env:      "pod_uid",
+			expected: []string{labelPodUID},
+		},
+		"multiple labels": {
+			env:      "container_id, pod_uid",
+			expected: []string{labelContainerID, labelPodUID},
+		},
+		"unknown and duplicate labels": {
+			env:      "pod_uid,foo,pod_uid,namespace",
+			expected: []string{labelPodUID},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseExtraLabels(tc.env))
+		})
+	}
+}
+
+func TestEventsQueueLength(t *testing.T) {
+	events := make(chan struct{}, 4)
+	events <- struct{}{}
+	events <- struct{}{}
+
+	gauge := NewEventsQueueLength("shim", "task", func() int { return len(events) })
+	assert.Equal(t, float64(2), testutil.ToFloat64(gauge))
+
+	<-events
+	assert.Equal(t, float64(1), testutil.ToFloat64(gauge))
+}
+
+func TestCheckpointRestoreLockWait(t *testing.T) {
+	wait := NewCheckpointRestoreLockWait("shim")
+	mu := NewTimedMutex(wait)
+
+	mu.Lock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mu.Unlock()
+	}()
+	mu.Lock()
+	mu.Unlock()
+
+	m := &dto.Metric{}
+	require.NoError(t, wait.Write(m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	assert.GreaterOrEqual(t, m.GetHistogram().GetSampleSum(), 0.05, "second lock should have waited for the first one")
+}
+
+func TestActivatorListening(t *testing.T) {
+	c := &Container{
+		cfg:       &Config{ContainerName: "listening", Ports: []uint16{80, 8080}},
+		activator: &FakeActivator{},
+	}
+	t.Cleanup(c.deleteMetrics)
+
+	c.activatorStarted(nil)
+	for _, port := range c.cfg.Ports {
+		assert.Equal(t, float64(1), testutil.ToFloat64(activatorListening.With(c.portLabels(port))))
+	}
+
+	c.activatorStarted(errors.New("boom"))
+	for _, port := range c.cfg.Ports {
+		assert.Equal(t, float64(0), testutil.ToFloat64(activatorListening.With(c.portLabels(port))))
+	}
+
+	c.deleteMetrics()
+	for _, port := range c.cfg.Ports {
+		assert.False(t, activatorListening.Delete(c.portLabels(port)), "metric should have been deleted")
+	}
+}
+
+func TestPortListening(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	c := &Container{
+		cfg:     &Config{ContainerName: "port-listening", Ports: []uint16{port, 1}},
+		process: &fakeProcess{pid: os.Getpid()},
+	}
+	t.Cleanup(c.deleteMetrics)
+
+	c.checkPortsListening(context.Background())
+	assert.Equal(t, float64(1), testutil.ToFloat64(portListening.With(c.portLabels(port))))
+	assert.Equal(t, float64(0), testutil.ToFloat64(portListening.With(c.portLabels(1))))
+
+	c.deleteMetrics()
+	assert.False(t, portListening.Delete(c.portLabels(port)), "metric should have been deleted")
+}
+
+func TestRestoresHealthy(t *testing.T) {
+	restoreHealthCheckTimeout = restoreHealthCheckInterval * 2
+	t.Cleanup(func() { restoreHealthCheckTimeout = 30 * time.Second })
+
+	netNS, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		netNS:     netNS,
+		cfg: &Config{
+			ContainerName:      "restores-healthy",
+			RestoreHealthCheck: &ReadinessProbe{Type: ReadinessProbeTCP, Port: port},
+		},
+	}
+	t.Cleanup(c.deleteMetrics)
+
+	c.checkRestoreHealth(c.setRestoreResult(nil))
+	assert.Equal(t, float64(1), testutil.ToFloat64(restoresHealthy.With(c.labels())))
+
+	// a restored process that does not listen anymore is not healthy.
+	require.NoError(t, l.Close())
+	c.checkRestoreHealth(c.setRestoreResult(nil))
+	assert.Equal(t, float64(1), testutil.ToFloat64(restoresHealthy.With(c.labels())))
+	assert.Contains(t, c.Status().LastRestoreError, "did not become healthy")
+
+	// the check of a previous restore does not overwrite the result of the
+	// last one.
+	stale := c.setRestoreResult(nil)
+	c.setRestoreResult(nil)
+	c.checkRestoreHealth(stale)
+	assert.Empty(t, c.Status().LastRestoreError)
+}
+
+func TestActivatorConnections(t *testing.T) {
+	c := &Container{
+		cfg: &Config{ContainerName: "connections", Ports: []uint16{80, 8080}},
+	}
+	t.Cleanup(c.deleteMetrics)
+
+	c.countActivatorConnection(80)
+	c.countActivatorConnection(80)
+	assert.Equal(t, float64(2), testutil.ToFloat64(activatorConnections.With(c.portLabels(80))))
+	assert.Equal(t, float64(0), testutil.ToFloat64(activatorConnections.With(c.portLabels(8080))))
+
+	c.deleteMetrics()
+	for _, port := range c.cfg.Ports {
+		assert.False(t, activatorConnections.Delete(c.portLabels(port)), "metric should have been deleted")
+	}
+}
@@ -0,0 +1,279 @@
+package zeropod
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/containerd/log"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrNotCheckpointed = errors.New("container does not use checkpointing")
+	ErrNotScaledDown   = errors.New("container is not scaled down")
+
+	// checkpointArchiveDirs are the dirs and files of the snapshot dir that
+	// are part of a checkpoint archive. The work dir only contains logs and
+	// stats of the checkpoint and is not needed to restore.
+	checkpointArchiveDirs = []string{"container", preDumpDirName, checkpointMetadataFile, rootfsDiffFile}
+	// preDumpParentLink is the link CRIU creates in the checkpoint images to
+	// reference the pre-dump they are based on. It's the only link that is
+	// part of a checkpoint archive.
+	preDumpParentLink = filepath.Join("container", "parent")
+)
+
+// ExportCheckpoint writes the checkpoint of the scaled down container as a
+// gzipped tar archive to the file at archive. The archive can be imported
+// into a container of the same pod spec on a different node using
+// ImportCheckpoint.
+func (c *Container) ExportCheckpoint(ctx context.Context, archive string) error {
+	if err := c.checkArchivable(); err != nil {
+		return err
+	}
+
+	c.checkpointRestore.Lock()
+	defer c.checkpointRestore.Unlock()
+
+	log.G(ctx).Infof("exporting checkpoint of container %s to %s", c.ID(), archive)
+	f, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := writeCheckpointArchive(snapshotDir(c.Bundle), f); err != nil {
+		return fmt.Errorf("writing checkpoint archive: %w", err)
+	}
+
+	return f.Close()
+}
+
+// ImportCheckpoint replaces the checkpoint of the scaled down container with
+// the one in the gzipped tar archive at archive, which has been created by
+// ExportCheckpoint. The archive needs to be taken from the same image and
+// process spec as the container, otherwise the restore would discard it. The
+// imported process is restored on the next incoming connection like any
+// other checkpoint: the network namespace of the pod is external to the
+// checkpoint and runc passes the one of the container on this node to CRIU,
+// while the cgroups are restored below the cgroup of the container on this
+// node. Established connections are not restored, so the application has to
+// handle reconnects.
+func (c *Container) ImportCheckpoint(ctx context.Context, archive string) error {
+	if err := c.checkArchivable(); err != nil {
+		return err
+	}
+
+	c.checkpointRestore.Lock()
+	defer c.checkpointRestore.Unlock()
+
+	log.G(ctx).Infof("importing checkpoint of container %s from %s", c.ID(), archive)
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return importCheckpointArchive(f, snapshotDir(c.Bundle), c.cfg.spec)
+}
+
+// importCheckpointArchive extracts the archive into a temporary dir next to
+// the checkpoint dir and only replaces the checkpoint once the whole archive
+// has been extracted and its metadata matches spec, so an invalid archive
+// does not leave the container without a checkpoint to restore. The dirs
+// are exchanged atomically, the replaced checkpoint is removed afterwards.
+func importCheckpointArchive(r io.Reader, dir string, spec *specs.Spec) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+
+	if err := readCheckpointArchive(r, tmp); err != nil {
+		return fmt.Errorf("reading checkpoint archive: %w", err)
+	}
+
+	meta, err := readCheckpointMetadata(filepath.Join(tmp, checkpointMetadataFile))
+	if err != nil {
+		return fmt.Errorf("reading checkpoint metadata of archive: %w", err)
+	}
+	if err := meta.matches(spec); err != nil {
+		return fmt.Errorf("checkpoint archive does not match the container: %w", err)
+	}
+
+	if err := unix.Renameat2(unix.AT_FDCWD, tmp, unix.AT_FDCWD, dir, unix.RENAME_EXCHANGE); err != nil {
+		if !errors.Is(err, unix.ENOENT) {
+			return fmt.Errorf("replacing checkpoint: %w", err)
+		}
+		// there is no checkpoint to replace.
+		if err := os.Rename(tmp, dir); err != nil {
+			return fmt.Errorf("replacing checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkArchivable returns an error if the container does not have a
+// checkpoint that can be exported or replaced.
+func (c *Container) checkArchivable() error {
+	if c.CheckpointMode() != v1.CheckpointMode_CHECKPOINT {
+		return ErrNotCheckpointed
+	}
+	if !c.ScaledDown() {
+		return ErrNotScaledDown
+	}
+	return nil
+}
+
+func writeCheckpointArchive(dir string, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, d := range checkpointArchiveDirs {
+		if _, err := os.Stat(filepath.Join(dir, d)); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := filepath.WalkDir(filepath.Join(dir, d), func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return addToArchive(tw, dir, path, entry)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func addToArchive(tw *tar.Writer, dir, path string, entry fs.DirEntry) error {
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+
+	// the pre-dump parent is referenced by a relative symlink.
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	if hdr.Name, err = filepath.Rel(dir, path); err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func readCheckpointArchive(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, hdr.Name)
+		if !withinDir(dir, target) {
+			return fmt.Errorf("invalid path in archive: %s", hdr.Name)
+		}
+		if top, _, _ := strings.Cut(filepath.ToSlash(filepath.Clean(hdr.Name)), "/"); !slices.Contains(checkpointArchiveDirs, top) {
+			return fmt.Errorf("unexpected path in archive: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// as the archive is extracted as root, the following entries
+			// must not be written through a link to outside of dir. Checking
+			// the link target is not enough as links can be chained, so
+			// only the parent link of the pre-dump is accepted.
+			if filepath.Clean(hdr.Name) != preDumpParentLink || hdr.Linkname != relativePreDumpDir() {
+				return fmt.Errorf("invalid link in archive: %s -> %s", hdr.Name, hdr.Linkname)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported file type %c in archive: %s", hdr.Typeflag, hdr.Name)
+		}
+	}
+}
+
+// withinDir returns true if path is below dir.
+func withinDir(dir, path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), filepath.Clean(dir)+string(os.PathSeparator))
+}
+
+func extractFile(r io.Reader, path string, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,218 @@
+package zeropod
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/pkg/cri/annotations"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointArchive(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "container"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, preDumpDirName), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "work"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "container", "inventory.img"), []byte("inventory"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, preDumpDirName, "pages-1.img"), []byte("pages"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "work", dumpLogFile), []byte("log"), 0o644))
+	require.NoError(t, writeCheckpointMetadata(filepath.Join(src, checkpointMetadataFile), checkpointMetadata{Image: "nginx"}))
+	require.NoError(t, os.Symlink(relativePreDumpDir(), filepath.Join(src, "container", "parent")))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeCheckpointArchive(src, buf))
+
+	dst := t.TempDir()
+	require.NoError(t, readCheckpointArchive(buf, dst))
+
+	b, err := os.ReadFile(filepath.Join(dst, "container", "inventory.img"))
+	require.NoError(t, err)
+	assert.Equal(t, "inventory", string(b))
+
+	b, err = os.ReadFile(filepath.Join(dst, "container", "parent", "pages-1.img"))
+	require.NoError(t, err)
+	assert.Equal(t, "pages", string(b), "pre-dump should be reachable through the parent link")
+
+	meta, err := readCheckpointMetadata(filepath.Join(dst, checkpointMetadataFile))
+	require.NoError(t, err)
+	assert.Equal(t, "nginx", meta.Image)
+
+	assert.NoFileExists(t, filepath.Join(dst, "work", dumpLogFile), "work dir should not be archived")
+}
+
+func TestReadCheckpointArchiveInvalidPath(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../escape", Typeflag: tar.TypeReg, Mode: 0o644}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	assert.Error(t, readCheckpointArchive(buf, t.TempDir()))
+}
+
+func TestReadCheckpointArchiveInvalidLink(t *testing.T) {
+	for name, link := range map[string]string{
+		"absolute": "/etc",
+		"escaping": "../../etc",
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			gw := gzip.NewWriter(buf)
+			tw := tar.NewWriter(gw)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "container", Typeflag: tar.TypeSymlink, Linkname: link}))
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "container/passwd", Typeflag: tar.TypeReg, Mode: 0o644}))
+			require.NoError(t, tw.Close())
+			require.NoError(t, gw.Close())
+
+			dir := t.TempDir()
+			assert.Error(t, readCheckpointArchive(buf, dir))
+			assert.NoFileExists(t, filepath.Join(dir, "container"))
+		})
+	}
+}
+
+func writeTestCheckpoint(t *testing.T, dir, inventory string, spec *specs.Spec) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "container"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "container", "inventory.img"), []byte(inventory), 0o644))
+	if spec != nil {
+		meta, err := newCheckpointMetadata(spec)
+		require.NoError(t, err)
+		require.NoError(t, writeCheckpointMetadata(filepath.Join(dir, checkpointMetadataFile), meta))
+	}
+}
+
+func testCheckpointArchive(t *testing.T, inventory string, spec *specs.Spec) *bytes.Buffer {
+	t.Helper()
+	src := t.TempDir()
+	writeTestCheckpoint(t, src, inventory, spec)
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeCheckpointArchive(src, buf))
+	return buf
+}
+
+func TestImportCheckpointArchive(t *testing.T) {
+	spec := &specs.Spec{
+		Annotations: map[string]string{annotations.ImageName: "nginx:1.27"},
+		Process:     &specs.Process{Args: []string{"nginx"}},
+	}
+	otherSpec := &specs.Spec{
+		Annotations: map[string]string{annotations.ImageName: "nginx:1.28"},
+		Process:     &specs.Process{Args: []string{"nginx"}},
+	}
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	writeTestCheckpoint(t, dir, "existing", nil)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "work"), 0o755))
+
+	for name, r := range map[string]*bytes.Buffer{
+		"invalid archive":  bytes.NewBufferString("not an archive"),
+		"without metadata": testCheckpointArchive(t, "imported", nil),
+		"other image":      testCheckpointArchive(t, "imported", otherSpec),
+	} {
+		assert.Error(t, importCheckpointArchive(r, dir, spec), name)
+		b, err := os.ReadFile(filepath.Join(dir, "container", "inventory.img"))
+		require.NoError(t, err)
+		assert.Equal(t, "existing", string(b), "existing checkpoint should be kept: %s", name)
+	}
+
+	require.NoError(t, importCheckpointArchive(testCheckpointArchive(t, "imported", spec), dir, spec))
+	b, err := os.ReadFile(filepath.Join(dir, "container", "inventory.img"))
+	require.NoError(t, err)
+	assert.Equal(t, "imported", string(b))
+	assert.NoDirExists(t, filepath.Join(dir, "work"), "replaced checkpoint should be removed")
+
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary dir should be removed")
+
+	// without an existing checkpoint the imported one is moved into place.
+	dir = filepath.Join(t.TempDir(), "snapshots")
+	require.NoError(t, importCheckpointArchive(testCheckpointArchive(t, "imported", spec), dir, spec))
+	assert.FileExists(t, filepath.Join(dir, "container", "inventory.img"))
+
+	// every link target is within the extracted dir, but chained together
+	// they point outside of it.
+	root := t.TempDir()
+	dir = filepath.Join(root, "a", "b", "snapshot")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a", "a"), 0o755))
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "container/x/y", Typeflag: tar.TypeDir, Mode: 0o755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: preDumpDirName, Typeflag: tar.TypeDir, Mode: 0o755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "container/x/y/l", Typeflag: tar.TypeSymlink, Linkname: "../../../pre-dump"}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "container/x/y/l/esc", Typeflag: tar.TypeSymlink, Linkname: "../../../../a"}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "container/x/y/l/esc/OUT", Typeflag: tar.TypeReg, Mode: 0o644}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	assert.Error(t, importCheckpointArchive(buf, dir, nil))
+	require.NoError(t, filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+		assert.NotEqual(t, "OUT", filepath.Base(path), "file should not be written through chained links")
+		return err
+	}))
+}
+
+func TestImportCheckpointRestore(t *testing.T) {
+	spec := &specs.Spec{
+		Annotations: map[string]string{annotations.ImageName: "nginx:1.27"},
+		Process:     &specs.Process{Args: []string{"nginx"}},
+	}
+	for name, tc := range map[string]struct {
+		archiveSpec       *specs.Spec
+		expectedInventory string
+	}{
+		"same spec": {
+			archiveSpec:       spec,
+			expectedInventory: "imported",
+		},
+		"other image": {
+			archiveSpec: &specs.Spec{
+				Annotations: map[string]string{annotations.ImageName: "nginx:1.28"},
+				Process:     &specs.Process{Args: []string{"nginx"}},
+			},
+			expectedInventory: "existing",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			bundle := t.TempDir()
+			cp := &FakeCheckpointer{Container: &runc.Container{ID: "foo", Bundle: bundle}, Process: &fakeProcess{pid: 5678}}
+			c := NewFakeContainer(ctx, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, spec: spec}, &sync.Mutex{}, &fakeProcess{pid: 1234}, cp)
+			t.Cleanup(c.CancelScaleDown)
+
+			require.NoError(t, c.ForceScaleDown(ctx))
+			writeTestCheckpoint(t, snapshotDir(bundle), "existing", spec)
+
+			archive := filepath.Join(t.TempDir(), "checkpoint.tar.gz")
+			require.NoError(t, os.WriteFile(archive, testCheckpointArchive(t, "imported", tc.archiveSpec).Bytes(), 0o644))
+			err := c.ImportCheckpoint(ctx, archive)
+			if tc.expectedInventory == "imported" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			require.NoError(t, c.ForceRestore(ctx))
+			assert.False(t, c.ScaledDown())
+			assert.Equal(t, 5678, c.Process().Pid())
+			assert.Zero(t, cp.ColdStarts(), "container should be restored from the checkpoint")
+
+			b, err := os.ReadFile(filepath.Join(snapshotDir(bundle), "container", "inventory.img"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedInventory, string(b))
+		})
+	}
+}
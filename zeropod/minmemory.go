@@ -0,0 +1,50 @@
+package zeropod
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/containerd/log"
+)
+
+// memoryUsage returns the current memory usage of the cgroup of the supplied
+// pid in bytes.
+func memoryUsage(pid int) (uint64, error) {
+	cgroupPath, err := cgroupV2Path(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return readCgroupUint(filepath.Join(cgroupPath, memoryCurrentFile))
+}
+
+// smallMemory returns true if the container uses less memory than the
+// configured minimum. Scaling such containers down would not free up a
+// meaningful amount of memory, so they are kept running and the decision is
+// reported by the scaling disabled metric.
+func (c *Container) smallMemory(ctx context.Context) bool {
+	if c.cfg.MinMemory <= 0 {
+		return false
+	}
+
+	if c.memoryUsage == nil {
+		c.memoryUsage = func() (uint64, error) {
+			return memoryUsage(c.process.Pid())
+		}
+	}
+
+	usage, err := c.memoryUsage()
+	if err != nil {
+		log.G(ctx).Errorf("unable to get memory usage: %s", err)
+		return false
+	}
+
+	small := usage < uint64(c.cfg.MinMemory)
+	if small {
+		log.G(ctx).Infof("container uses %d bytes of memory, below the minimum of %d bytes", usage, c.cfg.MinMemory)
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonMinMemory)).Set(1)
+	} else {
+		scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonMinMemory)).Set(0)
+	}
+	return small
+}
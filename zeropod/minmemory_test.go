@@ -0,0 +1,27 @@
+package zeropod
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmallMemory(t *testing.T) {
+	usage := uint64(1 << 20)
+	c := &Container{cfg: &Config{ContainerName: "min-memory", MinMemory: 10 << 20}}
+	t.Cleanup(c.deleteMetrics)
+	c.memoryUsage = func() (uint64, error) { return usage, nil }
+
+	assert.True(t, c.smallMemory(context.Background()))
+	assert.Equal(t, 1.0, testutil.ToFloat64(scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonMinMemory))))
+
+	usage = 100 << 20
+	assert.False(t, c.smallMemory(context.Background()))
+	assert.Equal(t, 0.0, testutil.ToFloat64(scalingDisabled.With(c.scalingDisabledLabels(scalingDisabledReasonMinMemory))))
+
+	c.cfg.MinMemory = 0
+	usage = 0
+	assert.False(t, c.smallMemory(context.Background()))
+}
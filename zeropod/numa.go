@@ -0,0 +1,126 @@
+package zeropod
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const (
+	numaMapsFile        = "numa_maps"
+	nodeCPUListFile     = "cpulist"
+	numaMapsPageSizeKey = "kernelpagesize_kB"
+)
+
+var sysNodePath = "/sys/devices/system/node"
+
+// saveNUMAPlacement records the NUMA node that holds most of the memory of
+// the process in the saved cpuset, so the restored container is pinned to
+// the same node. A cpuset that already pins the memory (e.g. by the
+// topology manager of the kubelet) is left as is.
+func (c *Container) saveNUMAPlacement(ctx context.Context) {
+	if !c.cfg.NUMAAffinity || c.cpuset.mems != "" {
+		return
+	}
+
+	placement, err := numaPlacement(filepath.Join(procPath, strconv.Itoa(c.process.Pid()), numaMapsFile), sysNodePath)
+	if err != nil {
+		log.G(ctx).Errorf("unable to get NUMA placement: %s", err)
+		return
+	}
+	if placement.empty() {
+		return
+	}
+
+	log.G(ctx).Infof("pinning container to NUMA node %s on restore", placement.mems)
+	c.cpuset.mems = placement.mems
+	if c.cpuset.cpus == "" {
+		c.cpuset.cpus = placement.cpus
+	}
+}
+
+// numaPlacement returns the cpuset of the NUMA node that holds most of the
+// memory in the numa_maps file. It's empty if the node only has a single
+// NUMA node, as there is no placement to preserve.
+func numaPlacement(numaMaps, nodePath string) (cpuset, error) {
+	nodes, err := filepath.Glob(filepath.Join(nodePath, "node[0-9]*"))
+	if err != nil {
+		return cpuset{}, err
+	}
+	if len(nodes) < 2 {
+		return cpuset{}, nil
+	}
+
+	node, err := dominantNUMANode(numaMaps)
+	if err != nil || node < 0 {
+		return cpuset{}, err
+	}
+
+	cpus, err := readCgroupString(filepath.Join(nodePath, fmt.Sprintf("node%d", node), nodeCPUListFile))
+	if err != nil {
+		return cpuset{}, err
+	}
+
+	return cpuset{cpus: cpus, mems: strconv.Itoa(node)}, nil
+}
+
+// dominantNUMANode returns the NUMA node with the most memory in the
+// numa_maps file or -1 if it does not contain any memory.
+func dominantNUMANode(numaMaps string) (int, error) {
+	f, err := os.Open(numaMaps)
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+
+	kb := map[int]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		pageSize := uint64(4)
+		pages := map[int]uint64{}
+		for _, field := range fields {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			if key == numaMapsPageSizeKey {
+				if size, err := strconv.ParseUint(value, 10, 64); err == nil {
+					pageSize = size
+				}
+				continue
+			}
+
+			nodeID, isNode := strings.CutPrefix(key, "N")
+			node, err := strconv.Atoi(nodeID)
+			if !isNode || err != nil {
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			pages[node] += n
+		}
+		for node, n := range pages {
+			kb[node] += n * pageSize
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return -1, err
+	}
+
+	dominant := -1
+	for node, size := range kb {
+		if dominant == -1 || size > kb[dominant] || (size == kb[dominant] && node < dominant) {
+			dominant = node
+		}
+	}
+	return dominant, nil
+}
@@ -0,0 +1,53 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNUMAPlacement(t *testing.T) {
+	// simulate a node with two NUMA nodes.
+	nodePath := t.TempDir()
+	for node, cpus := range map[string]string{"node0": "0-3\n", "node1": "4-7\n"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(nodePath, node), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(nodePath, node, nodeCPUListFile), []byte(cpus), 0o644))
+	}
+
+	numaMaps := filepath.Join(t.TempDir(), numaMapsFile)
+	require.NoError(t, os.WriteFile(numaMaps, []byte(
+		"00400000 default file=/app mapped=100 N0=100 kernelpagesize_kB=4\n"+
+			"7f0000000000 default anon=300 dirty=300 N0=50 N1=250 kernelpagesize_kB=4\n"+
+			"7f1000000000 default stack anon=3 dirty=3 N0=3 kernelpagesize_kB=4\n"), 0o644))
+
+	placement, err := numaPlacement(numaMaps, nodePath)
+	require.NoError(t, err)
+	assert.Equal(t, cpuset{cpus: "4-7", mems: "1"}, placement)
+
+	// huge pages outweigh the small pages on the other node.
+	require.NoError(t, os.WriteFile(numaMaps, []byte(
+		"7f0000000000 default anon=300 dirty=300 N1=300 kernelpagesize_kB=4\n"+
+			"7f2000000000 default huge anon=1 dirty=1 N0=1 kernelpagesize_kB=2048\n"), 0o644))
+	placement, err = numaPlacement(numaMaps, nodePath)
+	require.NoError(t, err)
+	assert.Equal(t, cpuset{cpus: "0-3", mems: "0"}, placement)
+
+	// nothing to preserve with a single NUMA node.
+	require.NoError(t, os.RemoveAll(filepath.Join(nodePath, "node1")))
+	placement, err = numaPlacement(numaMaps, nodePath)
+	require.NoError(t, err)
+	assert.True(t, placement.empty())
+}
+
+func TestSaveNUMAPlacementPinned(t *testing.T) {
+	c := &Container{
+		cfg:    &Config{NUMAAffinity: true},
+		cpuset: cpuset{cpus: "2-3", mems: "0"},
+	}
+	c.saveNUMAPlacement(context.Background())
+	assert.Equal(t, cpuset{cpus: "2-3", mems: "0"}, c.cpuset, "existing pinning should be kept")
+}
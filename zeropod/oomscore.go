@@ -0,0 +1,57 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const oomScoreAdjFile = "oom_score_adj"
+
+// saveOOMScoreAdj records the oom_score_adj of the running process so it can
+// be applied again after a restore.
+func (c *Container) saveOOMScoreAdj(ctx context.Context) {
+	adj, err := readOOMScoreAdj(c.process.Pid())
+	if err != nil {
+		log.G(ctx).Errorf("unable to read oom_score_adj: %s", err)
+		c.oomScoreAdj = nil
+		return
+	}
+	c.oomScoreAdj = &adj
+}
+
+// restoreOOMScoreAdj applies the oom_score_adj that has been saved before
+// the scale down to the restored process, or the one of the spec if there is
+// none. A process that is started from scratch instead of the checkpoint
+// only gets the one of the spec, which does not contain changes made while
+// the container was running. Applying it again makes sure the OOM killer
+// treats the process the same as before the scale down.
+func (c *Container) restoreOOMScoreAdj(ctx context.Context, pid int) {
+	adj := c.oomScoreAdj
+	if adj == nil && c.cfg.spec != nil && c.cfg.spec.Process != nil {
+		adj = c.cfg.spec.Process.OOMScoreAdj
+	}
+	if adj == nil {
+		return
+	}
+
+	if err := writeOOMScoreAdj(pid, *adj); err != nil {
+		log.G(ctx).Errorf("unable to restore oom_score_adj of process %d: %s", pid, err)
+	}
+}
+
+func readOOMScoreAdj(pid int) (int, error) {
+	b, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), oomScoreAdjFile))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func writeOOMScoreAdj(pid, adj int) error {
+	return os.WriteFile(filepath.Join(procPath, strconv.Itoa(pid), oomScoreAdjFile), []byte(strconv.Itoa(adj)), 0)
+}
@@ -0,0 +1,51 @@
+package zeropod
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOOMScoreAdj(t *testing.T) {
+	saved, spec := 500, 300
+	for name, tc := range map[string]struct {
+		saved    *int
+		spec     *int
+		expected int
+	}{
+		"saved": {
+			saved:    &saved,
+			spec:     &spec,
+			expected: 500,
+		},
+		"spec": {
+			spec:     &spec,
+			expected: 300,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// the restored process, raising the oom_score_adj does not need
+			// any privileges.
+			cmd := exec.Command("sleep", "10")
+			require.NoError(t, cmd.Start())
+			t.Cleanup(func() {
+				cmd.Process.Kill()
+				cmd.Wait()
+			})
+
+			c := &Container{
+				cfg:         &Config{spec: &specs.Spec{Process: &specs.Process{OOMScoreAdj: tc.spec}}},
+				oomScoreAdj: tc.saved,
+			}
+			c.restoreOOMScoreAdj(context.Background(), cmd.Process.Pid)
+
+			adj, err := readOOMScoreAdj(cmd.Process.Pid)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, adj)
+		})
+	}
+}
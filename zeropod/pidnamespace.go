@@ -0,0 +1,77 @@
+package zeropod
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/log"
+)
+
+const nsPIDField = "NSpid:"
+
+// savePIDNamespaceInit records if the running process is the init process
+// (PID 1) of its pid namespace. CRIU restores the process tree including the
+// parent/child relationships and the child subreaper flag, so a restored
+// init process keeps reaping the children and orphans of the container.
+func (c *Container) savePIDNamespaceInit(ctx context.Context) {
+	isInit, err := isPIDNamespaceInit(c.process.Pid())
+	if err != nil {
+		log.G(ctx).Errorf("unable to check if process is the init process of its pid namespace: %s", err)
+		isInit = false
+	}
+	c.pidNamespaceInit = isInit
+}
+
+// verifyPIDNamespaceInit checks that a restored process that has been the
+// init process of its pid namespace before the scale down still is. If it's
+// not (e.g. as it has been restored into a pid namespace that already had an
+// init process), orphaned processes of the container are not reparented to
+// it and their exits might never be reaped.
+func (c *Container) verifyPIDNamespaceInit(ctx context.Context, pid int) {
+	if !c.pidNamespaceInit {
+		return
+	}
+
+	isInit, err := isPIDNamespaceInit(pid)
+	if err != nil {
+		log.G(ctx).Errorf("unable to check if restored process is the init process of its pid namespace: %s", err)
+		return
+	}
+	if !isInit {
+		log.G(ctx).Errorf("restored process %d is not the init process of its pid namespace anymore, zombie processes of the container might not be reaped", pid)
+	}
+}
+
+// isPIDNamespaceInit returns true if the process is PID 1 in its innermost
+// pid namespace.
+func isPIDNamespaceInit(pid int) (bool, error) {
+	f, err := os.Open(filepath.Join(procPath, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), nsPIDField)
+		if !ok {
+			continue
+		}
+		// the pids are listed from the outermost to the innermost
+		// namespace.
+		pids := strings.Fields(value)
+		if len(pids) == 0 {
+			break
+		}
+		return pids[len(pids)-1] == "1", nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return false, fmt.Errorf("%s not found in status of process %d", nsPIDField, pid)
+}
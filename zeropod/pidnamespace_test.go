@@ -0,0 +1,44 @@
+package zeropod
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIDNamespaceInit(t *testing.T) {
+	// the init process of the new pid namespace spawns and reaps a child
+	// before it keeps running.
+	cmd := exec.Command("sh", "-c", "sleep 0.01 & wait; exec sleep 10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWPID}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start process in new pid namespace: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	isInit, err := isPIDNamespaceInit(cmd.Process.Pid)
+	require.NoError(t, err)
+	assert.True(t, isInit)
+
+	c := &Container{process: &fakeProcess{pid: cmd.Process.Pid}}
+	c.savePIDNamespaceInit(context.Background())
+	assert.True(t, c.pidNamespaceInit)
+
+	other := exec.Command("sleep", "10")
+	require.NoError(t, other.Start())
+	t.Cleanup(func() {
+		other.Process.Kill()
+		other.Wait()
+	})
+
+	isInit, err = isPIDNamespaceInit(other.Process.Pid)
+	require.NoError(t, err)
+	assert.False(t, isInit)
+}
@@ -1,22 +1,55 @@
 package zeropod
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/containerd/log"
+	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/prometheus/procfs"
 )
 
 const (
-	stateListen  = 10
-	procPath     = "/proc"
-	childrenFile = "children"
-	taskDir      = "task"
+	stateEstablished = 1
+	stateListen      = 10
+	procPath         = "/proc"
+	threadSelfPath   = "/proc/thread-self"
+	childrenFile     = "children"
+	taskDir          = "task"
 )
 
+// checkPortsListening reports if the process of the container is listening on
+// its ports. A port that is declared in the ports map but never bound by the
+// process can't be activated, as the connection is forwarded to a port
+// nobody is listening on.
+func (c *Container) checkPortsListening(ctx context.Context) {
+	listening, err := listeningPortsDeep(c.process.Pid())
+	if err != nil {
+		log.G(ctx).Warnf("unable to check listening ports of process: %s", err)
+		return
+	}
+
+	notListening := []uint16{}
+	for _, port := range c.cfg.Ports {
+		value := 1.0
+		if !slices.Contains(listening, port) {
+			value = 0
+			notListening = append(notListening, port)
+		}
+		portListening.With(c.portLabels(port)).Set(value)
+	}
+
+	if len(notListening) > 0 {
+		log.G(ctx).Warnf("process is not listening on ports %v of the container (listening on %v), check the %s annotation",
+			notListening, listening, AnnotationKey(PortsAnnotationKey))
+	}
+}
+
 // listeningPorts finds all ports of the pid that are in listen state of the
 // supplied process and all child processes. It finds both, ipv4 and ipv6
 // sockets.
@@ -84,6 +117,73 @@ func listeningPorts(pid int) ([]uint16, error) {
 	return ports, err
 }
 
+// listeningPortsInNS finds all ports that are in listen state within the
+// supplied network namespace, regardless of the process owning the socket.
+// It finds both, ipv4 and ipv6 sockets.
+func listeningPortsInNS(netNS ns.NetNS) (map[uint16]struct{}, error) {
+	ports := map[uint16]struct{}{}
+	err := netNS.Do(func(_ ns.NetNS) error {
+		// the net dir of the current thread reflects the network namespace
+		// we are currently in.
+		fs, err := procfs.NewFS(threadSelfPath)
+		if err != nil {
+			return err
+		}
+
+		tcp, err := fs.NetTCP()
+		if err != nil {
+			return err
+		}
+
+		tcp6, err := fs.NetTCP6()
+		if err != nil {
+			return err
+		}
+
+		for _, line := range append(tcp, tcp6...) {
+			if line.St == stateListen {
+				ports[uint16(line.LocalPort)] = struct{}{}
+			}
+		}
+		return nil
+	})
+
+	return ports, err
+}
+
+// establishedConnections returns the amount of established TCP connections
+// of the supplied process. It counts both, ipv4 and ipv6 sockets.
+func establishedConnections(pid int) (int, error) {
+	fs, err := procfs.NewFS(filepath.Join(procPath, strconv.Itoa(pid)))
+	if err != nil {
+		return 0, err
+	}
+
+	tcp, err := fs.NetTCP()
+	if err != nil {
+		return 0, err
+	}
+
+	tcp6, err := fs.NetTCP6()
+	if err != nil {
+		return 0, err
+	}
+
+	inos, err := inodes(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	connections := 0
+	for _, line := range append(tcp, tcp6...) {
+		if _, ok := inos[line.Inode]; ok && line.St == stateEstablished {
+			connections++
+		}
+	}
+
+	return connections, nil
+}
+
 func inodes(pid int) (map[uint64]struct{}, error) {
 	fs, err := procfs.NewFS(procPath)
 	if err != nil {
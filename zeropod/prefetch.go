@@ -0,0 +1,115 @@
+package zeropod
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/log"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+)
+
+// schedulePrefetch periodically reads the checkpoint images of the scaled
+// down container into the page cache while it's scaled down, so the restore
+// does not have to wait for them to be read from disk.
+func (c *Container) schedulePrefetch() {
+	if c.cfg.PrefetchInterval == 0 || c.CheckpointMode() != v1.CheckpointMode_CHECKPOINT {
+		return
+	}
+
+	c.prefetchMu.Lock()
+	defer c.prefetchMu.Unlock()
+	c.stopPrefetchTimer()
+	gen := c.prefetchGen
+	c.prefetchTimer = time.AfterFunc(c.cfg.PrefetchInterval, func() {
+		if !c.prefetchPending(gen) || !c.ScaledDown() {
+			return
+		}
+		c.prefetch(c.context)
+
+		// the prefetch might have been cancelled while it was running, in
+		// which case the timer must not be armed again.
+		c.prefetchMu.Lock()
+		defer c.prefetchMu.Unlock()
+		if c.prefetchGen == gen && !c.stopped.Load() {
+			c.prefetchTimer.Reset(c.cfg.PrefetchInterval)
+		}
+	})
+}
+
+func (c *Container) cancelPrefetch() {
+	c.prefetchMu.Lock()
+	defer c.prefetchMu.Unlock()
+	c.stopPrefetchTimer()
+}
+
+// stopPrefetchTimer stops the pending prefetch and increases the generation,
+// so a prefetch that is already running does not schedule the next one. It
+// needs to be called with prefetchMu held.
+func (c *Container) stopPrefetchTimer() {
+	c.prefetchGen++
+	if c.prefetchTimer != nil {
+		c.prefetchTimer.Stop()
+	}
+}
+
+// prefetchPending returns true if the prefetch with the supplied generation
+// has not been cancelled and the container has not been stopped.
+func (c *Container) prefetchPending(gen uint64) bool {
+	c.prefetchMu.Lock()
+	defer c.prefetchMu.Unlock()
+	return c.prefetchGen == gen && !c.stopped.Load()
+}
+
+func (c *Container) prefetch(ctx context.Context) {
+	before := time.Now()
+	prefetched := int64(0)
+	for _, dir := range []string{containerDir(c.Bundle), preDumpDir(c.Bundle)} {
+		n, err := prefetchFiles(dir)
+		if err != nil {
+			log.G(ctx).Errorf("unable to prefetch checkpoint images in %s: %s", dir, err)
+		}
+		prefetched += n
+	}
+
+	// the metrics of a container that has been stopped in the meantime
+	// have already been deleted.
+	if c.stopped.Load() {
+		return
+	}
+	checkpointPrefetchDuration.With(c.labels()).Observe(time.Since(before).Seconds())
+	checkpointPrefetchBytes.With(c.labels()).Set(float64(prefetched))
+	log.G(ctx).Debugf("prefetched %d bytes of checkpoint images in %s", prefetched, time.Since(before))
+}
+
+// prefetchFiles reads all regular files below dir, which leaves them in the
+// page cache, and returns the amount of bytes read. Symlinks are not
+// followed. A missing dir is not an error.
+func prefetchFiles(dir string) (int64, error) {
+	read := int64(0)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		n, err := io.Copy(io.Discard, f)
+		read += n
+		return err
+	})
+	return read, err
+}
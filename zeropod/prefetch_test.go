@@ -0,0 +1,56 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pages-1.img"), make([]byte, 4096), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "pages-2.img"), make([]byte, 1024), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "pages-1.img"), filepath.Join(dir, "parent")))
+
+	read, err := prefetchFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5120), read, "symlinks should not be followed")
+
+	read, err = prefetchFiles(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Zero(t, read)
+}
+
+func TestPrefetchOfStoppedContainer(t *testing.T) {
+	ctx := context.Background()
+	bundle := t.TempDir()
+	require.NoError(t, os.MkdirAll(containerDir(bundle), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(containerDir(bundle), "pages-1.img"), make([]byte, 32<<20), 0o644))
+
+	// the container stays scaled down when it's stopped, a prefetch that is
+	// running at that time must not schedule the next one and re-create its
+	// metrics.
+	for i := 0; i < 20; i++ {
+		c := NewFakeContainer(ctx, &Config{ContainerName: "prefetch-stopped", PrefetchInterval: time.Millisecond}, &sync.Mutex{},
+			&fakeProcess{pid: 1234}, &FakeCheckpointer{Container: &runc.Container{ID: "foo", Bundle: bundle}})
+		c.SetScaledDown(true)
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(checkpointPrefetchBytes.With(c.labels())) == 32<<20
+		}, time.Second, time.Millisecond)
+
+		// stop while the next prefetch is running.
+		time.Sleep(2 * time.Millisecond)
+		c.Stop(ctx)
+		time.Sleep(20 * time.Millisecond)
+		require.False(t, checkpointPrefetchBytes.Delete(c.labels()), "prefetch should not run after stop")
+	}
+}
@@ -0,0 +1,56 @@
+package zeropod
+
+import (
+	"sync"
+	"time"
+)
+
+// rateMeter measures the rate at which a counter (e.g. the CPU time or the
+// bytes written of a container) increases since the last reset. It's reset
+// when a scale down is scheduled while the timer of the previous one might
+// still be reading it, so it's safe for concurrent use.
+type rateMeter struct {
+	read  func() (float64, error)
+	mu    sync.Mutex
+	since time.Time
+	base  float64
+}
+
+func newRateMeter(read func() (float64, error)) *rateMeter {
+	return &rateMeter{read: read}
+}
+
+// reset starts a new measurement. If the counter can't be read, the
+// measurement is started by the next call to rate.
+func (m *rateMeter) reset() {
+	value, err := m.read()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.since, m.base = time.Time{}, 0
+	if err == nil {
+		m.since, m.base = time.Now(), value
+	}
+}
+
+// rate returns the increase of the counter per second since the last reset.
+// If there is nothing to compare to, a new measurement is started and ok is
+// false.
+func (m *rateMeter) rate() (rate float64, ok bool, err error) {
+	value, err := m.read()
+	if err != nil {
+		return 0, false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.since.IsZero() || value < m.base {
+		m.since, m.base = time.Now(), value
+		return 0, false, nil
+	}
+
+	elapsed := time.Since(m.since).Seconds()
+	if elapsed <= 0 {
+		return 0, true, nil
+	}
+	return (value - m.base) / elapsed, true, nil
+}
@@ -0,0 +1,73 @@
+package zeropod
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateMeter(t *testing.T) {
+	value, fail := 0.0, false
+	m := newRateMeter(func() (float64, error) {
+		if fail {
+			return 0, errors.New("boom")
+		}
+		return value, nil
+	})
+
+	m.reset()
+	time.Sleep(10 * time.Millisecond)
+	value = 100
+	rate, ok, err := m.rate()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, rate, 0.0)
+
+	// a counter that can't be read on reset is measured from the next read.
+	fail = true
+	m.reset()
+	_, _, err = m.rate()
+	assert.Error(t, err)
+	fail = false
+	_, ok, err = m.rate()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = m.rate()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// a counter that has been reset starts a new measurement.
+	value = 0
+	_, ok, err = m.rate()
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRateMeterConcurrent(t *testing.T) {
+	value := atomic.Int64{}
+	m := newRateMeter(func() (float64, error) {
+		return float64(value.Add(1)), nil
+	})
+
+	// the meter is reset by new scale downs while the timer of a previous
+	// one reads it.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.reset()
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := m.rate()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
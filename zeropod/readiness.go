@@ -0,0 +1,158 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/containernetworking/plugins/pkg/ns"
+)
+
+const (
+	readinessProbeInterval = time.Second
+	readinessProbeTimeout  = time.Second
+	readinessProbeDelim    = ":"
+)
+
+// ReadinessProbeType defines how the readiness of a container is probed.
+type ReadinessProbeType string
+
+const (
+	// ReadinessProbeTCP succeeds once the port is in listen state in the
+	// network namespace of the container.
+	ReadinessProbeTCP ReadinessProbeType = "tcp"
+	// ReadinessProbeHTTP succeeds once a GET request to the path on the port
+	// returns a status below 400.
+	ReadinessProbeHTTP ReadinessProbeType = "http"
+)
+
+// ReadinessProbe is used to detect when a container has become ready.
+type ReadinessProbe struct {
+	Type ReadinessProbeType
+	Port uint16
+	Path string
+}
+
+// parseReadinessProbe parses a probe in the format type:port[/path], e.g.
+// tcp:8080 or http:8080/healthz.
+func parseReadinessProbe(value string) (*ReadinessProbe, error) {
+	typ, target, ok := strings.Cut(value, readinessProbeDelim)
+	if !ok {
+		return nil, fmt.Errorf("invalid readiness probe %q, the format needs to be type:port[/path]", value)
+	}
+
+	probe := &ReadinessProbe{Type: ReadinessProbeType(typ), Path: "/"}
+	port, path, hasPath := strings.Cut(target, "/")
+	switch probe.Type {
+	case ReadinessProbeTCP:
+		if hasPath {
+			return nil, fmt.Errorf("invalid readiness probe %q, tcp probes don't support a path", value)
+		}
+	case ReadinessProbeHTTP:
+		if hasPath {
+			probe.Path = "/" + path
+		}
+	default:
+		return nil, fmt.Errorf("invalid readiness probe type %q, must be one of %q, %q",
+			typ, ReadinessProbeTCP, ReadinessProbeHTTP)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid readiness probe port: %w", err)
+	}
+	if p == 0 {
+		return nil, fmt.Errorf("invalid readiness probe port %d", p)
+	}
+	probe.Port = uint16(p)
+
+	return probe, nil
+}
+
+// ready probes the container in the supplied network namespace.
+func (p *ReadinessProbe) ready(ctx context.Context, netNS ns.NetNS) (bool, error) {
+	switch p.Type {
+	case ReadinessProbeHTTP:
+		return p.httpReady(ctx, netNS)
+	default:
+		ports, err := listeningPortsInNS(netNS)
+		if err != nil {
+			return false, err
+		}
+		_, ok := ports[p.Port]
+		return ok, nil
+	}
+}
+
+func (p *ReadinessProbe) httpReady(ctx context.Context, netNS ns.NetNS) (bool, error) {
+	client := &http.Client{
+		Timeout: readinessProbeTimeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var conn net.Conn
+				err := netNS.Do(func(_ ns.NetNS) error {
+					var err error
+					conn, err = (&net.Dialer{}).DialContext(ctx, network, addr)
+					return err
+				})
+				return conn, err
+			},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("http://%s%s", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(p.Port))), p.Path), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode < http.StatusBadRequest, nil
+}
+
+// awaitReadiness probes the container until it's ready and only then
+// schedules its first scale down, so a container that is still initializing
+// is never checkpointed. The probe uses the scale down timer, so cancelling
+// the scale down also stops the probing.
+func (c *Container) awaitReadiness() {
+	log.G(c.context).Infof("waiting for container to become ready before scheduling scale down")
+	c.scaleDownMu.Lock()
+	defer c.scaleDownMu.Unlock()
+	c.scaleDownGen++
+	gen := c.scaleDownGen
+	c.scaleDownTimer = time.AfterFunc(readinessProbeInterval, func() {
+		if !c.scaleDownPending(gen) || !c.Exists() {
+			return
+		}
+
+		ready, err := c.cfg.ReadinessProbe.ready(c.context, c.netNS)
+		if err != nil {
+			log.G(c.context).Debugf("readiness probe failed: %s", err)
+		}
+		if !ready {
+			c.scaleDownMu.Lock()
+			defer c.scaleDownMu.Unlock()
+			if c.scaleDownGen == gen {
+				c.scaleDownTimer.Reset(readinessProbeInterval)
+			}
+			return
+		}
+
+		log.G(c.context).Info("container is ready")
+		c.ready.Store(true)
+		if err := c.ScheduleScaleDown(); err != nil {
+			log.G(c.context).Errorf("unable to schedule scale down: %s", err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package zeropod
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReadinessProbe(t *testing.T) {
+	tests := map[string]struct {
+		value     string
+		expected  *ReadinessProbe
+		expectErr bool
+	}{
+		"tcp": {
+			value:    "tcp:8080",
+			expected: &ReadinessProbe{Type: ReadinessProbeTCP, Port: 8080, Path: "/"},
+		},
+		"http": {
+			value:    "http:8080/healthz",
+			expected: &ReadinessProbe{Type: ReadinessProbeHTTP, Port: 8080, Path: "/healthz"},
+		},
+		"http without path": {
+			value:    "http:80",
+			expected: &ReadinessProbe{Type: ReadinessProbeHTTP, Port: 80, Path: "/"},
+		},
+		"tcp with path": {
+			value:     "tcp:8080/healthz",
+			expectErr: true,
+		},
+		"invalid type": {
+			value:     "exec:true",
+			expectErr: true,
+		},
+		"invalid port": {
+			value:     "tcp:http",
+			expectErr: true,
+		},
+		"zero port": {
+			value:     "tcp:0",
+			expectErr: true,
+		},
+		"missing port": {
+			value:     "tcp",
+			expectErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			probe, err := parseReadinessProbe(tc.value)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, probe)
+		})
+	}
+}
+
+func TestReadinessProbe(t *testing.T) {
+	netNS, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	ready := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready || r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	port := uint16(ts.Listener.Addr().(*net.TCPAddr).Port)
+
+	ctx := context.Background()
+	ok, err := (&ReadinessProbe{Type: ReadinessProbeTCP, Port: port}).ready(ctx, netNS)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	probe := &ReadinessProbe{Type: ReadinessProbeHTTP, Port: port, Path: "/healthz"}
+	ok, err = probe.ready(ctx, netNS)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ready = true
+	ok, err = probe.ready(ctx, netNS)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ts.Close()
+	ok, _ = (&ReadinessProbe{Type: ReadinessProbeTCP, Port: port}).ready(ctx, netNS)
+	assert.False(t, ok)
+	ok, err = probe.ready(ctx, netNS)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestScaleDownAfterReadiness(t *testing.T) {
+	netNS, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, l.Close())
+
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		context:   context.Background(),
+		netNS:     netNS,
+		cfg: &Config{
+			ScaleDownDuration: time.Minute,
+			ReadinessProbe:    &ReadinessProbe{Type: ReadinessProbeTCP, Port: port},
+		},
+		trigger: scheduleTrigger{},
+	}
+	t.Cleanup(c.CancelScaleDown)
+	scaleDownAt := func() time.Time {
+		c.scaleDownMu.Lock()
+		defer c.scaleDownMu.Unlock()
+		return c.scaleDownAt
+	}
+
+	require.NoError(t, c.ScheduleScaleDown())
+	assert.True(t, scaleDownAt().IsZero(), "scale down should not be scheduled before the container is ready")
+
+	l, err = net.Listen("tcp4", l.Addr().String())
+	require.NoError(t, err)
+	defer l.Close()
+
+	assert.Eventually(t, func() bool {
+		return !scaleDownAt().IsZero()
+	}, readinessProbeInterval*3, readinessProbeInterval/10)
+	assert.True(t, c.ready.Load())
+	assert.WithinDuration(t, time.Now().Add(time.Minute), scaleDownAt(), time.Second)
+}
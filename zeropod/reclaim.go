@@ -0,0 +1,127 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/containerd/cgroups"
+	cgroupsv2 "github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/containerd/log"
+	"github.com/ctrox/zeropod/socket"
+)
+
+const (
+	cgroup2Mountpoint  = "/sys/fs/cgroup"
+	memoryCurrentFile  = "memory.current"
+	memoryReclaimFile  = "memory.reclaim"
+	reclaimNotComplete = syscall.EAGAIN
+)
+
+// reclaim reclaims the memory of the container while keeping the process
+// running. If there has been no activity since the last reclaim, there is
+// nothing new to reclaim and it's skipped. Afterwards, the next reclaim is
+// scheduled. Errors are only logged as the process is still running and
+// there is nothing to recover from.
+func (c *Container) reclaim(ctx context.Context) error {
+	last, err := c.tracker.LastActivity(uint32(c.process.Pid()))
+	noActivity := errors.Is(err, socket.NoActivityRecordedErr{}) || (err == nil && last.Before(c.lastReclaim))
+	if !c.lastReclaim.IsZero() && noActivity {
+		log.G(ctx).Info("no activity since last reclaim, skipping")
+		return c.ScheduleScaleDown()
+	}
+
+	cgroupPath, err := cgroupV2Path(c.process.Pid())
+	if err != nil {
+		log.G(ctx).Errorf("unable to reclaim memory: %s", err)
+		return c.ScheduleScaleDown()
+	}
+
+	reclaimed, err := reclaimCgroupMemory(cgroupPath)
+	if err != nil {
+		log.G(ctx).Errorf("unable to reclaim memory of cgroup %s: %s", cgroupPath, err)
+		return c.ScheduleScaleDown()
+	}
+	c.lastReclaim = time.Now()
+	log.G(ctx).Infof("reclaimed %d bytes of memory", reclaimed)
+
+	return c.ScheduleScaleDown()
+}
+
+// ReclaimSandboxMemory reclaims the memory of the cgroup of the sandbox
+// process with the supplied pid. Errors are only logged as the sandbox keeps
+// running regardless.
+func ReclaimSandboxMemory(ctx context.Context, pid int) {
+	cgroupPath, err := cgroupV2Path(pid)
+	if err != nil {
+		log.G(ctx).Errorf("unable to reclaim memory of sandbox: %s", err)
+		return
+	}
+
+	reclaimed, err := reclaimCgroupMemory(cgroupPath)
+	if err != nil {
+		log.G(ctx).Errorf("unable to reclaim memory of sandbox cgroup %s: %s", cgroupPath, err)
+		return
+	}
+	log.G(ctx).Infof("reclaimed %d bytes of sandbox memory", reclaimed)
+}
+
+// cgroupV2Path returns the path of the cgroup of the supplied pid. This is
+// only supported on cgroup v2 as v1 does not have a reclaim interface and
+// splits the controllers into separate hierarchies.
+func cgroupV2Path(pid int) (string, error) {
+	if cgroups.Mode() != cgroups.Unified {
+		return "", fmt.Errorf("cgroup v2 is required")
+	}
+
+	group, err := cgroupsv2.PidGroupPath(pid)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cgroup2Mountpoint, group), nil
+}
+
+// reclaimCgroupMemory asks the kernel to reclaim all of the memory currently
+// charged to the cgroup at cgroupPath and returns the amount of bytes that
+// have been reclaimed. The kernel only reclaims what it can (e.g. page cache
+// and anon memory if there is swap) and reports that it could not reclaim
+// the full amount, which is expected and not treated as an error.
+func reclaimCgroupMemory(cgroupPath string) (uint64, error) {
+	before, err := readCgroupUint(filepath.Join(cgroupPath, memoryCurrentFile))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.WriteFile(
+		filepath.Join(cgroupPath, memoryReclaimFile),
+		[]byte(strconv.FormatUint(before, 10)), 0,
+	); err != nil && !errors.Is(err, reclaimNotComplete) {
+		return 0, err
+	}
+
+	after, err := readCgroupUint(filepath.Join(cgroupPath, memoryCurrentFile))
+	if err != nil {
+		return 0, err
+	}
+
+	if after > before {
+		return 0, nil
+	}
+	return before - after, nil
+}
+
+func readCgroupUint(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
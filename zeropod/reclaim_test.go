@@ -0,0 +1,28 @@
+package zeropod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReclaimCgroupMemory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, memoryCurrentFile), []byte("4096\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, memoryReclaimFile), nil, 0o644))
+
+	reclaimed, err := reclaimCgroupMemory(dir)
+	require.NoError(t, err)
+	// memory.current is a regular file here, so nothing is actually reclaimed.
+	assert.Zero(t, reclaimed)
+
+	b, err := os.ReadFile(filepath.Join(dir, memoryReclaimFile))
+	require.NoError(t, err)
+	assert.Equal(t, "4096", string(b), "the full memory usage should be requested to be reclaimed")
+
+	_, err = reclaimCgroupMemory(t.TempDir())
+	assert.Error(t, err)
+}
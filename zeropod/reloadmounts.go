@@ -0,0 +1,106 @@
+package zeropod
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/log"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// saveMountsDigest records the contents of the mounts to reload before the
+// scale down, so the restore can tell if they have changed in the meantime.
+func (c *Container) saveMountsDigest(ctx context.Context) {
+	if len(c.cfg.ReloadMounts) == 0 {
+		return
+	}
+
+	digest, err := mountsDigest(c.cfg.spec.Mounts, c.cfg.ReloadMounts)
+	if err != nil {
+		log.G(ctx).Errorf("unable to read mounts to reload: %s", err)
+	}
+	c.reloadMountsDigest = digest
+}
+
+// mountsChanged returns true if the contents of the mounts to reload have
+// changed since the scale down. If they can't be compared, they are
+// considered changed, so the process reloads them rather than keeping stale
+// contents.
+func (c *Container) mountsChanged(ctx context.Context) bool {
+	digest, err := mountsDigest(c.cfg.spec.Mounts, c.cfg.ReloadMounts)
+	if err != nil {
+		log.G(ctx).Errorf("unable to read mounts to reload: %s", err)
+		return true
+	}
+	return c.reloadMountsDigest == "" || digest != c.reloadMountsDigest
+}
+
+// mountsDigest returns a digest of the contents of the mounts at the
+// supplied destinations. The sources of configmap, secret and projected
+// volumes are updated in place by the kubelet, which swaps the hidden
+// "..data" link to a new dir of files. The container sees the same
+// directory, so the files only have to be read again by the process. The
+// files directly below a directory are included, following the links of the
+// kubelet. Volumes mounted with a subPath are never updated, so their
+// digest does not change either.
+func mountsDigest(mounts []specs.Mount, destinations []string) (string, error) {
+	h := sha256.New()
+	var errs []error
+	for _, dest := range destinations {
+		for _, m := range mounts {
+			if m.Destination != dest {
+				continue
+			}
+			io.WriteString(h, dest)
+
+			info, err := os.Stat(m.Source)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !info.IsDir() {
+				errs = append(errs, hashFile(h, m.Source))
+				continue
+			}
+
+			entries, err := os.ReadDir(m.Source)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), "..") {
+					continue
+				}
+				src := filepath.Join(m.Source, entry.Name())
+				if info, err := os.Stat(src); err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				io.WriteString(h, entry.Name())
+				errs = append(errs, hashFile(h, src))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
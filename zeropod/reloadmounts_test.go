@@ -0,0 +1,48 @@
+package zeropod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountsDigest(t *testing.T) {
+	src := t.TempDir()
+
+	// mimic the layout of a configmap volume written by the kubelet.
+	writeData := func(name, contents string) {
+		data := filepath.Join(src, name)
+		require.NoError(t, os.Mkdir(data, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(data, "app.yaml"), []byte(contents), 0o644))
+		tmp := filepath.Join(src, "..data_tmp")
+		require.NoError(t, os.Symlink(name, tmp))
+		require.NoError(t, os.Rename(tmp, filepath.Join(src, "..data")))
+	}
+	writeData("..2024_01_01", "new: false\n")
+	require.NoError(t, os.Symlink("..data/app.yaml", filepath.Join(src, "app.yaml")))
+
+	mounts := []specs.Mount{{Destination: "/config", Source: src}}
+	before, err := mountsDigest(mounts, []string{"/config"})
+	require.NoError(t, err)
+
+	unchanged, err := mountsDigest(mounts, []string{"/config"})
+	require.NoError(t, err)
+	assert.Equal(t, before, unchanged)
+
+	writeData("..2024_01_02", "new: true\n")
+	after, err := mountsDigest(mounts, []string{"/config"})
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+
+	other, err := mountsDigest(mounts, []string{"/other"})
+	require.NoError(t, err)
+	assert.NotEqual(t, after, other)
+
+	mounts[0].Source = filepath.Join(src, "missing")
+	_, err = mountsDigest(mounts, []string{"/config"})
+	assert.Error(t, err)
+}
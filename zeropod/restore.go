@@ -1,12 +1,17 @@
 package zeropod
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/containerd/containerd/api/runtime/task/v2"
@@ -16,47 +21,435 @@ import (
 	cioutil "github.com/containerd/containerd/pkg/ioutil"
 	"github.com/containerd/containerd/pkg/process"
 	"github.com/containerd/containerd/pkg/stdio"
+	"github.com/containerd/containerd/protobuf"
 	"github.com/containerd/containerd/runtime/v2/runc"
+	runcC "github.com/containerd/go-runc"
 	"github.com/containerd/log"
+	"github.com/containerd/typeurl/v2"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
-var ErrAlreadyRestored = errors.New("container is already restored")
+var (
+	// dnsConfigFiles are the files that are refreshed on restore if enabled.
+	dnsConfigFiles = []string{"/etc/hosts", "/etc/resolv.conf"}
+
+	ErrAlreadyRestored  = errors.New("container is already restored")
+	ErrRestoreTimeout   = errors.New("restore timed out")
+	errRestoreAddrInUse = errors.New("address already in use")
+	// ErrBundleIncomplete is returned if parts of the bundle that are needed
+	// to restore the container have been removed (e.g. by a disk cleanup).
+	ErrBundleIncomplete = errors.New("bundle of the container is incomplete")
+	// ErrCheckpointMissing is returned if the checkpoint images of a scaled
+	// down container are gone.
+	ErrCheckpointMissing = errors.New("checkpoint of the container is missing")
+	// ErrContainerStopped is returned if the container has been stopped
+	// (e.g. killed while scaled down) before it could be restored.
+	ErrContainerStopped = errors.New("container has been stopped")
+
+	// postRestoreCommandTimeout limits how long the post restore command can
+	// delay the activation of the restored process.
+	postRestoreCommandTimeout = 10 * time.Second
+	// coldStartCommandTimeout limits how long the cold start command can
+	// delay the activation of a process that has been started from scratch.
+	coldStartCommandTimeout = time.Minute
+
+	restoreAddrInUseRetries  = 5
+	restoreRetryInterval     = time.Second
+	maxRestoreRetryBackoff   = time.Minute
+	restoreAddrInUseInterval = 100 * time.Millisecond
+	portReleaseTimeout       = time.Second
+	portReleaseInterval      = 10 * time.Millisecond
+)
 
 func (c *Container) Restore(ctx context.Context) (*runc.Container, process.Process, error) {
+	// wait for the budget before taking the lock, so checkpoints of other
+	// containers of the shim are not held up by a queued restore.
+	if err := c.waitForRestoreBudget(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	c.handoffMu.Lock()
+	defer c.handoffMu.Unlock()
+	container, p, err := c.restore(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the process might take a while to accept the connections, so they
+	// are handed back without the checkpointRestore lock, which is shared
+	// by all containers of the shim.
+	c.handBackConnections(ctx, p.Pid())
+	return container, p, nil
+}
+
+// restore restores the container while holding the checkpointRestore lock.
+func (c *Container) restore(ctx context.Context) (*runc.Container, process.Process, error) {
 	c.checkpointRestore.Lock()
 	defer c.checkpointRestore.Unlock()
+	if c.stopped.Load() {
+		return nil, nil, ErrContainerStopped
+	}
 	if !c.ScaledDown() {
 		return nil, nil, ErrAlreadyRestored
 	}
 
 	beforeRestore := time.Now()
-	go func() {
-		// as soon as we checkpoint the container, the log pipe is closed. As
-		// we currently have no way to instruct containerd to restore the logs
-		// and pipe it again, we do it manually.
-		if err := c.restoreLoggers(c.ID(), c.initialProcess.Stdio()); err != nil {
-			log.G(ctx).Errorf("error restoring loggers: %s", err)
+	done := c.trackRestoreProgress(ctx)
+	consumeBudget := measureRestoreCPU(ctx)
+	container, p, handleStarted, err := c.restoreWithRetries(ctx)
+	consumeBudget()
+	done()
+	if errors.Is(err, ErrAlreadyRestored) || errors.Is(err, ErrContainerStopped) {
+		// restored or stopped by someone else while waiting for a retry.
+		return nil, nil, err
+	}
+	gen := c.setRestoreResult(err)
+	if err != nil {
+		c.sendEvent(c.Status())
+		return nil, nil, err
+	}
+	restoreDuration.With(c.labels()).Observe(time.Since(beforeRestore).Seconds())
+	c.logIfSlow(ctx, "restore", time.Since(beforeRestore))
+	c.restoreCPUSet(ctx, p.Pid())
+	c.restoreOOMScoreAdj(ctx, p.Pid())
+	c.verifyPIDNamespaceInit(ctx, p.Pid())
+
+	if c.cfg.RefreshDNSConfig {
+		if err := rebindMounts(p.Pid(), c.cfg.spec.Mounts, dnsConfigFiles); err != nil {
+			log.G(ctx).Errorf("unable to refresh dns config: %s", err)
+		}
+	}
+
+	if c.startedFresh && len(c.cfg.ColdStartCommand) != 0 {
+		if err := c.runRestoreCommand(ctx, p, "cold start", c.cfg.ColdStartCommand, coldStartCommandTimeout); err != nil {
+			log.G(ctx).Errorf("cold start command failed: %s", err)
+		}
+	}
+
+	if len(c.cfg.PostRestoreCommand) != 0 {
+		if err := c.runRestoreCommand(ctx, p, "post restore", c.cfg.PostRestoreCommand, postRestoreCommandTimeout); err != nil {
+			log.G(ctx).Errorf("post restore command failed: %s", err)
+		}
+	}
+
+	c.Container = container
+	c.process = p
+	c.coldStart = false
+	c.SetScaledDown(false)
+
+	if c.cfg.RestoreSignal != 0 && (len(c.cfg.ReloadMounts) == 0 || c.mountsChanged(ctx)) {
+		// allow the application to reload state that might have changed
+		// while it was scaled down (e.g. rotated secrets). If mounts to
+		// reload are configured, it's only needed if they have changed.
+		log.G(ctx).Infof("sending %s to restored process %d", unix.SignalName(c.cfg.RestoreSignal), p.Pid())
+		if err := p.Kill(ctx, uint32(c.cfg.RestoreSignal), false); err != nil {
+			log.G(ctx).Errorf("unable to signal restored process: %s", err)
+		}
+	}
+
+	if c.postRestore != nil {
+		c.postRestore(container, handleStarted)
+	}
+
+	// process is running again, we don't need to redirect traffic anymore
+	if err := c.disableRedirects(); err != nil {
+		return nil, nil, fmt.Errorf("could not disable redirects: %w", err)
+	}
+	c.redirectsDisabledAt = time.Now()
+
+	restoresCompleted.With(c.labels()).Inc()
+	if c.cfg.RestoreHealthCheck != nil {
+		go c.checkRestoreHealth(gen)
+	}
+
+	return container, p, nil
+}
+
+// runRestoreCommand executes a command in the restored container before any
+// traffic is passed to it. The post restore command can be used to flush
+// caches that might be stale after the restore, e.g. the DNS cache of nscd or
+// systemd-resolved, while the cold start command allows warming up a
+// container that has been started from scratch.
+func (c *Container) runRestoreCommand(ctx context.Context, p process.Process, name string, args []string, timeout time.Duration) error {
+	initProcess, ok := p.(*process.Init)
+	if !ok {
+		return fmt.Errorf("process is not of type %T, got %T", process.Init{}, p)
+	}
+
+	spec := specs.Process{Args: args, Cwd: "/"}
+	if c.cfg.spec != nil && c.cfg.spec.Process != nil {
+		// run the command with the same user, env and capabilities as the
+		// process of the container.
+		spec = *c.cfg.spec.Process
+		spec.Args = args
+		spec.Terminal = false
+		spec.ConsoleSize = nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.G(ctx).Infof("running %s command %q in container %s", name, args, c.ID())
+	return initProcess.Runtime().Exec(ctx, c.ID(), spec, &runcC.ExecOpts{})
+}
+
+// restoreWithRetries restores the container and retries according to the
+// configured restore retries if it fails. If all attempts have failed and
+// the restore failure policy is cold-start, the container is started from
+// scratch instead. An outdated or missing checkpoint is discarded and the
+// container is started from scratch right away, while an incomplete bundle
+// fails without any retries. It needs to be called with checkpointRestore
+// held.
+func (c *Container) restoreWithRetries(ctx context.Context) (*runc.Container, process.Process, HandleStartedFunc, error) {
+	checkpoint := !c.cfg.DisableCheckpointing && !c.coldStart
+	if checkpoint {
+		if err := c.checkpointOutdated(); err != nil {
+			log.G(ctx).Warnf("discarding checkpoint, starting container without it: %s", err)
+			checkpoint = false
+			if err := os.RemoveAll(snapshotDir(c.Bundle)); err != nil {
+				log.G(ctx).Errorf("unable to remove outdated checkpoint: %s", err)
+			}
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		container, p, handleStarted, err := c.restoreAttempt(ctx, checkpoint)
+		if err == nil {
+			c.startedFresh = !checkpoint
+			return container, p, handleStarted, nil
 		}
-	}()
 
+		if errors.Is(err, ErrBundleIncomplete) {
+			// retrying does not bring the bundle back.
+			return nil, nil, nil, err
+		}
+
+		if checkpoint && errors.Is(err, ErrCheckpointMissing) {
+			log.G(ctx).Warnf("starting container without checkpoint: %s", err)
+			restoreColdStarts.With(c.labels()).Inc()
+			c.startedFresh = true
+			return c.restoreAttempt(ctx, false)
+		}
+
+		if attempt < c.cfg.RestoreRetries {
+			delay := c.restoreRetryDelay(attempt)
+			log.G(ctx).Warnf("restore failed, retrying in %s (retry %d/%d): %s",
+				delay, attempt+1, c.cfg.RestoreRetries, err)
+			if err := c.waitForRestoreRetry(ctx, delay); err != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+
+		if !checkpoint || c.cfg.RestoreFailurePolicy != RestoreFailurePolicyColdStart {
+			return nil, nil, nil, err
+		}
+
+		log.G(ctx).Errorf("restore failed, starting container without checkpoint: %s", err)
+		checkpoint = false
+		restoreColdStarts.With(c.labels()).Inc()
+		container, p, handleStarted, err = c.restoreAttempt(ctx, checkpoint)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		c.startedFresh = true
+		return container, p, handleStarted, nil
+	}
+}
+
+// restoreAttempt restores the container once. As soon as the container is
+// checkpointed, its log pipe is closed. As we currently have no way to
+// instruct containerd to restore the logs and pipe them again, we do it
+// manually. The loggers need to read the fifos before the process is
+// created, so they are set up for every attempt and closed again if it
+// fails. Otherwise every failed attempt would leave a reader of the fifos
+// and a handle of the log file behind. An attempt that reaches the restore
+// timeout fails with ErrRestoreTimeout once the checkpointer has cleaned it
+// up.
+func (c *Container) restoreAttempt(ctx context.Context, checkpoint bool) (*runc.Container, process.Process, HandleStartedFunc, error) {
+	logs, err := c.restoreLoggers(c.ID(), c.initialProcess.Stdio())
+	if err != nil {
+		log.G(ctx).Errorf("error restoring loggers: %s", err)
+	}
+
+	restoreCtx, stopTimeout := c.restoreContext(ctx)
+	defer stopTimeout()
+
+	container, p, handleStarted, err := c.checkpointer.Restore(restoreCtx, checkpoint)
+	if err != nil {
+		if logs != nil {
+			logs.Close()
+		}
+		if c.cfg.RestoreTimeout > 0 && restoreCtx.Err() != nil && ctx.Err() == nil {
+			if cleaner, ok := c.checkpointer.(RestoreCleaner); ok {
+				cleaner.CleanupRestore(ctx)
+			}
+			return nil, nil, nil, fmt.Errorf("%w after %s: %w", ErrRestoreTimeout, c.cfg.RestoreTimeout, err)
+		}
+		return nil, nil, nil, err
+	}
+	return container, p, handleStarted, nil
+}
+
+// waitForRestoreRetry waits for the delay before the next restore attempt.
+// The checkpoint/restore lock is shared by all containers of the shim, so it
+// is released while waiting to not hold up their checkpoints and restores.
+// It returns ErrAlreadyRestored if the container has been restored in the
+// meantime, ErrContainerStopped if it has been stopped (the process would be
+// restored for a container that containerd already considers exited) and the
+// error of ctx if it's done before the delay is up.
+func (c *Container) waitForRestoreRetry(ctx context.Context, delay time.Duration) error {
+	c.checkpointRestore.Unlock()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	c.checkpointRestore.Lock()
+	if err != nil {
+		return err
+	}
+	if c.stopped.Load() {
+		return ErrContainerStopped
+	}
+	if !c.ScaledDown() {
+		return ErrAlreadyRestored
+	}
+	return nil
+}
+
+// restoreRetryDelay returns the delay before the supplied retry attempt
+// (starting at 0). With a restore retry backoff, the delay is doubled on
+// every attempt up to maxRestoreRetryBackoff.
+func (c *Container) restoreRetryDelay(attempt int) time.Duration {
+	if c.cfg.RestoreRetryBackoff <= 0 {
+		return restoreRetryInterval
+	}
+
+	delay := c.cfg.RestoreRetryBackoff
+	for i := 0; i < attempt && delay < maxRestoreRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRestoreRetryBackoff)
+}
+
+// RestoreFailed handles a restore that has failed after all retries
+// according to the restore failure policy.
+func (c *Container) RestoreFailed(ctx context.Context, err error) {
+	if c.cfg.RestoreFailurePolicy == RestoreFailurePolicyRetry {
+		// the activator is still in place, so the next connection restores
+		// again.
+		log.G(ctx).Errorf("error restoring container, keeping it scaled down: %s", err)
+		return
+	}
+
+	if c.cfg.RestoreFailurePolicy == RestoreFailurePolicyFail && c.fail != nil {
+		log.G(ctx).Errorf("error restoring container, marking it as failed: %s", err)
+		c.CancelScaleDown()
+		c.fail(ctx)
+		return
+	}
+
+	// restore failed, this is currently unrecoverable, so we shutdown our
+	// shim and let containerd recreate it.
+	log.G(ctx).Fatalf("error restoring container, exiting shim: %s", err)
+	os.Exit(1)
+}
+
+// Restore restores the container from the checkpoint using runc and CRIU or
+// starts it from scratch if checkpoint is false.
+func (c *criuCheckpointer) Restore(ctx context.Context, checkpoint bool) (*runc.Container, process.Process, HandleStartedFunc, error) {
+	defer throttleCRIU(ctx)()
+
+	if err := validateBundle(c.Bundle, checkpoint); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if c.cfg.CheckpointRootfs {
+		if err := restoreRootfsDiff(ctx, c.Bundle); err != nil {
+			log.G(ctx).Errorf("unable to restore rootfs diff: %s", err)
+		}
+	}
+
+	runtimeOpts, err := runtimeOptions(c.Bundle)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading runtime options: %w", err)
+	}
+
+	procIO := c.restoreStdio(ctx)
 	createReq := &task.CreateTaskRequest{
 		ID:               c.ID(),
 		Bundle:           c.Bundle,
 		Terminal:         false,
-		Stdin:            c.initialProcess.Stdio().Stdin,
-		Stdout:           c.initialProcess.Stdio().Stdout,
-		Stderr:           c.initialProcess.Stdio().Stderr,
+		Stdin:            procIO.Stdin,
+		Stdout:           procIO.Stdout,
+		Stderr:           procIO.Stderr,
 		ParentCheckpoint: "",
 		Checkpoint:       containerDir(c.Bundle),
+		Options:          runtimeOpts,
 	}
 
-	if c.cfg.DisableCheckpointing {
+	if !checkpoint {
 		createReq.Checkpoint = ""
+	} else {
+		retainCRIULogs(ctx, c.Bundle, filepath.Join(c.Bundle, "work"), "restore")
+	}
+
+	// the ports might still be held by the checkpointed process if it has
+	// not fully exited yet. Restoring while that is the case will fail to
+	// bind the ports so we wait until they have been released.
+	if err := c.waitForPortsReleased(ctx); err != nil {
+		log.G(ctx).Warnf("restoring even though ports might still be in use: %s", err)
+	}
+
+	var (
+		container     *runc.Container
+		p             process.Process
+		handleStarted HandleStartedFunc
+	)
+	for attempt := 1; ; attempt++ {
+		container, p, handleStarted, err = c.restoreProcess(ctx, createReq)
+		if err == nil {
+			break
+		}
+
+		if ctx.Err() != nil {
+			// the restore has been aborted, it's cleaned up by the caller
+			// with a context that is not done yet.
+			return nil, nil, nil, err
+		}
+
+		if !errors.Is(err, errRestoreAddrInUse) || attempt >= restoreAddrInUseRetries {
+			c.CleanupRestore(ctx)
+			return nil, nil, nil, err
+		}
+
+		log.G(ctx).Warnf("restore failed as address is in use, retrying in %s (attempt %d/%d)",
+			restoreAddrInUseInterval, attempt, restoreAddrInUseRetries)
+		c.CleanupRestore(ctx)
+		select {
+		case <-ctx.Done():
+			return nil, nil, nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(restoreAddrInUseInterval):
+		}
 	}
 
+	return container, p, handleStarted, nil
+}
+
+// restoreProcess creates the container from the checkpoint and starts the
+// restored process.
+func (c *Container) restoreProcess(ctx context.Context, createReq *task.CreateTaskRequest) (*runc.Container, process.Process, HandleStartedFunc, error) {
 	container, err := runc.NewContainer(namespaces.WithNamespace(ctx, c.cfg.ContainerdNamespace), c.platform, createReq)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	// it's important to restore the cgroup as NewContainer won't set it as
 	// the process is not yet restored.
@@ -69,42 +462,285 @@ func (c *Container) Restore(ctx context.Context) (*runc.Container, process.Proce
 
 	p, err := container.Process("")
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	log.G(ctx).Info("restore: process created")
 
+	restoreLog := filepath.Join(container.Bundle, "work", restoreLogFile)
 	if err := p.Start(ctx); err != nil {
-		b, err := os.ReadFile(filepath.Join(container.Bundle, "work", "restore.log"))
+		b := c.readCRIULog(ctx, restoreLog)
+		log.G(ctx).Errorf("restore.log: %s", b)
+
+		if addrInUse(b) {
+			return nil, nil, nil, fmt.Errorf("start failed during restore: %w: %w", errRestoreAddrInUse, err)
+		}
+
+		return nil, nil, nil, fmt.Errorf("start failed during restore: %w", err)
+	}
+	if createReq.Checkpoint != "" {
+		c.readCRIULog(ctx, restoreLog)
+	}
+
+	return container, p, handleStarted, nil
+}
+
+// validateBundle checks that the bundle still contains everything that is
+// needed to create the container again, so a bundle that has been pruned
+// fails with a typed error instead of failing somewhere in runc. If
+// checkpoint is true, the checkpoint images are checked as well.
+func validateBundle(bundle string, checkpoint bool) error {
+	spec, err := GetSpec(bundle)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBundleIncomplete, err)
+	}
+
+	if spec.Root != nil {
+		rootfs := spec.Root.Path
+		if !filepath.IsAbs(rootfs) {
+			rootfs = filepath.Join(bundle, rootfs)
+		}
+		if err := checkRootfs(rootfs); err != nil {
+			return fmt.Errorf("%w: %w", ErrBundleIncomplete, err)
+		}
+	}
+
+	if checkpoint {
+		if err := checkCheckpointImages(containerDir(bundle)); err != nil {
+			return fmt.Errorf("%w: %w", ErrCheckpointMissing, err)
+		}
+	}
+
+	return nil
+}
+
+// checkRootfs returns an error if the rootfs is not a directory or empty,
+// e.g. because it's not mounted anymore.
+func checkRootfs(rootfs string) error {
+	f, err := os.Open(rootfs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Readdirnames(1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("rootfs %s is empty", rootfs)
+		}
+		return err
+	}
+	return nil
+}
+
+// runtimeOptions returns the runc options the container has been created with
+// (e.g. a custom runc binary or the systemd cgroup driver) so the restore
+// uses them as well. runc.NewContainer writes the options of the request to
+// the bundle, so restoring without them would also overwrite the original
+// options with the defaults for all following operations.
+func runtimeOptions(bundle string) (*anypb.Any, error) {
+	opts, err := runc.ReadOptions(bundle)
+	if err != nil || opts == nil {
+		return nil, err
+	}
+
+	data, err := typeurl.MarshalAny(opts)
+	if err != nil {
+		return nil, err
+	}
+	return protobuf.FromAny(data), nil
+}
+
+// addrInUse returns true if the restore log indicates that the restore
+// failed because one of the sockets could not be bound (EADDRINUSE).
+func addrInUse(restoreLog []byte) bool {
+	return bytes.Contains(bytes.ToLower(restoreLog), []byte(syscall.EADDRINUSE.Error()))
+}
+
+// rebindMounts bind mounts the current source of the mounts at the supplied
+// destinations again in the mount namespace of the process with the supplied
+// pid. A restored container keeps the files mounted that were present at
+// checkpoint time, so if the source has been replaced in the meantime (e.g.
+// the DNS config written by the kubelet), the container would keep seeing the
+// old contents.
+func rebindMounts(pid int, mounts []specs.Mount, destinations []string) error {
+	var errs []error
+	for _, dest := range destinations {
+		for _, m := range mounts {
+			if m.Destination != dest {
+				continue
+			}
+			if err := rebindMount(pid, m); err != nil {
+				errs = append(errs, fmt.Errorf("rebinding %s: %w", dest, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rebindMount clones the mount source in the mount namespace of the shim and
+// moves it over the destination in the mount namespace of the process. The
+// destination is resolved in the root of the process, so links in the
+// container can't point it to a path on the host.
+func rebindMount(pid int, m specs.Mount) error {
+	tree, err := unix.OpenTree(unix.AT_FDCWD, m.Source, unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("cloning mount source: %w", err)
+	}
+	defer unix.Close(tree)
+
+	if slices.Contains(m.Options, "ro") {
+		if err := unix.MountSetattr(tree, "", unix.AT_EMPTY_PATH, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+			return fmt.Errorf("making mount read-only: %w", err)
+		}
+	}
+
+	mntNS, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "ns", "mnt"))
+	if err != nil {
+		return err
+	}
+	defer mntNS.Close()
+
+	root, err := os.Open(procRoot(pid))
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		// the thread is never unlocked as it can't leave the mount namespace
+		// of the process again, it's terminated once the goroutine returns.
+		runtime.LockOSThread()
+		errs <- func() error {
+			// threads of the shim share their filesystem attributes, which
+			// prevents joining a mount namespace.
+			if err := unix.Unshare(unix.CLONE_FS); err != nil {
+				return fmt.Errorf("unsharing fs attributes: %w", err)
+			}
+			if err := unix.Setns(int(mntNS.Fd()), unix.CLONE_NEWNS); err != nil {
+				return fmt.Errorf("joining mount namespace: %w", err)
+			}
+			if err := unix.Fchdir(int(root.Fd())); err != nil {
+				return err
+			}
+			if err := unix.Chroot("."); err != nil {
+				return err
+			}
+			// detach the old mount so the mounts don't stack up with every
+			// restore.
+			if err := unix.Unmount(m.Destination, unix.MNT_DETACH); err != nil && !errors.Is(err, unix.EINVAL) {
+				return fmt.Errorf("unmounting: %w", err)
+			}
+			return unix.MoveMount(tree, "", unix.AT_FDCWD, m.Destination, unix.MOVE_MOUNT_F_EMPTY_PATH)
+		}()
+	}()
+
+	return <-errs
+}
+
+// procRoot returns the path to the root filesystem of the process with the
+// supplied pid as seen from the host.
+func procRoot(pid int) string {
+	return filepath.Join("/proc", strconv.Itoa(pid), "root")
+}
+
+// waitForPortsReleased waits until none of the ports of the container are in
+// listen state anymore. Note that the activator never binds the ports of the
+// container itself, so this only waits for the sockets of the checkpointed
+// process to be closed.
+func (c *Container) waitForPortsReleased(ctx context.Context) error {
+	deadline := time.Now().Add(portReleaseTimeout)
+	for {
+		listening, err := listeningPortsInNS(c.netNS)
 		if err != nil {
-			log.G(ctx).Errorf("error reading restore.log: %s", err)
+			return err
+		}
+
+		inUse := []uint16{}
+		for _, port := range c.cfg.Ports {
+			if _, ok := listening[port]; ok {
+				inUse = append(inUse, port)
+			}
+		}
+		if len(inUse) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("ports %v still in use after %s", inUse, portReleaseTimeout)
 		}
-		log.G(ctx).Errorf("restore.log: %s", b)
 
-		return nil, nil, fmt.Errorf("start failed during restore: %w", err)
+		log.G(ctx).Debugf("waiting for ports %v to be released", inUse)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(portReleaseInterval):
+		}
 	}
-	restoreDuration.With(c.labels()).Observe(time.Since(beforeRestore).Seconds())
+}
 
-	c.Container = container
-	c.process = p
-	c.SetScaledDown(false)
+// restoreContext returns a context that is cancelled once the configured
+// restore timeout has been reached. The returned func stops the timeout. Note
+// that the context is intentionally not cancelled on success as the process
+// IO is still being set up with it after the restore.
+func (c *Container) restoreContext(ctx context.Context) (context.Context, func() bool) {
+	if c.cfg.RestoreTimeout == 0 {
+		return ctx, func() bool { return false }
+	}
 
-	if c.postRestore != nil {
-		c.postRestore(container, handleStarted)
+	restoreCtx, cancel := context.WithCancel(ctx)
+	return restoreCtx, time.AfterFunc(c.cfg.RestoreTimeout, cancel).Stop
+}
+
+// CleanupRestore force deletes the runc container of a restore that has been
+// aborted. This kills any leftover processes of the restore and removes the
+// runc state so the container can be restored again.
+func (c *criuCheckpointer) CleanupRestore(ctx context.Context) {
+	initProcess, ok := c.initialProcess.(*process.Init)
+	if !ok {
+		return
 	}
 
-	// process is running again, we don't need to redirect traffic anymore
-	if err := c.activator.DisableRedirects(); err != nil {
-		return nil, nil, fmt.Errorf("could not disable redirects: %w", err)
+	log.G(ctx).Warnf("cleaning up aborted restore of container %s", c.ID())
+	if err := initProcess.Runtime().Delete(ctx, c.ID(), &runcC.DeleteOpts{Force: true}); err != nil {
+		log.G(ctx).Errorf("unable to delete container after aborted restore: %s", err)
 	}
+}
 
-	return container, p, nil
+// restoreStdio returns the stdio the restored process is created with. The
+// stdin of a detached container might already have been closed by containerd
+// (e.g. stdinOnce after the attached client went away) or its fifo might not
+// exist anymore. Opening it again would fail the restore, so the restored
+// process is created without stdin in that case.
+func (c *Container) restoreStdio(ctx context.Context) stdio.Stdio {
+	s := c.initialProcess.Stdio()
+	if s.Stdin == "" {
+		return s
+	}
+
+	if c.stdinClosed.Load() {
+		log.G(ctx).Info("stdin has been closed, restoring without stdin")
+		s.Stdin = ""
+		return s
+	}
+
+	if _, err := os.Stat(s.Stdin); err != nil {
+		log.G(ctx).Infof("stdin is not available anymore, restoring without stdin: %s", err)
+		s.Stdin = ""
+	}
+	return s
+}
+
+// SetStdinClosed marks the stdin of the container as closed.
+func (c *Container) SetStdinClosed() {
+	c.stdinClosed.Store(true)
 }
 
 // restoreLoggers creates the appropriate fifos and pipes the logs to the
-// container log at s.logPath. It blocks until the logs are closed. This has
-// been adapted from internal containerd code and the logging setup should be
-// pretty much the same.
-func (c *Container) restoreLoggers(id string, stdio stdio.Stdio) error {
+// container log at c.logPath until the process closes them or the returned
+// closer is closed. This has been adapted from internal containerd code and
+// the logging setup should be pretty much the same.
+func (c *Container) restoreLoggers(id string, stdio stdio.Stdio) (io.Closer, error) {
 	fifos := cio.NewFIFOSet(cio.Config{
 		Stdin:    "",
 		Stdout:   stdio.Stdout,
@@ -112,9 +748,9 @@ func (c *Container) restoreLoggers(id string, stdio stdio.Stdio) error {
 		Terminal: false,
 	}, func() error { return nil })
 
-	stdoutWC, stderrWC, err := createContainerLoggers(c.context, c.logPath, false)
+	stdoutWC, stderrWC, err := createContainerLoggers(c.context, c.logPath, int(c.cfg.MaxLogLineSize), false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		if err != nil {
@@ -128,21 +764,48 @@ func (c *Container) restoreLoggers(id string, stdio stdio.Stdio) error {
 	}()
 	containerIO, err := crio.NewContainerIO(id, crio.WithFIFOs(fifos))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if c.cfg.LogBufferSize > 0 {
+		// the fifos have been opened by NewContainerIO, so the buffer size
+		// of the pipes is kept until the process exits.
+		for _, fifo := range []string{stdio.Stdout, stdio.Stderr} {
+			if err := setPipeSize(fifo, int(c.cfg.LogBufferSize)); err != nil {
+				log.G(c.context).Errorf("unable to set log buffer size: %s", err)
+			}
+		}
 	}
 	containerIO.AddOutput("log", stdoutWC, stderrWC)
 	containerIO.Pipe()
 
-	return nil
+	return containerIO, nil
 }
 
-func createContainerLoggers(ctx context.Context, logPath string, tty bool) (stdout io.WriteCloser, stderr io.WriteCloser, err error) {
-	// from github.com/containerd/containerd/pkg/cri/config
-	const maxContainerLogLineSize = 16 * 1024
+// setPipeSize sets the buffer size of the fifo at path. A bigger buffer
+// allows a process to keep writing logs without blocking while the logger
+// is catching up.
+func setPipeSize(path string, size int) error {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_SETPIPE_SZ, size); err != nil {
+		return fmt.Errorf("setting pipe size of %s: %w", path, err)
+	}
+	return nil
+}
 
+func createContainerLoggers(ctx context.Context, logPath string, maxLineSize int, tty bool) (stdout io.WriteCloser, stderr io.WriteCloser, err error) {
 	if logPath != "" {
-		// Only generate container log when log path is specified.
-		f, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0777)
+		// Only generate container log when log path is specified. The file
+		// is reopened if it has been rotated by the kubelet.
+		f, err := openRotatingLogFile(logPath)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create and open log file: %w", err)
 		}
@@ -153,10 +816,10 @@ func createContainerLoggers(ctx context.Context, logPath string, tty bool) (stdo
 		}()
 		var stdoutCh, stderrCh <-chan struct{}
 		wc := cioutil.NewSerialWriteCloser(f)
-		stdout, stdoutCh = crio.NewCRILogger(logPath, wc, crio.Stdout, maxContainerLogLineSize)
+		stdout, stdoutCh = crio.NewCRILogger(logPath, wc, crio.Stdout, maxLineSize)
 		// Only redirect stderr when there is no tty.
 		if !tty {
-			stderr, stderrCh = crio.NewCRILogger(logPath, wc, crio.Stderr, maxContainerLogLineSize)
+			stderr, stderrCh = crio.NewCRILogger(logPath, wc, crio.Stderr, maxLineSize)
 		}
 		go func() {
 			if stdoutCh != nil {
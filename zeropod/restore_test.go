@@ -0,0 +1,596 @@
+package zeropod
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/pkg/stdio"
+	"github.com/containerd/containerd/runtime/v2/runc"
+	"github.com/containerd/containerd/runtime/v2/runc/options"
+	"github.com/containerd/typeurl/v2"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestAddrInUse(t *testing.T) {
+	assert.True(t, addrInUse([]byte("(00.012) Error (criu/sk-inet.c:747): inet: Can't bind inet socket (id 8): Address already in use")))
+	assert.False(t, addrInUse([]byte("(00.012) Error (criu/cr-restore.c:2397): Restoring FAILED.")))
+}
+
+func TestRuntimeOptions(t *testing.T) {
+	bundle := t.TempDir()
+	opts, err := runtimeOptions(bundle)
+	require.NoError(t, err)
+	assert.Nil(t, opts, "bundle without options should use the defaults")
+
+	require.NoError(t, runc.WriteOptions(bundle, &options.Options{
+		BinaryName:    "/usr/local/bin/runc-custom",
+		SystemdCgroup: true,
+	}))
+	opts, err = runtimeOptions(bundle)
+	require.NoError(t, err)
+
+	v, err := typeurl.UnmarshalAny(opts)
+	require.NoError(t, err)
+	runcOpts, ok := v.(*options.Options)
+	require.True(t, ok)
+	assert.True(t, runcOpts.SystemdCgroup)
+	assert.Equal(t, "/usr/local/bin/runc-custom", runcOpts.BinaryName)
+}
+
+func TestWaitForPortsReleased(t *testing.T) {
+	netNS, err := ns.GetCurrentNS()
+	require.NoError(t, err)
+
+	defaultTimeout := portReleaseTimeout
+	portReleaseTimeout = time.Millisecond * 500
+	t.Cleanup(func() { portReleaseTimeout = defaultTimeout })
+
+	tests := map[string]struct {
+		releaseAfter time.Duration
+		cancel       bool
+		expectErr    bool
+	}{
+		"port is released before restore": {
+			releaseAfter: 0,
+		},
+		"port is released during wait": {
+			releaseAfter: time.Millisecond * 100,
+		},
+		"port is never released": {
+			releaseAfter: time.Second * 2,
+			expectErr:    true,
+		},
+		"restore is cancelled": {
+			releaseAfter: time.Second * 2,
+			cancel:       true,
+			expectErr:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// simulate the checkpointed process that still holds on to the
+			// port while we are restoring.
+			l, err := net.Listen("tcp4", "127.0.0.1:0")
+			require.NoError(t, err)
+			port := uint16(l.Addr().(*net.TCPAddr).Port)
+			t.Cleanup(func() { l.Close() })
+
+			timer := time.AfterFunc(tc.releaseAfter, func() { l.Close() })
+			t.Cleanup(func() { timer.Stop() })
+
+			c := &Container{
+				netNS: netNS,
+				cfg:   &Config{Ports: []uint16{port}},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				cancel()
+			}
+
+			start := time.Now()
+			err = c.waitForPortsReleased(ctx)
+			if tc.cancel {
+				assert.ErrorIs(t, err, context.Canceled)
+				assert.Less(t, time.Since(start), portReleaseTimeout, "a cancelled restore should not keep waiting")
+				return
+			}
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, time.Since(start), tc.releaseAfter)
+
+			// the restored process should be able to bind the port now
+			l2, err := net.Listen("tcp4", l.Addr().String())
+			require.NoError(t, err)
+			l2.Close()
+		})
+	}
+}
+
+func TestRebindMounts(t *testing.T) {
+	// the process stands in for the restored container, its mounts are
+	// private so they are not visible to the test.
+	cmd := exec.Command("unshare", "--mount", "--propagation", "private", "sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start process in new mount namespace: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	pid := cmd.Process.Pid
+	require.Eventually(t, func() bool {
+		self, err := os.Readlink("/proc/self/ns/mnt")
+		require.NoError(t, err)
+		ns, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "ns", "mnt"))
+		return err == nil && ns != self
+	}, time.Second, 10*time.Millisecond)
+
+	src, root := t.TempDir(), t.TempDir()
+	hosts := filepath.Join(root, "hosts")
+	hostsSource := filepath.Join(src, "etc-hosts")
+	require.NoError(t, os.WriteFile(hosts, []byte("10.0.0.1 old\n"), 0o644))
+	require.NoError(t, os.WriteFile(hostsSource, []byte("10.0.0.2 new\n"), 0o644))
+
+	mounts := []specs.Mount{{Destination: hosts, Source: hostsSource, Options: []string{"rbind", "ro"}}}
+	if err := rebindMounts(pid, mounts, []string{hosts}); errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) {
+		t.Skipf("unable to bind mount: %s", err)
+	} else {
+		require.NoError(t, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(procRoot(pid), hosts))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2 new\n", string(b), "process should see the current source")
+	b, err = os.ReadFile(hosts)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1 old\n", string(b), "mount should only be visible to the process")
+	assert.Error(t, os.WriteFile(filepath.Join(procRoot(pid), hosts), []byte("foo"), 0o644), "mount should be read-only")
+
+	// the kubelet replaces the file, the previous mount is not stacked.
+	require.NoError(t, os.Remove(hostsSource))
+	require.NoError(t, os.WriteFile(hostsSource, []byte("10.0.0.3 newer\n"), 0o644))
+	require.NoError(t, rebindMounts(pid, mounts, []string{hosts}))
+	b, err = os.ReadFile(filepath.Join(procRoot(pid), hosts))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.3 newer\n", string(b))
+	mountInfo, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "mountinfo"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(mountInfo), " "+hosts+" "))
+
+	mounts[0].Source = filepath.Join(src, "missing")
+	assert.Error(t, rebindMounts(pid, mounts, []string{hosts}))
+}
+
+func TestRestoreWithRetries(t *testing.T) {
+	restoreRetryInterval = 0
+	t.Cleanup(func() { restoreRetryInterval = time.Second })
+
+	tests := map[string]struct {
+		retries            int
+		failRestores       int
+		policy             RestoreFailurePolicy
+		expectErr          bool
+		expectedRestores   int
+		expectedColdStarts int
+	}{
+		"no retries": {
+			failRestores:     1,
+			policy:           RestoreFailurePolicyExit,
+			expectErr:        true,
+			expectedRestores: 1,
+		},
+		"succeeds after retries": {
+			retries:          2,
+			failRestores:     2,
+			policy:           RestoreFailurePolicyExit,
+			expectedRestores: 3,
+		},
+		"retries exhausted": {
+			retries:          2,
+			failRestores:     3,
+			policy:           RestoreFailurePolicyFail,
+			expectErr:        true,
+			expectedRestores: 3,
+		},
+		"cold start after retries": {
+			retries:            1,
+			failRestores:       2,
+			policy:             RestoreFailurePolicyColdStart,
+			expectedRestores:   3,
+			expectedColdStarts: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cp := &FakeCheckpointer{FailRestores: tc.failRestores}
+			c := &Container{
+				Container:         &runc.Container{ID: "foo"},
+				cfg:               &Config{RestoreRetries: tc.retries, RestoreFailurePolicy: tc.policy},
+				context:           context.Background(),
+				initialProcess:    &fakeProcess{pid: 1234},
+				checkpointer:      cp,
+				checkpointRestore: &sync.Mutex{},
+				scaledDown:        true,
+			}
+
+			c.checkpointRestore.Lock()
+			_, _, _, err := c.restoreWithRetries(context.Background())
+			c.checkpointRestore.Unlock()
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			_, _, restores := cp.Calls()
+			assert.Equal(t, tc.expectedRestores, restores)
+			assert.Equal(t, tc.expectedColdStarts, cp.ColdStarts())
+			if !tc.expectErr {
+				assert.Equal(t, tc.expectedColdStarts != 0, c.startedFresh, "cold start command should only run after a cold start")
+			}
+		})
+	}
+}
+
+func TestRestoreRetryReleasesLock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, cp := newFakeContainer(t, &Config{
+		ScaleDownDuration:   time.Minute,
+		Ports:               []uint16{80},
+		RestoreRetries:      1,
+		RestoreRetryBackoff: time.Minute,
+	})
+	require.NoError(t, c.ForceScaleDown(ctx))
+	cp.FailRestores = 1
+
+	done := make(chan error)
+	go func() {
+		_, _, err := c.Restore(ctx)
+		done <- err
+	}()
+
+	lock := c.checkpointRestore.(*sync.Mutex)
+	assert.Eventually(t, func() bool {
+		_, _, restores := cp.Calls()
+		if restores == 0 || !lock.TryLock() {
+			return false
+		}
+		lock.Unlock()
+		return true
+	}, time.Second, time.Millisecond*10, "lock should be released while waiting for the retry")
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("cancelled restore should stop retrying")
+	}
+	_, _, restores := cp.Calls()
+	assert.Equal(t, 1, restores)
+	assert.True(t, c.ScaledDown())
+}
+
+func TestRestoreTimeout(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{
+		ScaleDownDuration: time.Minute,
+		Ports:             []uint16{80},
+		RestoreTimeout:    50 * time.Millisecond,
+	})
+	require.NoError(t, c.ForceScaleDown(ctx))
+	cp.BlockRestores = true
+
+	done := make(chan error)
+	go func() {
+		_, _, err := c.Restore(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrRestoreTimeout)
+	case <-time.After(time.Second):
+		t.Fatal("restore should be aborted once the timeout is reached")
+	}
+	assert.Equal(t, 1, cp.Cleanups(), "aborted restore should be cleaned up")
+	assert.True(t, c.ScaledDown())
+	assert.Equal(t, 1234, c.Process().Pid())
+
+	cp.BlockRestores = false
+	_, _, err := c.Restore(ctx)
+	require.NoError(t, err, "container should be restored again after the cleanup")
+	assert.False(t, c.ScaledDown())
+}
+
+func TestRestoreRetryOfStoppedContainer(t *testing.T) {
+	ctx := context.Background()
+	c, cp := newFakeContainer(t, &Config{
+		ScaleDownDuration:   time.Minute,
+		Ports:               []uint16{80},
+		RestoreRetries:      1,
+		RestoreRetryBackoff: 100 * time.Millisecond,
+	})
+	require.NoError(t, c.ForceScaleDown(ctx))
+	cp.FailRestores = 1
+
+	done := make(chan error)
+	go func() {
+		_, _, err := c.Restore(ctx)
+		done <- err
+	}()
+
+	// kill the scaled down container while the restore waits for the retry,
+	// the same way the task service does.
+	lock := c.checkpointRestore.(*sync.Mutex)
+	require.Eventually(t, func() bool {
+		_, _, restores := cp.Calls()
+		if restores == 0 || !lock.TryLock() {
+			return false
+		}
+		c.Stop(ctx)
+		lock.Unlock()
+		return true
+	}, time.Second, time.Millisecond*10)
+
+	assert.ErrorIs(t, <-done, ErrContainerStopped)
+	_, _, restores := cp.Calls()
+	assert.Equal(t, 1, restores, "stopped container should not be restored")
+	_, _, err := c.Restore(ctx)
+	assert.ErrorIs(t, err, ErrContainerStopped)
+}
+
+func TestRestoreClosesLoggersOfFailedAttempts(t *testing.T) {
+	restoreRetryInterval = 0
+	t.Cleanup(func() { restoreRetryInterval = time.Second })
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	p := &fakeProcess{pid: 1234, stdio: stdio.Stdio{
+		Stdout: filepath.Join(dir, "stdout"),
+		Stderr: filepath.Join(dir, "stderr"),
+	}}
+	cp := &FakeCheckpointer{Container: &runc.Container{ID: "foo"}, Process: &fakeProcess{pid: 5678}}
+	c := NewFakeContainer(ctx, &Config{ScaleDownDuration: time.Minute, Ports: []uint16{80}, RestoreRetries: 2}, &sync.Mutex{}, p, cp)
+	t.Cleanup(c.CancelScaleDown)
+	c.logPath = filepath.Join(dir, "container.log")
+
+	require.NoError(t, c.ForceScaleDown(ctx))
+	cp.FailRestores = 2
+	_, _, err := c.Restore(ctx)
+	require.NoError(t, err)
+	_, _, restores := cp.Calls()
+	assert.Equal(t, 3, restores)
+
+	assert.Eventually(t, func() bool {
+		return openFiles(t, c.logPath) == 1
+	}, time.Second, time.Millisecond*10, "only the logger of the successful attempt should be active")
+
+	t.Cleanup(func() {
+		// close the fifos so the remaining logger stops.
+		for _, fifo := range []string{p.stdio.Stdout, p.stdio.Stderr} {
+			if f, err := os.OpenFile(fifo, os.O_WRONLY|unix.O_NONBLOCK, 0); err == nil {
+				f.Close()
+			}
+		}
+	})
+}
+
+// openFiles returns the number of file descriptors of the current process
+// that refer to path.
+func openFiles(t *testing.T, path string) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	require.NoError(t, err)
+	n := 0
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && target == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRestoreWithoutCheckpointImages(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err                error
+		expectErr          bool
+		expectedColdStarts int
+	}{
+		"checkpoint missing": {
+			err:                fmt.Errorf("%w: inventory.img not found", ErrCheckpointMissing),
+			expectedColdStarts: 1,
+		},
+		"bundle incomplete": {
+			err:       fmt.Errorf("%w: config.json not found", ErrBundleIncomplete),
+			expectErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cp := &FakeCheckpointer{RestoreErr: tc.err}
+			c := &Container{
+				Container:      &runc.Container{ID: "foo"},
+				context:        context.Background(),
+				cfg:            &Config{RestoreRetries: 2, RestoreFailurePolicy: RestoreFailurePolicyExit},
+				initialProcess: &fakeProcess{pid: 1234},
+				checkpointer:   cp,
+			}
+
+			_, _, _, err := c.restoreWithRetries(context.Background())
+			if tc.expectErr {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedColdStarts, cp.ColdStarts())
+			_, _, restores := cp.Calls()
+			assert.Equal(t, 1+tc.expectedColdStarts, restores, "restore should not be retried")
+		})
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	bundle := t.TempDir()
+	assert.ErrorIs(t, validateBundle(bundle, false), ErrBundleIncomplete)
+
+	b, err := json.Marshal(&specs.Spec{Root: &specs.Root{Path: "rootfs"}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(bundle, "config.json"), b, 0o644))
+	assert.ErrorIs(t, validateBundle(bundle, false), ErrBundleIncomplete, "missing rootfs")
+
+	require.NoError(t, os.Mkdir(filepath.Join(bundle, "rootfs"), 0o755))
+	assert.ErrorIs(t, validateBundle(bundle, false), ErrBundleIncomplete, "unmounted rootfs")
+
+	require.NoError(t, os.Mkdir(filepath.Join(bundle, "rootfs", "bin"), 0o755))
+	assert.NoError(t, validateBundle(bundle, false))
+	assert.ErrorIs(t, validateBundle(bundle, true), ErrCheckpointMissing)
+
+	require.NoError(t, os.MkdirAll(containerDir(bundle), 0o755))
+	for _, image := range requiredCheckpointImages {
+		require.NoError(t, os.WriteFile(filepath.Join(containerDir(bundle), image), []byte("image"), 0o644))
+	}
+	assert.NoError(t, validateBundle(bundle, true))
+}
+
+func TestRestoreFailedMarksContainerFailed(t *testing.T) {
+	failed := false
+	c := &Container{
+		Container: &runc.Container{ID: "foo"},
+		cfg:       &Config{RestoreFailurePolicy: RestoreFailurePolicyFail},
+	}
+	c.RegisterFail(func(context.Context) { failed = true })
+
+	c.RestoreFailed(context.Background(), errFakeRestore)
+	assert.True(t, failed)
+}
+
+func TestRestoreFailedKeepsContainerScaledDown(t *testing.T) {
+	failed := false
+	c := &Container{
+		Container:  &runc.Container{ID: "foo"},
+		cfg:        &Config{RestoreFailurePolicy: RestoreFailurePolicyRetry},
+		scaledDown: true,
+	}
+	c.RegisterFail(func(context.Context) { failed = true })
+
+	c.RestoreFailed(context.Background(), errFakeRestore)
+	assert.False(t, failed)
+	assert.True(t, c.ScaledDown(), "container should stay scaled down to restore on the next connection")
+}
+
+func TestRestoreRetryDelay(t *testing.T) {
+	tests := map[string]struct {
+		backoff  time.Duration
+		expected []time.Duration
+	}{
+		"fixed interval": {
+			expected: []time.Duration{restoreRetryInterval, restoreRetryInterval, restoreRetryInterval},
+		},
+		"backoff": {
+			backoff:  time.Millisecond * 100,
+			expected: []time.Duration{time.Millisecond * 100, time.Millisecond * 200, time.Millisecond * 400},
+		},
+		"capped backoff": {
+			backoff:  time.Second * 40,
+			expected: []time.Duration{time.Second * 40, maxRestoreRetryBackoff, maxRestoreRetryBackoff},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Container{cfg: &Config{RestoreRetryBackoff: tc.backoff}}
+			for attempt, expected := range tc.expected {
+				assert.Equal(t, expected, c.restoreRetryDelay(attempt))
+			}
+		})
+	}
+}
+
+func TestRestoreStdio(t *testing.T) {
+	stdin := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, os.WriteFile(stdin, nil, 0o644))
+
+	tests := map[string]struct {
+		stdin         string
+		stdinClosed   bool
+		expectedStdin string
+	}{
+		"attached": {
+			stdin:         stdin,
+			expectedStdin: stdin,
+		},
+		"detached without stdin": {
+			stdin:         "",
+			expectedStdin: "",
+		},
+		"detached with closed stdin": {
+			stdin:         stdin,
+			stdinClosed:   true,
+			expectedStdin: "",
+		},
+		"detached with removed stdin fifo": {
+			stdin:         filepath.Join(t.TempDir(), "removed"),
+			expectedStdin: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Container{initialProcess: &fakeProcess{stdio: stdio.Stdio{
+				Stdin:  tc.stdin,
+				Stdout: "stdout",
+				Stderr: "stderr",
+			}}}
+			if tc.stdinClosed {
+				c.SetStdinClosed()
+			}
+
+			s := c.restoreStdio(context.Background())
+			assert.Equal(t, tc.expectedStdin, s.Stdin)
+			assert.Equal(t, "stdout", s.Stdout, "stdout should be kept")
+			assert.Equal(t, "stderr", s.Stderr, "stderr should be kept")
+		})
+	}
+}
+
+func TestSetPipeSize(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "stdout")
+	require.NoError(t, unix.Mkfifo(fifo, 0o600))
+
+	// keep the fifo open the same way the logger does.
+	f, err := os.OpenFile(fifo, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+
+	size := 256 * 1024
+	require.NoError(t, setPipeSize(fifo, size))
+
+	actual, err := unix.FcntlInt(f.Fd(), unix.F_GETPIPE_SZ, 0)
+	require.NoError(t, err)
+	assert.Equal(t, size, actual)
+
+	assert.NoError(t, setPipeSize("", size), "missing fifo should be ignored")
+}
@@ -0,0 +1,215 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/containerd/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// EnvRestoreCPUBudget can be set on the shim to limit the CPU time that
+	// restores of all shims on the node may consume per minute (e.g. "30s").
+	// Restores beyond the budget wait until it has been refilled.
+	EnvRestoreCPUBudget = "ZEROPOD_RESTORE_CPU_BUDGET"
+
+	restoreBudgetPeriod   = time.Minute
+	restoreBudgetInterval = 100 * time.Millisecond
+)
+
+var (
+	// restoreBudgetFile contains the state of the budget. As every pod has
+	// its own shim, the budget is shared through the filesystem of the node.
+	restoreBudgetFile  = "/run/zeropod/restore-budget"
+	restoreBudgetLimit = restoreBudgetFromEnv()
+)
+
+// restoreBudget is a token bucket of CPU time for restores of all shims on
+// the node. It holds up to budget and is refilled by budget per period. The
+// tokens and the time of the last refill are stored in a file which is
+// locked with an exclusive flock while it's updated.
+type restoreBudget struct {
+	path   string
+	budget time.Duration
+	// utilization of the budget as seen by the last update, stored as the
+	// bits of a float64.
+	utilization atomic.Uint64
+}
+
+func restoreBudgetFromEnv() *restoreBudget {
+	value := os.Getenv(EnvRestoreCPUBudget)
+	if value == "" {
+		return nil
+	}
+
+	budget, err := time.ParseDuration(value)
+	if err != nil || budget <= 0 {
+		log.L.Warnf("ignoring invalid %s %q, it needs to be a positive duration", EnvRestoreCPUBudget, value)
+		return nil
+	}
+
+	return &restoreBudget{path: restoreBudgetFile, budget: budget}
+}
+
+// acquire waits until there is budget left for a restore.
+func (b *restoreBudget) acquire(ctx context.Context) error {
+	logged := false
+	for {
+		tokens, err := b.update(func(tokens time.Duration) time.Duration { return tokens })
+		if err != nil {
+			return err
+		}
+		if tokens > 0 {
+			return nil
+		}
+
+		if !logged {
+			log.G(ctx).Infof("restore cpu budget of %s per %s is exhausted, waiting for it to refill", b.budget, restoreBudgetPeriod)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(restoreBudgetInterval):
+		}
+	}
+}
+
+// consume takes the CPU time used by a restore from the budget. As the cost
+// of a restore is only known afterwards, the budget can go into debt, which
+// is limited to one full budget.
+func (b *restoreBudget) consume(cost time.Duration) error {
+	_, err := b.update(func(tokens time.Duration) time.Duration {
+		return max(tokens-cost, -b.budget)
+	})
+	return err
+}
+
+// update refills the budget according to the time passed since the last
+// refill, applies fn to the tokens and stores the result.
+func (b *restoreBudget) update(fn func(time.Duration) time.Duration) (time.Duration, error) {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return 0, fmt.Errorf("creating restore budget dir: %w", err)
+	}
+
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("opening restore budget: %w", err)
+	}
+	defer f.Close()
+
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return 0, fmt.Errorf("locking restore budget: %w", err)
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return 0, fmt.Errorf("reading restore budget: %w", err)
+	}
+
+	now := time.Now()
+	tokens, refilled, ok := parseRestoreBudget(string(content))
+	if !ok {
+		// the budget is full if it has not been used yet.
+		tokens, refilled = b.budget, now
+	}
+	if elapsed := now.Sub(refilled); elapsed > 0 {
+		refill := time.Duration(float64(b.budget) * elapsed.Seconds() / restoreBudgetPeriod.Seconds())
+		tokens = min(tokens+refill, b.budget)
+	}
+	tokens = fn(tokens)
+
+	if err := f.Truncate(0); err != nil {
+		return 0, fmt.Errorf("writing restore budget: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%d %d", tokens, now.UnixNano())), 0); err != nil {
+		return 0, fmt.Errorf("writing restore budget: %w", err)
+	}
+
+	b.utilization.Store(math.Float64bits(1 - float64(tokens)/float64(b.budget)))
+	return tokens, nil
+}
+
+// parseRestoreBudget parses the content of the restore budget file, which
+// consists of the tokens and the unix time of the last refill in nanoseconds.
+func parseRestoreBudget(content string) (time.Duration, time.Time, bool) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, time.Time{}, false
+	}
+	tokens, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	refilled, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return time.Duration(tokens), time.Unix(0, refilled), true
+}
+
+// childrenCPUTime returns the user and system CPU time of all children of
+// the shim that have exited. The CPU time of CRIU is included as runc waits
+// for it and the shim waits for runc.
+func childrenCPUTime() time.Duration {
+	var usage unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_CHILDREN, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// waitForRestoreBudget waits until the restore of the container is allowed
+// to run and records the time spent waiting. If no budget is configured, it
+// returns immediately.
+func (c *Container) waitForRestoreBudget(ctx context.Context) error {
+	if restoreBudgetLimit == nil {
+		return nil
+	}
+
+	before := time.Now()
+	if err := restoreBudgetLimit.acquire(ctx); err != nil {
+		return fmt.Errorf("waiting for restore budget: %w", err)
+	}
+	restoreBudgetWait.With(c.labels()).Observe(time.Since(before).Seconds())
+	return nil
+}
+
+// measureRestoreCPU returns a func that takes the CPU time used since it was
+// called from the restore budget. The CPU time is approximated by the CPU
+// time of the children of the shim, so other children that exit in the
+// meantime (e.g. execs) are accounted to the restore as well.
+func measureRestoreCPU(ctx context.Context) func() {
+	if restoreBudgetLimit == nil {
+		return func() {}
+	}
+
+	before := childrenCPUTime()
+	return func() {
+		cost := childrenCPUTime() - before
+		if err := restoreBudgetLimit.consume(cost); err != nil {
+			log.G(ctx).Warnf("unable to consume restore budget: %s", err)
+			return
+		}
+		log.G(ctx).Debugf("restore used %s of the restore cpu budget", cost)
+	}
+}
+
+// restoreBudgetUtilization returns the utilization of the restore budget
+// between 0 and 1, or more if it's in debt.
+func restoreBudgetUtilization() float64 {
+	if restoreBudgetLimit == nil {
+		return 0
+	}
+	return math.Float64frombits(restoreBudgetLimit.utilization.Load())
+}
@@ -0,0 +1,50 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreBudget(t *testing.T) {
+	ctx := context.Background()
+	budget := &restoreBudget{path: filepath.Join(t.TempDir(), "restore-budget"), budget: time.Second}
+
+	// an unused budget is full.
+	require.NoError(t, budget.acquire(ctx))
+	assert.InDelta(t, 0, math.Float64frombits(budget.utilization.Load()), 0.01)
+
+	// the debt is limited to one full budget.
+	require.NoError(t, budget.consume(time.Second*5))
+	assert.InDelta(t, 2, math.Float64frombits(budget.utilization.Load()), 0.01)
+
+	// the budget is shared with other shims through the file.
+	other := &restoreBudget{path: budget.path, budget: time.Second}
+	ctx, cancel := context.WithTimeout(ctx, restoreBudgetInterval*2)
+	defer cancel()
+	assert.ErrorIs(t, other.acquire(ctx), context.DeadlineExceeded)
+}
+
+func TestRestoreBudgetRefill(t *testing.T) {
+	budget := &restoreBudget{path: filepath.Join(t.TempDir(), "restore-budget"), budget: time.Second}
+
+	refilled := time.Now().Add(-restoreBudgetPeriod / 2)
+	require.NoError(t, os.WriteFile(budget.path, []byte(fmt.Sprintf("%d %d", -time.Second, refilled.UnixNano())), 0o644))
+	tokens, err := budget.update(func(tokens time.Duration) time.Duration { return tokens })
+	require.NoError(t, err)
+	assert.InDelta(t, -time.Second/2, tokens, float64(time.Millisecond*10))
+
+	// the budget never grows beyond its size.
+	refilled = time.Now().Add(-restoreBudgetPeriod * 10)
+	require.NoError(t, os.WriteFile(budget.path, []byte(fmt.Sprintf("%d %d", 0, refilled.UnixNano())), 0o644))
+	tokens, err = budget.update(func(tokens time.Duration) time.Duration { return tokens })
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, tokens)
+}
@@ -0,0 +1,52 @@
+package zeropod
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/containerd/log"
+)
+
+const restoreHealthCheckInterval = 100 * time.Millisecond
+
+// restoreHealthCheckTimeout is the time a restored container has to pass the
+// restore health check.
+var restoreHealthCheckTimeout = 30 * time.Second
+
+// checkRestoreHealth probes the restored container until the restore health
+// check succeeds and counts the restore as healthy. A process that has been
+// restored but crashes or hangs right after never does, which makes it
+// distinguishable from a restore that fully succeeded. The check stops without
+// a result once the restore with the generation gen is not the last one
+// anymore, so it does not overwrite the result of a newer restore.
+func (c *Container) checkRestoreHealth(gen uint64) {
+	labels := c.labels()
+	deadline := time.Now().Add(restoreHealthCheckTimeout)
+	for {
+		healthy, err := c.cfg.RestoreHealthCheck.ready(c.context, c.netNS)
+		if err != nil {
+			log.G(c.context).Debugf("restore health check failed: %s", err)
+		}
+		if !c.isLastRestore(gen) {
+			return
+		}
+		if healthy {
+			restoresHealthy.With(labels).Inc()
+			return
+		}
+
+		if time.Now().After(deadline) {
+			err := fmt.Errorf("restored container did not become healthy within %s", restoreHealthCheckTimeout)
+			if !c.setRestoreError(gen, err) {
+				return
+			}
+			log.G(c.context).Warn(err)
+			c.sendEvent(c.Status())
+			return
+		}
+		if !c.Exists() || c.ScaledDown() {
+			return
+		}
+		time.Sleep(restoreHealthCheckInterval)
+	}
+}
@@ -0,0 +1,106 @@
+package zeropod
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/log"
+)
+
+const pagesImagePattern = "pages-*.img"
+
+var restoreProgressInterval = 5 * time.Second
+
+// trackRestoreProgress marks the container as being restored in its status
+// and logs that the restore is still in progress every
+// restoreProgressInterval, so a slow restore can be told apart from a stuck
+// one. The returned func needs to be called once the restore is done.
+func (c *Container) trackRestoreProgress(ctx context.Context) func() {
+	startedAt := time.Now()
+	pages := imagesSize(pagesImagePattern, containerDir(c.Bundle), preDumpDir(c.Bundle))
+	c.setRestoreProgress(startedAt, pages)
+	c.sendEvent(c.Status())
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(restoreProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.G(ctx).Infof("restore in progress for %s, restoring %d bytes of memory pages",
+					time.Since(startedAt).Round(time.Second), pages)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		c.setRestoreProgress(time.Time{}, 0)
+	}
+}
+
+func (c *Container) setRestoreProgress(startedAt time.Time, pages int64) {
+	c.restoreStatusMu.Lock()
+	defer c.restoreStatusMu.Unlock()
+	c.restoreStartedAt, c.restoreImagesSizeBytes = startedAt, pages
+}
+
+// setRestoreResult records the time and the error of the finished restore
+// and returns its generation.
+func (c *Container) setRestoreResult(err error) uint64 {
+	c.restoreStatusMu.Lock()
+	defer c.restoreStatusMu.Unlock()
+	c.restoreGen++
+	c.lastRestoreAt = time.Now()
+	c.lastRestoreError = ""
+	if err != nil {
+		c.lastRestoreError = err.Error()
+	}
+	return c.restoreGen
+}
+
+// setRestoreError records err as the error of the restore with the supplied
+// generation. It returns false without recording it if there has been
+// another restore since.
+func (c *Container) setRestoreError(gen uint64, err error) bool {
+	c.restoreStatusMu.Lock()
+	defer c.restoreStatusMu.Unlock()
+	if gen != c.restoreGen {
+		return false
+	}
+	c.lastRestoreError = err.Error()
+	return true
+}
+
+func (c *Container) isLastRestore(gen uint64) bool {
+	c.restoreStatusMu.Lock()
+	defer c.restoreStatusMu.Unlock()
+	return gen == c.restoreGen
+}
+
+// imagesSize returns the size of the regular files below dirs whose name
+// matches pattern. Missing dirs and files that can't be read are skipped as
+// the size is only informational.
+func imagesSize(pattern string, dirs ...string) int64 {
+	size := int64(0)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if match, _ := filepath.Match(pattern, entry.Name()); !match || !entry.Type().IsRegular() {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+			return nil
+		})
+	}
+	return size
+}
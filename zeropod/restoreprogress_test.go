@@ -0,0 +1,51 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/runtime/v2/runc"
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreProgress(t *testing.T) {
+	bundle := t.TempDir()
+	require.NoError(t, os.MkdirAll(containerDir(bundle), 0o755))
+	require.NoError(t, os.MkdirAll(preDumpDir(bundle), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(containerDir(bundle), "pages-1.img"), make([]byte, 4096), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(containerDir(bundle), "pagemap-1.img"), make([]byte, 512), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(preDumpDir(bundle), "pages-1.img"), make([]byte, 1024), 0o644))
+
+	events := make(chan *v1.ContainerStatus, 1)
+	c := &Container{
+		Container:  &runc.Container{ID: "foo", Bundle: bundle},
+		context:    context.Background(),
+		cfg:        &Config{},
+		scaledDown: true,
+		events:     events,
+	}
+
+	done := c.trackRestoreProgress(context.Background())
+	status := <-events
+	assert.NotNil(t, status.RestoreStartedAt)
+	assert.Equal(t, int64(5120), status.RestoreImagesSizeBytes)
+	assert.Equal(t, status.RestoreStartedAt, c.Status().RestoreStartedAt)
+
+	done()
+	assert.Nil(t, c.Status().RestoreStartedAt)
+	assert.Zero(t, c.Status().RestoreImagesSizeBytes)
+}
+
+func TestImagesSize(t *testing.T) {
+	bundle := t.TempDir()
+	require.NoError(t, os.MkdirAll(containerDir(bundle), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(containerDir(bundle), "pages-1.img"), make([]byte, 4096), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(containerDir(bundle), "pagemap-1.img"), make([]byte, 512), 0o644))
+
+	assert.Equal(t, int64(4096), imagesSize(pagesImagePattern, containerDir(bundle), preDumpDir(bundle)), "missing dirs should be skipped")
+	assert.Equal(t, int64(4608), imagesSize("*.img", containerDir(bundle)))
+}
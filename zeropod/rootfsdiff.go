@@ -0,0 +1,162 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/containerd/containerd/archive"
+	"github.com/containerd/containerd/mount"
+	cfs "github.com/containerd/continuity/fs"
+	"github.com/containerd/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	rootfsDiffFile    = "rootfs-diff.tar"
+	overlayFSType     = "overlay"
+	overlayUpperDir   = "upperdir="
+	overlayOpaqueAttr = "trusted.overlay.opaque"
+)
+
+// rootfsDiffPath returns the path of the archive containing the changes of
+// the writable layer of the container at checkpoint time.
+func rootfsDiffPath(bundle string) string {
+	return filepath.Join(snapshotDir(bundle), rootfsDiffFile)
+}
+
+// saveRootfsDiff writes the changes the container made to its rootfs to the
+// snapshot dir, so they can be applied again if the writable layer has been
+// reset by the time the container is restored.
+func saveRootfsDiff(ctx context.Context, bundle string) error {
+	upper, err := upperDir(filepath.Join(bundle, "rootfs"))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(rootfsDiffPath(bundle))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := writeOverlayDiff(upper, f); err != nil {
+		return fmt.Errorf("writing rootfs diff: %w", err)
+	}
+	log.G(ctx).Infof("saved rootfs diff of %s", upper)
+
+	return f.Close()
+}
+
+// restoreRootfsDiff applies the saved rootfs diff to the rootfs of the
+// container if its writable layer is empty. A writable layer that still has
+// contents has not been reset and already contains the changes.
+func restoreRootfsDiff(ctx context.Context, bundle string) error {
+	f, err := os.Open(rootfsDiffPath(bundle))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rootfs := filepath.Join(bundle, "rootfs")
+	upper, err := upperDir(rootfs)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(upper)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 0 {
+		return nil
+	}
+
+	log.G(ctx).Infof("writable layer of the container has been reset, applying rootfs diff")
+	if _, err := archive.Apply(ctx, rootfs, f); err != nil {
+		return fmt.Errorf("applying rootfs diff: %w", err)
+	}
+	return nil
+}
+
+// upperDir returns the upper dir of the overlay mounted at rootfs.
+func upperDir(rootfs string) (string, error) {
+	info, err := mount.Lookup(rootfs)
+	if err != nil {
+		return "", err
+	}
+	if info.Mountpoint != filepath.Clean(rootfs) || info.FSType != overlayFSType {
+		return "", fmt.Errorf("rootfs %s is not an overlay mount", rootfs)
+	}
+
+	for _, opt := range strings.Split(info.VFSOptions, ",") {
+		if dir, ok := strings.CutPrefix(opt, overlayUpperDir); ok {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("overlay at %s does not have an upper dir", rootfs)
+}
+
+// writeOverlayDiff writes the contents of the overlay upper dir as a tar
+// archive to w. Overlay whiteouts are converted to the whiteout files used
+// by OCI layers, so the archive can be applied through the overlay mount.
+func writeOverlayDiff(upper string, w io.Writer) error {
+	cw := archive.NewChangeWriter(w, upper)
+	err := filepath.WalkDir(upper, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == upper {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		p := "/" + strings.TrimPrefix(path, upper+string(os.PathSeparator))
+
+		if isOverlayWhiteout(info) {
+			return cw.HandleChange(cfs.ChangeKindDelete, p, nil, nil)
+		}
+
+		if info.IsDir() && isOverlayOpaque(path) {
+			// the contents of the lower layers are hidden, so the dir
+			// is removed before it's added again.
+			if err := cw.HandleChange(cfs.ChangeKindDelete, p, nil, nil); err != nil {
+				return err
+			}
+		}
+
+		return cw.HandleChange(cfs.ChangeKindAdd, p, info, nil)
+	})
+	if err != nil {
+		cw.Close()
+		return err
+	}
+
+	return cw.Close()
+}
+
+// isOverlayWhiteout returns true if the file marks a deleted file in an
+// overlay upper dir, which is a character device with device number 0/0.
+func isOverlayWhiteout(info fs.FileInfo) bool {
+	if info.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+func isOverlayOpaque(path string) bool {
+	buf := make([]byte, 1)
+	n, err := unix.Lgetxattr(path, overlayOpaqueAttr, buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
@@ -0,0 +1,53 @@
+package zeropod
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestOverlayDiff(t *testing.T) {
+	upper, root := t.TempDir(), t.TempDir()
+
+	// the rootfs as seen through the lower layers.
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "etc/deleted"), []byte("lower"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cache"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cache/old"), []byte("lower"), 0o644))
+
+	// the changes of the container in the upper dir.
+	require.NoError(t, os.MkdirAll(filepath.Join(upper, "etc"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(upper, "etc/added"), []byte("upper"), 0o644))
+	if err := unix.Mknod(filepath.Join(upper, "etc/deleted"), unix.S_IFCHR, 0); err != nil {
+		t.Skipf("unable to create whiteout: %s", err)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(upper, "cache"), 0o755))
+	if err := unix.Setxattr(filepath.Join(upper, "cache"), overlayOpaqueAttr, []byte("y"), 0); err != nil {
+		t.Skipf("unable to mark dir as opaque: %s", err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(upper, "cache/new"), []byte("upper"), 0o644))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeOverlayDiff(upper, buf))
+	_, err := archive.Apply(context.Background(), root, buf)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join(root, "etc/added"))
+	require.NoError(t, err)
+	assert.Equal(t, "upper", string(b))
+	b, err = os.ReadFile(filepath.Join(root, "cache/new"))
+	require.NoError(t, err)
+	assert.Equal(t, "upper", string(b))
+
+	for _, removed := range []string{"etc/deleted", "cache/old"} {
+		_, err := os.Stat(filepath.Join(root, removed))
+		assert.True(t, os.IsNotExist(err), "%s should have been removed", removed)
+	}
+}
@@ -0,0 +1,82 @@
+package zeropod
+
+import (
+	"sync"
+	"time"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// causes of scale events other than the scale down trigger.
+const (
+	ScaleEventCauseConnection = "connection"
+	ScaleEventCauseCooldown   = "redirect-cooldown"
+	ScaleEventCauseDiskWrites = "disk-writes"
+	ScaleEventCauseExec       = "exec"
+	ScaleEventCauseForced     = "forced"
+	ScaleEventCauseKillSwitch = "kill-switch"
+	ScaleEventCauseMinMemory  = "min-memory"
+	ScaleEventCauseSibling    = "sibling"
+	ScaleEventCauseSignal     = "signal"
+	ScaleEventCauseStartup    = "startup-grace"
+	ScaleEventCauseStop       = "stop"
+)
+
+// scaleEventHistory retains the most recent scale events of a container in a
+// ring buffer.
+type scaleEventHistory struct {
+	mu     sync.Mutex
+	events []*v1.ScaleEvent
+	next   int
+}
+
+func (h *scaleEventHistory) add(size int, event *v1.ScaleEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.events) < size {
+		h.events = append(h.events, event)
+		return
+	}
+
+	h.events[h.next] = event
+	h.next = (h.next + 1) % len(h.events)
+}
+
+// list returns the retained events, oldest first.
+func (h *scaleEventHistory) list() []*v1.ScaleEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append(append([]*v1.ScaleEvent{}, h.events[h.next:]...), h.events[:h.next]...)
+}
+
+// RecordScaleEvent adds a scale event to the history of the container, if
+// the event history is enabled, and sends it to the event sink of the shim.
+func (c *Container) RecordScaleEvent(typ v1.ScaleEventType, cause, message string) {
+	event := &v1.ScaleEvent{
+		Time:    timestamppb.New(time.Now()),
+		Type:    typ,
+		Cause:   cause,
+		Message: message,
+	}
+	c.publishScaleEvent(event)
+
+	if c.cfg.ScaleEventHistorySize <= 0 {
+		return
+	}
+	c.scaleEvents.add(c.cfg.ScaleEventHistorySize, event)
+}
+
+// ScaleEvents returns the most recent scale events of the container, oldest
+// first.
+func (c *Container) ScaleEvents() []*v1.ScaleEvent {
+	return c.scaleEvents.list()
+}
+
+// scaleDownCause returns the cause of scheduled scale downs.
+func (c *Container) scaleDownCause() string {
+	if c.cfg.ScaleDownTrigger == "" {
+		return string(ScaleDownTriggerIdleTimer)
+	}
+	return string(c.cfg.ScaleDownTrigger)
+}
@@ -0,0 +1,28 @@
+package zeropod
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "github.com/ctrox/zeropod/api/shim/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaleEvents(t *testing.T) {
+	c := &Container{cfg: &Config{}}
+	c.RecordScaleEvent(v1.ScaleEventType_SCALE_DOWN, ScaleEventCauseForced, "")
+	assert.Empty(t, c.ScaleEvents(), "events should not be recorded if the history is disabled")
+
+	c.cfg.ScaleEventHistorySize = 3
+	for i := 0; i < 5; i++ {
+		c.RecordScaleEvent(v1.ScaleEventType_RESTORE, ScaleEventCauseConnection, fmt.Sprint(i))
+	}
+
+	messages := []string{}
+	for _, event := range c.ScaleEvents() {
+		messages = append(messages, event.Message)
+		assert.Equal(t, ScaleEventCauseConnection, event.Cause)
+		assert.NotNil(t, event.Time)
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, messages, "only the most recent events should be retained, oldest first")
+}
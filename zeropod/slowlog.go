@@ -0,0 +1,40 @@
+package zeropod
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/containerd/log"
+)
+
+// EnvSlowOperationThreshold can be set on the shim to a duration (e.g. 2s).
+// Checkpoints and restores that take longer are logged as a warning along
+// with the identity of the container. By default, nothing is logged.
+const EnvSlowOperationThreshold = "ZEROPOD_SLOW_OPERATION_THRESHOLD"
+
+var slowOperationThreshold = parseSlowOperationThreshold(os.Getenv(EnvSlowOperationThreshold))
+
+func parseSlowOperationThreshold(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold <= 0 {
+		log.L.Warnf("ignoring invalid %s %q, it needs to be a positive duration", EnvSlowOperationThreshold, value)
+		return 0
+	}
+	return threshold
+}
+
+// logIfSlow logs a warning if the operation (checkpoint or restore) of the
+// container took longer than the slow operation threshold.
+func (c *Container) logIfSlow(ctx context.Context, operation string, took time.Duration) {
+	if slowOperationThreshold == 0 || took < slowOperationThreshold {
+		return
+	}
+
+	log.G(ctx).Warnf("slow %s of container %s (%s) in pod %s/%s took %s, exceeding the threshold of %s",
+		operation, c.cfg.ContainerName, c.ID(), c.cfg.PodNamespace, c.cfg.PodName, took, slowOperationThreshold)
+}
@@ -0,0 +1,20 @@
+package zeropod
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSlowOperationThreshold(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"":    0,
+		"2s":  2 * time.Second,
+		"0s":  0,
+		"-1s": 0,
+		"foo": 0,
+	} {
+		assert.Equal(t, expected, parseSlowOperationThreshold(value), "value %q", value)
+	}
+}
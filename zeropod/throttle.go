@@ -0,0 +1,126 @@
+package zeropod
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups"
+	cgroupsv2 "github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/containerd/log"
+)
+
+const (
+	// EnvCRIUCPUMax can be set on the shim to limit the CPU usage of CRIU
+	// during checkpoint and restore. The value is written to cpu.max of the
+	// cgroup CRIU runs in (e.g. "50000 100000" for half a CPU).
+	EnvCRIUCPUMax = "ZEROPOD_CRIU_CPU_MAX"
+	// EnvCRIUIOMax can be set on the shim to limit the IO of CRIU during
+	// checkpoint and restore. The value is a semicolon-delimited list of
+	// entries that are written to io.max of the cgroup CRIU runs in (e.g.
+	// "8:0 wbps=10485760;8:16 wbps=10485760").
+	EnvCRIUIOMax = "ZEROPOD_CRIU_IO_MAX"
+
+	criuCgroupName = "zeropod-criu"
+	cgroupProcs    = "cgroup.procs"
+	cgroupCPUMax   = "cpu.max"
+	cgroupIOMax    = "io.max"
+	ioMaxDelim     = ";"
+)
+
+var criuLimits = criuThrottleFromEnv()
+
+// criuThrottle limits the resources of CRIU by running it in a dedicated
+// cgroup. As runc and CRIU are started by the shim, the shim itself is moved
+// to the cgroup for the duration of the checkpoint or restore so they
+// inherit it. The restored process is moved to the cgroup of the container
+// by runc, so it is not affected by the limits.
+type criuThrottle struct {
+	mountpoint string
+	cpuMax     string
+	ioMax      []string
+}
+
+func criuThrottleFromEnv() *criuThrottle {
+	cpuMax, ioMax := os.Getenv(EnvCRIUCPUMax), os.Getenv(EnvCRIUIOMax)
+	if cpuMax == "" && ioMax == "" {
+		return nil
+	}
+
+	t := &criuThrottle{mountpoint: cgroup2Mountpoint, cpuMax: cpuMax}
+	if ioMax != "" {
+		t.ioMax = strings.Split(ioMax, ioMaxDelim)
+	}
+	return t
+}
+
+// throttleCRIU applies the configured CRIU limits to the shim and returns a
+// func that reverts them. If no limits are configured or they can't be
+// applied, it's a noop as scaling should still work, just not throttled.
+func throttleCRIU(ctx context.Context) func() {
+	if criuLimits == nil {
+		return func() {}
+	}
+
+	if cgroups.Mode() != cgroups.Unified {
+		log.G(ctx).Warn("limiting resources of CRIU requires cgroup v2")
+		return func() {}
+	}
+
+	release, err := criuLimits.apply(os.Getpid())
+	if err != nil {
+		log.G(ctx).Errorf("unable to limit resources of CRIU: %s", err)
+		return func() {}
+	}
+
+	return func() {
+		if err := release(); err != nil {
+			log.G(ctx).Errorf("unable to move shim back to its cgroup: %s", err)
+		}
+	}
+}
+
+// apply moves the process with the supplied pid to the throttled cgroup and
+// returns a func that moves it back to its original cgroup.
+func (t *criuThrottle) apply(pid int) (func() error, error) {
+	origGroup, err := cgroupsv2.PidGroupPath(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(t.mountpoint, criuCgroupName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if t.cpuMax != "" {
+		if err := writeCgroupFile(dir, cgroupCPUMax, t.cpuMax); err != nil {
+			return nil, err
+		}
+	}
+
+	// io.max only accepts a single device per write.
+	for _, entry := range t.ioMax {
+		if err := writeCgroupFile(dir, cgroupIOMax, strings.TrimSpace(entry)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeCgroupFile(dir, cgroupProcs, strconv.Itoa(pid)); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return writeCgroupFile(filepath.Join(t.mountpoint, origGroup), cgroupProcs, strconv.Itoa(pid))
+	}, nil
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0); err != nil {
+		return fmt.Errorf("writing %q to %s: %w", value, file, err)
+	}
+	return nil
+}
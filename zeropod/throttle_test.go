@@ -0,0 +1,64 @@
+package zeropod
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	cgroupsv2 "github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRIUThrottleFromEnv(t *testing.T) {
+	t.Setenv(EnvCRIUCPUMax, "")
+	t.Setenv(EnvCRIUIOMax, "")
+	assert.Nil(t, criuThrottleFromEnv())
+
+	t.Setenv(EnvCRIUCPUMax, "50000 100000")
+	t.Setenv(EnvCRIUIOMax, "8:0 wbps=1048576;8:16 rbps=1048576")
+	throttle := criuThrottleFromEnv()
+	require.NotNil(t, throttle)
+	assert.Equal(t, "50000 100000", throttle.cpuMax)
+	assert.Equal(t, []string{"8:0 wbps=1048576", "8:16 rbps=1048576"}, throttle.ioMax)
+}
+
+func TestCRIUThrottleApply(t *testing.T) {
+	pid := os.Getpid()
+	origGroup, err := cgroupsv2.PidGroupPath(pid)
+	if err != nil {
+		t.Skipf("skipping as cgroup of test process can't be determined: %s", err)
+	}
+
+	// fake cgroup fs, the kernel would create the interface files.
+	mountpoint := t.TempDir()
+	criuDir := filepath.Join(mountpoint, criuCgroupName)
+	origDir := filepath.Join(mountpoint, origGroup)
+	require.NoError(t, os.MkdirAll(criuDir, 0o755))
+	require.NoError(t, os.MkdirAll(origDir, 0o755))
+	for _, file := range []string{cgroupProcs, cgroupCPUMax, cgroupIOMax} {
+		require.NoError(t, os.WriteFile(filepath.Join(criuDir, file), nil, 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(origDir, cgroupProcs), nil, 0o644))
+
+	throttle := &criuThrottle{
+		mountpoint: mountpoint,
+		cpuMax:     "50000 100000",
+		ioMax:      []string{"8:0 wbps=1048576"},
+	}
+	release, err := throttle.apply(pid)
+	require.NoError(t, err)
+
+	assertFile := func(path, expected string) {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+	assertFile(filepath.Join(criuDir, cgroupCPUMax), "50000 100000")
+	assertFile(filepath.Join(criuDir, cgroupIOMax), "8:0 wbps=1048576")
+	assertFile(filepath.Join(criuDir, cgroupProcs), strconv.Itoa(pid))
+
+	require.NoError(t, release())
+	assertFile(filepath.Join(origDir, cgroupProcs), strconv.Itoa(pid))
+}
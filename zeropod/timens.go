@@ -0,0 +1,73 @@
+package zeropod
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd/pkg/cri/annotations"
+	"github.com/containerd/log"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// PrepareTimeNamespace adds a time namespace to the spec in the bundle if
+// the container uses the paused timer mode. It needs to be called before the
+// container is created as the namespace can't be changed afterwards.
+func PrepareTimeNamespace(ctx context.Context, bundle string) error {
+	spec, err := GetSpec(bundle)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := NewConfig(ctx, spec)
+	if err != nil {
+		// invalid configs are reported once the container is started.
+		return nil
+	}
+
+	if cfg.ContainerType == annotations.ContainerTypeSandbox ||
+		!cfg.IsZeropodContainer() ||
+		cfg.TimerMode != TimerModePaused {
+		return nil
+	}
+
+	if !addTimeNamespace(spec) {
+		return nil
+	}
+
+	log.G(ctx).Infof("running container %s in its own time namespace", cfg.ContainerName)
+	return writeSpec(bundle, spec)
+}
+
+// addTimeNamespace adds a new time namespace to the spec. It returns false if
+// the spec already contains one.
+func addTimeNamespace(spec *specs.Spec) bool {
+	if spec.Linux == nil {
+		spec.Linux = &specs.Linux{}
+	}
+
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.TimeNamespace {
+			return false
+		}
+	}
+
+	spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.TimeNamespace})
+	return true
+}
+
+func writeSpec(bundle string, spec *specs.Spec) error {
+	configPath := filepath.Join(bundle, "config.json")
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(configPath, b, info.Mode().Perm())
+}
@@ -0,0 +1,88 @@
+package zeropod
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/pkg/cri/annotations"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareTimeNamespace(t *testing.T) {
+	tests := map[string]struct {
+		annotations  map[string]string
+		expectTimeNS bool
+	}{
+		"paused": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "app",
+				TimerModeAnnotationKey:     string(TimerModePaused),
+			},
+			expectTimeNS: true,
+		},
+		"wall clock": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation: "app",
+			},
+			expectTimeNS: false,
+		},
+		"sandbox": {
+			annotations: map[string]string{
+				CRIContainerTypeAnnotation: annotations.ContainerTypeSandbox,
+				TimerModeAnnotationKey:     string(TimerModePaused),
+			},
+			expectTimeNS: false,
+		},
+		"other container": {
+			annotations: map[string]string{
+				CRIContainerNameAnnotation:  "sidecar",
+				ContainerNamesAnnotationKey: "app",
+				TimerModeAnnotationKey:      string(TimerModePaused),
+			},
+			expectTimeNS: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bundle := t.TempDir()
+			spec := &specs.Spec{
+				Annotations: tc.annotations,
+				Linux: &specs.Linux{Namespaces: []specs.LinuxNamespace{
+					{Type: specs.PIDNamespace},
+				}},
+			}
+			b, err := json.Marshal(spec)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(bundle, "config.json"), b, 0o600))
+
+			require.NoError(t, PrepareTimeNamespace(context.Background(), bundle))
+			// preparing it again must not add a second namespace
+			require.NoError(t, PrepareTimeNamespace(context.Background(), bundle))
+
+			spec, err = GetSpec(bundle)
+			require.NoError(t, err)
+			timeNamespaces := 0
+			for _, ns := range spec.Linux.Namespaces {
+				if ns.Type == specs.TimeNamespace {
+					timeNamespaces++
+				}
+			}
+			if tc.expectTimeNS {
+				assert.Equal(t, 1, timeNamespaces)
+			} else {
+				assert.Zero(t, timeNamespaces)
+			}
+			assert.Equal(t, tc.annotations, spec.Annotations)
+
+			info, err := os.Stat(filepath.Join(bundle, "config.json"))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package zeropod
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/log"
+	"github.com/ctrox/zeropod/socket"
+)
+
+const (
+	cpuStatFile  = "cpu.stat"
+	cpuUsageStat = "usage_usec"
+	// cpuIdleThreshold is the fraction of a single CPU a process can use
+	// while still being considered idle.
+	cpuIdleThreshold = 0.01
+	// connectionCheckInterval is the default interval in which the
+	// connections are checked again if the process still had established
+	// connections once the scale down duration was up.
+	connectionCheckInterval = 10 * time.Second
+)
+
+// scaleDownTrigger decides when a container is scaled down. The scale down
+// is scheduled after the scale down duration and once it's due, the trigger
+// decides if the container is scaled down or if it should be delayed.
+type scaleDownTrigger interface {
+	// reset is called whenever a new scale down is scheduled.
+	reset()
+	// delay returns the duration by which the scale down should be delayed.
+	// If it's zero, the container is scaled down.
+	delay(ctx context.Context) time.Duration
+}
+
+func newScaleDownTrigger(c *Container) scaleDownTrigger {
+	switch c.cfg.ScaleDownTrigger {
+	case ScaleDownTriggerConnectionIdle:
+		return &connectionIdleTrigger{
+			interval: c.activityCheckInterval(),
+			connections: func() (int, error) {
+				if c.cfg.ActivatorMetering {
+					activity, err := c.meteredActivity()
+					return int(activity.Connections), err
+				}
+				return establishedConnections(c.process.Pid())
+			},
+		}
+	case ScaleDownTriggerCPUIdle:
+		return newCPUIdleTrigger(c.cfg.ScaleDownDuration, func() (time.Duration, error) {
+			return cpuUsage(c.process.Pid())
+		})
+	case ScaleDownTriggerSchedule:
+		return scheduleTrigger{}
+	default:
+		return &idleTimerTrigger{
+			duration: c.cfg.ScaleDownDuration,
+			lastActivity: func() (time.Time, error) {
+				if c.cfg.ActivatorMetering {
+					activity, err := c.meteredActivity()
+					return activity.LastActivity, err
+				}
+				return c.tracker.LastActivity(uint32(c.process.Pid()))
+			},
+		}
+	}
+}
+
+// activityCheckInterval returns the interval in which the activity of a
+// container that is not idle yet is checked again.
+func (c *Container) activityCheckInterval() time.Duration {
+	if c.cfg.ActivityCheckInterval > 0 {
+		return c.cfg.ActivityCheckInterval
+	}
+	return min(c.cfg.ScaleDownDuration, connectionCheckInterval)
+}
+
+// idleTimerTrigger scales down once there has been no network activity for
+// the scale down duration.
+type idleTimerTrigger struct {
+	duration     time.Duration
+	lastActivity func() (time.Time, error)
+}
+
+func (t *idleTimerTrigger) reset() {}
+
+func (t *idleTimerTrigger) delay(ctx context.Context) time.Duration {
+	last, err := t.lastActivity()
+	if errors.Is(err, socket.NoActivityRecordedErr{}) {
+		log.G(ctx).Info(err)
+		return 0
+	}
+	if err != nil {
+		log.G(ctx).Errorf("unable to get last TCP activity from tracker: %s", err)
+		return 0
+	}
+
+	log.G(ctx).Infof("last activity was %s ago", time.Since(last))
+	// we want to delay the scaledown by the scale down duration after the
+	// last activity. If that's in the past, there is no need to delay.
+	return max(t.duration-time.Since(last), 0)
+}
+
+// connectionIdleTrigger scales down once the process does not have any
+// established connections. Long-lived connections without any traffic (e.g.
+// websockets) keep the container running.
+type connectionIdleTrigger struct {
+	interval    time.Duration
+	connections func() (int, error)
+}
+
+func (t *connectionIdleTrigger) reset() {}
+
+func (t *connectionIdleTrigger) delay(ctx context.Context) time.Duration {
+	connections, err := t.connections()
+	if err != nil {
+		log.G(ctx).Errorf("unable to get established connections: %s", err)
+		return 0
+	}
+
+	if connections > 0 {
+		log.G(ctx).Infof("process has %d established connections", connections)
+		return t.interval
+	}
+
+	return 0
+}
+
+// cpuIdleTrigger scales down once the CPU usage of the container has been
+// below the cpuIdleThreshold for the scale down duration. This allows
+// scaling down containers that don't serve any requests but e.g. poll in the
+// background only when they are not doing any work.
+type cpuIdleTrigger struct {
+	duration time.Duration
+	usage    *rateMeter
+}
+
+func newCPUIdleTrigger(duration time.Duration, usage func() (time.Duration, error)) *cpuIdleTrigger {
+	return &cpuIdleTrigger{
+		duration: duration,
+		usage: newRateMeter(func() (float64, error) {
+			usage, err := usage()
+			return usage.Seconds(), err
+		}),
+	}
+}
+
+func (t *cpuIdleTrigger) reset() {
+	t.usage.reset()
+}
+
+func (t *cpuIdleTrigger) delay(ctx context.Context) time.Duration {
+	ratio, ok, err := t.usage.rate()
+	if err != nil {
+		log.G(ctx).Errorf("unable to get CPU usage: %s", err)
+		return 0
+	}
+
+	if !ok {
+		// the usage could not be read when the scale down was scheduled,
+		// the measurement starts now.
+		return t.duration
+	}
+
+	log.G(ctx).Infof("CPU usage was %.2f%% since the last check", ratio*100)
+	if ratio > cpuIdleThreshold {
+		t.usage.reset()
+		return t.duration
+	}
+
+	return 0
+}
+
+// scheduleTrigger scales down as soon as the scale down duration is up,
+// regardless of any activity.
+type scheduleTrigger struct{}
+
+func (scheduleTrigger) reset() {}
+
+func (scheduleTrigger) delay(context.Context) time.Duration {
+	return 0
+}
+
+// cpuUsage returns the total CPU time used by the cgroup of the supplied pid.
+func cpuUsage(pid int) (time.Duration, error) {
+	cgroupPath, err := cgroupV2Path(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return readCPUUsage(filepath.Join(cgroupPath, cpuStatFile))
+}
+
+func readCPUUsage(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || key != cpuUsageStat {
+			continue
+		}
+
+		usec, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(usec) * time.Microsecond, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("%s not found in %s", cpuUsageStat, path)
+}
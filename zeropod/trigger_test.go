@@ -0,0 +1,151 @@
+package zeropod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctrox/zeropod/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaleDownTriggers(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		trigger       scaleDownTrigger
+		expectedDelay func(t *testing.T, delay time.Duration)
+	}{
+		"idle timer without activity": {
+			trigger: &idleTimerTrigger{
+				duration: time.Minute,
+				lastActivity: func() (time.Time, error) {
+					return time.Time{}, socket.NoActivityRecordedErr{}
+				},
+			},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Zero(t, delay)
+			},
+		},
+		"idle timer with recent activity": {
+			trigger: &idleTimerTrigger{
+				duration: time.Minute,
+				lastActivity: func() (time.Time, error) {
+					return time.Now().Add(-time.Second * 20), nil
+				},
+			},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.InDelta(t, time.Second*40, delay, float64(time.Second))
+			},
+		},
+		"idle timer with old activity": {
+			trigger: &idleTimerTrigger{
+				duration: time.Minute,
+				lastActivity: func() (time.Time, error) {
+					return time.Now().Add(-time.Hour), nil
+				},
+			},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Zero(t, delay)
+			},
+		},
+		"connection idle with connections": {
+			trigger: &connectionIdleTrigger{
+				interval:    time.Second * 10,
+				connections: func() (int, error) { return 2, nil },
+			},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Equal(t, time.Second*10, delay)
+			},
+		},
+		"connection idle without connections": {
+			trigger: &connectionIdleTrigger{
+				interval:    time.Second * 10,
+				connections: func() (int, error) { return 0, nil },
+			},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Zero(t, delay)
+			},
+		},
+		"connection idle with error": {
+			trigger: &connectionIdleTrigger{
+				interval:    time.Second * 10,
+				connections: func() (int, error) { return 0, errors.New("boom") },
+			},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Zero(t, delay)
+			},
+		},
+		"cpu idle while busy": {
+			trigger: newCPUIdleTrigger(time.Minute, cpuUsageGrowing(time.Second)),
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Equal(t, time.Minute, delay)
+			},
+		},
+		"cpu idle while idle": {
+			trigger: newCPUIdleTrigger(time.Minute, cpuUsageGrowing(0)),
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Zero(t, delay)
+			},
+		},
+		"schedule": {
+			trigger: scheduleTrigger{},
+			expectedDelay: func(t *testing.T, delay time.Duration) {
+				assert.Zero(t, delay)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			tc.trigger.reset()
+			time.Sleep(time.Millisecond * 10)
+			tc.expectedDelay(t, tc.trigger.delay(ctx))
+		})
+	}
+}
+
+// cpuUsageGrowing returns a usage func that reports an increase of the
+// usage by step on every call.
+func cpuUsageGrowing(step time.Duration) func() (time.Duration, error) {
+	usage := time.Duration(0)
+	return func() (time.Duration, error) {
+		usage += step
+		return usage, nil
+	}
+}
+
+func TestReadCPUUsage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), cpuStatFile)
+	require.NoError(t, os.WriteFile(file, []byte("usage_usec 1500\nuser_usec 1000\nsystem_usec 500\n"), 0o644))
+
+	usage, err := readCPUUsage(file)
+	require.NoError(t, err)
+	assert.Equal(t, time.Microsecond*1500, usage)
+
+	require.NoError(t, os.WriteFile(file, []byte("user_usec 1000\n"), 0o644))
+	_, err = readCPUUsage(file)
+	assert.Error(t, err)
+}
+
+func TestActivityCheckInterval(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg      *Config
+		expected time.Duration
+	}{
+		"default":                {cfg: &Config{ScaleDownDuration: time.Minute}, expected: connectionCheckInterval},
+		"short scale down":       {cfg: &Config{ScaleDownDuration: time.Second}, expected: time.Second},
+		"configured":             {cfg: &Config{ScaleDownDuration: time.Minute, ActivityCheckInterval: time.Second * 30}, expected: time.Second * 30},
+		"longer than scale down": {cfg: &Config{ScaleDownDuration: time.Second, ActivityCheckInterval: time.Minute}, expected: time.Minute},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &Container{cfg: tc.cfg}
+			assert.Equal(t, tc.expected, c.activityCheckInterval())
+		})
+	}
+}